@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/turnerem/zenzen/service"
+)
+
+// CacheStatsResponse reports how the API's read cache is doing
+type CacheStatsResponse struct {
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Invalidations uint64  `json:"invalidations"`
+	Evictions     uint64  `json:"evictions"`
+	Entries       int     `json:"entries"`
+}
+
+// handleCacheStats handles GET /api/v1/cache
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	cache, ok := s.store.(*service.CachedStore)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Cache not enabled", "set cache.enabled in config.yaml")
+		return
+	}
+
+	stats := cache.Stats()
+	writeJSON(w, http.StatusOK, CacheStatsResponse{
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		HitRatio:      stats.HitRatio(),
+		Invalidations: stats.Invalidations,
+		Evictions:     stats.Evictions,
+		Entries:       stats.Entries,
+	})
+}
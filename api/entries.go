@@ -0,0 +1,291 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// errInvalidEntry is returned for entry requests that fail validation
+var errInvalidEntry = errors.New("invalid entry")
+
+// EntryRequest is the body of POST, PUT and PATCH /api/v1/entries. Timestamps are RFC 3339
+// and estimated_duration uses the TUI's syntax, such as 1h30m or 2d; an empty string clears
+// a field. POST and PUT replace the whole entry, PATCH only the fields present.
+type EntryRequest struct {
+	Title             *string   `json:"title"`
+	Tags              *[]string `json:"tags"`
+	StartedAt         *string   `json:"started_at"`
+	EndedAt           *string   `json:"ended_at"`
+	EstimatedDuration *string   `json:"estimated_duration"`
+	Body              *string   `json:"body"`
+}
+
+// handleCreateEntry handles POST /api/v1/entries. The server picks the ID, and the entry
+// starts now unless started_at says otherwise.
+func (s *Server) handleCreateEntry(w http.ResponseWriter, r *http.Request) {
+	var req EntryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	entry := core.Entry{ID: newEntryID(), Tags: []string{}, StartedAtTimestamp: time.Now().UTC()}
+	if err := req.apply(&entry); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid entry", err.Error())
+		return
+	}
+
+	saved, err := service.SaveEntry(r.Context(), s.store, entry)
+	if err != nil {
+		writeStoreError(w, "Failed to create entry", err)
+		return
+	}
+	s.publishEntry(r, service.StreamEntryCreated, saved.ID, saved.Version)
+
+	w.Header().Set("Location", "/api/v1/entries/"+saved.ID)
+	writeEntry(w, http.StatusCreated, saved)
+}
+
+// handleReplaceEntry handles PUT /api/v1/entries/{id}, creating the entry if it doesn't exist.
+// With If-Match it only replaces the version the client last read.
+func (s *Server) handleReplaceEntry(w http.ResponseWriter, r *http.Request) {
+	var req EntryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	entry := core.Entry{ID: chi.URLParam(r, "id"), Tags: []string{}, Version: version}
+	if err := req.apply(&entry); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid entry", err.Error())
+		return
+	}
+
+	_, exists, err := s.findEntry(r, entry.ID)
+	if err != nil {
+		writeStoreError(w, "Failed to replace entry", err)
+		return
+	}
+
+	saved, err := service.SaveEntry(r.Context(), s.store, entry)
+	if err != nil {
+		writeStoreError(w, "Failed to replace entry", err)
+		return
+	}
+
+	status, event := http.StatusOK, service.StreamEntryUpdated
+	if !exists {
+		status, event = http.StatusCreated, service.StreamEntryCreated
+	}
+	s.publishEntry(r, event, saved.ID, saved.Version)
+	writeEntry(w, status, saved)
+}
+
+// handlePatchEntry handles PATCH /api/v1/entries/{id}, changing only the fields sent.
+// Without If-Match an entry changed between reading and saving is a 409 the client can retry.
+func (s *Server) handlePatchEntry(w http.ResponseWriter, r *http.Request) {
+	var req EntryRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	entry, exists, err := s.findEntry(r, chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, "Failed to update entry", err)
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "Entry not found", "")
+		return
+	}
+
+	if version > 0 {
+		entry.Version = version
+	}
+	if err := req.apply(&entry); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid entry", err.Error())
+		return
+	}
+
+	saved, err := service.SaveEntry(r.Context(), s.store, entry)
+	if version == 0 && errors.Is(err, service.ErrConflict) {
+		writeError(w, http.StatusConflict, "Failed to update entry", err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, "Failed to update entry", err)
+		return
+	}
+	s.publishEntry(r, service.StreamEntryUpdated, saved.ID, saved.Version)
+
+	writeEntry(w, http.StatusOK, saved)
+}
+
+// handleDeleteEntry handles DELETE /api/v1/entries/{id}.
+// With If-Match it only deletes the version the client last read, checked by the store as it
+// deletes, and answers 412 if the entry has moved on or is gone. Without it the entry is read
+// first and that version deleted, so the event names the version removed.
+func (s *Server) handleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	matched := version > 0
+	if !matched {
+		entry, exists, err := s.findEntry(r, id)
+		if err != nil {
+			writeStoreError(w, "Failed to delete entry", err)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "Entry not found", "")
+			return
+		}
+		version = entry.Version
+	}
+
+	err := service.DeleteEntryVersion(r.Context(), s.store, id, version)
+	if !matched && errors.Is(err, service.ErrConflict) {
+		writeError(w, http.StatusConflict, "Failed to delete entry", err.Error())
+		return
+	}
+	if err != nil {
+		writeStoreError(w, "Failed to delete entry", err)
+		return
+	}
+	s.publishEntry(r, service.StreamEntryDeleted, id, version)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findEntry looks up one entry, reporting whether it exists
+func (s *Server) findEntry(r *http.Request, id string) (core.Entry, bool, error) {
+	result, err := s.store.Query(r.Context(), service.Query{IDs: []string{id}, Limit: 1})
+	if err != nil || len(result.Entries) == 0 {
+		return core.Entry{}, false, err
+	}
+	return result.Entries[0], true, nil
+}
+
+// apply copies the fields present in the request onto entry, then checks the result
+func (req EntryRequest) apply(entry *core.Entry) error {
+	if req.Title != nil {
+		entry.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Tags != nil {
+		entry.Tags = []string{}
+		for _, tag := range *req.Tags {
+			if err := (service.Tag{Name: tag}).Validate(); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidEntry, err)
+			}
+			entry.Tags = append(entry.Tags, tag)
+		}
+	}
+	if req.StartedAt != nil {
+		started, err := parseEntryTime("started_at", *req.StartedAt)
+		if err != nil {
+			return err
+		}
+		entry.StartedAtTimestamp = started
+	}
+	if req.EndedAt != nil {
+		ended, err := parseEntryTime("ended_at", *req.EndedAt)
+		if err != nil {
+			return err
+		}
+		entry.EndedAtTimestamp = ended
+	}
+	if req.EstimatedDuration != nil {
+		if err := core.ValidateDuration(*req.EstimatedDuration); err != nil {
+			return fmt.Errorf("%w: estimated_duration: %v", errInvalidEntry, err)
+		}
+		entry.EstimatedDuration = core.ParseDuration(*req.EstimatedDuration)
+	}
+	if req.Body != nil {
+		entry.Body = *req.Body
+	}
+
+	if entry.Title == "" {
+		return fmt.Errorf("%w: title is required", errInvalidEntry)
+	}
+	if !entry.EndedAtTimestamp.IsZero() {
+		if entry.StartedAtTimestamp.IsZero() {
+			return fmt.Errorf("%w: ended_at needs a started_at", errInvalidEntry)
+		}
+		if entry.EndedAtTimestamp.Before(entry.StartedAtTimestamp) {
+			return fmt.Errorf("%w: ended_at is before started_at", errInvalidEntry)
+		}
+	}
+	return nil
+}
+
+// parseEntryTime parses an RFC 3339 timestamp; blank is the zero time
+func parseEntryTime(field, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be RFC 3339, like 2025-01-31T09:00:00Z", errInvalidEntry, field)
+	}
+	return t, nil
+}
+
+// ifMatchVersion returns the version an If-Match header asks for, 0 if there is none.
+// A header that isn't one of our ETags can never match, so it is answered with a 412.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version < 1 || entryETag(version) != header {
+		writeError(w, http.StatusPreconditionFailed, "Precondition failed", fmt.Sprintf("If-Match %s is not an entry ETag", header))
+		return 0, false
+	}
+	return version, true
+}
+
+// writeEntry answers with an entry and its ETag
+func writeEntry(w http.ResponseWriter, status int, entry core.Entry) {
+	w.Header().Set("ETag", entryETag(entry.Version))
+	writeJSON(w, status, toEntryResponse(entry))
+}
+
+var (
+	entryIDMu   sync.Mutex
+	lastEntryID int64
+)
+
+// newEntryID returns a new entry ID in the TUI's format, the current time in nanoseconds,
+// bumped past the last one so concurrent requests never share an ID
+func newEntryID() string {
+	entryIDMu.Lock()
+	defer entryIDMu.Unlock()
+
+	id := time.Now().UnixNano()
+	if id <= lastEntryID {
+		id = lastEntryID + 1
+	}
+	lastEntryID = id
+	return strconv.FormatInt(id, 10)
+}
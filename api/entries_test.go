@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// editAfterRead makes a concurrent edit to an entry right after the handler reads it
+type editAfterRead struct {
+	*service.MemoryStore
+	edit core.Entry
+}
+
+func (s *editAfterRead) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	result, err := s.MemoryStore.Query(ctx, q)
+	if err != nil {
+		return result, err
+	}
+	return result, s.MemoryStore.SaveEntry(ctx, s.edit)
+}
+
+func TestCreateEntry(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+
+	rec := serve(t, s, http.MethodPost, "/api/v1/entries", `{"title": " Deploy ", "tags": ["infra/k8s"], "estimated_duration": "1h30m"}`)
+	var created EntryResponse
+	decode(t, rec, http.StatusCreated, &created)
+
+	if created.Title != "Deploy" || created.EstimatedDuration == "" || !created.InProgress || created.Version != 1 {
+		t.Errorf("created = %+v; want the trimmed title, estimate, running and version 1", created)
+	}
+	if got, want := rec.Header().Get("Location"), "/api/v1/entries/"+created.ID; got != want {
+		t.Errorf("Location = %q; want %q", got, want)
+	}
+	if got := rec.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %q; want %q", got, `"1"`)
+	}
+
+	// The Location answers with the same entry
+	var fetched EntryResponse
+	decode(t, serve(t, s, http.MethodGet, rec.Header().Get("Location"), ""), http.StatusOK, &fetched)
+	if fetched.ID != created.ID {
+		t.Errorf("GET Location = %+v; want entry %s", fetched, created.ID)
+	}
+}
+
+func TestCreateEntryValidation(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+
+	for name, body := range map[string]string{
+		"missing title":    `{"tags": ["go"]}`,
+		"blank title":      `{"title": "   "}`,
+		"bad tag":          `{"title": "Deploy", "tags": [""]}`,
+		"bad timestamp":    `{"title": "Deploy", "started_at": "yesterday"}`,
+		"bad estimate":     `{"title": "Deploy", "estimated_duration": "soon"}`,
+		"ended too early":  `{"title": "Deploy", "started_at": "2025-01-31T10:00:00Z", "ended_at": "2025-01-31T09:00:00Z"}`,
+		"unknown field":    `{"title": "Deploy", "owner": "bob"}`,
+		"malformed json":   `{"title": `,
+		"ended, unstarted": `{"title": "Deploy", "started_at": "", "ended_at": "2025-01-31T09:00:00Z"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			decode(t, serve(t, s, http.MethodPost, "/api/v1/entries", body), http.StatusBadRequest, nil)
+		})
+	}
+
+	entries, _ := s.store.GetAll(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("invalid requests stored %d entries", len(entries))
+	}
+}
+
+func TestReplaceEntry(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+
+	// PUT creates an entry that doesn't exist, then replaces it
+	rec := serve(t, s, http.MethodPut, "/api/v1/entries/42", `{"title": "Deploy", "tags": ["infra"]}`)
+	var entry EntryResponse
+	decode(t, rec, http.StatusCreated, &entry)
+	if entry.ID != "42" || entry.Version != 1 {
+		t.Errorf("created = %+v; want entry 42 at version 1", entry)
+	}
+
+	rec = serve(t, s, http.MethodPut, "/api/v1/entries/42", `{"title": "Deploy v2"}`, "If-Match", `"1"`)
+	decode(t, rec, http.StatusOK, &entry)
+	if entry.Title != "Deploy v2" || len(entry.Tags) != 0 || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("replaced = %+v, ETag %s; want the whole entry replaced at version 2", entry, rec.Header().Get("ETag"))
+	}
+
+	// The version read before the replace no longer matches
+	decode(t, serve(t, s, http.MethodPut, "/api/v1/entries/42", `{"title": "Stale"}`, "If-Match", `"1"`), http.StatusPreconditionFailed, nil)
+	decode(t, serve(t, s, http.MethodPut, "/api/v1/entries/42", `{"title": "Stale"}`, "If-Match", `W/"2"`), http.StatusPreconditionFailed, nil)
+}
+
+func TestPatchEntry(t *testing.T) {
+	store := service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Tags: []string{"infra"}, Body: "notes", Version: 1})
+	s := newTestServer(t, store)
+
+	var entry EntryResponse
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/1", `{"title": "Deploy v2"}`), http.StatusOK, &entry)
+	if entry.Title != "Deploy v2" || entry.Body != "notes" || len(entry.Tags) != 1 || entry.Version != 2 {
+		t.Errorf("patched = %+v; want only the title changed", entry)
+	}
+
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/1", `{"title": "Stale"}`, "If-Match", `"1"`), http.StatusPreconditionFailed, nil)
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/1", `{"title": ""}`), http.StatusBadRequest, nil)
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/missing", `{"title": "New"}`), http.StatusNotFound, nil)
+}
+
+func TestPatchEntryConflict(t *testing.T) {
+	store := &editAfterRead{
+		MemoryStore: service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}),
+		edit:        core.Entry{ID: "1", Title: "Edited in the TUI"},
+	}
+	s := newTestServer(t, store)
+
+	// Without If-Match, an edit between the read and the save is a 409 to retry
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/1", `{"body": "more"}`), http.StatusConflict, nil)
+
+	entries, _ := store.MemoryStore.GetAll(context.Background())
+	if entries["1"].Title != "Edited in the TUI" {
+		t.Errorf("entry = %+v; want the concurrent edit kept", entries["1"])
+	}
+}
+
+func TestDeleteEntry(t *testing.T) {
+	store := service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}, core.Entry{ID: "2", Title: "Review", Version: 1})
+	s := newTestServer(t, store)
+
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", `"2"`), http.StatusPreconditionFailed, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", "soon"), http.StatusPreconditionFailed, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", `"1"`), http.StatusNoContent, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", `"1"`), http.StatusPreconditionFailed, nil)
+
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/2", ""), http.StatusNoContent, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/2", ""), http.StatusNotFound, nil)
+
+	entries, _ := store.GetAll(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("entries left = %v; want none", entries)
+	}
+}
+
+func TestDeleteEntryChecksVersionAsItDeletes(t *testing.T) {
+	store := &editBeforeDelete{
+		MemoryStore: service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}),
+		edit:        core.Entry{ID: "1", Title: "Edited in the TUI"},
+	}
+	s := newTestServer(t, store)
+
+	// Version 1 was current when the request arrived, but not when the delete ran
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", `"1"`), http.StatusPreconditionFailed, nil)
+	// Without If-Match the version read just before the delete is checked, and the race is a conflict
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", ""), http.StatusConflict, nil)
+
+	entries, _ := store.GetAll(context.Background())
+	if entries["1"].Title != "Edited in the TUI" {
+		t.Errorf("entry = %+v; want the concurrent edit kept", entries["1"])
+	}
+}
+
+// editBeforeDelete makes a concurrent edit to an entry just before the store deletes
+type editBeforeDelete struct {
+	*service.MemoryStore
+	edit core.Entry
+}
+
+func (s *editBeforeDelete) DeleteEntry(ctx context.Context, id string) error {
+	s.MemoryStore.SaveEntry(ctx, s.edit)
+	return s.MemoryStore.DeleteEntry(ctx, id)
+}
+
+func (s *editBeforeDelete) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	s.MemoryStore.SaveEntry(ctx, s.edit)
+	return s.MemoryStore.DeleteEntryVersion(ctx, id, version)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/logger"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	// eventLogSize is how many recent changes, of every owner, a stream can resume from
+	eventLogSize = 1000
+
+	// defaultHeartbeat is how often an idle stream sends a comment to keep proxies from closing it
+	defaultHeartbeat = 15 * time.Second
+
+	// streamRetry is how long browsers wait before reconnecting a dropped stream
+	streamRetry = 3 * time.Second
+)
+
+// StreamEventResponse is the data of one event on GET /api/v1/events
+type StreamEventResponse struct {
+	EntryID string `json:"entry_id,omitempty"`
+	Version int64  `json:"version,omitempty"` // Entry events
+	State   string `json:"state,omitempty"`   // Timer events: running, paused or stopped
+	At      string `json:"at"`
+}
+
+// FollowChanges feeds the event stream from the store's change feed until ctx is cancelled,
+// so it carries writes made by every process. Call it before serving. Without a feed, the
+// stream carries the entry and timer changes made through this server.
+func (s *Server) FollowChanges(ctx context.Context) error {
+	feed, ok := s.store.(service.ChangeFeed)
+	if !ok {
+		return service.ErrChangeFeedUnsupported
+	}
+	if err := s.events.Follow(ctx, feed); err != nil {
+		return err
+	}
+	s.following = true
+	return nil
+}
+
+// handleEvents handles GET /api/v1/events, streaming the caller's entry and timer changes
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.streamEvents(w, r)
+}
+
+// handleWorkspaceEvents handles GET /api/v1/workspaces/{workspace}/events; membership is
+// checked when the stream opens
+func (s *Server) handleWorkspaceEvents(w http.ResponseWriter, r *http.Request) {
+	workspaces, ok := s.workspaceStore(w, "Failed to stream events")
+	if !ok {
+		return
+	}
+
+	ctx, _, err := service.OpenWorkspace(r.Context(), workspaces, workspaceParam(r), service.RoleViewer)
+	if err != nil {
+		writeStoreError(w, "Failed to stream events", err)
+		return
+	}
+
+	s.streamEvents(w, r.WithContext(ctx))
+}
+
+// streamEvents writes the context owner's changes as Server-Sent Events until the client
+// goes away. A Last-Event-ID header resumes after that event; if it is unknown, or the
+// events since have been dropped from the log, the stream starts with a resync event.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := service.OwnerFromContext(ctx)
+	rc := http.NewResponseController(w)
+
+	// Waiting before reading the cursor, so nothing published in between is missed
+	wake, stop := s.events.Wait()
+	defer stop()
+
+	cursor := s.events.Cursor()
+	resync := false
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if resumed, ok := s.events.ParseEventID(last); ok {
+			cursor = resumed
+		} else {
+			resync = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stops nginx buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		logger.Error("event_stream_unsupported", "error", err.Error())
+		return
+	}
+	logger.Info("event_stream_opened", "owner", owner, "resumed", r.Header.Get("Last-Event-ID") != "")
+
+	heartbeat := s.heartbeat
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		events, next, complete := s.events.Since(owner, cursor)
+		if resync || !complete {
+			events = []service.StreamEvent{{Seq: next, Type: service.StreamResync, At: time.Now().UTC()}}
+			resync = false
+		}
+		cursor = next
+
+		for _, event := range events {
+			if err := s.writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+		if len(events) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("event_stream_closed", "owner", owner)
+			return
+		case <-wake:
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeStreamEvent writes one event in the text/event-stream format
+func (s *Server) writeStreamEvent(w http.ResponseWriter, event service.StreamEvent) error {
+	data, err := json.Marshal(StreamEventResponse{
+		EntryID: event.EntryID,
+		Version: event.Version,
+		State:   event.Timer,
+		At:      event.At.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", s.events.EventID(event.Seq), event.Type, data)
+	return err
+}
+
+// publishEntry adds a write to an entry made through this server to the event stream, unless
+// the stream follows the store's change feed, which reports it already
+func (s *Server) publishEntry(r *http.Request, eventType service.StreamEventType, id string, version int64) {
+	if s.following {
+		return
+	}
+	s.events.Publish(service.StreamEvent{
+		Type:    eventType,
+		Owner:   service.OwnerFromContext(r.Context()),
+		EntryID: id,
+		Version: version,
+	})
+}
+
+// publishEntries adds updates to entries made through this server to the event stream
+func (s *Server) publishEntries(r *http.Request, entries []core.Entry) {
+	for _, entry := range entries {
+		s.publishEntry(r, service.StreamEntryUpdated, entry.ID, entry.Version)
+	}
+}
+
+// publishTimer adds a change to the caller's timer to the event stream
+func (s *Server) publishTimer(r *http.Request, entryID, state string) {
+	s.events.Publish(service.StreamEvent{
+		Type:    service.StreamTimer,
+		Owner:   service.OwnerFromContext(r.Context()),
+		EntryID: entryID,
+		Timer:   state,
+	})
+}
+
+// skipStreams applies timeout to every request except event streams, which stay open
+func skipStreams(timeout func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		timed := timeout(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/events") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timed.ServeHTTP(w, r)
+		})
+	}
+}
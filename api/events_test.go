@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// streamFrame is one blank-line separated block of an event stream
+type streamFrame struct {
+	id, event, data, comment string
+}
+
+// openStream opens GET /api/v1/events on a live server. Headers are given as name, value pairs.
+func openStream(t *testing.T, s *Server, headers ...string) *bufio.Reader {
+	t.Helper()
+
+	ts := httptest.NewServer(s)
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/events", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("X-API-Key", testAPIKey)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		resp.Body.Close()
+		ts.Close()
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	if frame := readFrame(t, stream); frame.event != "" {
+		t.Fatalf("first frame = %+v; want the retry interval", frame)
+	}
+	return stream
+}
+
+// readFrame reads the next frame, failing the test if none arrives within a second
+func readFrame(t *testing.T, stream *bufio.Reader) streamFrame {
+	t.Helper()
+
+	frames := make(chan streamFrame, 1)
+	go func() {
+		var frame streamFrame
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				close(frames)
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				frames <- frame
+				return
+			}
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				frame.id = value
+			case "event":
+				frame.event = value
+			case "data":
+				frame.data = value
+			case "":
+				frame.comment = value
+			}
+		}
+	}()
+
+	select {
+	case frame, ok := <-frames:
+		if !ok {
+			t.Fatalf("stream ended")
+		}
+		return frame
+	case <-time.After(time.Second):
+		t.Fatalf("no frame within a second")
+		return streamFrame{}
+	}
+}
+
+// readEvent reads the next event, skipping heartbeats
+func readEvent(t *testing.T, stream *bufio.Reader) (streamFrame, StreamEventResponse) {
+	t.Helper()
+
+	for {
+		frame := readFrame(t, stream)
+		if frame.event == "" {
+			continue
+		}
+		var data StreamEventResponse
+		if err := json.Unmarshal([]byte(frame.data), &data); err != nil {
+			t.Fatalf("failed to decode %q: %v", frame.data, err)
+		}
+		return frame, data
+	}
+}
+
+func TestEventsWithoutChangeFeed(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}, core.Entry{ID: "2", Title: "Ship", Version: 3}))
+	if err := s.FollowChanges(context.Background()); err == nil {
+		t.Fatalf("FollowChanges() error = nil; want the memory store to have no feed")
+	}
+	stream := openStream(t, s)
+
+	// Writes through the API are streamed in the feed's place
+	var created EntryResponse
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries", `{"title": "Review"}`), http.StatusCreated, &created)
+	decode(t, serve(t, s, http.MethodPatch, "/api/v1/entries/1", `{"title": "Deploy v2"}`), http.StatusOK, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/1", "", "If-Match", `"2"`), http.StatusNoContent, nil)
+	// Deleting nothing sends nothing; deleting without If-Match names the version deleted
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/missing", ""), http.StatusNotFound, nil)
+	decode(t, serve(t, s, http.MethodDelete, "/api/v1/entries/2", ""), http.StatusNoContent, nil)
+
+	for _, want := range []StreamEventResponse{
+		{EntryID: created.ID, Version: 1},
+		{EntryID: "1", Version: 2},
+		{EntryID: "1", Version: 2},
+		{EntryID: "2", Version: 3},
+	} {
+		frame, got := readEvent(t, stream)
+		if got.EntryID != want.EntryID || got.Version != want.Version {
+			t.Errorf("%s event = %+v; want entry %s at version %d", frame.event, got, want.EntryID, want.Version)
+		}
+	}
+
+	// Starting a timer reports the entry and the timer
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/"+created.ID+"/start", ""), http.StatusOK, nil)
+	if frame, got := readEvent(t, stream); frame.event != "updated" || got.EntryID != created.ID {
+		t.Errorf("event = %s %+v; want entry %s updated", frame.event, got, created.ID)
+	}
+	if frame, got := readEvent(t, stream); frame.event != "timer" || got.State != "running" {
+		t.Errorf("event = %s %+v; want the timer running", frame.event, got)
+	}
+}
+
+func TestEventsResume(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+	for _, id := range []string{"1", "2", "3"} {
+		s.events.Publish(service.StreamEvent{Type: service.StreamEntryCreated, Owner: service.DefaultOwner, EntryID: id, Version: 1})
+	}
+	s.events.Publish(service.StreamEvent{Type: service.StreamEntryCreated, Owner: "bob", EntryID: "4", Version: 1})
+
+	// Resuming after the first event replays the rest of the caller's own
+	stream := openStream(t, s, "Last-Event-ID", s.events.EventID(1))
+	for _, want := range []string{"2", "3"} {
+		frame, got := readEvent(t, stream)
+		if frame.event != "created" || got.EntryID != want {
+			t.Errorf("event = %s %+v; want entry %s created", frame.event, got, want)
+		}
+	}
+
+	s.events.Publish(service.StreamEvent{Type: service.StreamEntryDeleted, Owner: service.DefaultOwner, EntryID: "2", Version: 1})
+	frame, got := readEvent(t, stream)
+	if frame.event != "deleted" || got.EntryID != "2" || frame.id != s.events.EventID(5) {
+		t.Errorf("event = %+v %+v; want entry 2 deleted with ID %s", frame, got, s.events.EventID(5))
+	}
+}
+
+func TestEventsResync(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+	s.events.Publish(service.StreamEvent{Type: service.StreamEntryCreated, Owner: service.DefaultOwner, EntryID: "1", Version: 1})
+
+	// An ID from before a restart can't be resumed from
+	for _, last := range []string{"stale-1", s.events.EventID(99), "garbage"} {
+		stream := openStream(t, s, "Last-Event-ID", last)
+		if frame, _ := readEvent(t, stream); frame.event != "resync" {
+			t.Errorf("first event after Last-Event-ID %s = %+v; want resync", last, frame)
+		}
+	}
+
+	// So can't one whose events have been dropped from the log
+	full := NewServer(service.NewMemoryStore(), testAPIKey)
+	full.events = service.NewEventLog(2)
+	for _, id := range []string{"1", "2", "3"} {
+		full.events.Publish(service.StreamEvent{Type: service.StreamEntryCreated, Owner: service.DefaultOwner, EntryID: id})
+	}
+	stream := openStream(t, full, "Last-Event-ID", full.events.EventID(0))
+	if frame, _ := readEvent(t, stream); frame.event != "resync" {
+		t.Errorf("first event after dropped events = %+v; want resync", frame)
+	}
+}
+
+func TestEventsHeartbeat(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+	s.heartbeat = 10 * time.Millisecond
+
+	stream := openStream(t, s)
+	if frame := readFrame(t, stream); frame.comment != "heartbeat" {
+		t.Errorf("idle frame = %+v; want a heartbeat", frame)
+	}
+}
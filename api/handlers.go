@@ -2,13 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
 )
 
 // EntryResponse represents an entry in API responses
@@ -24,12 +32,28 @@ type EntryResponse struct {
 	Body                  string        `json:"body"`
 	InProgress            bool          `json:"in_progress"`
 	EstimationBias        string        `json:"estimation_bias,omitempty"`
+	Version               int64         `json:"version"`
 }
 
-// EntriesResponse represents a list of entries
+// EntriesResponse represents a page of entries. Total counts the matches on every page.
 type EntriesResponse struct {
-	Entries []EntryResponse `json:"entries"`
-	Total   int             `json:"total"`
+	Entries    []EntryResponse `json:"entries"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// SearchResultResponse represents an entry matched by a search
+type SearchResultResponse struct {
+	Entry   EntryResponse `json:"entry"`
+	Rank    float64       `json:"rank"`
+	Snippet string        `json:"snippet,omitempty"`
+}
+
+// SearchResponse represents search results, most relevant first
+type SearchResponse struct {
+	Query   string                 `json:"query"`
+	Results []SearchResultResponse `json:"results"`
+	Total   int                    `json:"total"`
 }
 
 // ErrorResponse represents an error response
@@ -47,36 +71,31 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, response)
 }
 
-// handleGetEntries handles GET /api/v1/entries
+// handleGetEntries handles GET /api/v1/entries, filtered, sorted and paged as entriesQuery
+// describes. By default every entry comes back, most recent first.
 func (s *Server) handleGetEntries(w http.ResponseWriter, r *http.Request) {
-	entries, err := s.store.GetAll()
+	q, err := entriesQuery(r)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to fetch entries", err.Error())
+		writeStoreError(w, "Invalid query", err)
 		return
 	}
 
-	// Convert to response format and sort by StartedAt (most recent first)
-	entryList := make([]EntryResponse, 0, len(entries))
-	for _, entry := range entries {
-		entryList = append(entryList, toEntryResponse(entry))
+	result, err := s.store.Query(r.Context(), q)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch entries", err)
+		return
 	}
 
-	// Sort by StartedAt timestamp, most recent first
-	sort.Slice(entryList, func(i, j int) bool {
-		// Parse timestamps for comparison
-		timeI, errI := time.Parse(time.RFC3339, entryList[i].StartedAt)
-		timeJ, errJ := time.Parse(time.RFC3339, entryList[j].StartedAt)
-
-		if errI != nil || errJ != nil {
-			return false
-		}
-
-		return timeI.After(timeJ)
-	})
+	// Convert to response format
+	entryList := make([]EntryResponse, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		entryList = append(entryList, toEntryResponse(entry))
+	}
 
 	response := EntriesResponse{
-		Entries: entryList,
-		Total:   len(entryList),
+		Entries:    entryList,
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
 	}
 
 	writeJSON(w, http.StatusOK, response)
@@ -90,22 +109,70 @@ func (s *Server) handleGetEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := s.store.GetAll()
+	result, err := s.store.Query(r.Context(), service.Query{IDs: []string{id}, Limit: 1})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to fetch entry", err.Error())
+		writeStoreError(w, "Failed to fetch entry", err)
 		return
 	}
 
-	entry, exists := entries[id]
-	if !exists {
+	if len(result.Entries) == 0 {
 		writeError(w, http.StatusNotFound, "Entry not found", "")
 		return
 	}
 
+	// Clients send the ETag back in If-Match to make their writes conditional
+	entry := result.Entries[0]
+	etag := entryETag(entry.Version)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	response := toEntryResponse(entry)
 	writeJSON(w, http.StatusOK, response)
 }
 
+// handleSearch handles GET /api/v1/search?q=...&limit=...
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, "Missing search query", "set the q parameter")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, "Invalid limit", fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit))
+			return
+		}
+		limit = n
+	}
+
+	results, err := service.Search(r.Context(), s.store, text, limit)
+	if err != nil {
+		writeStoreError(w, "Failed to search entries", err)
+		return
+	}
+
+	response := SearchResponse{
+		Query:   text,
+		Results: make([]SearchResultResponse, 0, len(results)),
+		Total:   len(results),
+	}
+	for _, result := range results {
+		response.Results = append(response.Results, SearchResultResponse{
+			Entry:   toEntryResponse(result.Entry),
+			Rank:    result.Rank,
+			Snippet: result.Snippet,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
 // toEntryResponse converts core.Entry to EntryResponse
 func toEntryResponse(entry core.Entry) EntryResponse {
 	resp := EntryResponse{
@@ -114,6 +181,7 @@ func toEntryResponse(entry core.Entry) EntryResponse {
 		Tags:         entry.Tags,
 		Body:         entry.Body,
 		InProgress:   entry.InProgress(),
+		Version:      entry.Version,
 	}
 
 	// Format timestamps
@@ -155,6 +223,11 @@ func toEntryResponse(entry core.Entry) EntryResponse {
 	return resp
 }
 
+// entryETag is the strong validator for one stored version of an entry
+func entryETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
 // formatDuration formats a duration in human-readable format
 func formatDuration(d time.Duration) string {
 	if d == 0 {
@@ -197,3 +270,27 @@ func writeError(w http.ResponseWriter, status int, error string, message string)
 	}
 	writeJSON(w, status, response)
 }
+
+// writeStoreError maps store errors to HTTP statuses: a version conflict is a failed
+// precondition, an invalid query, tag or workspace is the client's fault, workspaces the
+// caller isn't in don't exist for them, a role too low is forbidden, a timer in the wrong
+// state conflicts, a backend without tag metadata, workspaces or timers can't do what was
+// asked, and anything else is ours
+func writeStoreError(w http.ResponseWriter, error string, err error) {
+	switch {
+	case errors.Is(err, service.ErrConflict):
+		writeError(w, http.StatusPreconditionFailed, error, err.Error())
+	case errors.Is(err, service.ErrInvalidQuery), errors.Is(err, service.ErrInvalidTag), errors.Is(err, service.ErrInvalidWorkspace):
+		writeError(w, http.StatusBadRequest, error, err.Error())
+	case errors.Is(err, service.ErrWorkspaceNotFound), errors.Is(err, service.ErrEntryNotFound):
+		writeError(w, http.StatusNotFound, error, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, error, err.Error())
+	case errors.Is(err, service.ErrLastAdmin), errors.Is(err, service.ErrTimerRunning), errors.Is(err, service.ErrTimerNotRunning):
+		writeError(w, http.StatusConflict, error, err.Error())
+	case errors.Is(err, service.ErrTagMetadataUnsupported), errors.Is(err, service.ErrWorkspacesUnsupported), errors.Is(err, service.ErrTimersUnsupported):
+		writeError(w, http.StatusNotImplemented, error, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, error, err.Error())
+	}
+}
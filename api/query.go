@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/service"
+)
+
+const maxEntriesLimit = 500
+
+// entriesQuery reads the filters, order and page of GET /api/v1/entries from the URL. On top
+// of entryFilters it takes:
+//
+//	sort     started_at (default), ended_at or last_modified
+//	order    desc (default) or asc
+//	limit    Page size, up to maxEntriesLimit; every entry when unset
+//	cursor   next_cursor from the previous page, sent with the same parameters
+func entriesQuery(r *http.Request) (service.Query, error) {
+	params := r.URL.Query()
+	q, err := entryFilters(params)
+	if err != nil {
+		return service.Query{}, err
+	}
+	q.SortBy = params.Get("sort")
+	q.Cursor = params.Get("cursor")
+	q.Count = true
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return service.Query{}, fmt.Errorf("%w: order must be asc or desc", service.ErrInvalidQuery)
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxEntriesLimit {
+			return service.Query{}, fmt.Errorf("%w: limit must be between 1 and %d", service.ErrInvalidQuery, maxEntriesLimit)
+		}
+		q.Limit = n
+	}
+
+	if err := q.Validate(); err != nil {
+		return service.Query{}, err
+	}
+	return q, nil
+}
+
+// entryFilters reads the filters shared by the entry list and the stats from the URL:
+//
+//	tag            Entries carrying the tag or a tag beneath it; repeat to require several
+//	text           Case-insensitive substring of the title or body
+//	status         in_progress or finished
+//	started_from   Started at or after this time; started_before, ended_from and
+//	               ended_before work alike. RFC 3339 or a UTC date like 2025-01-31
+func entryFilters(params url.Values) (service.Query, error) {
+	q := service.Query{Text: strings.TrimSpace(params.Get("text"))}
+
+	for _, tag := range params["tag"] {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			q.Tags = append(q.Tags, tag)
+		}
+	}
+
+	switch params.Get("status") {
+	case "":
+	case "in_progress":
+		inProgress := true
+		q.InProgress = &inProgress
+	case "finished":
+		inProgress := false
+		q.InProgress = &inProgress
+	default:
+		return service.Query{}, fmt.Errorf("%w: status must be in_progress or finished", service.ErrInvalidQuery)
+	}
+
+	bounds := []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"started_from", &q.StartedAt.From},
+		{"started_before", &q.StartedAt.To},
+		{"ended_from", &q.EndedAt.From},
+		{"ended_before", &q.EndedAt.To},
+	}
+	for _, b := range bounds {
+		t, err := parseQueryTime(b.param, params.Get(b.param))
+		if err != nil {
+			return service.Query{}, err
+		}
+		*b.dest = t
+	}
+
+	return q, nil
+}
+
+// parseQueryTime parses an RFC 3339 timestamp or a date, taken as midnight UTC; blank is the zero time
+func parseQueryTime(param, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: %s must be RFC 3339 or a date, like 2025-01-31", service.ErrInvalidQuery, param)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+func TestEntriesQuery(t *testing.T) {
+	day := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	q, err := entriesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/entries?tag=infra&tag=+go+&tag=&text=+deploy+&status=finished&started_from=2025-01-31&ended_before=2025-02-01T09:30:00Z&sort=last_modified&order=asc&limit=20", nil))
+	if err != nil {
+		t.Fatalf("entriesQuery() error = %v", err)
+	}
+	if !slices.Equal(q.Tags, []string{"infra", "go"}) || q.Text != "deploy" || q.InProgress == nil || *q.InProgress {
+		t.Errorf("filters = %+v; want tags infra and go, text deploy, finished", q)
+	}
+	if !q.StartedAt.From.Equal(day) || !q.EndedAt.To.Equal(day.Add(33*time.Hour+30*time.Minute)) {
+		t.Errorf("time bounds = %v, %v; want the date at midnight UTC and the timestamp", q.StartedAt.From, q.EndedAt.To)
+	}
+	if q.SortBy != service.SortByLastModified || !q.Ascending || q.Limit != 20 || !q.Count {
+		t.Errorf("order = %+v; want last_modified ascending, 20 a page, counted", q)
+	}
+
+	// The defaults: newest started first, every entry
+	q, err = entriesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/entries", nil))
+	if err != nil || q.SortBy != service.SortByStartedAt || q.Ascending || q.Limit != 0 {
+		t.Errorf("entriesQuery() = %+v, %v; want started_at descending and no limit", q, err)
+	}
+}
+
+func TestEntriesQueryRejects(t *testing.T) {
+	for _, raw := range []string{
+		"status=done",
+		"order=up",
+		"sort=title",
+		"limit=0",
+		"limit=501",
+		"limit=ten",
+		"started_from=yesterday",
+		"ended_before=31/01/2025",
+		"cursor=not-a-cursor",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			_, err := entriesQuery(httptest.NewRequest(http.MethodGet, "/api/v1/entries?"+raw, nil))
+			if !errors.Is(err, service.ErrInvalidQuery) {
+				t.Errorf("entriesQuery(%s) error = %v; want ErrInvalidQuery", raw, err)
+			}
+		})
+	}
+}
+
+func TestGetEntriesPages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	var seeded []core.Entry
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		entry := core.Entry{ID: id, Title: "Entry " + id, Tags: []string{"work"}, StartedAtTimestamp: start.Add(time.Duration(i) * time.Hour)}
+		if id == "c" {
+			entry.Tags = []string{"home"}
+		}
+		seeded = append(seeded, entry)
+	}
+	s := newTestServer(t, service.NewMemoryStore(seeded...))
+
+	// Following next_cursor with the same parameters visits every match once, in order
+	var ids []string
+	params := url.Values{"tag": {"work"}, "order": {"asc"}, "limit": {"2"}}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("next_cursor never ran out; got %v so far", ids)
+		}
+
+		var page EntriesResponse
+		decode(t, serve(t, s, http.MethodGet, "/api/v1/entries?"+params.Encode(), ""), http.StatusOK, &page)
+		if page.Total != 4 {
+			t.Errorf("total = %d; want every match counted on each page", page.Total)
+		}
+		for _, entry := range page.Entries {
+			ids = append(ids, entry.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		params.Set("cursor", page.NextCursor)
+	}
+	if !slices.Equal(ids, []string{"a", "b", "d", "e"}) {
+		t.Errorf("paged IDs = %v; want a, b, d, e", ids)
+	}
+
+	decode(t, serve(t, s, http.MethodGet, "/api/v1/entries?limit=1000", ""), http.StatusBadRequest, nil)
+}
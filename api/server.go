@@ -14,10 +14,16 @@ import (
 )
 
 type Server struct {
-	store   service.Store
-	router  *chi.Mux
-	apiKey  string
-	cognito *CognitoConfig
+	store       service.Store
+	router      *chi.Mux
+	apiKey      string
+	apiKeyOwner string // Owner whose entries API key requests read and write
+	cognito     *CognitoConfig
+	timerPolicy service.TimerPolicy   // What starting a timer does while another runs; pause when unset
+	deliveries  service.DeliveryQueue // Webhook delivery log; nil when webhooks are off
+	events      *service.EventLog     // Recent changes for GET /api/v1/events
+	following   bool                  // Whether events come from the store's change feed, not this server's writes
+	heartbeat   time.Duration         // How often idle event streams send a comment; defaultHeartbeat when unset
 }
 
 // NewServer creates a new API server
@@ -27,6 +33,7 @@ func NewServer(store service.Store, apiKey string) *Server {
 		router:  chi.NewRouter(),
 		apiKey:  apiKey,
 		cognito: nil,
+		events:  service.NewEventLog(eventLogSize),
 	}
 
 	s.setupMiddleware()
@@ -35,6 +42,22 @@ func NewServer(store service.Store, apiKey string) *Server {
 	return s
 }
 
+// SetAPIKeyOwner sets whose entries requests authenticated with the API key act on.
+// The default is service.DefaultOwner, which owns the entries from before owners existed.
+func (s *Server) SetAPIKeyOwner(owner string) {
+	s.apiKeyOwner = owner
+}
+
+// SetTimerPolicy sets what starting a timer does while another entry's timer runs
+func (s *Server) SetTimerPolicy(policy service.TimerPolicy) {
+	s.timerPolicy = policy
+}
+
+// SetWebhookDeliveries sets where GET /api/v1/webhooks/deliveries reads the delivery log
+func (s *Server) SetWebhookDeliveries(deliveries service.DeliveryQueue) {
+	s.deliveries = deliveries
+}
+
 // SetCognitoConfig sets the Cognito configuration for JWT authentication
 func (s *Server) SetCognitoConfig(cognito *CognitoConfig) {
 	s.cognito = cognito
@@ -44,14 +67,14 @@ func (s *Server) setupMiddleware() {
 	// Basic middleware
 	s.router.Use(middleware.Logger)
 	s.router.Use(middleware.Recoverer)
-	s.router.Use(middleware.Timeout(60 * time.Second))
+	s.router.Use(skipStreams(middleware.Timeout(60 * time.Second)))
 
 	// CORS configuration for mobile access
 	s.router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // Configure this properly for production
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "If-Match", "If-None-Match", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link", "ETag", "Location"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -66,16 +89,43 @@ func (s *Server) setupRoutes() {
 	// API v1 routes
 	s.router.Route("/api/v1", func(r chi.Router) {
 		r.Get("/entries", s.handleGetEntries)
+		r.Post("/entries", s.handleCreateEntry)
 		r.Get("/entries/{id}", s.handleGetEntry)
-
-		// Future: write endpoints
-		// r.Post("/entries", s.handleCreateEntry)
-		// r.Put("/entries/{id}", s.handleUpdateEntry)
-		// r.Delete("/entries/{id}", s.handleDeleteEntry)
+		r.Put("/entries/{id}", s.handleReplaceEntry)
+		r.Patch("/entries/{id}", s.handlePatchEntry)
+		r.Delete("/entries/{id}", s.handleDeleteEntry)
+		r.Post("/entries/{id}/start", s.handleStartTimer)
+		r.Post("/entries/{id}/pause", s.handlePauseTimer)
+		r.Post("/entries/{id}/stop", s.handleStopTimer)
+		r.Get("/timer/current", s.handleCurrentTimer)
+		r.Get("/events", s.handleEvents)
+		r.Get("/search", s.handleSearch)
+
+		r.Get("/stats/bias", s.handleBiasStats)
+		r.Get("/stats/time", s.handleTimeStats)
+
+		r.Get("/tags", s.handleListTags)
+		r.Post("/tags/merge", s.handleMergeTags)
+		r.Put("/tags/{name}", s.handleSaveTag)
+		r.Delete("/tags/{name}", s.handleDeleteTag)
+		r.Post("/tags/{name}/rename", s.handleRenameTag)
+
+		r.Get("/workspaces", s.handleListWorkspaces)
+		r.Post("/workspaces", s.handleCreateWorkspace)
+		r.Get("/workspaces/{workspace}/entries", s.handleGetWorkspaceEntries)
+		r.Get("/workspaces/{workspace}/events", s.handleWorkspaceEvents)
+		r.Get("/workspaces/{workspace}/members", s.handleListMembers)
+		r.Put("/workspaces/{workspace}/members/{principal}", s.handleSaveMember)
+		r.Delete("/workspaces/{workspace}/members/{principal}", s.handleDeleteMember)
+
+		r.Get("/webhooks/deliveries", s.handleListDeliveries)
+
+		r.Get("/cache", s.handleCacheStats)
 	})
 }
 
-// authMiddleware validates API key or Cognito JWT token
+// authMiddleware validates API key or Cognito JWT token, and puts the principal in the
+// request context so every store call is scoped to their entries
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for health check
@@ -88,16 +138,24 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		if s.cognito != nil {
 			bearerToken := extractBearerToken(r)
 			if bearerToken != "" {
-				_, err := s.cognito.ValidateToken(bearerToken)
+				token, err := s.cognito.ValidateToken(bearerToken)
 				if err != nil {
 					logger.Warn("cognito_token_validation_failed", "error", err.Error())
 					http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
 					return
 				}
 
-				// Token is valid
-				logger.Info("authenticated", "method", "cognito")
-				next.ServeHTTP(w, r)
+				// Token is valid; its subject owns the entries the request may touch
+				subject, err := token.Claims.GetSubject()
+				if err != nil || subject == "" {
+					logger.Warn("cognito_token_validation_failed", "error", "missing sub claim")
+					http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+					return
+				}
+
+				logger.Info("authenticated", "method", "cognito", "principal", subject)
+				principal := service.Principal{ID: subject, Method: "cognito"}
+				next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
 				return
 			}
 		}
@@ -115,7 +173,8 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		}
 
 		logger.Info("authenticated", "method", "api_key")
-		next.ServeHTTP(w, r)
+		principal := service.Principal{ID: s.apiKeyOwner, Method: "api_key"}
+		next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
 	})
 }
 
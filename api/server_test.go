@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turnerem/zenzen/service"
+)
+
+const testAPIKey = "test-key"
+
+// newTestServer serves the API over store, authenticated with testAPIKey
+func newTestServer(t *testing.T, store service.Store) *Server {
+	t.Helper()
+	return NewServer(store, testAPIKey)
+}
+
+// serve sends a request with the API key and returns the recorded answer. Headers are
+// given as name, value pairs.
+func serve(t *testing.T, s *Server, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-API-Key", testAPIKey)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode reads a JSON answer into v, failing the test unless it has the wanted status
+func decode(t *testing.T, rec *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("status = %d; want %d (body %s)", rec.Code, status, rec.Body)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode %s: %v", rec.Body, err)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/entries", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without a key = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if rec := serve(t, s, http.MethodGet, "/api/v1/entries", ""); rec.Code != http.StatusOK {
+		t.Errorf("status with the key = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
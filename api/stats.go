@@ -0,0 +1,183 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// RatioStatsResponse summarises actual-over-estimated ratios; above 1 means work overran
+type RatioStatsResponse struct {
+	Entries          int     `json:"entries"`
+	EstimatedMinutes int64   `json:"estimated_minutes"`
+	ActualMinutes    int64   `json:"actual_minutes"`
+	Mean             float64 `json:"mean"`
+	Median           float64 `json:"median"`
+	P25              float64 `json:"p25"`
+	P75              float64 `json:"p75"`
+	P90              float64 `json:"p90"`
+	TrendPerWeek     float64 `json:"trend_per_week"`
+}
+
+// TagBiasResponse represents the bias of the entries under a tag
+type TagBiasResponse struct {
+	Tag string `json:"tag"`
+	RatioStatsResponse
+}
+
+// PeriodBiasResponse represents the bias of the entries started in a period
+type PeriodBiasResponse struct {
+	Start string `json:"start"`
+	RatioStatsResponse
+}
+
+// SizeBiasResponse represents the bias of the entries with an estimate in a band
+type SizeBiasResponse struct {
+	Size       string `json:"size"`
+	MinMinutes int64  `json:"min_minutes"`
+	MaxMinutes int64  `json:"max_minutes,omitempty"` // Unbounded when absent
+	RatioStatsResponse
+}
+
+// BiasStatsResponse represents estimation bias, broken down by tag, period and estimate size
+type BiasStatsResponse struct {
+	Period   string               `json:"period"`
+	Overall  RatioStatsResponse   `json:"overall"`
+	ByTag    []TagBiasResponse    `json:"by_tag"`
+	ByPeriod []PeriodBiasResponse `json:"by_period"`
+	BySize   []SizeBiasResponse   `json:"by_size"`
+}
+
+// TagTimeResponse represents the time spent on the entries under a tag
+type TagTimeResponse struct {
+	Tag           string `json:"tag"`
+	Entries       int    `json:"entries"`
+	ActualMinutes int64  `json:"actual_minutes"`
+}
+
+// PeriodTimeResponse represents the time spent in a period, in total and by tag
+type PeriodTimeResponse struct {
+	Start         string            `json:"start"`
+	Entries       int               `json:"entries"`
+	ActualMinutes int64             `json:"actual_minutes"`
+	ByTag         []TagTimeResponse `json:"by_tag"`
+}
+
+// TimeStatsResponse represents time spent per period, oldest first
+type TimeStatsResponse struct {
+	Period  string               `json:"period"`
+	Periods []PeriodTimeResponse `json:"periods"`
+}
+
+// handleBiasStats handles GET /api/v1/stats/bias. It takes the entry list's filters and
+// period=week (default) or month.
+func (s *Server) handleBiasStats(w http.ResponseWriter, r *http.Request) {
+	entries, period, ok := s.statsEntries(w, r)
+	if !ok {
+		return
+	}
+
+	report := service.BiasBreakdown(entries, period)
+	response := BiasStatsResponse{
+		Period:   string(period),
+		Overall:  toRatioStatsResponse(report.Overall),
+		ByTag:    make([]TagBiasResponse, 0, len(report.ByTag)),
+		ByPeriod: make([]PeriodBiasResponse, 0, len(report.ByPeriod)),
+		BySize:   make([]SizeBiasResponse, 0, len(report.BySize)),
+	}
+	for _, bias := range report.ByTag {
+		response.ByTag = append(response.ByTag, TagBiasResponse{Tag: bias.Tag, RatioStatsResponse: toRatioStatsResponse(bias.RatioStats)})
+	}
+	for _, bias := range report.ByPeriod {
+		response.ByPeriod = append(response.ByPeriod, PeriodBiasResponse{Start: bias.Start.Format(time.DateOnly), RatioStatsResponse: toRatioStatsResponse(bias.RatioStats)})
+	}
+	for _, bias := range report.BySize {
+		response.BySize = append(response.BySize, SizeBiasResponse{
+			Size:               bias.Size.Label,
+			MinMinutes:         minutes(bias.Size.Min),
+			MaxMinutes:         minutes(bias.Size.Max),
+			RatioStatsResponse: toRatioStatsResponse(bias.RatioStats),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleTimeStats handles GET /api/v1/stats/time. It takes the entry list's filters and
+// period=week (default) or month.
+func (s *Server) handleTimeStats(w http.ResponseWriter, r *http.Request) {
+	entries, period, ok := s.statsEntries(w, r)
+	if !ok {
+		return
+	}
+
+	periods := service.TimeByPeriod(entries, period)
+	response := TimeStatsResponse{
+		Period:  string(period),
+		Periods: make([]PeriodTimeResponse, 0, len(periods)),
+	}
+	for _, spent := range periods {
+		byTag := make([]TagTimeResponse, 0, len(spent.ByTag))
+		for _, tag := range spent.ByTag {
+			byTag = append(byTag, TagTimeResponse{Tag: tag.Tag, Entries: tag.Entries, ActualMinutes: minutes(tag.Actual)})
+		}
+		response.Periods = append(response.Periods, PeriodTimeResponse{
+			Start:         spent.Start.Format(time.DateOnly),
+			Entries:       spent.Entries,
+			ActualMinutes: minutes(spent.Actual),
+			ByTag:         byTag,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// statsEntries fetches the entries the request's filters select and reads its period,
+// writing a 400 if either is invalid
+func (s *Server) statsEntries(w http.ResponseWriter, r *http.Request) (map[string]core.Entry, service.Period, bool) {
+	params := r.URL.Query()
+	period, err := service.ParsePeriod(params.Get("period"))
+	if err != nil {
+		writeStoreError(w, "Invalid query", err)
+		return nil, "", false
+	}
+	q, err := entryFilters(params)
+	if err != nil {
+		writeStoreError(w, "Invalid query", err)
+		return nil, "", false
+	}
+
+	result, err := s.store.Query(r.Context(), q)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch entries", err)
+		return nil, "", false
+	}
+
+	entries := make(map[string]core.Entry, len(result.Entries))
+	for _, entry := range result.Entries {
+		entries[entry.ID] = entry
+	}
+	return entries, period, true
+}
+
+// toRatioStatsResponse converts service.RatioStats to RatioStatsResponse
+func toRatioStatsResponse(stats service.RatioStats) RatioStatsResponse {
+	return RatioStatsResponse{
+		Entries:          stats.Entries,
+		EstimatedMinutes: minutes(stats.Estimated),
+		ActualMinutes:    minutes(stats.Actual),
+		Mean:             stats.Mean,
+		Median:           stats.Median,
+		P25:              stats.P25,
+		P75:              stats.P75,
+		P90:              stats.P90,
+		TrendPerWeek:     stats.Trend,
+	}
+}
+
+// minutes rounds a duration to whole minutes
+func minutes(d time.Duration) int64 {
+	return int64(d.Round(time.Minute) / time.Minute)
+}
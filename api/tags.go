@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/turnerem/zenzen/service"
+)
+
+// maxRequestBody caps JSON request bodies
+const maxRequestBody = 1 << 20
+
+// TagResponse represents a tag in API responses
+type TagResponse struct {
+	Name        string `json:"name"`
+	Count       int    `json:"count"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"`
+}
+
+// TagsResponse represents every tag, by name
+type TagsResponse struct {
+	Tags  []TagResponse `json:"tags"`
+	Total int           `json:"total"`
+}
+
+// TagRequest is the body of PUT /api/v1/tags/{name}
+type TagRequest struct {
+	Description string `json:"description"`
+	Color       string `json:"color"`
+}
+
+// RenameTagRequest is the body of POST /api/v1/tags/{name}/rename
+type RenameTagRequest struct {
+	To string `json:"to"`
+}
+
+// MergeTagsRequest is the body of POST /api/v1/tags/merge
+type MergeTagsRequest struct {
+	From []string `json:"from"`
+	Into string   `json:"into"`
+}
+
+// TagChangeResponse reports how many entries a rename or merge rewrote
+type TagChangeResponse struct {
+	Tag     string `json:"tag"`
+	Entries int    `json:"entries"`
+}
+
+// handleListTags handles GET /api/v1/tags
+func (s *Server) handleListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := service.ListTags(r.Context(), s.store)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch tags", err)
+		return
+	}
+
+	response := TagsResponse{
+		Tags:  make([]TagResponse, 0, len(tags)),
+		Total: len(tags),
+	}
+	for _, tag := range tags {
+		response.Tags = append(response.Tags, toTagResponse(tag))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleSaveTag handles PUT /api/v1/tags/{name}, setting the tag's description and colour
+func (s *Server) handleSaveTag(w http.ResponseWriter, r *http.Request) {
+	var req TagRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	tag := service.Tag{Name: tagParam(r), Description: req.Description, Color: req.Color}
+	if err := service.SaveTag(r.Context(), s.store, tag); err != nil {
+		writeStoreError(w, "Failed to save tag", err)
+		return
+	}
+
+	// Answer with the tag as listed, so the count is current
+	tags, err := service.ListTags(r.Context(), s.store)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch tag", err)
+		return
+	}
+	for _, listed := range tags {
+		if listed.Name == tag.Name {
+			tag = listed
+			break
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toTagResponse(tag))
+}
+
+// handleDeleteTag handles DELETE /api/v1/tags/{name}, dropping the tag's metadata.
+// Entries keep the tag.
+func (s *Server) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	tagStore, ok := s.store.(service.TagStore)
+	if !ok {
+		writeStoreError(w, "Failed to delete tag", service.ErrTagMetadataUnsupported)
+		return
+	}
+
+	if err := tagStore.DeleteTag(r.Context(), tagParam(r)); err != nil {
+		writeStoreError(w, "Failed to delete tag", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRenameTag handles POST /api/v1/tags/{name}/rename.
+// Renaming onto a tag already in use merges the two.
+func (s *Server) handleRenameTag(w http.ResponseWriter, r *http.Request) {
+	var req RenameTagRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	changed, err := service.RenameTag(r.Context(), s.store, tagParam(r), req.To)
+	s.publishEntries(r, changed)
+	if err != nil {
+		writeTagChangeError(w, "Failed to rename tag", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TagChangeResponse{Tag: req.To, Entries: len(changed)})
+}
+
+// handleMergeTags handles POST /api/v1/tags/merge
+func (s *Server) handleMergeTags(w http.ResponseWriter, r *http.Request) {
+	var req MergeTagsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.From) == 0 {
+		writeError(w, http.StatusBadRequest, "Missing tags to merge", "set from to the tags to merge into into")
+		return
+	}
+
+	changed, err := service.MergeTags(r.Context(), s.store, req.From, req.Into)
+	s.publishEntries(r, changed)
+	if err != nil {
+		writeTagChangeError(w, "Failed to merge tags", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TagChangeResponse{Tag: req.Into, Entries: len(changed)})
+}
+
+// toTagResponse converts service.Tag to TagResponse
+func toTagResponse(tag service.Tag) TagResponse {
+	return TagResponse{
+		Name:        tag.Name,
+		Count:       tag.Count,
+		Description: tag.Description,
+		Color:       tag.Color,
+	}
+}
+
+// tagParam returns the unescaped {name} path parameter, so tags may contain spaces or slashes
+func tagParam(r *http.Request) string {
+	name := chi.URLParam(r, "name")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// writeTagChangeError reports a rename or merge that failed. A conflict here means an entry
+// changed while being rewritten, not a failed If-Match, so it is a 409 the client can retry.
+func writeTagChangeError(w http.ResponseWriter, error string, err error) {
+	if errors.Is(err, service.ErrConflict) {
+		writeError(w, http.StatusConflict, error, err.Error())
+		return
+	}
+	writeStoreError(w, error, err)
+}
+
+// decodeJSON reads a JSON request body into v, writing a 400 if it can't
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return false
+	}
+	return true
+}
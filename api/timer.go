@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// timerStopped is the state reported once an entry's timer has been stopped
+const timerStopped = "stopped"
+
+// TimerResponse represents the caller's timer and the entry it times
+type TimerResponse struct {
+	State          string        `json:"state"` // running, paused or stopped
+	Since          string        `json:"since"` // When it entered that state
+	ElapsedSeconds int64         `json:"elapsed_seconds"`
+	Entry          EntryResponse `json:"entry"`
+}
+
+// handleStartTimer handles POST /api/v1/entries/{id}/start. It starts or resumes timing the
+// entry; if another entry's timer is running, the server's timer policy pauses it or answers 409.
+func (s *Server) handleStartTimer(w http.ResponseWriter, r *http.Request) {
+	policy := s.timerPolicy
+	if policy == "" {
+		policy = service.TimerPolicyPause
+	}
+
+	// The running entry, which starting another pauses, to report its change too
+	previous, _, running, err := service.CurrentTimer(r.Context(), s.store)
+	if err != nil {
+		writeStoreError(w, "Failed to start timer", err)
+		return
+	}
+
+	timer, entry, err := service.StartTimer(r.Context(), s.store, chi.URLParam(r, "id"), policy)
+	if err != nil {
+		writeStoreError(w, "Failed to start timer", err)
+		return
+	}
+	switch {
+	case !running || previous.State != service.TimerRunning:
+		s.publishEntry(r, service.StreamEntryUpdated, entry.ID, entry.Version)
+	case previous.EntryID != timer.EntryID:
+		if paused, ok, err := s.findEntry(r, previous.EntryID); err == nil && ok {
+			s.publishEntry(r, service.StreamEntryUpdated, paused.ID, paused.Version)
+		}
+		s.publishEntry(r, service.StreamEntryUpdated, entry.ID, entry.Version)
+	}
+	s.publishTimer(r, timer.EntryID, string(timer.State))
+
+	writeTimer(w, string(timer.State), timer.Since, entry)
+}
+
+// handlePauseTimer handles POST /api/v1/entries/{id}/pause, answering 409 unless the entry's timer is running
+func (s *Server) handlePauseTimer(w http.ResponseWriter, r *http.Request) {
+	timer, entry, err := service.PauseTimer(r.Context(), s.store, chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, "Failed to pause timer", err)
+		return
+	}
+	s.publishEntry(r, service.StreamEntryUpdated, entry.ID, entry.Version)
+	s.publishTimer(r, timer.EntryID, string(timer.State))
+
+	writeTimer(w, string(timer.State), timer.Since, entry)
+}
+
+// handleStopTimer handles POST /api/v1/entries/{id}/stop, finishing the entry.
+// It answers 409 if the entry had already finished and wasn't paused.
+func (s *Server) handleStopTimer(w http.ResponseWriter, r *http.Request) {
+	// A paused entry has already ended, so stopping it only clears the timer
+	before, _, err := s.findEntry(r, chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, "Failed to stop timer", err)
+		return
+	}
+
+	entry, err := service.StopTimer(r.Context(), s.store, chi.URLParam(r, "id"))
+	if err != nil {
+		writeStoreError(w, "Failed to stop timer", err)
+		return
+	}
+	if entry.Version != before.Version {
+		s.publishEntry(r, service.StreamEntryUpdated, entry.ID, entry.Version)
+	}
+	s.publishTimer(r, entry.ID, timerStopped)
+
+	writeTimer(w, timerStopped, entry.EndedAtTimestamp, entry)
+}
+
+// handleCurrentTimer handles GET /api/v1/timer/current, answering 204 when nothing is being timed
+func (s *Server) handleCurrentTimer(w http.ResponseWriter, r *http.Request) {
+	timer, entry, ok, err := service.CurrentTimer(r.Context(), s.store)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch timer", err)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeTimer(w, string(timer.State), timer.Since, entry)
+}
+
+// writeTimer answers with a timer's state, the time its entry has been worked on, and the entry with its ETag
+func writeTimer(w http.ResponseWriter, state string, since time.Time, entry core.Entry) {
+	end := entry.EndedAtTimestamp
+	if entry.InProgress() {
+		end = time.Now()
+	}
+
+	w.Header().Set("ETag", entryETag(entry.Version))
+	writeJSON(w, http.StatusOK, TimerResponse{
+		State:          state,
+		Since:          since.UTC().Format(time.RFC3339),
+		ElapsedSeconds: int64(end.Sub(entry.StartedAtTimestamp) / time.Second),
+		Entry:          toEntryResponse(entry),
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+func TestTimerEndpoints(t *testing.T) {
+	store := service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}, core.Entry{ID: "2", Title: "Review", Version: 1})
+	s := newTestServer(t, store)
+
+	rec := serve(t, s, http.MethodGet, "/api/v1/timer/current", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("current before starting = %d; want %d", rec.Code, http.StatusNoContent)
+	}
+
+	var timer TimerResponse
+	rec = serve(t, s, http.MethodPost, "/api/v1/entries/1/start", "")
+	decode(t, rec, http.StatusOK, &timer)
+	if timer.State != "running" || timer.Entry.ID != "1" || !timer.Entry.InProgress || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("started = %+v, ETag %s; want entry 1 running at version 2", timer, rec.Header().Get("ETag"))
+	}
+
+	// The default policy pauses the running entry to start another
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/2/start", ""), http.StatusOK, &timer)
+	decode(t, serve(t, s, http.MethodGet, "/api/v1/timer/current", ""), http.StatusOK, &timer)
+	if timer.State != "running" || timer.Entry.ID != "2" {
+		t.Errorf("current = %+v; want entry 2 running", timer)
+	}
+	var first EntryResponse
+	decode(t, serve(t, s, http.MethodGet, "/api/v1/entries/1", ""), http.StatusOK, &first)
+	if first.InProgress {
+		t.Errorf("entry 1 = %+v; want it paused when entry 2 started", first)
+	}
+
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/1/pause", ""), http.StatusConflict, nil)
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/2/pause", ""), http.StatusOK, &timer)
+	if timer.State != "paused" || timer.Entry.InProgress {
+		t.Errorf("paused = %+v; want entry 2 paused", timer)
+	}
+
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/2/stop", ""), http.StatusOK, &timer)
+	if timer.State != "stopped" || timer.Entry.EndedAt == "" {
+		t.Errorf("stopped = %+v; want entry 2 ended", timer)
+	}
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/2/stop", ""), http.StatusConflict, nil)
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/missing/start", ""), http.StatusNotFound, nil)
+
+	rec = serve(t, s, http.MethodGet, "/api/v1/timer/current", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("current after stopping = %d; want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestStartTimerRejects(t *testing.T) {
+	s := newTestServer(t, service.NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", Version: 1}, core.Entry{ID: "2", Title: "Review", Version: 1}))
+	s.SetTimerPolicy(service.TimerPolicyReject)
+
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/1/start", ""), http.StatusOK, nil)
+	decode(t, serve(t, s, http.MethodPost, "/api/v1/entries/2/start", ""), http.StatusConflict, nil)
+
+	var timer TimerResponse
+	decode(t, serve(t, s, http.MethodGet, "/api/v1/timer/current", ""), http.StatusOK, &timer)
+	if timer.Entry.ID != "1" {
+		t.Errorf("current = %+v; want entry 1 still running", timer)
+	}
+}
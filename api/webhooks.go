@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// DeliveryResponse represents one webhook delivery and its attempts so far
+type DeliveryResponse struct {
+	ID             string          `json:"id"`
+	Subscription   string          `json:"subscription"`
+	Event          string          `json:"event"`
+	EntryID        string          `json:"entry_id"`
+	Status         string          `json:"status"` // pending, delivered or failed
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"` // Pending deliveries only
+	LastError      string          `json:"last_error,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    string          `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// DeliveriesResponse represents the caller's most recent deliveries, newest first
+type DeliveriesResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+}
+
+// handleListDeliveries handles GET /api/v1/webhooks/deliveries?limit=...
+func (s *Server) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if s.deliveries == nil {
+		writeError(w, http.StatusNotFound, "Webhooks not enabled", "add webhooks.subscriptions to config.yaml")
+		return
+	}
+
+	limit := defaultDeliveriesLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			writeError(w, http.StatusBadRequest, "Invalid limit", fmt.Sprintf("limit must be between 1 and %d", maxDeliveriesLimit))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := s.deliveries.ListDeliveries(r.Context(), limit)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch deliveries", err)
+		return
+	}
+
+	response := DeliveriesResponse{Deliveries: make([]DeliveryResponse, 0, len(deliveries))}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, toDeliveryResponse(delivery))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// toDeliveryResponse converts service.Delivery to DeliveryResponse
+func toDeliveryResponse(delivery service.Delivery) DeliveryResponse {
+	resp := DeliveryResponse{
+		ID:             delivery.ID,
+		Subscription:   delivery.SubscriptionID,
+		Event:          string(delivery.Event),
+		EntryID:        delivery.EntryID,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastError:      delivery.LastError,
+		ResponseStatus: delivery.ResponseStatus,
+		CreatedAt:      delivery.CreatedAt.UTC().Format(time.RFC3339),
+		Payload:        json.RawMessage(delivery.Payload),
+	}
+	if delivery.Status == service.DeliveryPending {
+		resp.NextAttemptAt = delivery.NextAttemptAt.UTC().Format(time.RFC3339)
+	}
+	if !delivery.DeliveredAt.IsZero() {
+		resp.DeliveredAt = delivery.DeliveredAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/turnerem/zenzen/service"
+)
+
+// WorkspaceResponse represents a workspace and the caller's role in it
+type WorkspaceResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+// WorkspacesResponse represents the caller's workspaces, by name
+type WorkspacesResponse struct {
+	Workspaces []WorkspaceResponse `json:"workspaces"`
+	Total      int                 `json:"total"`
+}
+
+// MemberResponse represents a workspace member
+type MemberResponse struct {
+	PrincipalID string `json:"principal_id"`
+	Role        string `json:"role"`
+	AddedAt     string `json:"added_at"`
+}
+
+// MembersResponse represents a workspace's members, by principal ID
+type MembersResponse struct {
+	Members []MemberResponse `json:"members"`
+	Total   int              `json:"total"`
+}
+
+// CreateWorkspaceRequest is the body of POST /api/v1/workspaces
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// MemberRequest is the body of PUT /api/v1/workspaces/{workspace}/members/{principal}
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+// handleListWorkspaces handles GET /api/v1/workspaces
+func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, ok := s.workspaceStore(w, "Failed to fetch workspaces")
+	if !ok {
+		return
+	}
+
+	memberships, err := service.ListWorkspaces(r.Context(), workspaces)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch workspaces", err)
+		return
+	}
+
+	response := WorkspacesResponse{
+		Workspaces: make([]WorkspaceResponse, 0, len(memberships)),
+		Total:      len(memberships),
+	}
+	for _, membership := range memberships {
+		response.Workspaces = append(response.Workspaces, toWorkspaceResponse(membership.Workspace, membership.Role))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleCreateWorkspace handles POST /api/v1/workspaces, making the caller its admin
+func (s *Server) handleCreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var req CreateWorkspaceRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	workspaces, ok := s.workspaceStore(w, "Failed to create workspace")
+	if !ok {
+		return
+	}
+
+	workspace, err := service.CreateWorkspace(r.Context(), workspaces, req.Name)
+	if err != nil {
+		writeStoreError(w, "Failed to create workspace", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toWorkspaceResponse(workspace, service.RoleAdmin))
+}
+
+// handleGetWorkspaceEntries handles GET /api/v1/workspaces/{workspace}/entries; any member may list them
+func (s *Server) handleGetWorkspaceEntries(w http.ResponseWriter, r *http.Request) {
+	workspaces, ok := s.workspaceStore(w, "Failed to fetch entries")
+	if !ok {
+		return
+	}
+
+	ctx, _, err := service.OpenWorkspace(r.Context(), workspaces, workspaceParam(r), service.RoleViewer)
+	if err != nil {
+		writeStoreError(w, "Failed to fetch entries", err)
+		return
+	}
+
+	s.handleGetEntries(w, r.WithContext(ctx))
+}
+
+// handleListMembers handles GET /api/v1/workspaces/{workspace}/members; any member may list them
+func (s *Server) handleListMembers(w http.ResponseWriter, r *http.Request) {
+	workspaces, ok := s.workspaceStore(w, "Failed to fetch members")
+	if !ok {
+		return
+	}
+
+	members, err := service.ListMembers(r.Context(), workspaces, workspaceParam(r))
+	if err != nil {
+		writeStoreError(w, "Failed to fetch members", err)
+		return
+	}
+
+	response := MembersResponse{
+		Members: make([]MemberResponse, 0, len(members)),
+		Total:   len(members),
+	}
+	for _, member := range members {
+		response.Members = append(response.Members, toMemberResponse(member))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleSaveMember handles PUT /api/v1/workspaces/{workspace}/members/{principal}, adding a
+// member or changing their role. Only admins may.
+func (s *Server) handleSaveMember(w http.ResponseWriter, r *http.Request) {
+	var req MemberRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	role, err := service.ParseRole(req.Role)
+	if err != nil {
+		writeStoreError(w, "Failed to save member", err)
+		return
+	}
+	workspaces, ok := s.workspaceStore(w, "Failed to save member")
+	if !ok {
+		return
+	}
+
+	member, err := service.SetMember(r.Context(), workspaces, workspaceParam(r), principalParam(r), role)
+	if err != nil {
+		writeStoreError(w, "Failed to save member", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toMemberResponse(member))
+}
+
+// handleDeleteMember handles DELETE /api/v1/workspaces/{workspace}/members/{principal}.
+// Admins may remove anyone; members may remove themselves.
+func (s *Server) handleDeleteMember(w http.ResponseWriter, r *http.Request) {
+	workspaces, ok := s.workspaceStore(w, "Failed to remove member")
+	if !ok {
+		return
+	}
+
+	if err := service.RemoveMember(r.Context(), workspaces, workspaceParam(r), principalParam(r)); err != nil {
+		writeStoreError(w, "Failed to remove member", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workspaceStore returns the store's workspaces, writing a 501 if it keeps none
+func (s *Server) workspaceStore(w http.ResponseWriter, error string) (service.WorkspaceStore, bool) {
+	workspaces, err := service.Workspaces(s.store)
+	if err != nil {
+		writeStoreError(w, error, err)
+		return nil, false
+	}
+	return workspaces, true
+}
+
+// toWorkspaceResponse converts service.Workspace to WorkspaceResponse
+func toWorkspaceResponse(workspace service.Workspace, role service.Role) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      string(role),
+		CreatedAt: workspace.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// toMemberResponse converts service.Member to MemberResponse
+func toMemberResponse(member service.Member) MemberResponse {
+	return MemberResponse{
+		PrincipalID: member.PrincipalID,
+		Role:        string(member.Role),
+		AddedAt:     member.AddedAt.Format(time.RFC3339),
+	}
+}
+
+// workspaceParam returns the {workspace} path parameter
+func workspaceParam(r *http.Request) string {
+	return chi.URLParam(r, "workspace")
+}
+
+// principalParam returns the unescaped {principal} path parameter, since Cognito identity
+// IDs contain colons
+func principalParam(r *http.Request) string {
+	principal := chi.URLParam(r, "principal")
+	if unescaped, err := url.PathUnescape(principal); err == nil {
+		return unescaped
+	}
+	return principal
+}
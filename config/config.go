@@ -3,20 +3,44 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Database DatabaseConfig `yaml:"database"`
-	Sync     SyncConfig     `yaml:"sync"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Sync       SyncConfig       `yaml:"sync"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Backup     BackupConfig     `yaml:"backup"`
+	Cache      CacheConfig      `yaml:"cache"`
+	Timer      TimerConfig      `yaml:"timer"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`
 }
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMarkdown = "markdown"
+)
+
 type DatabaseConfig struct {
-	ConnectionString  string `yaml:"connection_string"`   // Legacy: local connection
-	LocalConnection   string `yaml:"local_connection"`    // Local Postgres
-	CloudConnection   string `yaml:"cloud_connection"`    // Cloud Postgres (RDS/Neon)
+	Driver           string     `yaml:"driver"`            // Local storage driver: "postgres" (default), "sqlite" or "markdown"
+	Path             string     `yaml:"path"`              // SQLite database file or Markdown directory
+	ConnectionString string     `yaml:"connection_string"` // Legacy: local connection
+	LocalConnection  string     `yaml:"local_connection"`  // Local Postgres
+	CloudConnection  string     `yaml:"cloud_connection"`  // Cloud Postgres (RDS/Neon)
+	Pool             PoolConfig `yaml:"pool"`              // Postgres connection pool
+	Owner            string     `yaml:"owner"`             // Whose entries sync, backups and the API key use in a shared cloud database (e.g. your Cognito sub); empty for a single-user database
+}
+
+type PoolConfig struct {
+	MaxConns        int32  `yaml:"max_conns"`          // Maximum open connections (default: max(4, CPUs))
+	MinConns        int32  `yaml:"min_conns"`          // Connections kept open when idle
+	MaxConnLifetime string `yaml:"max_conn_lifetime"`  // Recycle connections after this long (e.g. "1h")
+	MaxConnIdleTime string `yaml:"max_conn_idle_time"` // Close connections idle this long (e.g. "30m")
 }
 
 type SyncConfig struct {
@@ -24,6 +48,51 @@ type SyncConfig struct {
 	Interval string `yaml:"interval"` // Sync interval (e.g. "60s", "5m")
 }
 
+// EncryptionConfig encrypts entries before they reach the cloud database; the local database stays plaintext
+type EncryptionConfig struct {
+	Enabled        bool     `yaml:"enabled"`         // Encrypt titles and bodies in the cloud database
+	KeyFile        string   `yaml:"key_file"`        // File holding a base64 256-bit key (openssl rand -base64 32)
+	Passphrase     string   `yaml:"passphrase"`      // Used when there is no key_file; prefer ZENZEN_ENCRYPTION_PASSPHRASE
+	EncryptTags    bool     `yaml:"encrypt_tags"`    // Also encrypt tags
+	OldKeyFiles    []string `yaml:"old_key_files"`   // Retired keys, still read until `zenzen rekey` has run
+	OldPassphrases []string `yaml:"old_passphrases"` // Retired passphrases, likewise
+}
+
+// BackupConfig controls where `zenzen backup` writes archives and the TUI's automatic backups
+type BackupConfig struct {
+	Dir      string `yaml:"dir"`      // Directory for backup archives (default: "backups")
+	Interval string `yaml:"interval"` // Back up automatically this often while the TUI runs (e.g. "1h"); empty disables
+	Keep     int    `yaml:"keep"`     // Archives kept in dir after an automatic backup; 0 keeps them all
+}
+
+// CacheConfig puts a read-through cache in front of the API server's database
+type CacheConfig struct {
+	Enabled    bool   `yaml:"enabled"`     // Cache reads in the API server
+	TTL        string `yaml:"ttl"`         // How long cached reads are served (default: "30s")
+	MaxEntries int    `yaml:"max_entries"` // Entries held in memory (default: 10000)
+}
+
+// TimerConfig controls the API's timer endpoints
+type TimerConfig struct {
+	Policy string `yaml:"policy"` // Starting a timer while another runs: "pause" it (default) or "reject" the start
+}
+
+// WebhooksConfig sends signed HTTP callbacks when entries change
+type WebhooksConfig struct {
+	Subscriptions []WebhookSubscription `yaml:"subscriptions"`
+	MaxAttempts   int                   `yaml:"max_attempts"`    // Attempts before a delivery fails (default: 8)
+	RetryDelay    string                `yaml:"retry_delay"`     // Wait before the first retry, doubling after each (default: "30s")
+	MaxRetryDelay string                `yaml:"max_retry_delay"` // Longest wait between retries (default: "1h")
+}
+
+// WebhookSubscription is one endpoint and the entry events it receives
+type WebhookSubscription struct {
+	ID     string   `yaml:"id"`     // Names it in the delivery log (default: the URL)
+	URL    string   `yaml:"url"`    // Receives a POST per event
+	Secret string   `yaml:"secret"` // Required; signs each body (X-Zenzen-Signature); prefer ZENZEN_WEBHOOK_SECRET_<ID>
+	Events []string `yaml:"events"` // created, updated, completed, deleted; empty for all
+}
+
 // LoadConfig loads the full configuration from file or environment
 func LoadConfig() (*Config, error) {
 	configPath := "config.yaml"
@@ -38,15 +107,51 @@ func LoadConfig() (*Config, error) {
 	}
 
 	// Apply environment variable overrides
+	if driver := os.Getenv("ZENZEN_DB_DRIVER"); driver != "" {
+		cfg.Database.Driver = driver
+	}
+	if path := os.Getenv("ZENZEN_SQLITE_PATH"); path != "" {
+		cfg.Database.Path = path
+	}
 	if localConn := os.Getenv("ZENZEN_LOCAL_DB_CONNECTION"); localConn != "" {
 		cfg.Database.LocalConnection = localConn
 	}
 	if cloudConn := os.Getenv("ZENZEN_CLOUD_DB_CONNECTION"); cloudConn != "" {
 		cfg.Database.CloudConnection = cloudConn
 	}
+	if maxConns := os.Getenv("ZENZEN_DB_POOL_MAX_CONNS"); maxConns != "" {
+		n, err := strconv.ParseInt(maxConns, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ZENZEN_DB_POOL_MAX_CONNS: %w", err)
+		}
+		cfg.Database.Pool.MaxConns = int32(n)
+	}
+	if owner := os.Getenv("ZENZEN_OWNER"); owner != "" {
+		cfg.Database.Owner = owner
+	}
 	if syncEnabled := os.Getenv("ZENZEN_SYNC_ENABLED"); syncEnabled != "" {
 		cfg.Sync.Enabled = syncEnabled == "true"
 	}
+	if backupDir := os.Getenv("ZENZEN_BACKUP_DIR"); backupDir != "" {
+		cfg.Backup.Dir = backupDir
+	}
+	if cacheEnabled := os.Getenv("ZENZEN_CACHE_ENABLED"); cacheEnabled != "" {
+		cfg.Cache.Enabled = cacheEnabled == "true"
+	}
+	if policy := os.Getenv("ZENZEN_TIMER_POLICY"); policy != "" {
+		cfg.Timer.Policy = policy
+	}
+	for i, subscription := range cfg.Webhooks.Subscriptions {
+		if secret := os.Getenv(webhookSecretEnv(subscription.ID)); subscription.ID != "" && secret != "" {
+			cfg.Webhooks.Subscriptions[i].Secret = secret
+		}
+	}
+	if keyFile := os.Getenv("ZENZEN_ENCRYPTION_KEY_FILE"); keyFile != "" {
+		cfg.Encryption.KeyFile = keyFile
+	}
+	if passphrase := os.Getenv("ZENZEN_ENCRYPTION_PASSPHRASE"); passphrase != "" {
+		cfg.Encryption.Passphrase = passphrase
+	}
 
 	return &cfg, nil
 }
@@ -76,6 +181,17 @@ func GetConnectionString() (string, error) {
 	return "", fmt.Errorf("no database connection configured. Set ZENZEN_DB_CONNECTION env var or create config.yaml (see config.example.yaml)")
 }
 
+// LocalDriver returns the configured local storage driver, defaulting to Postgres
+func (c *Config) LocalDriver() (string, error) {
+	switch c.Database.Driver {
+	case "", DriverPostgres:
+		return DriverPostgres, nil
+	case DriverSQLite, DriverMarkdown:
+		return c.Database.Driver, nil
+	}
+	return "", fmt.Errorf("unknown database driver %q (expected %q, %q or %q)", c.Database.Driver, DriverPostgres, DriverSQLite, DriverMarkdown)
+}
+
 // GetSyncInterval returns the sync interval as a time.Duration
 func (c *Config) GetSyncInterval() (time.Duration, error) {
 	if c.Sync.Interval == "" {
@@ -83,3 +199,87 @@ func (c *Config) GetSyncInterval() (time.Duration, error) {
 	}
 	return time.ParseDuration(c.Sync.Interval)
 }
+
+// GetBackupDir returns the directory for backup archives
+func (c *Config) GetBackupDir() string {
+	if c.Backup.Dir == "" {
+		return "backups"
+	}
+	return c.Backup.Dir
+}
+
+// GetBackupInterval returns how often the TUI backs up automatically; zero disables it
+func (c *Config) GetBackupInterval() (time.Duration, error) {
+	if c.Backup.Interval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(c.Backup.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid backup interval: %w", err)
+	}
+	if interval < time.Minute {
+		return 0, fmt.Errorf("backup interval %s is shorter than a minute", interval)
+	}
+	return interval, nil
+}
+
+// GetCacheLimits returns the cache's TTL and entry limit, with defaults filled in
+func (c *Config) GetCacheLimits() (ttl time.Duration, maxEntries int, err error) {
+	ttl, maxEntries = 30*time.Second, 10000
+	if c.Cache.TTL != "" {
+		if ttl, err = time.ParseDuration(c.Cache.TTL); err != nil {
+			return 0, 0, fmt.Errorf("invalid cache ttl: %w", err)
+		}
+	}
+	if c.Cache.MaxEntries > 0 {
+		maxEntries = c.Cache.MaxEntries
+	}
+	return ttl, maxEntries, nil
+}
+
+// GetWebhookRetries returns how many times a delivery is attempted and how long to wait
+// between attempts, with defaults filled in
+func (c *Config) GetWebhookRetries() (maxAttempts int, delay, maxDelay time.Duration, err error) {
+	maxAttempts, delay, maxDelay = 8, 30*time.Second, time.Hour
+	if c.Webhooks.MaxAttempts > 0 {
+		maxAttempts = c.Webhooks.MaxAttempts
+	}
+	if c.Webhooks.RetryDelay != "" {
+		if delay, err = time.ParseDuration(c.Webhooks.RetryDelay); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid webhooks retry_delay: %w", err)
+		}
+	}
+	if c.Webhooks.MaxRetryDelay != "" {
+		if maxDelay, err = time.ParseDuration(c.Webhooks.MaxRetryDelay); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid webhooks max_retry_delay: %w", err)
+		}
+	}
+	return maxAttempts, delay, maxDelay, nil
+}
+
+// webhookSecretEnv names the environment variable that overrides a subscription's secret,
+// e.g. ZENZEN_WEBHOOK_SECRET_TEAM_CHAT for team-chat
+func webhookSecretEnv(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, id)
+	return "ZENZEN_WEBHOOK_SECRET_" + strings.ToUpper(name)
+}
+
+// GetPoolLifetimes returns the pool's connection lifetime and idle timeout; zero means the driver default
+func (c *Config) GetPoolLifetimes() (lifetime, idle time.Duration, err error) {
+	if c.Database.Pool.MaxConnLifetime != "" {
+		if lifetime, err = time.ParseDuration(c.Database.Pool.MaxConnLifetime); err != nil {
+			return 0, 0, fmt.Errorf("invalid max_conn_lifetime: %w", err)
+		}
+	}
+	if c.Database.Pool.MaxConnIdleTime != "" {
+		if idle, err = time.ParseDuration(c.Database.Pool.MaxConnIdleTime); err != nil {
+			return 0, 0, fmt.Errorf("invalid max_conn_idle_time: %w", err)
+		}
+	}
+	return lifetime, idle, nil
+}
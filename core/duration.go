@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseDuration converts strings like "5d", "2h", "1h30m", "2d5h" to time.Duration
+func ParseDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	var total time.Duration
+	var currentNum int
+	hasDigits := false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		if ch >= '0' && ch <= '9' {
+			currentNum = currentNum*10 + int(ch-'0')
+			hasDigits = true
+		} else if ch == 'd' || ch == 'h' || ch == 'm' || ch == 'w' {
+			if !hasDigits {
+				continue
+			}
+
+			switch ch {
+			case 'm':
+				total += time.Duration(currentNum) * time.Minute
+			case 'h':
+				total += time.Duration(currentNum) * time.Hour
+			case 'd':
+				total += time.Duration(currentNum) * DAY
+			case 'w':
+				total += time.Duration(currentNum) * WEEK
+			}
+
+			currentNum = 0
+			hasDigits = false
+		}
+	}
+
+	return total
+}
+
+// ValidateDuration reports whether s is entirely number-unit pairs, such as "1h30m" or "2d",
+// that ParseDuration reads in full. Blank is valid and means no duration.
+func ValidateDuration(s string) error {
+	s = strings.TrimSpace(s)
+	hasDigits := false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch >= '0' && ch <= '9':
+			hasDigits = true
+		case strings.IndexByte("mhdw", ch) >= 0 && hasDigits:
+			hasDigits = false
+		default:
+			return fmt.Errorf("invalid duration %q: use numbers with units w, d, h or m, like 1h30m", s)
+		}
+	}
+	if hasDigits {
+		return fmt.Errorf("invalid duration %q: missing unit after the last number", s)
+	}
+	return nil
+}
+
+// FormatDuration converts time.Duration to a human-readable string like "5d", "1h30m"
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	var result string
+
+	// Weeks
+	weeks := d / WEEK
+	if weeks > 0 {
+		result += fmt.Sprintf("%dw", weeks)
+		d -= weeks * WEEK
+	}
+
+	// Days
+	days := d / DAY
+	if days > 0 {
+		result += fmt.Sprintf("%dd", days)
+		d -= days * DAY
+	}
+
+	// Hours
+	hours := d / time.Hour
+	if hours > 0 {
+		result += fmt.Sprintf("%dh", hours)
+		d -= hours * time.Hour
+	}
+
+	// Minutes
+	minutes := d / time.Minute
+	if minutes > 0 {
+		result += fmt.Sprintf("%dm", minutes)
+	}
+
+	return result
+}
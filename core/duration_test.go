@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		input string
+		want  time.Duration
+	}{
+		{input: "", want: 0},
+		{input: "45m", want: 45 * time.Minute},
+		{input: "1h30m", want: 90 * time.Minute},
+		{input: "2d", want: 2 * DAY},
+		{input: "1w3d", want: WEEK + 3*DAY},
+		{input: " 2d5h ", want: 2*DAY + 5*time.Hour},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			got := ParseDuration(c.input)
+			if got != c.want {
+				t.Errorf("ParseDuration(%q) = %v; want %v", c.input, got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateDuration(t *testing.T) {
+	for _, valid := range []string{"", "45m", "1h30m", " 2d5h ", "1w3d"} {
+		if err := ValidateDuration(valid); err != nil {
+			t.Errorf("ValidateDuration(%q) error = %v; want nil", valid, err)
+		}
+	}
+	for _, invalid := range []string{"90", "1h30", "h", "1.5h", "2 days", "-1h"} {
+		if err := ValidateDuration(invalid); err == nil {
+			t.Errorf("ValidateDuration(%q) error = nil; want an error", invalid)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := []struct {
+		input time.Duration
+		want  string
+	}{
+		{input: 0, want: ""},
+		{input: 90 * time.Minute, want: "1h30m"},
+		{input: WEEK + 3*DAY, want: "1w3d"},
+	}
+	for _, c := range cases {
+		t.Run(c.want, func(t *testing.T) {
+			got := FormatDuration(c.input)
+			if got != c.want {
+				t.Errorf("FormatDuration(%v) = %q; want %q", c.input, got, c.want)
+			}
+			if ParseDuration(got) != c.input {
+				t.Errorf("ParseDuration(FormatDuration(%v)) = %v", c.input, ParseDuration(got))
+			}
+		})
+	}
+}
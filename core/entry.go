@@ -19,6 +19,7 @@ type Entry struct {
 	LastModifiedTimestamp time.Time     `json:"LastModified"`
 	EstimatedDuration     time.Duration `json:"Estimated Duration"`
 	Body                  string        `json:"Body"`
+	Version               int64         `json:"Version"` // Stored version this copy was read at; 0 if never saved
 }
 
 // FieldDisplayNames maps struct field names to human-readable display names
@@ -3,66 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/turnerem/zenzen/config"
 	"github.com/turnerem/zenzen/core"
-	"github.com/turnerem/zenzen/storage"
+	"github.com/turnerem/zenzen/service"
 )
 
-// parseDuration converts strings like "5d", "2h", "1h30m", "2d5h" to time.Duration
-func parseDuration(s string) time.Duration {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return 0
-	}
-
-	var total time.Duration
-	var currentNum int
-	hasDigits := false
-
-	for i := 0; i < len(s); i++ {
-		ch := s[i]
-
-		if ch >= '0' && ch <= '9' {
-			currentNum = currentNum*10 + int(ch-'0')
-			hasDigits = true
-		} else if ch == 'd' || ch == 'h' || ch == 'm' || ch == 'w' {
-			if !hasDigits {
-				continue
-			}
-
-			switch ch {
-			case 'm':
-				total += time.Duration(currentNum) * time.Minute
-			case 'h':
-				total += time.Duration(currentNum) * time.Hour
-			case 'd':
-				total += time.Duration(currentNum) * core.DAY
-			case 'w':
-				total += time.Duration(currentNum) * core.WEEK
-			}
-
-			currentNum = 0
-			hasDigits = false
-		}
-	}
-
-	return total
-}
-
 func createTestData() error {
 	ctx := context.Background()
 
-	// Get database connection string
-	connString, err := config.GetConnectionString()
+	// Load database configuration
+	cfg, err := loadLocalConfig()
 	if err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
 
-	// Initialize SQL storage
-	store, err := storage.NewSQLStorage(ctx, connString)
+	// Initialize local storage
+	store, err := openLocalStore(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("error connecting to database: %w", err)
 	}
@@ -133,9 +90,10 @@ func createTestData() error {
 		},
 	}
 
-	// Save each entry to the database
+	// Save every entry in one transaction, so a failed run leaves nothing half-written
+	batch := service.NewBatch(store)
 	for _, log := range testLogs {
-		entry := core.Entry{
+		batch.Save(core.Entry{
 			ID:                    log.id,
 			Title:                 log.title,
 			Tags:                  log.tags,
@@ -144,12 +102,14 @@ func createTestData() error {
 			EstimatedDuration:     log.estimatedDuration,
 			Body:                  log.body,
 			LastModifiedTimestamp: time.Now(),
-		}
+		})
+	}
 
-		if err := store.SaveEntry(entry); err != nil {
-			return fmt.Errorf("error saving entry %s: %w", log.title, err)
-		}
+	if err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("error saving entries: %w", err)
+	}
 
+	for _, log := range testLogs {
 		fmt.Printf("✓ Added %s\n", log.title)
 	}
 
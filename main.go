@@ -33,6 +33,62 @@ func main() {
 				os.Exit(1)
 			}
 			return
+		case "sync-log":
+			logger.SetupLogger("sync")
+			if err := runSyncLog(os.Args[2:]); err != nil {
+				logger.Error("sync_log_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "webhooks":
+			logger.SetupLogger("sync")
+			if err := runWebhookLog(os.Args[2:]); err != nil {
+				logger.Error("webhooks_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "migrate":
+			logger.SetupLogger("sync")
+			if err := runMigrate(os.Args[2:]); err != nil {
+				logger.Error("migrate_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "tags":
+			logger.SetupLogger("tags")
+			if err := runTags(os.Args[2:]); err != nil {
+				logger.Error("tags_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "rekey":
+			logger.SetupLogger("sync")
+			if err := runRekey(); err != nil {
+				logger.Error("rekey_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "backup":
+			logger.SetupLogger("setup")
+			if err := runBackup(os.Args[2:]); err != nil {
+				logger.Error("backup_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "restore":
+			logger.SetupLogger("setup")
+			if err := runRestore(os.Args[2:]); err != nil {
+				logger.Error("restore_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
+		case "claim":
+			logger.SetupLogger("setup")
+			if err := runClaim(os.Args[2:]); err != nil {
+				logger.Error("claim_command_failed", "error", err.Error())
+				os.Exit(1)
+			}
+			return
 		case "api":
 			logger.SetupLogger("api")
 			if err := runAPIServer(); err != nil {
@@ -56,7 +112,9 @@ func main() {
 		defer logFile.Close()
 	}
 
-	ctx := context.Background()
+	// Cancelled when the TUI exits so in-flight queries and the sync loop stop
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Load full configuration
 	cfg, err := config.LoadConfig()
@@ -65,23 +123,23 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Get local connection string (with fallback to legacy format)
-	localConnString := cfg.Database.LocalConnection
-	if localConnString == "" {
-		localConnString = cfg.Database.ConnectionString
-	}
-	if localConnString == "" {
-		logger.Error("no_database_configured", "message", "Set local_connection in config.yaml")
+	// Initialize local storage (Postgres or SQLite)
+	localStore, err := openLocalStore(ctx, cfg)
+	if err != nil {
+		logger.Error("local_database_connection_failed", "error", err.Error())
 		os.Exit(1)
 	}
+	defer localStore.Close(ctx)
 
-	// Initialize local SQL storage
-	localStore, err := storage.NewSQLStorage(ctx, localConnString)
+	// Fire the configured webhooks for edits made in the TUI and entries sync pulls in
+	entryStore, dispatcher, err := withWebhooks(cfg, localStore, localStore)
 	if err != nil {
-		logger.Error("local_database_connection_failed", "error", err.Error())
+		logger.Error("webhooks_config_invalid", "error", err.Error())
 		os.Exit(1)
 	}
-	defer localStore.Close(ctx)
+	if dispatcher != nil {
+		go dispatcher.Run(ctx)
+	}
 
 	// Initialize cloud storage and sync service if configured
 	var syncService *service.SyncService
@@ -98,6 +156,13 @@ func main() {
 		} else {
 			defer cloudStore.Close(ctx)
 
+			// Refuse to sync rather than push plaintext when the key can't be loaded
+			cloud, err := encryptCloudStore(cfg, cloudStore)
+			if err != nil {
+				logger.Error("cloud_encryption_failed", "error", err.Error())
+				os.Exit(1)
+			}
+
 			// Get sync interval
 			interval, err := cfg.GetSyncInterval()
 			if err != nil {
@@ -106,37 +171,200 @@ func main() {
 			}
 
 			// Create and start sync service
-			syncService = service.NewSyncService(localStore, cloudStore, interval)
-			syncService.Start()
+			syncService = service.NewSyncService(entryStore, cloud, interval)
+			syncService.SetOwner(cfg.Database.Owner)
+			if history, ok := localStore.(service.SyncLog); ok {
+				syncService.SetSyncLog(history)
+			}
+			syncService.Start(ctx)
 			defer syncService.Stop()
 		}
 	}
 
+	// Back up the local database in the background while the TUI runs, if configured
+	if interval, err := cfg.GetBackupInterval(); err != nil {
+		logger.Warn("invalid_backup_interval", "interval", cfg.Backup.Interval, "error", err.Error(), "mode", "no_automatic_backups")
+	} else if interval > 0 {
+		go runAutoBackups(ctx, localStore, cfg.GetBackupDir(), interval, cfg.Backup.Keep)
+	}
+
+	// Act as database.owner in the workspaces the local database shares, while the local
+	// database's own entries stay with the default owner, as sync expects
+	principal := service.Principal{ID: cfg.Database.Owner, Method: "local"}
+	userCtx := service.WithOwner(service.WithPrincipal(ctx, principal), service.DefaultOwner)
+
+	// The callbacks work on the open workspace, the user's own entries until they switch
+	scope := userCtx
+
 	// Initialize notes service (using local storage)
-	notes := service.NewNotes(localStore)
+	notes := service.NewNotes(entryStore)
 
 	// Load all notes
-	if err := notes.LoadAll(); err != nil {
+	if err := notes.LoadAll(scope); err != nil {
 		logger.Error("notes_load_failed", "error", err.Error())
 		os.Exit(1)
 	}
 
 	// Create callbacks for TUI
 	saveEntryFn := func(entry core.Entry) error {
-		return notes.SaveEntry(entry)
+		return notes.SaveEntry(scope, entry)
 	}
 
 	deleteEntryFn := func(id string) error {
-		return notes.Delete(id)
+		return notes.Delete(scope, id)
+	}
+
+	queryEntriesFn := func(q service.Query) ([]core.Entry, error) {
+		result, err := notes.ListLogsSorted(scope, q)
+		return result.Entries, err
+	}
+
+	searchEntriesFn := func(text string) ([]service.SearchResult, error) {
+		return notes.Search(scope, text, 0)
+	}
+
+	listTagsFn := func() ([]service.Tag, error) {
+		return notes.ListTags(scope)
+	}
+
+	renameTagFn := func(from, to string) error {
+		return notes.MergeTags(scope, []string{from}, to)
+	}
+
+	// Show changes made by other processes, such as the API server, as they happen.
+	// Each workspace has its own subscription, dropped when switching away.
+	stopChanges := func() {}
+	subscribe := func(scoped context.Context) <-chan service.ChangeEvent {
+		feed, ok := localStore.(service.ChangeFeed)
+		if !ok {
+			return nil
+		}
+		subCtx, cancel := context.WithCancel(scoped)
+		events, err := feed.Subscribe(subCtx)
+		if err != nil {
+			cancel()
+			logger.Warn("change_feed_unavailable", "error", err.Error())
+			return nil
+		}
+		stopChanges = cancel
+		return events
+	}
+	changes := subscribe(scope)
+
+	// Workspaces shared through the local database, if it keeps them
+	var listWorkspacesFn ListWorkspacesFunc
+	var switchWorkspaceFn SwitchWorkspaceFunc
+	if workspaces, err := service.Workspaces(localStore); err == nil {
+		listWorkspacesFn = func() ([]service.Membership, error) {
+			return service.ListWorkspaces(userCtx, workspaces)
+		}
+		switchWorkspaceFn = func(workspaceID string) (map[string]core.Entry, <-chan service.ChangeEvent, error) {
+			next := userCtx
+			if workspaceID != "" {
+				opened, _, err := service.OpenWorkspace(userCtx, workspaces, workspaceID, service.RoleViewer)
+				if err != nil {
+					return nil, nil, err
+				}
+				next = opened
+			}
+			if err := notes.LoadAll(next); err != nil {
+				return nil, nil, err
+			}
+			stopChanges()
+			scope = next
+			return notes.Entries, subscribe(scope), nil
+		}
 	}
 
 	// Start interactive TUI
-	if err := StartTUI(notes.Entries, saveEntryFn, deleteEntryFn); err != nil {
+	if err := StartTUI(notes.Entries, saveEntryFn, deleteEntryFn, queryEntriesFn, searchEntriesFn, listTagsFn, renameTagFn, listWorkspacesFn, switchWorkspaceFn, changes); err != nil {
 		logger.Error("tui_start_failed", "error", err.Error())
 		os.Exit(1)
 	}
 }
 
+// LocalStore is the storage used on this machine.
+// Backends that also implement service.SyncLog record sync history.
+type LocalStore interface {
+	service.Store
+	Close(ctx context.Context) error
+}
+
+// openLocalStore opens the local storage backend selected by database.driver
+func openLocalStore(ctx context.Context, cfg *config.Config) (LocalStore, error) {
+	driver, err := cfg.LocalDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	switch driver {
+	case config.DriverSQLite:
+		logger.Info("local_storage_selected", "driver", driver, "path", cfg.Database.Path)
+		store, err := storage.NewSQLiteStorage(ctx, cfg.Database.Path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case config.DriverMarkdown:
+		logger.Info("local_storage_selected", "driver", driver, "path", cfg.Database.Path)
+		store, err := storage.NewMarkdownStorage(cfg.Database.Path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	// Get local connection string (with fallback to legacy format)
+	connString := cfg.Database.LocalConnection
+	if connString == "" {
+		connString = cfg.Database.ConnectionString
+	}
+	if connString == "" {
+		return nil, fmt.Errorf("no local database connection configured. Set local_connection (or driver: sqlite and path) in config.yaml")
+	}
+
+	// Pooled, since background sync shares the store with the TUI
+	poolCfg, err := storagePoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("local_storage_selected", "driver", driver)
+	store, err := storage.NewPooledSQLStorage(ctx, connString, poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// storagePoolConfig converts database.pool from config.yaml
+func storagePoolConfig(cfg *config.Config) (storage.PoolConfig, error) {
+	lifetime, idle, err := cfg.GetPoolLifetimes()
+	if err != nil {
+		return storage.PoolConfig{}, err
+	}
+	return storage.PoolConfig{
+		MaxConns:        cfg.Database.Pool.MaxConns,
+		MinConns:        cfg.Database.Pool.MinConns,
+		MaxConnLifetime: lifetime,
+		MaxConnIdleTime: idle,
+	}, nil
+}
+
+// loadLocalConfig loads config.yaml, falling back to ZENZEN_DB_CONNECTION when there is none
+func loadLocalConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err == nil {
+		return cfg, nil
+	}
+
+	connString, connErr := config.GetConnectionString()
+	if connErr != nil {
+		return nil, connErr
+	}
+	return &config.Config{Database: config.DatabaseConfig{LocalConnection: connString}}, nil
+}
+
 // runSyncNow performs an immediate one-time sync between local and cloud databases
 func runSyncNow() error {
 	ctx := context.Background()
@@ -154,17 +382,8 @@ func runSyncNow() error {
 		return nil
 	}
 
-	// Get local connection
-	localConnString := cfg.Database.LocalConnection
-	if localConnString == "" {
-		localConnString = cfg.Database.ConnectionString
-	}
-	if localConnString == "" {
-		return fmt.Errorf("no local database connection configured")
-	}
-
 	logger.Info("connecting_to_database", "type", "local")
-	localStore, err := storage.NewSQLStorage(ctx, localConnString)
+	localStore, err := openLocalStore(ctx, cfg)
 	if err != nil {
 		logger.Error("local_connection_failed", "error", err.Error())
 		return fmt.Errorf("error connecting to local database: %w", err)
@@ -179,13 +398,184 @@ func runSyncNow() error {
 	}
 	defer cloudStore.Close(ctx)
 
+	cloud, err := encryptCloudStore(cfg, cloudStore)
+	if err != nil {
+		return err
+	}
+
+	// Entries pulled in fire the configured webhooks
+	entryStore, dispatcher, err := withWebhooks(cfg, localStore, localStore)
+	if err != nil {
+		return err
+	}
+
 	// Create sync service
-	syncService := service.NewSyncService(localStore, cloudStore, 0)
+	syncService := service.NewSyncService(entryStore, cloud, 0)
+	syncService.SetOwner(cfg.Database.Owner)
+	if history, ok := localStore.(service.SyncLog); ok {
+		syncService.SetSyncLog(history)
+	}
 
 	// Perform sync
-	syncService.SyncNow()
+	syncService.SyncNow(ctx)
 	logger.Info("manual_sync_completed")
 
+	// Make one attempt at the deliveries now; failures are retried by the TUI or API server
+	if dispatcher != nil {
+		if _, err := dispatcher.DeliverDue(ctx); err != nil {
+			logger.Warn("webhook_dispatch_failed", "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// withWebhooks wraps store so entry changes fire the webhooks in config.yaml, queueing their
+// deliveries in queueStore's database. With no webhooks configured it returns store and a
+// nil dispatcher; a database that can't queue deliveries is logged and left without them.
+func withWebhooks(cfg *config.Config, store, queueStore service.Store) (service.Store, *service.WebhookDispatcher, error) {
+	if len(cfg.Webhooks.Subscriptions) == 0 {
+		return store, nil, nil
+	}
+
+	subscriptions, err := webhookSubscriptions(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxAttempts, delay, maxDelay, err := cfg.GetWebhookRetries()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queue, err := service.Deliveries(queueStore)
+	if err != nil {
+		logger.Warn("webhooks_unavailable", "driver", cfg.Database.Driver, "error", err.Error())
+		return store, nil, nil
+	}
+
+	logger.Info("webhooks_enabled", "subscriptions", len(subscriptions))
+	opts := service.WebhookOptions{MaxAttempts: maxAttempts, BaseDelay: delay, MaxDelay: maxDelay}
+	return service.NewWebhookStore(store, queue, subscriptions), service.NewWebhookDispatcher(queue, subscriptions, opts), nil
+}
+
+// webhookSubscriptions converts webhooks.subscriptions from config.yaml
+func webhookSubscriptions(cfg *config.Config) ([]service.Subscription, error) {
+	subscriptions := make([]service.Subscription, 0, len(cfg.Webhooks.Subscriptions))
+	seen := make(map[string]bool)
+	for _, configured := range cfg.Webhooks.Subscriptions {
+		if configured.URL == "" {
+			return nil, fmt.Errorf("webhook subscription %q has no url", configured.ID)
+		}
+		subscription := service.Subscription{ID: configured.ID, URL: configured.URL, Secret: configured.Secret}
+		if subscription.ID == "" {
+			subscription.ID = configured.URL
+		}
+		if subscription.Secret == "" {
+			return nil, fmt.Errorf("webhook subscription %q has no secret", subscription.ID)
+		}
+		if seen[subscription.ID] {
+			return nil, fmt.Errorf("webhook subscription %q is configured twice", subscription.ID)
+		}
+		seen[subscription.ID] = true
+
+		for _, name := range configured.Events {
+			event, err := service.ParseWebhookEvent(name)
+			if err != nil {
+				return nil, fmt.Errorf("webhook subscription %q: %w", subscription.ID, err)
+			}
+			subscription.Events = append(subscription.Events, event)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// encryptCloudStore wraps the cloud store so entries are encrypted before they leave this
+// machine, when encryption is enabled in config.yaml
+func encryptCloudStore(cfg *config.Config, store service.Store) (service.Store, error) {
+	if !cfg.Encryption.Enabled {
+		return store, nil
+	}
+
+	opts, err := encryptionOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("cloud_encryption_enabled", "encrypt_tags", opts.EncryptTags, "old_keys", len(opts.OldKeys))
+	return storage.NewEncryptedStore(store, opts), nil
+}
+
+// encryptionOptions loads the current key, from key_file or else passphrase, and any retired keys
+func encryptionOptions(cfg *config.Config) (storage.EncryptionOptions, error) {
+	enc := cfg.Encryption
+	opts := storage.EncryptionOptions{EncryptTags: enc.EncryptTags}
+
+	var err error
+	switch {
+	case enc.KeyFile != "":
+		opts.Key, err = storage.ReadKeyFile(enc.KeyFile)
+	case enc.Passphrase != "":
+		opts.Key, err = storage.KeyFromPassphrase(enc.Passphrase)
+	default:
+		err = fmt.Errorf("encryption is enabled but neither key_file nor passphrase (ZENZEN_ENCRYPTION_PASSPHRASE) is set")
+	}
+	if err != nil {
+		return storage.EncryptionOptions{}, err
+	}
+
+	for _, path := range enc.OldKeyFiles {
+		key, err := storage.ReadKeyFile(path)
+		if err != nil {
+			return storage.EncryptionOptions{}, err
+		}
+		opts.OldKeys = append(opts.OldKeys, key)
+	}
+	for _, passphrase := range enc.OldPassphrases {
+		key, err := storage.KeyFromPassphrase(passphrase)
+		if err != nil {
+			return storage.EncryptionOptions{}, err
+		}
+		opts.OldKeys = append(opts.OldKeys, key)
+	}
+
+	return opts, nil
+}
+
+// runRekey re-encrypts the cloud database with the current key. Run it after enabling
+// encryption to encrypt existing entries, or after rotating keys so old ones can be retired.
+func runRekey() error {
+	ctx := context.Background()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.Encryption.Enabled {
+		return fmt.Errorf("encryption is not enabled in config.yaml")
+	}
+	if cfg.Database.CloudConnection == "" {
+		return fmt.Errorf("no cloud_connection configured")
+	}
+
+	opts, err := encryptionOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	cloudStore, err := storage.NewSQLStorage(ctx, cfg.Database.CloudConnection)
+	if err != nil {
+		return fmt.Errorf("error connecting to cloud database: %w", err)
+	}
+	defer cloudStore.Close(ctx)
+
+	// Only this owner's entries; others sharing the database have keys of their own
+	n, err := storage.NewEncryptedStore(cloudStore, opts).Rekey(service.WithOwner(ctx, cfg.Database.Owner))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted %d entries with the current key\n", n)
+	logger.Info("rekey_completed", "entries", n)
+
 	return nil
 }
 
@@ -217,14 +607,50 @@ func runAPIServer() error {
 
 	logger.Info("api_database_selected", "type", dbType)
 
-	// Connect to database
-	store, err := storage.NewSQLStorage(ctx, connString)
+	poolCfg, err := storagePoolConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Connect to database; handlers run concurrently, so use a connection pool
+	store, err := storage.NewPooledSQLStorage(ctx, connString, poolCfg)
 	if err != nil {
 		logger.Error("api_database_connection_failed", "error", err.Error())
 		return fmt.Errorf("error connecting to database: %w", err)
 	}
 	defer store.Close(ctx)
 
+	// The cloud database holds ciphertext when encryption is enabled
+	var apiStore service.Store = store
+	if dbType == "cloud" {
+		if apiStore, err = encryptCloudStore(cfg, store); err != nil {
+			return err
+		}
+	}
+
+	// Fire the configured webhooks for writes made through the API, queued in its database
+	apiStore, dispatcher, err := withWebhooks(cfg, apiStore, store)
+	if err != nil {
+		return err
+	}
+	if dispatcher != nil {
+		go dispatcher.Run(ctx)
+	}
+
+	// Cache plaintext reads in memory, dropping them whenever any process writes an entry
+	if cfg.Cache.Enabled {
+		ttl, maxEntries, err := cfg.GetCacheLimits()
+		if err != nil {
+			return err
+		}
+		cached := service.NewCachedStore(apiStore, service.CacheOptions{TTL: ttl, MaxEntries: maxEntries})
+		if err := cached.InvalidateOnChanges(ctx); err != nil {
+			logger.Warn("cache_change_feed_unavailable", "error", err.Error(), "mode", "ttl_only")
+		}
+		logger.Info("api_cache_enabled", "ttl", ttl.String(), "max_entries", maxEntries)
+		apiStore = cached
+	}
+
 	// Get API key from environment or generate a warning
 	apiKey := os.Getenv("ZENZEN_API_KEY")
 	if apiKey == "" {
@@ -238,8 +664,23 @@ func runAPIServer() error {
 		fmt.Sscanf(portStr, "%d", &port)
 	}
 
-	// Create API server
-	apiServer := api.NewServer(store, apiKey)
+	// Create API server; Cognito users get their own entries, the API key gets database.owner's
+	apiServer := api.NewServer(apiStore, apiKey)
+	apiServer.SetAPIKeyOwner(cfg.Database.Owner)
+
+	timerPolicy, err := service.ParseTimerPolicy(cfg.Timer.Policy)
+	if err != nil {
+		return fmt.Errorf("invalid timer.policy: %w", err)
+	}
+	apiServer.SetTimerPolicy(timerPolicy)
+	if dispatcher != nil {
+		apiServer.SetWebhookDeliveries(store)
+	}
+
+	// Stream every process's entry changes to GET /api/v1/events
+	if err := apiServer.FollowChanges(ctx); err != nil {
+		logger.Warn("events_change_feed_unavailable", "error", err.Error(), "mode", "api_writes_only")
+	}
 
 	// Configure Cognito if environment variables are set
 	cognitoRegion := os.Getenv("COGNITO_REGION")
@@ -264,3 +705,588 @@ func runAPIServer() error {
 
 	return apiServer.Start(port)
 }
+
+// runSyncLog prints recent sync runs, or the sync history of a single entry
+// Usage: zenzen sync-log [-n limit] [entry-id]
+func runSyncLog(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("sync-log", flag.ContinueOnError)
+	limit := flags.Int("n", 20, "number of records to show")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	localStore, err := openLocalStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error connecting to local database: %w", err)
+	}
+	defer localStore.Close(ctx)
+
+	history, ok := localStore.(service.SyncLog)
+	if !ok {
+		return fmt.Errorf("the %s storage driver does not record sync history", cfg.Database.Driver)
+	}
+
+	// Per-entry history
+	if entryID := flags.Arg(0); entryID != "" {
+		records, err := history.EntrySyncHistory(ctx, entryID, *limit)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			fmt.Printf("No sync history for entry %s\n", entryID)
+			return nil
+		}
+
+		fmt.Printf("Sync history for entry %s:\n", entryID)
+		for _, record := range records {
+			line := fmt.Sprintf("  %s  %-4s  run #%d",
+				record.SyncedAt.Local().Format("2006-01-02 15:04:05"), record.Direction, record.RunID)
+			if record.Error != "" {
+				line += "  error: " + record.Error
+			}
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	// Recent runs
+	runs, err := history.ListSyncRuns(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("No sync runs recorded yet")
+		return nil
+	}
+
+	fmt.Println("Recent sync runs:")
+	for _, run := range runs {
+		fmt.Printf("  #%-5d %s  %6dms  pushed %d  pulled %d  conflicts %d  errors %d\n",
+			run.ID,
+			run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			run.Duration().Milliseconds(),
+			run.Pushed,
+			run.Pulled,
+			run.Conflicts,
+			len(run.Errors))
+		for _, msg := range run.Errors {
+			fmt.Printf("         ! %s\n", msg)
+		}
+	}
+
+	return nil
+}
+
+// runWebhookLog prints the most recent webhook deliveries queued in the local database
+// Usage: zenzen webhooks [-n limit]
+func runWebhookLog(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("webhooks", flag.ContinueOnError)
+	limit := flags.Int("n", 20, "number of deliveries to show")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	localStore, err := openLocalStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error connecting to local database: %w", err)
+	}
+	defer localStore.Close(ctx)
+
+	queue, err := service.Deliveries(localStore)
+	if err != nil {
+		return fmt.Errorf("the %s storage driver does not queue webhook deliveries", cfg.Database.Driver)
+	}
+
+	deliveries, err := queue.ListDeliveries(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		fmt.Println("No webhook deliveries yet")
+		return nil
+	}
+
+	fmt.Println("Recent webhook deliveries:")
+	for _, delivery := range deliveries {
+		line := fmt.Sprintf("  %s  %-9s  %-9s  %-20s  entry %s  attempts %d",
+			delivery.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			delivery.Status,
+			delivery.Event,
+			delivery.SubscriptionID,
+			delivery.EntryID,
+			delivery.Attempts)
+		if delivery.Status == service.DeliveryPending && delivery.Attempts > 0 {
+			line += "  next " + delivery.NextAttemptAt.Local().Format("15:04:05")
+		}
+		fmt.Println(line)
+		if delivery.LastError != "" {
+			fmt.Printf("         ! %s\n", delivery.LastError)
+		}
+	}
+
+	return nil
+}
+
+// runTags lists, renames and merges tags on the local database
+// Usage: zenzen tags [list] | rename <old> <new> | merge <into> <tag>...
+// Renaming or merging a tag moves the tags beneath it too.
+func runTags(args []string) error {
+	ctx := context.Background()
+
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch {
+	case action == "list":
+	case action == "rename" && len(args) == 3:
+	case action == "merge" && len(args) >= 3:
+	default:
+		return fmt.Errorf("usage: zenzen tags [list] | rename <old> <new> | merge <into> <tag>...")
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	localStore, err := openLocalStore(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("error connecting to local database: %w", err)
+	}
+	defer localStore.Close(ctx)
+
+	switch action {
+	case "rename":
+		changed, err := service.RenameTag(ctx, localStore, args[1], args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Renamed %s to %s on %d entries\n", args[1], args[2], len(changed))
+		logger.Info("tag_renamed", "from", args[1], "to", args[2], "entries", len(changed))
+		return nil
+
+	case "merge":
+		changed, err := service.MergeTags(ctx, localStore, args[2:], args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Merged %d tags into %s on %d entries\n", len(args[2:]), args[1], len(changed))
+		logger.Info("tags_merged", "from", args[2:], "to", args[1], "entries", len(changed))
+		return nil
+	}
+
+	tags, err := service.ListTags(ctx, localStore)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		fmt.Println("No tags yet")
+		return nil
+	}
+
+	// Bias rolls up each subtree, so namespaces get a line even when they aren't tags
+	entries, err := localStore.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	biases := make(map[string]service.TagBias)
+	for _, bias := range service.BiasByTag(entries) {
+		biases[bias.Tag] = bias
+	}
+
+	fmt.Printf("  %-24s %5s  %s\n", "tag", "uses", "actual/estimate")
+	for _, tag := range service.TagTree(tags) {
+		ratio := fmt.Sprintf("%15s", "-")
+		if bias := biases[tag.Name]; bias.Entries > 0 {
+			ratio = fmt.Sprintf("%15.2f", bias.Ratio())
+		}
+		line := fmt.Sprintf("  %-24s %5d  %s", tag.Name, tag.Count, ratio)
+		if tag.Color != "" {
+			line += "  " + tag.Color
+		}
+		if tag.Description != "" {
+			line += "  " + tag.Description
+		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// runBackup writes every entry, tag's metadata and sync run to a backup archive
+// Usage: zenzen backup [-cloud] [-o file]
+func runBackup(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	cloud := flags.Bool("cloud", false, "back up the cloud database instead of the local one")
+	output := flags.String("o", "", "archive to write (default: a timestamped file in backup.dir)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+	if *cloud {
+		ctx = service.WithOwner(ctx, cfg.Database.Owner)
+	}
+
+	store, closeStore, err := openBackupStore(ctx, cfg, *cloud)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	path := *output
+	if path == "" {
+		if path, err = service.WriteBackupFile(ctx, store, cfg.GetBackupDir()); err != nil {
+			return err
+		}
+	} else {
+		backup, err := service.CreateBackup(ctx, store)
+		if err != nil {
+			return err
+		}
+		if err := service.WriteBackupTo(path, backup); err != nil {
+			return err
+		}
+	}
+
+	backup, err := service.ReadBackupFile(path)
+	if err != nil {
+		return fmt.Errorf("backup written to %s failed verification: %w", path, err)
+	}
+	fmt.Printf("Backed up %d entries, %d tags and %d sync runs to %s\n", len(backup.Entries), len(backup.Tags), len(backup.SyncRuns), path)
+	logger.Info("backup_written", "path", path, "entries", len(backup.Entries))
+
+	return nil
+}
+
+// runRestore loads a backup archive into the local or cloud database.
+// Replacing writes a backup of what is there first, so a mistaken restore can be undone.
+// Usage: zenzen restore [-cloud] [-mode merge|replace] <file>
+func runRestore(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	cloud := flags.Bool("cloud", false, "restore into the cloud database instead of the local one")
+	modeFlag := flags.String("mode", string(service.RestoreMerge), "merge keeps newer entries; replace makes the database match the backup")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: zenzen restore [-cloud] [-mode merge|replace] <file>")
+	}
+	mode, err := service.ParseRestoreMode(*modeFlag)
+	if err != nil {
+		return err
+	}
+
+	// Verify the archive before touching the database
+	backup, err := service.ReadBackupFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+	if *cloud {
+		ctx = service.WithOwner(ctx, cfg.Database.Owner)
+	}
+
+	store, closeStore, err := openBackupStore(ctx, cfg, *cloud)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	if mode == service.RestoreReplace {
+		path, err := service.WriteBackupFile(ctx, store, cfg.GetBackupDir())
+		if err != nil {
+			return fmt.Errorf("failed to back up before replacing: %w", err)
+		}
+		fmt.Printf("Backed up the current database to %s\n", path)
+	}
+
+	result, err := service.Restore(ctx, store, backup, mode)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s: %d added, %d updated, %d unchanged, %d deleted, %d tags, %d sync runs\n",
+		flags.Arg(0), backup.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		result.Added, result.Updated, result.Unchanged, result.Deleted, result.Tags, result.SyncRuns)
+	logger.Info("backup_restored", "path", flags.Arg(0), "restore_mode", mode, "added", result.Added, "updated", result.Updated, "deleted", result.Deleted)
+
+	return nil
+}
+
+// openBackupStore opens the local database, or the cloud one as sync sees it, decrypted.
+// Callers scope ctx to database.owner for the cloud, as sync does.
+func openBackupStore(ctx context.Context, cfg *config.Config, cloud bool) (service.Store, func(), error) {
+	if !cloud {
+		localStore, err := openLocalStore(ctx, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error connecting to local database: %w", err)
+		}
+		return localStore, func() { localStore.Close(ctx) }, nil
+	}
+
+	if cfg.Database.CloudConnection == "" {
+		return nil, nil, fmt.Errorf("no cloud_connection configured")
+	}
+	cloudStore, err := storage.NewSQLStorage(ctx, cfg.Database.CloudConnection)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to cloud database: %w", err)
+	}
+	store, err := encryptCloudStore(cfg, cloudStore)
+	if err != nil {
+		cloudStore.Close(ctx)
+		return nil, nil, err
+	}
+	return store, func() { cloudStore.Close(ctx) }, nil
+}
+
+// runClaim hands entries and tag metadata from one owner to another, by default the entries
+// stored before entries had owners, so a single-user database can be shared with a team.
+// Usage: zenzen claim [-cloud] [-from owner] <owner>
+func runClaim(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("claim", flag.ContinueOnError)
+	cloud := flags.Bool("cloud", false, "claim entries in the cloud database instead of the local one")
+	from := flags.String("from", service.DefaultOwner, "owner to take entries from (default: entries without an owner)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || flags.Arg(0) == *from {
+		return fmt.Errorf("usage: zenzen claim [-cloud] [-from owner] <owner>")
+	}
+	to := flags.Arg(0)
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	// Ciphertext doesn't depend on the owner, so the raw store is used even when encrypting
+	var store service.Store
+	if *cloud {
+		if cfg.Database.CloudConnection == "" {
+			return fmt.Errorf("no cloud_connection configured")
+		}
+		cloudStore, err := storage.NewSQLStorage(ctx, cfg.Database.CloudConnection)
+		if err != nil {
+			return fmt.Errorf("error connecting to cloud database: %w", err)
+		}
+		defer cloudStore.Close(ctx)
+		store = cloudStore
+	} else {
+		localStore, err := openLocalStore(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("error connecting to local database: %w", err)
+		}
+		defer localStore.Close(ctx)
+		store = localStore
+	}
+
+	migrator, ok := store.(service.OwnerMigrator)
+	if !ok {
+		return fmt.Errorf("the %s storage driver does not support owners", cfg.Database.Driver)
+	}
+	moved, err := migrator.ReassignOwner(ctx, *from, to)
+	if err != nil {
+		return err
+	}
+
+	left, err := store.GetAll(service.WithOwner(ctx, *from))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d entries to %s\n", moved, to)
+	if len(left) > 0 {
+		fmt.Printf("%d entries were left behind because %s already has entries with the same IDs\n", len(left), to)
+	}
+	logger.Info("entries_claimed", "to", to, "entries", moved, "left", len(left))
+
+	return nil
+}
+
+// runAutoBackups backs store up to dir every interval until ctx is cancelled, keeping the newest keep archives
+func runAutoBackups(ctx context.Context, store service.Store, dir string, interval time.Duration, keep int) {
+	logger.Info("automatic_backups_enabled", "dir", dir, "interval", interval.String(), "keep", keep)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := service.WriteBackupFile(ctx, store, dir)
+			if err != nil {
+				logger.Error("automatic_backup_failed", "error", err.Error())
+				continue
+			}
+			logger.Info("automatic_backup_written", "path", path)
+
+			pruned, err := service.PruneBackups(dir, keep)
+			if err != nil {
+				logger.Warn("backup_prune_failed", "error", err.Error())
+			} else if len(pruned) > 0 {
+				logger.Info("old_backups_pruned", "count", len(pruned))
+			}
+		}
+	}
+}
+
+// runMigrate applies, rolls back or reports schema migrations on the local and cloud databases
+// Usage: zenzen migrate [-local|-cloud] up|down [steps]|status
+func runMigrate(args []string) error {
+	ctx := context.Background()
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	localOnly := flags.Bool("local", false, "only migrate the local database")
+	cloudOnly := flags.Bool("cloud", false, "only migrate the cloud database")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	action := flags.Arg(0)
+	steps := 1
+	switch action {
+	case "up", "status":
+	case "down":
+		if n := flags.Arg(1); n != "" {
+			if _, err := fmt.Sscanf(n, "%d", &steps); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", n)
+			}
+		}
+	default:
+		return fmt.Errorf("usage: zenzen migrate [-local|-cloud] up|down [steps]|status")
+	}
+
+	cfg, err := loadLocalConfig()
+	if err != nil {
+		return err
+	}
+
+	type target struct {
+		name string
+		open func() (*storage.Migrator, error)
+	}
+	var targets []target
+
+	if !*cloudOnly {
+		driver, err := cfg.LocalDriver()
+		if err != nil {
+			return err
+		}
+		switch driver {
+		case config.DriverSQLite:
+			targets = append(targets, target{"local", func() (*storage.Migrator, error) {
+				return storage.NewSQLiteMigrator(ctx, cfg.Database.Path)
+			}})
+		case config.DriverMarkdown:
+			fmt.Println("local: markdown storage has no schema to migrate")
+		default:
+			connString := cfg.Database.LocalConnection
+			if connString == "" {
+				connString = cfg.Database.ConnectionString
+			}
+			targets = append(targets, target{"local", func() (*storage.Migrator, error) {
+				return storage.NewSQLMigrator(ctx, connString)
+			}})
+		}
+	}
+	if !*localOnly && cfg.Database.CloudConnection != "" {
+		targets = append(targets, target{"cloud", func() (*storage.Migrator, error) {
+			return storage.NewSQLMigrator(ctx, cfg.Database.CloudConnection)
+		}})
+	}
+
+	for _, t := range targets {
+		migrator, err := t.open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		err = runMigrateAction(ctx, migrator, t.name, action, steps)
+		migrator.Close(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+
+	return nil
+}
+
+// runMigrateAction performs a single migrate action against one database
+func runMigrateAction(ctx context.Context, migrator *storage.Migrator, name, action string, steps int) error {
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("%s: applied %04d_%s\n", name, migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Printf("%s: already up to date (version %d)\n", name, migrator.LatestVersion())
+		}
+		logger.Info("migrate_up_completed", "database", name, "applied", len(applied))
+
+	case "down":
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("%s: rolled back %04d_%s\n", name, migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info("migrate_down_completed", "database", name, "rolled_back", len(rolledBack))
+
+	case "status":
+		current, err := migrator.CurrentVersion(ctx)
+		if err != nil {
+			return err
+		}
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: version %d (latest %d)\n", name, current, migrator.LatestVersion())
+		for _, status := range statuses {
+			if status.Applied {
+				fmt.Printf("  [x] %04d_%s  applied %s\n", status.Version, status.Name, status.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("  [ ] %04d_%s\n", status.Version, status.Name)
+			}
+		}
+		if current > migrator.LatestVersion() {
+			fmt.Printf("  ! database was migrated by a newer zenzen; upgrade before using it\n")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,710 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+const (
+	// BackupFormat identifies a zenzen backup archive
+	BackupFormat = "zenzen-backup"
+
+	// BackupVersion is the archive version written by WriteBackup. ReadBackup reads this
+	// version and older ones, and refuses newer ones rather than dropping what it can't read.
+	BackupVersion = 1
+
+	// BackupExtension is the file extension of backup archives
+	BackupExtension = ".tar.gz"
+
+	// Files inside the archive. The manifest comes first and holds the checksums of the rest.
+	backupManifestFile = "manifest.json"
+	backupEntriesFile  = "entries.jsonl"
+	backupTagsFile     = "tags.json"
+	backupSyncRunsFile = "sync_runs.json"
+
+	// backupHistoryLimit is high enough to read a store's whole sync history
+	backupHistoryLimit = 1 << 30
+)
+
+var (
+	// ErrInvalidBackup is returned for archives that aren't zenzen backups or fail their checksums
+	ErrInvalidBackup = errors.New("invalid backup archive")
+
+	// ErrUnsupportedBackupVersion is returned for archives written by a newer zenzen
+	ErrUnsupportedBackupVersion = errors.New("unsupported backup version")
+)
+
+// RestoreMode decides what Restore does with entries already in the store
+type RestoreMode string
+
+const (
+	// RestoreMerge keeps entries missing from the backup and only overwrites those the
+	// backup holds a newer copy of, by last modified time
+	RestoreMerge RestoreMode = "merge"
+
+	// RestoreReplace makes the store match the backup, deleting entries it doesn't hold
+	RestoreReplace RestoreMode = "replace"
+)
+
+// ParseRestoreMode parses "merge" or "replace"
+func ParseRestoreMode(s string) (RestoreMode, error) {
+	switch mode := RestoreMode(s); mode {
+	case RestoreMerge, RestoreReplace:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown restore mode %q (expected %q or %q)", s, RestoreMerge, RestoreReplace)
+}
+
+// BackupManifest describes a backup archive
+type BackupManifest struct {
+	Format    string            `json:"format"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Entries   int               `json:"entries"`
+	Tags      int               `json:"tags"`
+	SyncRuns  int               `json:"sync_runs"`
+	Checksums map[string]string `json:"checksums"` // SHA-256 of each other file, by name
+}
+
+// Backup is everything a store holds: entries, tag metadata and sync history
+type Backup struct {
+	CreatedAt time.Time
+	Entries   []core.Entry
+	Tags      []Tag     // Only tags with metadata; counts come from the entries
+	SyncRuns  []SyncRun // Empty when the store doesn't keep a SyncLog
+}
+
+// RestoreResult counts what Restore changed
+type RestoreResult struct {
+	Added     int // Entries not in the store before
+	Updated   int // Entries overwritten by the backup's copy
+	Unchanged int // Entries left alone because the store's copy is as new or newer
+	Deleted   int // Entries not in the backup, in RestoreReplace mode
+	Tags      int // Tags whose metadata was restored
+	SyncRuns  int // Sync runs added to the history
+}
+
+// backupEntry is an entry as written to entries.jsonl. It has its own field names so the
+// archive format doesn't change with core.Entry; versions aren't kept as they belong to a store.
+type backupEntry struct {
+	ID                string    `json:"id"`
+	Title             string    `json:"title"`
+	Tags              []string  `json:"tags,omitempty"`
+	StartedAt         time.Time `json:"started_at,omitzero"`
+	EndedAt           time.Time `json:"ended_at,omitzero"`
+	LastModified      time.Time `json:"last_modified,omitzero"`
+	EstimatedDuration string    `json:"estimated_duration,omitempty"` // As a Go duration, e.g. "1h30m0s"
+	Body              string    `json:"body"`
+}
+
+// backupTag is tag metadata as written to tags.json
+type backupTag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"`
+}
+
+// backupSyncRun is a sync run as written to sync_runs.json
+type backupSyncRun struct {
+	StartedAt time.Time             `json:"started_at"`
+	EndedAt   time.Time             `json:"ended_at,omitzero"`
+	Pushed    int                   `json:"pushed"`
+	Pulled    int                   `json:"pulled"`
+	Conflicts int                   `json:"conflicts"`
+	Errors    []string              `json:"errors,omitempty"`
+	Entries   []backupSyncRunRecord `json:"entries,omitempty"`
+}
+
+// backupSyncRunRecord is one entry touched by a sync run
+type backupSyncRunRecord struct {
+	EntryID   string    `json:"entry_id"`
+	Direction string    `json:"direction"`
+	SyncedAt  time.Time `json:"synced_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// CreateBackup reads everything from store. Tag metadata is included when the store is a
+// TagStore and sync history when it is a SyncLog; records for entries that have since been
+// deleted are left out.
+func CreateBackup(ctx context.Context, store Store) (Backup, error) {
+	entries, err := store.GetAll(ctx)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	backup := Backup{CreatedAt: time.Now().UTC(), Entries: make([]core.Entry, 0, len(entries))}
+	for _, entry := range entries {
+		backup.Entries = append(backup.Entries, entry)
+	}
+	sort.Slice(backup.Entries, func(i, j int) bool { return backup.Entries[i].ID < backup.Entries[j].ID })
+
+	if tagStore, ok := store.(TagStore); ok {
+		tags, err := tagStore.ListTags(ctx)
+		if err != nil {
+			return Backup{}, err
+		}
+		for _, tag := range tags {
+			if tag.Description != "" || tag.Color != "" {
+				backup.Tags = append(backup.Tags, Tag{Name: tag.Name, Description: tag.Description, Color: tag.Color})
+			}
+		}
+		sortTags(backup.Tags)
+	}
+
+	if history, ok := store.(SyncLog); ok {
+		if backup.SyncRuns, err = readSyncHistory(ctx, history, backup.Entries); err != nil {
+			return Backup{}, err
+		}
+	}
+
+	return backup, nil
+}
+
+// readSyncHistory reads every sync run, oldest first, with the records of the given entries.
+// Records are read for all runs at once rather than entry by entry.
+func readSyncHistory(ctx context.Context, history SyncLog, entries []core.Entry) ([]SyncRun, error) {
+	runs, err := history.ListSyncRuns(ctx, backupHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(runs)
+
+	byID := make(map[int64]int, len(runs))
+	runIDs := make([]int64, 0, len(runs))
+	for i, run := range runs {
+		byID[run.ID] = i
+		runIDs = append(runIDs, run.ID)
+	}
+	kept := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		kept[entry.ID] = true
+	}
+
+	records, err := history.SyncRunEntries(ctx, runIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if i, ok := byID[record.RunID]; ok && kept[record.EntryID] {
+			runs[i].Entries = append(runs[i].Entries, record)
+		}
+	}
+	for i := range runs {
+		sort.SliceStable(runs[i].Entries, func(a, b int) bool {
+			return runs[i].Entries[a].SyncedAt.Before(runs[i].Entries[b].SyncedAt)
+		})
+	}
+
+	return runs, nil
+}
+
+// WriteBackup writes a backup as a gzip-compressed tar archive
+func WriteBackup(w io.Writer, backup Backup) error {
+	files, err := encodeBackupFiles(backup)
+	if err != nil {
+		return err
+	}
+
+	manifest := BackupManifest{
+		Format:    BackupFormat,
+		Version:   BackupVersion,
+		CreatedAt: backup.CreatedAt,
+		Entries:   len(backup.Entries),
+		Tags:      len(backup.Tags),
+		SyncRuns:  len(backup.SyncRuns),
+		Checksums: make(map[string]string, len(files)),
+	}
+	for _, file := range files {
+		manifest.Checksums[file.name] = checksum(file.data)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range append([]backupFile{{backupManifestFile, manifestData}}, files...) {
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0600,
+			Size:    int64(len(file.data)),
+			ModTime: backup.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write backup archive: %w", err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return fmt.Errorf("failed to write backup archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write backup archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write backup archive: %w", err)
+	}
+
+	return nil
+}
+
+// backupFile is one file inside a backup archive
+type backupFile struct {
+	name string
+	data []byte
+}
+
+// encodeBackupFiles encodes everything but the manifest
+func encodeBackupFiles(backup Backup) ([]backupFile, error) {
+	var entries bytes.Buffer
+	encoder := json.NewEncoder(&entries)
+	for _, entry := range backup.Entries {
+		record := backupEntry{
+			ID:           entry.ID,
+			Title:        entry.Title,
+			Tags:         entry.Tags,
+			StartedAt:    entry.StartedAtTimestamp,
+			EndedAt:      entry.EndedAtTimestamp,
+			LastModified: entry.LastModifiedTimestamp,
+			Body:         entry.Body,
+		}
+		if entry.EstimatedDuration != 0 {
+			record.EstimatedDuration = entry.EstimatedDuration.String()
+		}
+		if err := encoder.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to encode entry %s: %w", entry.ID, err)
+		}
+	}
+
+	tags := make([]backupTag, 0, len(backup.Tags))
+	for _, tag := range backup.Tags {
+		tags = append(tags, backupTag{Name: tag.Name, Description: tag.Description, Color: tag.Color})
+	}
+	tagsData, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+
+	runs := make([]backupSyncRun, 0, len(backup.SyncRuns))
+	for _, run := range backup.SyncRuns {
+		record := backupSyncRun{
+			StartedAt: run.StartedAt,
+			EndedAt:   run.EndedAt,
+			Pushed:    run.Pushed,
+			Pulled:    run.Pulled,
+			Conflicts: run.Conflicts,
+			Errors:    run.Errors,
+		}
+		for _, entry := range run.Entries {
+			record.Entries = append(record.Entries, backupSyncRunRecord{
+				EntryID:   entry.EntryID,
+				Direction: entry.Direction,
+				SyncedAt:  entry.SyncedAt,
+				Error:     entry.Error,
+			})
+		}
+		runs = append(runs, record)
+	}
+	runsData, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sync runs: %w", err)
+	}
+
+	return []backupFile{
+		{backupEntriesFile, entries.Bytes()},
+		{backupTagsFile, tagsData},
+		{backupSyncRunsFile, runsData},
+	}, nil
+}
+
+// ReadBackup reads an archive written by WriteBackup, verifying every file against the
+// manifest's checksums before decoding anything
+func ReadBackup(r io.Reader) (Backup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Backup{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Backup{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return Backup{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		files[header.Name] = data
+	}
+
+	var manifest BackupManifest
+	manifestData, ok := files[backupManifestFile]
+	if !ok {
+		return Backup{}, fmt.Errorf("%w: no %s", ErrInvalidBackup, backupManifestFile)
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return Backup{}, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, backupManifestFile, err)
+	}
+	if manifest.Format != BackupFormat {
+		return Backup{}, fmt.Errorf("%w: format %q", ErrInvalidBackup, manifest.Format)
+	}
+	if manifest.Version > BackupVersion {
+		return Backup{}, fmt.Errorf("%w: version %d is newer than this zenzen reads (%d)", ErrUnsupportedBackupVersion, manifest.Version, BackupVersion)
+	}
+
+	for _, name := range []string{backupEntriesFile, backupTagsFile, backupSyncRunsFile} {
+		data, ok := files[name]
+		if !ok {
+			return Backup{}, fmt.Errorf("%w: no %s", ErrInvalidBackup, name)
+		}
+		if want := manifest.Checksums[name]; checksum(data) != want {
+			return Backup{}, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBackup, name)
+		}
+	}
+
+	backup, err := decodeBackupFiles(files)
+	if err != nil {
+		return Backup{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	backup.CreatedAt = manifest.CreatedAt
+
+	if len(backup.Entries) != manifest.Entries || len(backup.Tags) != manifest.Tags || len(backup.SyncRuns) != manifest.SyncRuns {
+		return Backup{}, fmt.Errorf("%w: contents don't match the manifest", ErrInvalidBackup)
+	}
+
+	return backup, nil
+}
+
+// decodeBackupFiles decodes the verified files of an archive
+func decodeBackupFiles(files map[string][]byte) (Backup, error) {
+	var backup Backup
+
+	decoder := json.NewDecoder(bytes.NewReader(files[backupEntriesFile]))
+	for {
+		var record backupEntry
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return Backup{}, fmt.Errorf("%s: %w", backupEntriesFile, err)
+		}
+		if record.ID == "" {
+			return Backup{}, fmt.Errorf("%s: entry without an id", backupEntriesFile)
+		}
+
+		entry := core.Entry{
+			ID:                    record.ID,
+			Title:                 record.Title,
+			Tags:                  record.Tags,
+			StartedAtTimestamp:    record.StartedAt,
+			EndedAtTimestamp:      record.EndedAt,
+			LastModifiedTimestamp: record.LastModified,
+			Body:                  record.Body,
+		}
+		if record.EstimatedDuration != "" {
+			estimate, err := time.ParseDuration(record.EstimatedDuration)
+			if err != nil {
+				return Backup{}, fmt.Errorf("%s: entry %s: %w", backupEntriesFile, record.ID, err)
+			}
+			entry.EstimatedDuration = estimate
+		}
+		backup.Entries = append(backup.Entries, entry)
+	}
+
+	var tags []backupTag
+	if err := json.Unmarshal(files[backupTagsFile], &tags); err != nil {
+		return Backup{}, fmt.Errorf("%s: %w", backupTagsFile, err)
+	}
+	for _, tag := range tags {
+		backup.Tags = append(backup.Tags, Tag{Name: tag.Name, Description: tag.Description, Color: tag.Color})
+	}
+
+	var runs []backupSyncRun
+	if err := json.Unmarshal(files[backupSyncRunsFile], &runs); err != nil {
+		return Backup{}, fmt.Errorf("%s: %w", backupSyncRunsFile, err)
+	}
+	for _, record := range runs {
+		run := SyncRun{
+			StartedAt: record.StartedAt,
+			EndedAt:   record.EndedAt,
+			Pushed:    record.Pushed,
+			Pulled:    record.Pulled,
+			Conflicts: record.Conflicts,
+			Errors:    record.Errors,
+		}
+		for _, entry := range record.Entries {
+			run.Entries = append(run.Entries, SyncRunEntry{
+				EntryID:   entry.EntryID,
+				Direction: entry.Direction,
+				SyncedAt:  entry.SyncedAt,
+				Error:     entry.Error,
+			})
+		}
+		backup.SyncRuns = append(backup.SyncRuns, run)
+	}
+
+	return backup, nil
+}
+
+// Restore loads a backup into store.
+// Entries are saved conditionally against the versions just read, so an entry edited during
+// the restore fails it with ErrConflict and it can be retried. In RestoreReplace mode the
+// entries the backup doesn't hold are deleted in the same transaction, then tag metadata
+// the backup doesn't hold. Tag metadata is skipped for stores that aren't a TagStore, and sync history for
+// stores that aren't a SyncLog; runs already in the history are not added twice.
+func Restore(ctx context.Context, store Store, backup Backup, mode RestoreMode) (RestoreResult, error) {
+	var result RestoreResult
+
+	current, err := store.GetAll(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	batch := NewBatch(store)
+	inBackup := make(map[string]bool, len(backup.Entries))
+	for _, entry := range backup.Entries {
+		inBackup[entry.ID] = true
+
+		existing, ok := current[entry.ID]
+		switch {
+		case !ok:
+			result.Added++
+		case mode == RestoreMerge && !entry.LastModifiedTimestamp.After(existing.LastModifiedTimestamp):
+			result.Unchanged++
+			continue
+		default:
+			result.Updated++
+		}
+		entry.Version = existing.Version
+		batch.Save(entry)
+	}
+	if mode == RestoreReplace {
+		for id := range current {
+			if !inBackup[id] {
+				batch.Delete(id)
+				result.Deleted++
+			}
+		}
+	}
+	if err := batch.Commit(ctx); err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to restore entries: %w", err)
+	}
+
+	if tagStore, ok := store.(TagStore); ok {
+		if result.Tags, err = restoreTags(ctx, tagStore, backup.Tags, mode); err != nil {
+			return result, err
+		}
+	}
+
+	if history, ok := store.(SyncLog); ok {
+		if result.SyncRuns, err = restoreSyncHistory(ctx, history, backup.SyncRuns); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// restoreTags saves the backup's tag metadata. Merging keeps metadata the store already has.
+func restoreTags(ctx context.Context, store TagStore, tags []Tag, mode RestoreMode) (int, error) {
+	existing, err := store.ListTags(ctx)
+	if err != nil {
+		return 0, err
+	}
+	hasMetadata := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		hasMetadata[tag.Name] = tag.Description != "" || tag.Color != ""
+	}
+
+	restored := 0
+	inBackup := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		inBackup[tag.Name] = true
+		if mode == RestoreMerge && hasMetadata[tag.Name] {
+			continue
+		}
+		if err := tag.Validate(); err != nil {
+			return restored, err
+		}
+		if err := store.SaveTag(ctx, tag); err != nil {
+			return restored, fmt.Errorf("failed to restore tag %s: %w", tag.Name, err)
+		}
+		restored++
+	}
+
+	if mode == RestoreReplace {
+		for name, ok := range hasMetadata {
+			if ok && !inBackup[name] {
+				if err := store.DeleteTag(ctx, name); err != nil {
+					return restored, fmt.Errorf("failed to delete tag %s: %w", name, err)
+				}
+			}
+		}
+	}
+
+	return restored, nil
+}
+
+// restoreSyncHistory records the runs not already in the history, matched by start time
+func restoreSyncHistory(ctx context.Context, history SyncLog, runs []SyncRun) (int, error) {
+	if len(runs) == 0 {
+		return 0, nil
+	}
+
+	existing, err := history.ListSyncRuns(ctx, backupHistoryLimit)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, run := range existing {
+		seen[syncRunKey(run)] = true
+	}
+
+	restored := 0
+	for _, run := range runs {
+		if seen[syncRunKey(run)] {
+			continue
+		}
+		if err := history.RecordSyncRun(ctx, run); err != nil {
+			return restored, fmt.Errorf("failed to restore sync run: %w", err)
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// syncRunKey identifies a sync run across stores; IDs are assigned by each store.
+// Start times are compared to the second as backends keep different precisions.
+func syncRunKey(run SyncRun) string {
+	return run.StartedAt.UTC().Format(time.RFC3339)
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteBackupFile backs store up to a new timestamped archive in dir, returning its path
+func WriteBackupFile(ctx context.Context, store Store, dir string) (string, error) {
+	backup, err := CreateBackup(ctx, store)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	// Two backups in the same second get numbered rather than overwriting one another
+	name := "zenzen-" + backup.CreatedAt.Format("20060102-150405")
+	path := filepath.Join(dir, name+BackupExtension)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s.%d%s", name, n, BackupExtension))
+	}
+	if err := WriteBackupTo(path, backup); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// WriteBackupTo writes a backup archive to path. It writes a temporary file first and
+// renames it, so a failed backup never leaves a truncated archive behind.
+func WriteBackupTo(path string, backup Backup) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".zenzen-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteBackup(tmp, backup); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return nil
+}
+
+// ReadBackupFile reads and verifies a backup archive
+func ReadBackupFile(path string) (Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+	return ReadBackup(f)
+}
+
+// PruneBackups deletes all but the newest keep archives written by WriteBackupFile in dir.
+// A keep of 0 or less keeps everything.
+func PruneBackups(dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "zenzen-*"+BackupExtension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(matches) <= keep {
+		return nil, nil
+	}
+
+	// Oldest first: by timestamp, then by number for backups made in the same second
+	sort.SliceStable(matches, func(i, j int) bool {
+		iName, iNumber := backupOrder(matches[i])
+		jName, jNumber := backupOrder(matches[j])
+		if iName != jName {
+			return iName < jName
+		}
+		return iNumber < jNumber
+	})
+	pruned := matches[:len(matches)-keep]
+	for _, path := range pruned {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return pruned, nil
+}
+
+// backupOrder splits an archive path from WriteBackupFile into its timestamped name and its
+// number, which is 1 for the first backup of a second
+func backupOrder(path string) (string, int) {
+	name := strings.TrimSuffix(filepath.Base(path), BackupExtension)
+	if base, suffix, ok := strings.Cut(name, "."); ok {
+		if n, err := strconv.Atoi(suffix); err == nil {
+			return base, n
+		}
+	}
+	return name, 1
+}
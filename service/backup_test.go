@@ -0,0 +1,229 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func backupEntries() []core.Entry {
+	older := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	return []core.Entry{
+		{ID: "1", Title: "Write report", Tags: []string{"work/reports"}, StartedAtTimestamp: older, EndedAtTimestamp: older.Add(time.Hour), LastModifiedTimestamp: older, EstimatedDuration: 90 * time.Minute, Body: "Quarterly"},
+		{ID: "2", Title: "Read", LastModifiedTimestamp: older},
+	}
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(backupEntries()...)
+	assertNilError(t, store.SaveTag(ctx, Tag{Name: "work", Description: "Day job", Color: "#336699"}))
+
+	backup, err := CreateBackup(ctx, store)
+	assertNilError(t, err)
+
+	var buf bytes.Buffer
+	assertNilError(t, WriteBackup(&buf, backup))
+	read, err := ReadBackup(&buf)
+	assertNilError(t, err)
+
+	assertEquality(t, len(read.Entries), 2)
+	want := backupEntries()[0]
+	got := read.Entries[0]
+	if got.ID != want.ID || got.Title != want.Title || got.Body != want.Body || got.EstimatedDuration != want.EstimatedDuration ||
+		!got.EndedAtTimestamp.Equal(want.EndedAtTimestamp) || !got.LastModifiedTimestamp.Equal(want.LastModifiedTimestamp) {
+		t.Errorf("entry = %+v; want %+v", got, want)
+	}
+	assertEquality(t, got.Tags, want.Tags)
+	assertEquality(t, read.Tags, []Tag{{Name: "work", Description: "Day job", Color: "#336699"}})
+	assertEquality(t, read.Entries[1].Tags == nil, true)
+
+	// Restoring into an empty store brings back entries and metadata
+	target := NewMemoryStore()
+	result, err := Restore(ctx, target, read, RestoreMerge)
+	assertNilError(t, err)
+	assertEquality(t, result, RestoreResult{Added: 2, Tags: 1})
+
+	tags, err := ListTags(ctx, target)
+	assertNilError(t, err)
+	assertEquality(t, tags[0], Tag{Name: "work", Description: "Day job", Color: "#336699"})
+}
+
+func TestRestoreModes(t *testing.T) {
+	ctx := context.Background()
+	backup := Backup{Entries: backupEntries()}
+	later := backup.Entries[0].LastModifiedTimestamp.Add(time.Hour)
+
+	newStore := func() *MemoryStore {
+		return NewMemoryStore(
+			core.Entry{ID: "1", Title: "Edited since", LastModifiedTimestamp: later},
+			core.Entry{ID: "2", Title: "Older", LastModifiedTimestamp: later.Add(-2 * time.Hour)},
+			core.Entry{ID: "3", Title: "Not in backup", LastModifiedTimestamp: later},
+		)
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		store := newStore()
+		result, err := Restore(ctx, store, backup, RestoreMerge)
+		assertNilError(t, err)
+		assertEquality(t, result, RestoreResult{Updated: 1, Unchanged: 1})
+
+		entries, _ := store.GetAll(ctx)
+		assertEquality(t, entries["1"].Title, "Edited since") // Newer than the backup's copy
+		assertEquality(t, entries["2"].Title, "Read")
+		assertEquality(t, entries["3"].Title, "Not in backup")
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		store := newStore()
+		result, err := Restore(ctx, store, backup, RestoreReplace)
+		assertNilError(t, err)
+		assertEquality(t, result, RestoreResult{Updated: 2, Deleted: 1})
+
+		entries, _ := store.GetAll(ctx)
+		assertEquality(t, len(entries), 2)
+		assertEquality(t, entries["1"].Title, "Write report")
+	})
+}
+
+func TestRestoreReplacesTagMetadata(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	assertNilError(t, store.SaveTag(ctx, Tag{Name: "old", Description: "Gone after replace"}))
+	assertNilError(t, store.SaveTag(ctx, Tag{Name: "work", Description: "Local"}))
+
+	backup := Backup{Tags: []Tag{{Name: "work", Description: "From backup"}}}
+
+	result, err := Restore(ctx, store, backup, RestoreMerge)
+	assertNilError(t, err)
+	assertEquality(t, result.Tags, 0) // Merging keeps local metadata
+
+	_, err = Restore(ctx, store, backup, RestoreReplace)
+	assertNilError(t, err)
+	tags, _ := ListTags(ctx, store)
+	assertEquality(t, tags, []Tag{{Name: "work", Description: "From backup"}})
+}
+
+func TestRestoreSkipsSyncRunsAlreadyRecorded(t *testing.T) {
+	ctx := context.Background()
+	started := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	history := &recordingSyncLog{runs: []SyncRun{{ID: 7, StartedAt: started}}}
+
+	added, err := restoreSyncHistory(ctx, history, []SyncRun{
+		{StartedAt: started.Add(300 * time.Millisecond)}, // Same run, stored with less precision
+		{StartedAt: started.Add(time.Minute)},
+	})
+	assertNilError(t, err)
+	assertEquality(t, added, 1)
+	assertEquality(t, len(history.runs), 2)
+}
+
+func TestReadBackupRejectsTampering(t *testing.T) {
+	var buf bytes.Buffer
+	assertNilError(t, WriteBackup(&buf, Backup{Entries: backupEntries()}))
+
+	// Rewrite the archive with one changed byte in the entries
+	files := readArchive(t, buf.Bytes())
+	files[backupEntriesFile] = bytes.Replace(files[backupEntriesFile], []byte("Quarterly"), []byte("Quarterlz"), 1)
+	if _, err := ReadBackup(bytes.NewReader(writeArchive(t, files))); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("ReadBackup() error = %v; want ErrInvalidBackup", err)
+	}
+
+	// An archive from a newer zenzen is refused
+	files = readArchive(t, buf.Bytes())
+	files[backupManifestFile] = bytes.Replace(files[backupManifestFile], []byte(`"version": 1`), []byte(`"version": 2`), 1)
+	if _, err := ReadBackup(bytes.NewReader(writeArchive(t, files))); !errors.Is(err, ErrUnsupportedBackupVersion) {
+		t.Errorf("ReadBackup() error = %v; want ErrUnsupportedBackupVersion", err)
+	}
+
+	if _, err := ReadBackup(bytes.NewReader([]byte("not an archive"))); !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("ReadBackup() error = %v; want ErrInvalidBackup", err)
+	}
+}
+
+func TestBackupFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewMemoryStore(backupEntries()...)
+
+	path, err := WriteBackupFile(ctx, store, dir)
+	assertNilError(t, err)
+	backup, err := ReadBackupFile(path)
+	assertNilError(t, err)
+	assertEquality(t, len(backup.Entries), 2)
+
+	// A second backup in the same second doesn't overwrite the first
+	again, err := WriteBackupFile(ctx, store, dir)
+	assertNilError(t, err)
+	if again == path {
+		t.Errorf("WriteBackupFile() wrote %s twice", path)
+	}
+
+	// The tenth backup of a second is newer than the second one
+	for _, name := range []string{"zenzen-20250101-000000.tar.gz", "zenzen-20250102-000000.2.tar.gz", "zenzen-20250102-000000.10.tar.gz", "zenzen-20250102-000000.tar.gz", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0600)
+	}
+	pruned, err := PruneBackups(dir, 3)
+	assertNilError(t, err)
+	assertEquality(t, pruned, []string{
+		filepath.Join(dir, "zenzen-20250101-000000.tar.gz"),
+		filepath.Join(dir, "zenzen-20250102-000000.tar.gz"),
+		filepath.Join(dir, "zenzen-20250102-000000.2.tar.gz"),
+	})
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("PruneBackups() removed a file it didn't write")
+	}
+}
+
+func TestParseRestoreMode(t *testing.T) {
+	mode, err := ParseRestoreMode("replace")
+	assertNilError(t, err)
+	assertEquality(t, mode, RestoreReplace)
+
+	if _, err := ParseRestoreMode("overwrite"); err == nil {
+		t.Errorf("expected an unknown mode to be rejected")
+	}
+}
+
+// readArchive returns the files in a backup archive, by name
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	assertNilError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		assertNilError(t, err)
+		files[header.Name], _ = io.ReadAll(tr)
+	}
+}
+
+// writeArchive writes files into a backup archive, manifest first
+func writeArchive(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{backupManifestFile, backupEntriesFile, backupTagsFile, backupSyncRunsFile} {
+		assertNilError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name]))}))
+		tw.Write(files[name])
+	}
+	assertNilError(t, tw.Close())
+	assertNilError(t, gz.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+// Batch is a unit of work over a Store: stage saves and deletes, then Commit them together.
+// Staging an ID again replaces whatever was staged for it before, so the saves and deletes
+// never overlap.
+type Batch struct {
+	store   Store
+	saves   []core.Entry
+	deletes []string
+	staged  map[string]bool // IDs with a staged save or delete
+}
+
+// NewBatch starts an empty batch against store
+func NewBatch(store Store) *Batch {
+	return &Batch{store: store, staged: make(map[string]bool)}
+}
+
+// Save stages an entry to be saved
+func (b *Batch) Save(entry core.Entry) {
+	b.unstage(entry.ID)
+	b.saves = append(b.saves, entry)
+}
+
+// Delete stages an entry to be deleted
+func (b *Batch) Delete(id string) {
+	b.unstage(id)
+	b.deletes = append(b.deletes, id)
+}
+
+// Len returns the number of staged changes
+func (b *Batch) Len() int {
+	return len(b.saves) + len(b.deletes)
+}
+
+// Commit writes the staged saves and deletes with one WriteEntries call, so either all of
+// them are applied or none are. After a failure everything stays staged for a retry.
+func (b *Batch) Commit(ctx context.Context) error {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+
+	if err := b.store.WriteEntries(ctx, b.saves, b.deletes); err != nil {
+		return err
+	}
+
+	b.saves = nil
+	b.deletes = nil
+	clear(b.staged)
+	return nil
+}
+
+// unstage drops any change already staged for id, making way for a new one
+func (b *Batch) unstage(id string) {
+	if !b.staged[id] {
+		b.staged[id] = true
+		return
+	}
+
+	saves := b.saves[:0]
+	for _, entry := range b.saves {
+		if entry.ID != id {
+			saves = append(saves, entry)
+		}
+	}
+	b.saves = saves
+
+	deletes := b.deletes[:0]
+	for _, staged := range b.deletes {
+		if staged != id {
+			deletes = append(deletes, staged)
+		}
+	}
+	b.deletes = deletes
+}
+
+// UniqueEntries keeps the last copy of each ID, in the order the IDs first appear.
+// Batch writes use it so a repeated ID behaves the same on every backend.
+func UniqueEntries(entries []core.Entry) []core.Entry {
+	index := make(map[string]int, len(entries))
+	unique := make([]core.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if i, ok := index[entry.ID]; ok {
+			unique[i] = entry
+			continue
+		}
+		index[entry.ID] = len(unique)
+		unique = append(unique, entry)
+	}
+	return unique
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestBatch(t *testing.T) {
+	t.Run("commit applies saves and deletes", func(t *testing.T) {
+		store := newSeededStore()
+		batch := NewBatch(store)
+
+		batch.Save(core.Entry{ID: "3", Title: "New"})
+		batch.Delete(k8sLog.ID)
+		assertEquality(t, batch.Len(), 2)
+
+		assertNilError(t, batch.Commit(context.Background()))
+		assertEquality(t, batch.Len(), 0)
+
+		entries, _ := store.GetAll(context.Background())
+		if _, ok := entries[k8sLog.ID]; ok {
+			t.Errorf("expected entry %s to be deleted", k8sLog.ID)
+		}
+		assertEquality(t, entries["3"].Title, "New")
+	})
+
+	t.Run("later change to an ID replaces the earlier one", func(t *testing.T) {
+		store := newSeededStore()
+		batch := NewBatch(store)
+
+		batch.Delete(k8sLog.ID)
+		batch.Save(core.Entry{ID: k8sLog.ID, Title: "Kept after all"})
+		assertEquality(t, batch.Len(), 1)
+
+		assertNilError(t, batch.Commit(context.Background()))
+
+		entries, _ := store.GetAll(context.Background())
+		assertEquality(t, entries[k8sLog.ID].Title, "Kept after all")
+	})
+
+	t.Run("failed commit stays staged", func(t *testing.T) {
+		store := NewMemoryStore()
+		batch := NewBatch(store)
+		batch.Save(core.Entry{ID: "1", Title: "Deleted elsewhere", Version: 4})
+
+		if err := batch.Commit(context.Background()); !errors.Is(err, ErrConflict) {
+			t.Fatalf("expected ErrConflict, got %v", err)
+		}
+		assertEquality(t, batch.Len(), 1)
+	})
+}
+
+func TestUniqueEntries(t *testing.T) {
+	entries := UniqueEntries([]core.Entry{
+		{ID: "a", Title: "first a"},
+		{ID: "b", Title: "b"},
+		{ID: "a", Title: "last a"},
+	})
+
+	assertEquality(t, entries, []core.Entry{
+		{ID: "a", Title: "last a"},
+		{ID: "b", Title: "b"},
+	})
+}
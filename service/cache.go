@@ -0,0 +1,441 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+// CacheOptions configures a CachedStore
+type CacheOptions struct {
+	TTL        time.Duration // How long a cached read is served; 0 caches until the next write
+	MaxEntries int           // Entries held across the snapshot and cached pages; 0 is unlimited
+}
+
+// CacheStats counts how a CachedStore's reads were served
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64 // Writes and change events that emptied the cache
+	Evictions     uint64 // Cached snapshots and pages dropped to stay within MaxEntries
+	Entries       int    // Entries held now
+}
+
+// HitRatio returns the share of reads served from the cache; 0 before any reads
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CachedStore is a read-through cache in front of any Store.
+// GetAll is cached as a snapshot of every entry, which also answers Query in memory while
+// it is fresh; otherwise each Query page is cached on its own. Snapshots and pages are
+// cached per owner and evicted least recently used first. Every write through the store
+// empties the cache. Writes made elsewhere are only seen once the TTL expires, or at once
+// with InvalidateOnChanges.
+// Search, tag metadata and change feeds are passed on to the wrapped store.
+type CachedStore struct {
+	store Store
+	opts  CacheOptions
+
+	mu         sync.Mutex
+	generation uint64                   // Bumped by every invalidation, so loads started before one aren't cached
+	pages      map[string]*list.Element // Cached snapshots and Query results by owner and query
+	lru        *list.List               // Of *cachedPage, most recently used first
+	size       int                      // Entries in the snapshots and pages
+	stats      CacheStats
+}
+
+// cachedPage is one owner's cached GetAll snapshot or Query result
+type cachedPage struct {
+	key      string
+	all      map[string]core.Entry // Set for snapshots
+	result   QueryResult           // Set for Query results
+	loadedAt time.Time
+}
+
+// len returns how many entries the page holds
+func (p *cachedPage) len() int {
+	if p.all != nil {
+		return len(p.all)
+	}
+	return len(p.result.Entries)
+}
+
+// NewCachedStore wraps store with a read-through cache
+func NewCachedStore(store Store, opts CacheOptions) *CachedStore {
+	return &CachedStore{
+		store: store,
+		opts:  opts,
+		pages: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// GetAll returns every entry of the context's owner, from their snapshot while it is fresh
+func (c *CachedStore) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	key := snapshotKey(ctx)
+
+	c.mu.Lock()
+	if page := c.lookup(key); page != nil {
+		c.stats.Hits++
+		entries := copyEntries(page.all)
+		c.mu.Unlock()
+		return entries, nil
+	}
+	c.stats.Misses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	entries, err := c.store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(generation, &cachedPage{key: key, all: copyEntries(entries), loadedAt: time.Now()})
+	return entries, nil
+}
+
+// Query answers from the owner's snapshot or a cached page while fresh, and caches the page otherwise
+func (c *CachedStore) Query(ctx context.Context, q Query) (QueryResult, error) {
+	query, err := json.Marshal(q)
+	if err != nil {
+		return c.store.Query(ctx, q)
+	}
+	key := OwnerFromContext(ctx) + "\x00" + string(query)
+
+	c.mu.Lock()
+	if page := c.lookup(snapshotKey(ctx)); page != nil {
+		c.stats.Hits++
+		result, err := QueryEntries(page.all, q)
+		c.mu.Unlock()
+		return result, err
+	}
+	if page := c.lookup(key); page != nil {
+		c.stats.Hits++
+		result := copyResult(page.result)
+		c.mu.Unlock()
+		return result, nil
+	}
+	c.stats.Misses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	result, err := c.store.Query(ctx, q)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(generation, &cachedPage{key: key, result: copyResult(result), loadedAt: time.Now()})
+	return result, nil
+}
+
+// SaveEntry saves through to the wrapped store and empties the cache
+func (c *CachedStore) SaveEntry(ctx context.Context, entry core.Entry) error {
+	defer c.Invalidate()
+	return c.store.SaveEntry(ctx, entry)
+}
+
+// SaveEntryVersion saves through to the wrapped store, empties the cache and returns the new version
+func (c *CachedStore) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	defer c.Invalidate()
+	return c.store.SaveEntryVersion(ctx, entry)
+}
+
+// SaveEntries saves through to the wrapped store and empties the cache
+func (c *CachedStore) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	defer c.Invalidate()
+	return c.store.SaveEntries(ctx, entries)
+}
+
+// DeleteEntry deletes through the wrapped store and empties the cache
+func (c *CachedStore) DeleteEntry(ctx context.Context, id string) error {
+	defer c.Invalidate()
+	return c.store.DeleteEntry(ctx, id)
+}
+
+// DeleteEntries deletes through the wrapped store and empties the cache
+func (c *CachedStore) DeleteEntries(ctx context.Context, ids []string) error {
+	defer c.Invalidate()
+	return c.store.DeleteEntries(ctx, ids)
+}
+
+// DeleteEntryVersion deletes through the wrapped store and empties the cache
+func (c *CachedStore) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	defer c.Invalidate()
+	return c.store.DeleteEntryVersion(ctx, id, version)
+}
+
+// WriteEntries writes through to the wrapped store and empties the cache
+func (c *CachedStore) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	defer c.Invalidate()
+	return c.store.WriteEntries(ctx, saves, deletes)
+}
+
+// Search uses the wrapped store's full-text search, or else searches the cached entries
+func (c *CachedStore) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+	if searcher, ok := c.store.(Searcher); ok {
+		return searcher.Search(ctx, text, limit)
+	}
+
+	entries, err := c.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return SearchEntries(entries, text, limit), nil
+}
+
+// ListTags uses the wrapped store's tag counts, or else counts the cached entries
+func (c *CachedStore) ListTags(ctx context.Context) ([]Tag, error) {
+	if tagStore, ok := c.store.(TagStore); ok {
+		return tagStore.ListTags(ctx)
+	}
+
+	entries, err := c.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return CountTags(entries), nil
+}
+
+// SaveTag saves tag metadata to the wrapped store; entries are unaffected
+func (c *CachedStore) SaveTag(ctx context.Context, tag Tag) error {
+	tagStore, ok := c.store.(TagStore)
+	if !ok {
+		return ErrTagMetadataUnsupported
+	}
+	return tagStore.SaveTag(ctx, tag)
+}
+
+// DeleteTag deletes tag metadata from the wrapped store; entries are unaffected
+func (c *CachedStore) DeleteTag(ctx context.Context, name string) error {
+	tagStore, ok := c.store.(TagStore)
+	if !ok {
+		return ErrTagMetadataUnsupported
+	}
+	return tagStore.DeleteTag(ctx, name)
+}
+
+// CreateWorkspace creates a workspace in the wrapped store
+func (c *CachedStore) CreateWorkspace(ctx context.Context, workspace Workspace, admin Member) error {
+	workspaces, err := Workspaces(c.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.CreateWorkspace(ctx, workspace, admin)
+}
+
+// ListWorkspaces lists principal's workspaces from the wrapped store, uncached so
+// membership changes apply at once
+func (c *CachedStore) ListWorkspaces(ctx context.Context, principal string) ([]Membership, error) {
+	workspaces, err := Workspaces(c.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListWorkspaces(ctx, principal)
+}
+
+// ListMembers lists a workspace's members from the wrapped store, uncached so
+// membership changes apply at once
+func (c *CachedStore) ListMembers(ctx context.Context, workspaceID string) ([]Member, error) {
+	workspaces, err := Workspaces(c.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListMembers(ctx, workspaceID)
+}
+
+// SaveMember saves a workspace member to the wrapped store
+func (c *CachedStore) SaveMember(ctx context.Context, workspaceID string, member Member) error {
+	workspaces, err := Workspaces(c.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.SaveMember(ctx, workspaceID, member)
+}
+
+// DeleteMember removes a workspace member from the wrapped store
+func (c *CachedStore) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	workspaces, err := Workspaces(c.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.DeleteMember(ctx, workspaceID, principal)
+}
+
+// GetTimer reads the owner's timer from the wrapped store, uncached
+func (c *CachedStore) GetTimer(ctx context.Context) (Timer, bool, error) {
+	timers, err := Timers(c.store)
+	if err != nil {
+		return Timer{}, false, err
+	}
+	return timers.GetTimer(ctx)
+}
+
+// SaveTimer saves the owner's timer to the wrapped store
+func (c *CachedStore) SaveTimer(ctx context.Context, timer Timer) error {
+	timers, err := Timers(c.store)
+	if err != nil {
+		return err
+	}
+	return timers.SaveTimer(ctx, timer)
+}
+
+// ClaimTimer claims the owner's timer in the wrapped store
+func (c *CachedStore) ClaimTimer(ctx context.Context, timer Timer, replacing string) error {
+	timers, err := Timers(c.store)
+	if err != nil {
+		return err
+	}
+	return timers.ClaimTimer(ctx, timer, replacing)
+}
+
+// DeleteTimer removes the owner's timer from the wrapped store
+func (c *CachedStore) DeleteTimer(ctx context.Context) error {
+	timers, err := Timers(c.store)
+	if err != nil {
+		return err
+	}
+	return timers.DeleteTimer(ctx)
+}
+
+// Outbox returns the queue the wrapped store writes deliveries to along with entries, if any
+func (c *CachedStore) Outbox() DeliveryQueue {
+	outbox, ok := c.store.(DeliveryOutbox)
+	if !ok {
+		return nil
+	}
+	return outbox.Outbox()
+}
+
+// Subscribe passes on the wrapped store's change feed
+func (c *CachedStore) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	feed, ok := c.store.(ChangeFeed)
+	if !ok {
+		return nil, ErrChangeFeedUnsupported
+	}
+	return feed.Subscribe(ctx)
+}
+
+// InvalidateOnChanges empties the cache whenever the wrapped store reports a change to any
+// owner's entries, so writes made by other processes are seen at once rather than after
+// the TTL. It stops when ctx is cancelled, and returns ErrChangeFeedUnsupported if the store
+// has no change feed.
+func (c *CachedStore) InvalidateOnChanges(ctx context.Context) error {
+	changes, err := c.Subscribe(WithAllOwners(ctx))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for range changes {
+			c.Invalidate()
+		}
+	}()
+	return nil
+}
+
+// Invalidate empties the cache
+func (c *CachedStore) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.stats.Invalidations++
+	c.pages = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+// Stats returns the cache's counters
+func (c *CachedStore) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.size
+	return stats
+}
+
+// snapshotKey is the cache key of the GetAll snapshot of the context's owner
+func snapshotKey(ctx context.Context) string {
+	return OwnerFromContext(ctx) + "\x00all"
+}
+
+// lookup returns the page cached under key while it is fresh, marking it recently used
+func (c *CachedStore) lookup(key string) *cachedPage {
+	elem, ok := c.pages[key]
+	if !ok {
+		return nil
+	}
+	page := elem.Value.(*cachedPage)
+	if !c.fresh(page.loadedAt) {
+		c.removePage(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return page
+}
+
+// add caches a page loaded during generation, unless an invalidation has happened since
+// or it is bigger than the whole cache, so the cache never exceeds MaxEntries
+func (c *CachedStore) add(generation uint64, page *cachedPage) {
+	if generation != c.generation || (c.opts.MaxEntries > 0 && page.len() > c.opts.MaxEntries) {
+		return
+	}
+	if elem, ok := c.pages[page.key]; ok {
+		c.removePage(elem)
+	}
+	c.pages[page.key] = c.lru.PushFront(page)
+	c.size += page.len()
+	c.evict()
+}
+
+// fresh reports whether something cached at loadedAt can still be served
+func (c *CachedStore) fresh(loadedAt time.Time) bool {
+	return c.opts.TTL <= 0 || time.Since(loadedAt) < c.opts.TTL
+}
+
+// removePage forgets one cached page
+func (c *CachedStore) removePage(elem *list.Element) {
+	page := c.lru.Remove(elem).(*cachedPage)
+	delete(c.pages, page.key)
+	c.size -= page.len()
+}
+
+// evict drops the least recently used pages until the cache fits in MaxEntries
+func (c *CachedStore) evict() {
+	for c.opts.MaxEntries > 0 && c.size > c.opts.MaxEntries && c.lru.Len() > 0 {
+		c.removePage(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// copyEntries copies a map of entries so callers can't change what is cached
+func copyEntries(entries map[string]core.Entry) map[string]core.Entry {
+	copied := make(map[string]core.Entry, len(entries))
+	for id, entry := range entries {
+		copied[id] = copyEntry(entry)
+	}
+	return copied
+}
+
+// copyResult copies a Query result so callers can't change what is cached
+func copyResult(result QueryResult) QueryResult {
+	entries := make([]core.Entry, len(result.Entries))
+	for i, entry := range result.Entries {
+		entries[i] = copyEntry(entry)
+	}
+	result.Entries = entries
+	return result
+}
@@ -0,0 +1,215 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func TestCachedStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) service.Store {
+		return service.NewCachedStore(service.NewMemoryStore(), service.CacheOptions{})
+	})
+}
+
+func TestCachedStore_SmallCache(t *testing.T) {
+	// Big enough for a page or two but not the whole store, so reads keep missing and evicting
+	storetest.Run(t, func(t *testing.T) service.Store {
+		return service.NewCachedStore(service.NewMemoryStore(), service.CacheOptions{MaxEntries: 2})
+	})
+}
+
+// countingStore counts reads that reach the wrapped store
+type countingStore struct {
+	*service.MemoryStore
+	getAlls, queries int
+}
+
+func (c *countingStore) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	c.getAlls++
+	return c.MemoryStore.GetAll(ctx)
+}
+
+func (c *countingStore) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	c.queries++
+	return c.MemoryStore.Query(ctx, q)
+}
+
+func TestCachedStore_ServesReadsUntilWrite(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStore{MemoryStore: service.NewMemoryStore(core.Entry{ID: "1", Title: "One", Tags: []string{"work"}})}
+	cache := service.NewCachedStore(inner, service.CacheOptions{})
+
+	for range 3 {
+		entries, err := cache.GetAll(ctx)
+		if err != nil {
+			t.Fatalf("GetAll() error = %v", err)
+		}
+		entries["1"].Tags[0] = "changed by the caller"
+	}
+	// The snapshot answers queries too
+	result, err := cache.Query(ctx, service.Query{Tags: []string{"work"}})
+	if err != nil || len(result.Entries) != 1 {
+		t.Fatalf("Query() = %v, %v; want the cached entry", result.Entries, err)
+	}
+	if inner.getAlls != 1 || inner.queries != 0 {
+		t.Errorf("store read %d times and queried %d; want 1 and 0", inner.getAlls, inner.queries)
+	}
+
+	if err := cache.SaveEntry(ctx, core.Entry{ID: "2", Title: "Two"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	entries, _ := cache.GetAll(ctx)
+	if len(entries) != 2 || inner.getAlls != 2 {
+		t.Errorf("GetAll() after a write = %d entries after %d reads; want 2 after 2", len(entries), inner.getAlls)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 3 || stats.Misses != 2 || stats.Invalidations != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCachedStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStore{MemoryStore: service.NewMemoryStore()}
+	cache := service.NewCachedStore(inner, service.CacheOptions{TTL: 20 * time.Millisecond})
+
+	cache.Query(ctx, service.Query{Limit: 10})
+	cache.Query(ctx, service.Query{Limit: 10})
+	time.Sleep(30 * time.Millisecond)
+	cache.Query(ctx, service.Query{Limit: 10})
+
+	if inner.queries != 2 {
+		t.Errorf("store queried %d times; want 2", inner.queries)
+	}
+}
+
+func TestCachedStore_EvictsLeastRecentlyUsedPages(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStore{MemoryStore: service.NewMemoryStore(
+		core.Entry{ID: "1", Tags: []string{"a"}},
+		core.Entry{ID: "2", Tags: []string{"b"}},
+		core.Entry{ID: "3", Tags: []string{"c"}},
+	)}
+	cache := service.NewCachedStore(inner, service.CacheOptions{MaxEntries: 2})
+
+	query := func(tag string) {
+		if _, err := cache.Query(ctx, service.Query{Tags: []string{tag}}); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+	}
+	query("a")
+	query("b")
+	query("a") // Hit; b is now least recently used
+	query("c") // Evicts b
+	query("a") // Still cached
+	query("b") // Miss
+
+	if inner.queries != 4 {
+		t.Errorf("store queried %d times; want 4", inner.queries)
+	}
+	if stats := cache.Stats(); stats.Evictions != 2 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v; want 2 evictions and 2 entries held", stats)
+	}
+
+	// A snapshot bigger than the cache isn't kept
+	cache.GetAll(ctx)
+	cache.GetAll(ctx)
+	if inner.getAlls != 2 {
+		t.Errorf("store read %d times; want 2", inner.getAlls)
+	}
+}
+
+// writeDuringRead saves an entry while a read is in flight, as another request would
+type writeDuringRead struct {
+	*service.MemoryStore
+	cache *service.CachedStore
+	write bool
+}
+
+func (w *writeDuringRead) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	entries, err := w.MemoryStore.GetAll(ctx)
+	if w.write {
+		w.write = false
+		w.cache.SaveEntry(ctx, core.Entry{ID: "written", Title: "Mid-read"})
+	}
+	return entries, err
+}
+
+func TestCachedStore_DiscardsReadsOverlappingWrites(t *testing.T) {
+	ctx := context.Background()
+	inner := &writeDuringRead{MemoryStore: service.NewMemoryStore(), write: true}
+	cache := service.NewCachedStore(inner, service.CacheOptions{})
+	inner.cache = cache
+
+	cache.GetAll(ctx) // Read before the write landed; must not be cached
+	entries, _ := cache.GetAll(ctx)
+	if _, ok := entries["written"]; !ok {
+		t.Errorf("GetAll() served a snapshot from before a write")
+	}
+}
+
+// feedStore is a MemoryStore with a change feed the test publishes to
+type feedStore struct {
+	*service.MemoryStore
+	changes chan service.ChangeEvent
+}
+
+func (f *feedStore) Subscribe(ctx context.Context) (<-chan service.ChangeEvent, error) {
+	return f.changes, nil
+}
+
+func TestCachedStore_InvalidateOnChanges(t *testing.T) {
+	ctx := context.Background()
+
+	if err := service.NewCachedStore(service.NewMemoryStore(), service.CacheOptions{}).InvalidateOnChanges(ctx); !errors.Is(err, service.ErrChangeFeedUnsupported) {
+		t.Fatalf("InvalidateOnChanges() error = %v; want ErrChangeFeedUnsupported", err)
+	}
+
+	inner := &feedStore{MemoryStore: service.NewMemoryStore(), changes: make(chan service.ChangeEvent)}
+	cache := service.NewCachedStore(inner, service.CacheOptions{})
+	if err := cache.InvalidateOnChanges(ctx); err != nil {
+		t.Fatalf("InvalidateOnChanges() error = %v", err)
+	}
+	cache.GetAll(ctx)
+
+	// Another process writes straight to the database
+	inner.SaveEntry(ctx, core.Entry{ID: "elsewhere"})
+	inner.changes <- service.ChangeEvent{Op: service.ChangeInsert, ID: "elsewhere", Version: 1}
+	close(inner.changes)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Stats().Invalidations == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("change did not invalidate the cache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if entries, _ := cache.GetAll(ctx); len(entries) != 1 {
+		t.Errorf("GetAll() = %d entries; want the one written elsewhere", len(entries))
+	}
+}
+
+func TestCachedStore_PassesOnOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	cache := service.NewCachedStore(service.NewMemoryStore(core.Entry{ID: "1", Title: "Kubernetes", Tags: []string{"infra"}}), service.CacheOptions{})
+
+	if err := service.SaveTag(ctx, cache, service.Tag{Name: "infra", Color: "#336699"}); err != nil {
+		t.Fatalf("SaveTag() error = %v", err)
+	}
+	tags, err := service.ListTags(ctx, cache)
+	if err != nil || len(tags) != 1 || tags[0].Color != "#336699" || tags[0].Count != 1 {
+		t.Errorf("ListTags() = %+v, %v", tags, err)
+	}
+
+	results, err := service.Search(ctx, cache, "kubernetes", 0)
+	if err != nil || len(results) != 1 {
+		t.Errorf("Search() = %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrChangeFeedUnsupported is returned by Subscribe on stores that wrap one without a change feed
+var ErrChangeFeedUnsupported = errors.New("store does not publish changes")
+
+// ChangeOp is the kind of change a ChangeEvent reports
+type ChangeOp string
+
+const (
+	ChangeInsert ChangeOp = "insert"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete"
+	ChangeResync ChangeOp = "resync" // Changes may have been missed, e.g. while reconnecting; reload everything
+)
+
+// ChangeEvent reports that an entry was written, by any process sharing the store
+type ChangeEvent struct {
+	Op      ChangeOp
+	Owner   string // Whose entry changed; empty for ChangeResync
+	ID      string // Empty for ChangeResync
+	Version int64  // Stored version after the change; for deletes, the version deleted
+}
+
+// ChangeFeed is implemented by stores that can report changes as they happen, so readers
+// can react instead of polling. Events include the subscriber's own writes and arrive once
+// the writing transaction commits. Like every Store call, a subscription only reports the
+// context owner's changes, unless the context was made by WithAllOwners.
+// The channel is closed when ctx is cancelled.
+type ChangeFeed interface {
+	Subscribe(ctx context.Context) (<-chan ChangeEvent, error)
+}
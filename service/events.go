@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamEventType is the kind of change a StreamEvent reports
+type StreamEventType string
+
+const (
+	StreamEntryCreated StreamEventType = "created"
+	StreamEntryUpdated StreamEventType = "updated"
+	StreamEntryDeleted StreamEventType = "deleted"
+	StreamTimer        StreamEventType = "timer"  // The owner's timer started, paused or stopped
+	StreamResync       StreamEventType = "resync" // Events may have been missed; reload everything
+)
+
+// StreamEvent is a change to an owner's entries or timer, numbered in an EventLog
+type StreamEvent struct {
+	Seq     uint64
+	Type    StreamEventType
+	Owner   string // Whose entries changed; empty for StreamResync, which every owner sees
+	EntryID string // Empty for StreamResync
+	Version int64  // Entry events: stored version after the change, or the version deleted
+	Timer   string // Timer events: running, paused or stopped
+	At      time.Time
+}
+
+// EventLog keeps the most recent entry and timer changes of every owner in memory, so
+// readers can follow them and pick up where they left off after reconnecting. Sequence
+// numbers restart with the process; IDs carry the log's start time so old ones are spotted.
+type EventLog struct {
+	mu       sync.Mutex
+	epoch    string
+	events   []StreamEvent // Oldest first, at most capacity
+	capacity int
+	last     uint64                     // Seq of the latest event; 0 before any
+	waiters  map[chan struct{}]struct{} // Woken by every Publish
+}
+
+// NewEventLog creates a log holding the last capacity events
+func NewEventLog(capacity int) *EventLog {
+	return &EventLog{
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		capacity: max(capacity, 1),
+		waiters:  make(map[chan struct{}]struct{}),
+	}
+}
+
+// Publish numbers an event, adds it to the log, dropping the oldest beyond capacity, and
+// wakes every waiter
+func (l *EventLog) Publish(event StreamEvent) StreamEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.last++
+	event.Seq = l.last
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	if len(l.events) == l.capacity {
+		l.events = append(l.events[:0], l.events[1:]...)
+	}
+	l.events = append(l.events, event)
+
+	for wake := range l.waiters {
+		select {
+		case wake <- struct{}{}:
+		default: // Already due to wake
+		}
+	}
+	return event
+}
+
+// Cursor returns the position after the latest event, to follow only what comes next
+func (l *EventLog) Cursor() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// Since returns owner's events after cursor and the cursor after them. It reports false if
+// events after cursor have already been dropped, or cursor is from the future.
+func (l *EventLog) Since(owner string, cursor uint64) ([]StreamEvent, uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cursor > l.last {
+		return nil, l.last, false
+	}
+	complete := len(l.events) == 0 || l.events[0].Seq <= cursor+1
+
+	var events []StreamEvent
+	for _, event := range l.events {
+		if event.Seq > cursor && (event.Owner == owner || event.Type == StreamResync) {
+			events = append(events, event)
+		}
+	}
+	return events, l.last, complete
+}
+
+// Wait returns a channel that receives after each Publish, and a func to stop waiting
+func (l *EventLog) Wait() (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	l.waiters[wake] = struct{}{}
+	l.mu.Unlock()
+
+	return wake, func() {
+		l.mu.Lock()
+		delete(l.waiters, wake)
+		l.mu.Unlock()
+	}
+}
+
+// EventID returns the ID a reader resumes from after seq, such as an SSE Last-Event-ID
+func (l *EventLog) EventID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", l.epoch, seq)
+}
+
+// ParseEventID returns the cursor an EventID stands for, reporting false if it is malformed
+// or from before this log started
+func (l *EventLog) ParseEventID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != l.epoch {
+		return 0, false
+	}
+	cursor, err := strconv.ParseUint(seq, 10, 64)
+	return cursor, err == nil
+}
+
+// Follow publishes every owner's entry changes from feed until ctx is cancelled
+func (l *EventLog) Follow(ctx context.Context, feed ChangeFeed) error {
+	changes, err := feed.Subscribe(WithAllOwners(ctx))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for change := range changes {
+			l.Publish(changeStreamEvent(change))
+		}
+	}()
+	return nil
+}
+
+// changeStreamEvent converts a change feed event
+func changeStreamEvent(change ChangeEvent) StreamEvent {
+	event := StreamEvent{Owner: change.Owner, EntryID: change.ID, Version: change.Version}
+	switch change.Op {
+	case ChangeInsert:
+		event.Type = StreamEntryCreated
+	case ChangeUpdate:
+		event.Type = StreamEntryUpdated
+	case ChangeDelete:
+		event.Type = StreamEntryDeleted
+	default:
+		return StreamEvent{Type: StreamResync}
+	}
+	return event
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEventLog_Since(t *testing.T) {
+	log := NewEventLog(3)
+	start := log.Cursor()
+
+	log.Publish(StreamEvent{Type: StreamEntryCreated, Owner: "alice", EntryID: "1"})
+	log.Publish(StreamEvent{Type: StreamEntryCreated, Owner: "bob", EntryID: "2"})
+	log.Publish(StreamEvent{Type: StreamResync})
+
+	// Each owner sees their own events and resyncs
+	events, cursor, complete := log.Since("alice", start)
+	assertEquality(t, complete, true)
+	assertEquality(t, len(events), 2)
+	assertEquality(t, events[0].EntryID, "1")
+	assertEquality(t, events[1].Type, StreamResync)
+	assertEquality(t, cursor, log.Cursor())
+
+	// Nothing new since the cursor
+	events, _, complete = log.Since("alice", cursor)
+	assertEquality(t, len(events), 0)
+	assertEquality(t, complete, true)
+
+	// The log keeps the last three events; resuming from before them is incomplete
+	log.Publish(StreamEvent{Type: StreamTimer, Owner: "alice", EntryID: "1", Timer: "running"})
+	events, _, complete = log.Since("alice", start)
+	assertEquality(t, complete, false)
+	events, _, complete = log.Since("alice", start+1)
+	assertEquality(t, complete, true)
+	assertEquality(t, len(events), 2)
+
+	if _, _, complete := log.Since("alice", log.Cursor()+1); complete {
+		t.Errorf("Since(future cursor) complete = true; want false")
+	}
+}
+
+func TestEventLog_EventID(t *testing.T) {
+	log := NewEventLog(10)
+	event := log.Publish(StreamEvent{Type: StreamEntryUpdated, Owner: "alice", EntryID: "1"})
+
+	cursor, ok := log.ParseEventID(log.EventID(event.Seq))
+	assertEquality(t, ok, true)
+	assertEquality(t, cursor, event.Seq)
+
+	// IDs from a previous run of the server aren't resumed from
+	for _, id := range []string{"", "42", NewEventLog(10).EventID(event.Seq), log.EventID(event.Seq) + "x"} {
+		if _, ok := log.ParseEventID(id); ok {
+			t.Errorf("ParseEventID(%q) ok = true; want false", id)
+		}
+	}
+}
+
+func TestEventLog_Follow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	feed := make(fakeFeed, 2)
+	log := NewEventLog(10)
+	wake, stop := log.Wait()
+	defer stop()
+
+	assertNilError(t, log.Follow(ctx, feed))
+	feed <- ChangeEvent{Op: ChangeDelete, Owner: "alice", ID: "1", Version: 4}
+
+	select {
+	case <-wake:
+	case <-time.After(time.Second):
+		t.Fatal("Publish didn't wake the waiter")
+	}
+	events, _, _ := log.Since("alice", 0)
+	assertEquality(t, len(events), 1)
+	assertEquality(t, events[0].Type, StreamEntryDeleted)
+	assertEquality(t, events[0].Version, int64(4))
+}
+
+// fakeFeed is a ChangeFeed whose events are sent by the test
+type fakeFeed chan ChangeEvent
+
+func (f fakeFeed) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	if !SeesAllOwners(ctx) {
+		panic("event log subscribed for one owner")
+	}
+	return f, nil
+}
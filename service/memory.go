@@ -0,0 +1,480 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+// MemoryStore is a concurrency-safe, in-memory Store.
+// Useful for tests and as a scratch backend; nothing is persisted.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	owners     map[string]*memoryOwner     // By owner; see WithOwner
+	workspaces map[string]*memoryWorkspace // By workspace ID
+	deliveries []Delivery                  // Webhook deliveries of every owner, oldest first
+}
+
+// memoryOwner holds one owner's entries and tag metadata
+type memoryOwner struct {
+	entries map[string]core.Entry
+	tags    map[string]Tag // Metadata only; counts come from entries
+	timer   *Timer         // nil when the owner isn't timing anything
+}
+
+// memoryWorkspace holds a workspace and its members; its entries are held by its owner
+type memoryWorkspace struct {
+	workspace Workspace
+	members   map[string]Member // By principal ID
+}
+
+// NewMemoryStore creates a MemoryStore seeded with the given entries, owned by DefaultOwner
+func NewMemoryStore(entries ...core.Entry) *MemoryStore {
+	m := &MemoryStore{owners: make(map[string]*memoryOwner), workspaces: make(map[string]*memoryWorkspace)}
+	data := m.owned(context.Background())
+	for _, entry := range entries {
+		data.entries[entry.ID] = copyEntry(entry)
+	}
+	return m
+}
+
+// viewed returns the data of the owner ctx is scoped to, for reading; callers hold mu
+func (m *MemoryStore) viewed(ctx context.Context) *memoryOwner {
+	if data, ok := m.owners[OwnerFromContext(ctx)]; ok {
+		return data
+	}
+	return &memoryOwner{}
+}
+
+// owned returns the data of the owner ctx is scoped to, creating it; callers hold mu for writing
+func (m *MemoryStore) owned(ctx context.Context) *memoryOwner {
+	owner := OwnerFromContext(ctx)
+	data, ok := m.owners[owner]
+	if !ok {
+		data = &memoryOwner{entries: make(map[string]core.Entry), tags: make(map[string]Tag)}
+		m.owners[owner] = data
+	}
+	return data
+}
+
+// GetAll returns a copy of every stored entry
+func (m *MemoryStore) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := m.viewed(ctx)
+	result := make(map[string]core.Entry, len(data.entries))
+	for id, entry := range data.entries {
+		result[id] = copyEntry(entry)
+	}
+	return result, nil
+}
+
+// SaveEntry inserts or replaces an entry, checking its version when set
+func (m *MemoryStore) SaveEntry(ctx context.Context, entry core.Entry) error {
+	return m.SaveEntries(ctx, []core.Entry{entry})
+}
+
+// SaveEntryVersion saves an entry as SaveEntry does and returns its new version
+func (m *MemoryStore) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	saved, err := m.write(ctx, []core.Entry{entry}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return saved[0].Version, nil
+}
+
+// SaveEntries saves every entry, or none if any version check fails
+func (m *MemoryStore) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	return m.WriteEntries(ctx, entries, nil)
+}
+
+// DeleteEntry removes an entry; deleting a missing entry is not an error
+func (m *MemoryStore) DeleteEntry(ctx context.Context, id string) error {
+	return m.DeleteEntries(ctx, []string{id})
+}
+
+// DeleteEntries removes every listed entry, skipping missing ones
+func (m *MemoryStore) DeleteEntries(ctx context.Context, ids []string) error {
+	return m.WriteEntries(ctx, nil, ids)
+}
+
+// DeleteEntryVersion removes an entry if it is at version, or at any version when it is 0
+func (m *MemoryStore) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := m.owned(ctx)
+	stored, exists := data.entries[id]
+	if !exists || (version > 0 && stored.Version != version) {
+		return NotDeletedError(id, version)
+	}
+	delete(data.entries, id)
+	m.enqueue(DeliveriesFromContext(ctx))
+	return nil
+}
+
+// WriteEntries saves and deletes together, or changes nothing if any version check fails
+func (m *MemoryStore) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	_, err := m.write(ctx, saves, deletes)
+	return err
+}
+
+// write applies WriteEntries, returning the entries saved at their new versions
+func (m *MemoryStore) write(ctx context.Context, saves []core.Entry, deletes []string) ([]core.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := m.owned(ctx)
+	saves = UniqueEntries(saves)
+	staged := make([]core.Entry, 0, len(saves))
+	for _, entry := range saves {
+		stored, exists := data.entries[entry.ID]
+		if entry.Version > 0 && (!exists || stored.Version != entry.Version) {
+			return nil, fmt.Errorf("%w: entry %s", ErrConflict, entry.ID)
+		}
+		entry.Version = stored.Version + 1
+		staged = append(staged, copyEntry(entry))
+	}
+
+	for _, entry := range staged {
+		data.entries[entry.ID] = copyEntry(entry)
+	}
+	for _, id := range deletes {
+		delete(data.entries, id)
+	}
+	m.enqueue(DeliveriesFromContext(ctx))
+	return staged, nil
+}
+
+// Query filters, sorts and pages the stored entries
+func (m *MemoryStore) Query(ctx context.Context, q Query) (QueryResult, error) {
+	if err := ctx.Err(); err != nil {
+		return QueryResult{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return QueryEntries(m.viewed(ctx).entries, q)
+}
+
+// ListTags counts the tags in use and adds any with metadata, by name
+func (m *MemoryStore) ListTags(ctx context.Context) ([]Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := m.viewed(ctx)
+	tags := CountTags(data.entries)
+	for i, tag := range tags {
+		meta := data.tags[tag.Name]
+		tags[i].Description, tags[i].Color = meta.Description, meta.Color
+	}
+	for name, meta := range data.tags {
+		if !slices.ContainsFunc(tags, func(tag Tag) bool { return tag.Name == name }) {
+			tags = append(tags, Tag{Name: name, Description: meta.Description, Color: meta.Color})
+		}
+	}
+	sortTags(tags)
+	return tags, nil
+}
+
+// SaveTag sets a tag's description and colour
+func (m *MemoryStore) SaveTag(ctx context.Context, tag Tag) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.owned(ctx).tags[tag.Name] = Tag{Name: tag.Name, Description: tag.Description, Color: tag.Color}
+	return nil
+}
+
+// DeleteTag drops a tag's metadata
+func (m *MemoryStore) DeleteTag(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.owned(ctx).tags, name)
+	return nil
+}
+
+// CreateWorkspace stores a workspace with its first admin
+func (m *MemoryStore) CreateWorkspace(ctx context.Context, workspace Workspace, admin Member) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.workspaces[workspace.ID]; exists {
+		return fmt.Errorf("workspace %s already exists", workspace.ID)
+	}
+	m.workspaces[workspace.ID] = &memoryWorkspace{
+		workspace: workspace,
+		members:   map[string]Member{admin.PrincipalID: admin},
+	}
+	return nil
+}
+
+// ListWorkspaces returns every workspace principal belongs to, with their role in it
+func (m *MemoryStore) ListWorkspaces(ctx context.Context, principal string) ([]Membership, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var memberships []Membership
+	for _, data := range m.workspaces {
+		if member, ok := data.members[principal]; ok {
+			memberships = append(memberships, Membership{Workspace: data.workspace, Role: member.Role})
+		}
+	}
+	return memberships, nil
+}
+
+// ListMembers returns a workspace's members, none if it doesn't exist
+func (m *MemoryStore) ListMembers(ctx context.Context, workspaceID string) ([]Member, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.workspaces[workspaceID]
+	if !ok {
+		return nil, nil
+	}
+	members := make([]Member, 0, len(data.members))
+	for _, member := range data.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// SaveMember adds a member to a workspace or changes their role
+func (m *MemoryStore) SaveMember(ctx context.Context, workspaceID string, member Member) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.workspaces[workspaceID]
+	if !ok {
+		return ErrWorkspaceNotFound
+	}
+	if existing, ok := data.members[member.PrincipalID]; ok {
+		member.AddedAt = existing.AddedAt
+	}
+	data.members[member.PrincipalID] = member
+	return nil
+}
+
+// DeleteMember removes a member from a workspace
+func (m *MemoryStore) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if data, ok := m.workspaces[workspaceID]; ok {
+		delete(data.members, principal)
+	}
+	return nil
+}
+
+// GetTimer returns the owner's timer, reporting whether there is one
+func (m *MemoryStore) GetTimer(ctx context.Context) (Timer, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Timer{}, false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if timer := m.viewed(ctx).timer; timer != nil {
+		return *timer, true, nil
+	}
+	return Timer{}, false, nil
+}
+
+// SaveTimer replaces the owner's timer
+func (m *MemoryStore) SaveTimer(ctx context.Context, timer Timer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.owned(ctx).timer = &timer
+	return nil
+}
+
+// ClaimTimer saves a running timer unless another entry's, other than replacing, is running
+func (m *MemoryStore) ClaimTimer(ctx context.Context, timer Timer, replacing string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := m.owned(ctx)
+	if current := data.timer; current != nil && current.State == TimerRunning &&
+		current.EntryID != timer.EntryID && current.EntryID != replacing {
+		return fmt.Errorf("%w: entry %s", ErrTimerRunning, current.EntryID)
+	}
+	data.timer = &timer
+	return nil
+}
+
+// DeleteTimer removes the owner's timer
+func (m *MemoryStore) DeleteTimer(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.owned(ctx).timer = nil
+	return nil
+}
+
+// EnqueueDeliveries adds pending deliveries, skipping any whose ID is already queued
+func (m *MemoryStore) EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.enqueue(deliveries)
+	return nil
+}
+
+// Outbox returns the store itself: writes queue the deliveries in their context along with them
+func (m *MemoryStore) Outbox() DeliveryQueue {
+	return m
+}
+
+// enqueue adds deliveries not already queued; callers hold mu for writing
+func (m *MemoryStore) enqueue(deliveries []Delivery) {
+	for _, delivery := range deliveries {
+		if slices.ContainsFunc(m.deliveries, func(queued Delivery) bool { return queued.ID == delivery.ID }) {
+			continue
+		}
+		m.deliveries = append(m.deliveries, copyDelivery(delivery))
+	}
+}
+
+// ClaimDeliveries returns the pending deliveries due by now, oldest first, holding them until now plus lease
+func (m *MemoryStore) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var claimed []Delivery
+	for i := range m.deliveries {
+		delivery := &m.deliveries[i]
+		if delivery.Status != DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if limit > 0 && len(claimed) == limit {
+			break
+		}
+		delivery.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, copyDelivery(*delivery))
+	}
+	return claimed, nil
+}
+
+// UpdateDelivery records the outcome of an attempt
+func (m *MemoryStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == delivery.ID {
+			m.deliveries[i] = copyDelivery(delivery)
+			return nil
+		}
+	}
+	return fmt.Errorf("delivery %s not found", delivery.ID)
+}
+
+// ListDeliveries returns the owner's most recent deliveries, newest first
+func (m *MemoryStore) ListDeliveries(ctx context.Context, limit int) ([]Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	owner := OwnerFromContext(ctx)
+	var deliveries []Delivery
+	for i := len(m.deliveries) - 1; i >= 0 && (limit <= 0 || len(deliveries) < limit); i-- {
+		if m.deliveries[i].Owner == owner {
+			deliveries = append(deliveries, copyDelivery(m.deliveries[i]))
+		}
+	}
+	return deliveries, nil
+}
+
+// copyDelivery detaches the delivery's payload from the caller's slice
+func copyDelivery(delivery Delivery) Delivery {
+	delivery.Payload = append([]byte(nil), delivery.Payload...)
+	return delivery
+}
+
+// copyEntry detaches the entry's tags from the caller's slice
+func copyEntry(entry core.Entry) core.Entry {
+	if entry.Tags != nil {
+		entry.Tags = append([]string(nil), entry.Tags...)
+	}
+	return entry
+}
@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) service.Store {
+		return service.NewMemoryStore()
+	})
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	store := service.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := fmt.Sprintf("%d", i)
+			if err := store.SaveEntry(context.Background(), core.Entry{ID: id, Title: id, Tags: []string{"a"}}); err != nil {
+				t.Errorf("SaveEntry() error = %v", err)
+			}
+			if _, err := store.GetAll(context.Background()); err != nil {
+				t.Errorf("GetAll() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, _ := store.GetAll(context.Background())
+	if len(entries) != 50 {
+		t.Errorf("expected 50 entries, got %d", len(entries))
+	}
+}
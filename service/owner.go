@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultOwner owns entries saved without an owner in the context: the TUI's local database,
+// and every entry stored before entries had owners. ReassignOwner hands them to a user.
+const DefaultOwner = ""
+
+// ErrOwnersUnsupported is returned by stores that can only hold DefaultOwner's entries
+var ErrOwnersUnsupported = errors.New("store does not support entry owners")
+
+type ownerKey struct{}
+type principalKey struct{}
+type allOwnersKey struct{}
+
+// WithOwner scopes every Store call made with the returned context to owner's entries.
+// Stores read and write only the entries of the owner in the context, so one owner can
+// never see or overwrite another's, even with the same entry ID.
+func WithOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+// OwnerFromContext returns the owner a Store call is scoped to, DefaultOwner if none was set
+func OwnerFromContext(ctx context.Context) string {
+	owner, _ := ctx.Value(ownerKey{}).(string)
+	return owner
+}
+
+// WithAllOwners lets a ChangeFeed subscription made with the returned context report every
+// owner's changes, for processes that serve all owners, such as the API server's cache.
+// Never use it for a request made on behalf of a user. Reads and writes stay scoped.
+func WithAllOwners(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allOwnersKey{}, true)
+}
+
+// SeesAllOwners reports whether ctx was made by WithAllOwners
+func SeesAllOwners(ctx context.Context) bool {
+	all, _ := ctx.Value(allOwnersKey{}).(bool)
+	return all
+}
+
+// Principal is the authenticated user a request acts for
+type Principal struct {
+	ID     string // Stable user ID, such as a Cognito sub; also the owner of their entries
+	Method string // How they authenticated, e.g. "cognito" or "api_key"
+}
+
+// WithPrincipal records who a request acts for, and scopes it to their own entries
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return WithOwner(context.WithValue(ctx, principalKey{}, principal), principal.ID)
+}
+
+// PrincipalFromContext returns who a request acts for, if it was authenticated
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// OwnerMigrator is implemented by stores that can move entries and tag metadata between
+// owners, such as handing the entries from before owners existed to a signed-in user.
+// Entries whose ID the new owner already uses are left where they are.
+type OwnerMigrator interface {
+	ReassignOwner(ctx context.Context, from, to string) (int, error)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+const (
+	SortByStartedAt    = "started_at"
+	SortByEndedAt      = "ended_at"
+	SortByLastModified = "last_modified"
+)
+
+// ErrInvalidQuery is returned for unknown sort fields and malformed cursors
+var ErrInvalidQuery = errors.New("invalid query")
+
+// TimeRange bounds a timestamp: From is inclusive, To is exclusive.
+// A zero bound is open; entries without the timestamp never match a bounded range.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// IsZero reports whether neither bound is set
+func (r TimeRange) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains reports whether t falls within the range
+func (r TimeRange) Contains(t time.Time) bool {
+	if r.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !t.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// Query selects, orders and pages entries. The zero value returns every entry, newest first.
+type Query struct {
+	IDs        []string  // Only these entries
+	Tags       []string  // Entries carrying every one of these tags, or a tag beneath it
+	Text       string    // Case-insensitive substring of the title or body
+	StartedAt  TimeRange // Bounds on StartedAtTimestamp
+	EndedAt    TimeRange // Bounds on EndedAtTimestamp
+	InProgress *bool     // Only entries with (true) or without (false) an end time
+	SortBy     string    // SortByStartedAt (default), SortByEndedAt or SortByLastModified
+	Ascending  bool      // Oldest first; entries without the sort timestamp always come last
+	Limit      int       // Page size; 0 returns everything
+	Cursor     string    // NextCursor from the previous page
+	Count      bool      // Also count every match into QueryResult.Total, ignoring Limit and Cursor
+}
+
+// QueryResult is one page of a query
+type QueryResult struct {
+	Entries    []core.Entry
+	NextCursor string // Empty on the last page
+	Total      int    // Matches across every page; only set when Query.Count is
+}
+
+// Cursor marks the last entry of a page. Pages are keyed on (sort timestamp, ID).
+type Cursor struct {
+	Time time.Time `json:"t,omitzero"`
+	ID   string    `json:"id"`
+}
+
+// Validate fills in defaults and rejects unknown sort fields and bad cursors
+func (q *Query) Validate() error {
+	switch q.SortBy {
+	case "":
+		q.SortBy = SortByStartedAt
+	case SortByStartedAt, SortByEndedAt, SortByLastModified:
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, q.SortBy)
+	}
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	}
+	if _, err := q.DecodeCursor(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeCursor parses q.Cursor; it returns nil when there is none
+func (q Query) DecodeCursor() (*Cursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return &c, nil
+}
+
+// EncodeCursor returns the cursor that resumes the query after entry
+func (q Query) EncodeCursor(entry core.Entry) string {
+	data, _ := json.Marshal(Cursor{Time: q.SortTime(entry), ID: entry.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// SortTime returns the timestamp the query orders entry by
+func (q Query) SortTime(entry core.Entry) time.Time {
+	switch q.SortBy {
+	case SortByEndedAt:
+		return entry.EndedAtTimestamp
+	case SortByLastModified:
+		return entry.LastModifiedTimestamp
+	default:
+		return entry.StartedAtTimestamp
+	}
+}
+
+// Matches reports whether entry passes the query's filters
+func (q Query) Matches(entry core.Entry) bool {
+	if len(q.IDs) > 0 && !containsString(q.IDs, entry.ID) {
+		return false
+	}
+	for _, filter := range q.Tags {
+		if !slices.ContainsFunc(entry.Tags, func(tag string) bool { return TagMatches(tag, filter) }) {
+			return false
+		}
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(entry.Title), text) && !strings.Contains(strings.ToLower(entry.Body), text) {
+			return false
+		}
+	}
+	if !q.StartedAt.Contains(entry.StartedAtTimestamp) || !q.EndedAt.Contains(entry.EndedAtTimestamp) {
+		return false
+	}
+	if q.InProgress != nil && entry.InProgress() != *q.InProgress {
+		return false
+	}
+	return true
+}
+
+// Less orders two entries: by sort timestamp with missing timestamps last, then by ID
+func (q Query) Less(a, b core.Entry) bool {
+	return q.less(q.SortTime(a), a.ID, q.SortTime(b), b.ID)
+}
+
+func (q Query) less(timeA time.Time, idA string, timeB time.Time, idB string) bool {
+	if timeA.IsZero() != timeB.IsZero() {
+		return !timeA.IsZero()
+	}
+	if !timeA.Equal(timeB) {
+		if q.Ascending {
+			return timeA.Before(timeB)
+		}
+		return timeA.After(timeB)
+	}
+	if q.Ascending {
+		return idA < idB
+	}
+	return idA > idB
+}
+
+// QueryEntries evaluates a query in memory, for stores that can't push it down
+func QueryEntries(entries map[string]core.Entry, q Query) (QueryResult, error) {
+	if err := q.Validate(); err != nil {
+		return QueryResult{}, err
+	}
+	cursor, _ := q.DecodeCursor()
+
+	var matched []core.Entry
+	total := 0
+	for _, entry := range entries {
+		if !q.Matches(entry) {
+			continue
+		}
+		total++
+		// Keep only entries that sort after the cursor
+		if cursor != nil && !q.less(cursor.Time, cursor.ID, q.SortTime(entry), entry.ID) {
+			continue
+		}
+		matched = append(matched, copyEntry(entry))
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return q.Less(matched[i], matched[j])
+	})
+
+	var result QueryResult
+	if q.Count {
+		result.Total = total
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+		result.NextCursor = q.EncodeCursor(matched[len(matched)-1])
+	}
+	result.Entries = matched
+	return result, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
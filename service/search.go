@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+const (
+	HighlightStart = "<mark>" // Opens a matched term in a search snippet
+	HighlightEnd   = "</mark>"
+
+	snippetRadius = 60 // Characters of context either side of the first match
+)
+
+// SearchResult is an entry matched by a full-text search
+type SearchResult struct {
+	Entry   core.Entry
+	Rank    float64 // Higher is more relevant
+	Snippet string  // Match context as HTML: the text is escaped and matches are wrapped in HighlightStart/HighlightEnd
+}
+
+// Searcher is implemented by stores with native full-text search
+type Searcher interface {
+	Search(ctx context.Context, text string, limit int) ([]SearchResult, error)
+}
+
+// Search runs a full-text search, most relevant first. A limit of 0 returns every match.
+// Stores that don't implement Searcher fall back to SearchEntries.
+func Search(ctx context.Context, store Store, text string, limit int) ([]SearchResult, error) {
+	if searcher, ok := store.(Searcher); ok {
+		return searcher.Search(ctx, text, limit)
+	}
+
+	entries, err := store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return SearchEntries(entries, text, limit), nil
+}
+
+// SearchEntries matches entries containing every word of text, ignoring case.
+// Title matches rank above body matches; there is no stemming.
+func SearchEntries(entries map[string]core.Entry, text string, limit int) []SearchResult {
+	terms := strings.Fields(strings.ToLower(text))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+	for _, entry := range entries {
+		title := strings.ToLower(entry.Title)
+		body := strings.ToLower(entry.Body)
+
+		var rank float64
+		matched := true
+		for _, term := range terms {
+			inTitle := strings.Count(title, term)
+			inBody := strings.Count(body, term)
+			if inTitle == 0 && inBody == 0 {
+				matched = false
+				break
+			}
+			rank += float64(2*inTitle + inBody)
+		}
+		if !matched {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Entry:   copyEntry(entry),
+			Rank:    rank,
+			Snippet: highlightSnippet(entry.Body, terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Entry.ID < results[j].Entry.ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// highlightSnippet cuts the body around the first matching term, escapes it as HTML and
+// highlights every term in it
+func highlightSnippet(body string, terms []string) string {
+	lower := strings.ToLower(body)
+
+	// Matching on the lowered copy is only safe while byte offsets line up
+	if len(lower) != len(body) {
+		return ""
+	}
+
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := max(first-snippetRadius, 0)
+	end := min(first+snippetRadius, len(body))
+	// Don't split multi-byte characters
+	for start > 0 && !isRuneStart(body[start]) {
+		start--
+	}
+	for end < len(body) && !isRuneStart(body[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	window, lowerWindow := body[start:end], lower[start:end]
+	plain := 0 // Start of the text since the last match
+	for i := 0; i < len(window); {
+		if term := termAt(lowerWindow[i:], terms); term != "" {
+			b.WriteString(html.EscapeString(window[plain:i]))
+			b.WriteString(HighlightStart + html.EscapeString(window[i:i+len(term)]) + HighlightEnd)
+			i += len(term)
+			plain = i
+			continue
+		}
+		i++
+	}
+	b.WriteString(html.EscapeString(window[plain:]))
+	if end < len(body) {
+		b.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// termAt returns the longest term s starts with
+func termAt(s string, terms []string) string {
+	var found string
+	for _, term := range terms {
+		if len(term) > len(found) && strings.HasPrefix(s, term) {
+			found = term
+		}
+	}
+	return found
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
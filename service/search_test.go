@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestSearchEntries(t *testing.T) {
+	entries := map[string]core.Entry{
+		"1": {ID: "1", Title: "K8s", Body: "Deployed the operator. Kubernetes operators reconcile state."},
+		"2": {ID: "2", Title: "Kubernetes networking", Body: "Services and ingress."},
+		"3": {ID: "3", Title: "Groceries", Body: "Eggs, milk"},
+	}
+
+	t.Run("every word must match", func(t *testing.T) {
+		results := SearchEntries(entries, "kubernetes OPERATOR", 0)
+
+		if len(results) != 1 || results[0].Entry.ID != "1" {
+			t.Fatalf("expected only entry 1, got %+v", results)
+		}
+	})
+
+	t.Run("title matches rank first", func(t *testing.T) {
+		results := SearchEntries(entries, "kubernetes", 0)
+
+		if len(results) != 2 || results[0].Entry.ID != "2" || results[1].Entry.ID != "1" {
+			t.Fatalf("expected entries 2 then 1, got %+v", results)
+		}
+	})
+
+	t.Run("snippet highlights matches", func(t *testing.T) {
+		results := SearchEntries(entries, "operator", 0)
+
+		want := "Deployed the <mark>operator</mark>. Kubernetes <mark>operator</mark>s reconcile state."
+		if len(results) != 1 || results[0].Snippet != want {
+			t.Fatalf("Snippet = %q; want %q", results[0].Snippet, want)
+		}
+	})
+
+	t.Run("snippet escapes HTML", func(t *testing.T) {
+		entries := map[string]core.Entry{"1": {ID: "1", Body: `Fixed <script>alert("operator")</script> & more`}}
+		results := SearchEntries(entries, "operator", 0)
+
+		want := "Fixed &lt;script&gt;alert(&#34;<mark>operator</mark>&#34;)&lt;/script&gt; &amp; more"
+		if len(results) != 1 || results[0].Snippet != want {
+			t.Fatalf("Snippet = %q; want %q", results[0].Snippet, want)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		if results := SearchEntries(entries, "kubernetes", 1); len(results) != 1 {
+			t.Errorf("expected 1 result, got %d", len(results))
+		}
+	})
+
+	t.Run("blank text matches nothing", func(t *testing.T) {
+		if results := SearchEntries(entries, "  ", 0); len(results) != 0 {
+			t.Errorf("expected no results, got %+v", results)
+		}
+	})
+}
+
+func TestNotesSearch(t *testing.T) {
+	notes := NewNotes(newSeededStore())
+
+	results, err := notes.Search(context.Background(), "youtube", 10)
+	assertNilError(t, err)
+
+	if len(results) != 1 || results[0].Entry.ID != systemDesignLog.ID {
+		t.Fatalf("expected the system design entry, got %+v", results)
+	}
+}
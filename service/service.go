@@ -1,15 +1,35 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/turnerem/zenzen/core"
 )
 
+// ErrConflict is returned when saving an entry whose stored version has moved on
+var ErrConflict = errors.New("entry was modified by another writer")
+
+// Store persists entries.
+// SaveEntry with a non-zero Version only succeeds if the stored version still matches, and
+// bumps it; a zero Version saves unconditionally. Either way the stored version increases.
+// SaveEntryVersion saves as SaveEntry does and returns the version it stored, read as it writes.
+// SaveEntries and DeleteEntries apply every change or none; see UniqueEntries for repeated IDs.
+// WriteEntries applies saves then deletes in one transaction, so a failure in either changes nothing.
+// DeleteEntryVersion deletes an entry only while its stored version matches, checked as it
+// deletes (a version of 0 matches any), and returns NotDeletedError when nothing matched.
 type Store interface {
-	GetAll() (map[string]core.Entry, error)
-	SaveEntry(entry core.Entry) error
-	DeleteEntry(id string) error
+	GetAll(ctx context.Context) (map[string]core.Entry, error)
+	SaveEntry(ctx context.Context, entry core.Entry) error
+	SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error)
+	SaveEntries(ctx context.Context, entries []core.Entry) error
+	DeleteEntry(ctx context.Context, id string) error
+	DeleteEntries(ctx context.Context, ids []string) error
+	DeleteEntryVersion(ctx context.Context, id string, version int64) error
+	WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error
+	Query(ctx context.Context, q Query) (QueryResult, error)
 }
 
 type Notes struct {
@@ -17,20 +37,13 @@ type Notes struct {
 	Entries map[string]core.Entry
 }
 
-type Opts struct {
-	// Page     int
-	// PageSize int
-	SortBy string // e.g., "Title", "Date"
-	// Filter   string // e.g., "Tag:learning"
-}
-
 func NewNotes(store Store) *Notes {
 	return &Notes{store: store}
 }
 
-func (l *Notes) LoadAll() error {
+func (l *Notes) LoadAll(ctx context.Context) error {
 	// read in all logs and store in l.Entries
-	logs, err := l.store.GetAll()
+	logs, err := l.store.GetAll(ctx)
 	if err != nil {
 		return err
 	}
@@ -39,22 +52,95 @@ func (l *Notes) LoadAll() error {
 	return nil
 }
 
-func (l *Notes) Delete(ID string) error {
+func (l *Notes) Delete(ctx context.Context, ID string) error {
+	if err := DeleteEntry(ctx, l.store, ID); err != nil {
+		return err
+	}
 	delete(l.Entries, ID)
-	return l.store.DeleteEntry(ID)
+	return nil
 }
 
 // SaveEntry persists a single entry to storage
-// Sets LastModifiedTimestamp to current time before saving
-func (l *Notes) SaveEntry(entry core.Entry) error {
+// Sets LastModifiedTimestamp to current time before saving.
+// Returns ErrConflict if the entry changed in storage since it was loaded.
+func (l *Notes) SaveEntry(ctx context.Context, entry core.Entry) error {
+	saved, err := SaveEntry(ctx, l.store, entry)
+	if err != nil {
+		return err
+	}
+
+	l.Entries[entry.ID] = saved
+	return nil
+}
+
+// SaveEntry saves a user's edit to an entry, setting LastModifiedTimestamp to now, and
+// returns the entry as stored, at its new version. A non-zero Version makes the save
+// conditional, returning ErrConflict if the entry changed in storage since it was read.
+func SaveEntry(ctx context.Context, store Store, entry core.Entry) (core.Entry, error) {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return core.Entry{}, err
+	}
+
 	// Set last modified timestamp for user edits
 	entry.LastModifiedTimestamp = time.Now()
 
-	l.Entries[entry.ID] = entry
-	return l.store.SaveEntry(entry)
+	// Track the version the store wrote so the next save isn't a conflict
+	version, err := store.SaveEntryVersion(ctx, entry)
+	if err != nil {
+		return core.Entry{}, err
+	}
+	entry.Version = version
+
+	return entry, nil
 }
 
-// returns logs for page size, filtered and sorted
-// func (l *Notes) ListLogsSorted(opts Opts) ([]core.Entry, error) {
+// DeleteEntry deletes an entry; deleting a missing entry is not an error
+func DeleteEntry(ctx context.Context, store Store, id string) error {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return err
+	}
+	return store.DeleteEntry(ctx, id)
+}
+
+// DeleteEntryVersion deletes an entry only if it is still at version, checked by the store as
+// it deletes. It returns ErrConflict if the entry has moved on or is gone. A version of 0
+// deletes whatever is stored, returning ErrEntryNotFound if there is nothing to delete.
+func DeleteEntryVersion(ctx context.Context, store Store, id string, version int64) error {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return err
+	}
+	return store.DeleteEntryVersion(ctx, id, version)
+}
+
+// NotDeletedError is what DeleteEntryVersion returns when nothing matched id at version
+func NotDeletedError(id string, version int64) error {
+	if version == 0 {
+		return fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+	return fmt.Errorf("%w: entry %s is not at version %d", ErrConflict, id, version)
+}
+
+// ListTags returns every tag with its usage count, by name
+func (l *Notes) ListTags(ctx context.Context) ([]Tag, error) {
+	return ListTags(ctx, l.store)
+}
 
-// }
+// MergeTags replaces the from tags with to on every entry, keeping the loaded entries in step.
+// Renaming a tag is merging it on its own.
+func (l *Notes) MergeTags(ctx context.Context, from []string, to string) error {
+	changed, err := MergeTags(ctx, l.store, from, to)
+	for _, entry := range changed {
+		l.Entries[entry.ID] = entry
+	}
+	return err
+}
+
+// ListLogsSorted returns one page of entries, filtered and sorted by the store
+func (l *Notes) ListLogsSorted(ctx context.Context, q Query) (QueryResult, error) {
+	return l.store.Query(ctx, q)
+}
+
+// Search returns entries matching text, most relevant first
+func (l *Notes) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+	return Search(ctx, l.store, text, limit)
+}
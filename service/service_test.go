@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -25,9 +27,6 @@ const (
 }`
 )
 
-type MockStore struct {
-}
-
 var (
 	k8sLog = core.Entry{
 		ID:                    "1",
@@ -50,27 +49,14 @@ var (
 	}
 )
 
-func (m *MockStore) GetAll() (map[string]core.Entry, error) {
-	return map[string]core.Entry{
-		"1": k8sLog,
-		"2": systemDesignLog,
-	}, nil
-}
-
-func (m *MockStore) SaveEntry(entry core.Entry) error {
-	// Mock save - does nothing
-	return nil
-}
-
-func (m *MockStore) DeleteEntry(id string) error {
-	// Mock delete - does nothing
-	return nil
+func newSeededStore() *MemoryStore {
+	return NewMemoryStore(k8sLog, systemDesignLog)
 }
 
 func TestLoadAll(t *testing.T) {
 	t.Run("get list", func(t *testing.T) {
-		notes := NewNotes(&MockStore{})
-		err := notes.LoadAll()
+		notes := NewNotes(newSeededStore())
+		err := notes.LoadAll(context.Background())
 
 		assertNilError(t, err)
 
@@ -85,11 +71,11 @@ func TestLoadAll(t *testing.T) {
 
 func TestDelete(t *testing.T) {
 	t.Run("delete existing log", func(t *testing.T) {
-		notes := NewNotes(&MockStore{})
-		err := notes.LoadAll()
+		notes := NewNotes(newSeededStore())
+		err := notes.LoadAll(context.Background())
 		assertNilError(t, err)
 
-		notes.Delete("1")
+		notes.Delete(context.Background(), "1")
 
 		want := map[string]core.Entry{
 			"2": systemDesignLog,
@@ -100,11 +86,11 @@ func TestDelete(t *testing.T) {
 	})
 
 	t.Run("delete non-existing log", func(t *testing.T) {
-		notes := NewNotes(&MockStore{})
-		err := notes.LoadAll()
+		notes := NewNotes(newSeededStore())
+		err := notes.LoadAll(context.Background())
 		assertNilError(t, err)
 
-		notes.Delete("non-existing-id")
+		notes.Delete(context.Background(), "non-existing-id")
 
 		want := map[string]core.Entry{
 			"1": k8sLog,
@@ -115,6 +101,70 @@ func TestDelete(t *testing.T) {
 	})
 }
 
+func TestSaveEntry(t *testing.T) {
+	t.Run("consecutive saves track the version", func(t *testing.T) {
+		notes := NewNotes(NewMemoryStore())
+		assertNilError(t, notes.LoadAll(context.Background()))
+
+		entry := core.Entry{ID: "1", Title: "Draft"}
+		assertNilError(t, notes.SaveEntry(context.Background(), entry))
+
+		entry = notes.Entries["1"]
+		entry.Title = "Second draft"
+		assertNilError(t, notes.SaveEntry(context.Background(), entry))
+
+		assertEquality(t, notes.Entries["1"].Version, int64(2))
+	})
+
+	t.Run("stale copy conflicts", func(t *testing.T) {
+		store := NewMemoryStore()
+		notes := NewNotes(store)
+		assertNilError(t, notes.LoadAll(context.Background()))
+		assertNilError(t, notes.SaveEntry(context.Background(), core.Entry{ID: "1", Title: "Draft"}))
+		stale := notes.Entries["1"]
+
+		// Another writer saves first
+		other := stale
+		other.Title = "Edited elsewhere"
+		assertNilError(t, store.SaveEntry(context.Background(), other))
+
+		stale.Title = "Edited here"
+		err := notes.SaveEntry(context.Background(), stale)
+		if !errors.Is(err, ErrConflict) {
+			t.Fatalf("expected ErrConflict, got %v", err)
+		}
+		assertEquality(t, notes.Entries["1"].Title, "Draft")
+	})
+
+	t.Run("write straight after the save conflicts", func(t *testing.T) {
+		store := &editAfterSave{MemoryStore: NewMemoryStore(), edit: core.Entry{ID: "1", Title: "Edited elsewhere"}}
+
+		// The save reports the version it wrote, not the other writer's that followed
+		saved, err := SaveEntry(context.Background(), store, core.Entry{ID: "1", Title: "Draft"})
+		assertNilError(t, err)
+		assertEquality(t, saved.Version, int64(1))
+
+		saved.Title = "Second draft"
+		if _, err := SaveEntry(context.Background(), store.MemoryStore, saved); !errors.Is(err, ErrConflict) {
+			t.Errorf("expected ErrConflict, got %v", err)
+		}
+	})
+}
+
+// editAfterSave saves another writer's edit right after each save
+type editAfterSave struct {
+	*MemoryStore
+	edit core.Entry
+}
+
+func (s *editAfterSave) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	version, err := s.MemoryStore.SaveEntryVersion(ctx, entry)
+	if err != nil {
+		return version, err
+	}
+	return version, s.MemoryStore.SaveEntry(ctx, s.edit)
+}
+
 func assertEquality[V any](t *testing.T, got, want V) {
 	t.Helper()
 
@@ -0,0 +1,379 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+// TagBias totals estimated against actual time for the finished entries under a tag
+type TagBias struct {
+	Tag       string
+	Entries   int           // Finished entries with an estimate
+	Estimated time.Duration // Sum of their estimates
+	Actual    time.Duration // Sum of their actual durations
+}
+
+// Ratio is actual time over estimated: above 1 means the work took longer than estimated.
+// It is 0 when nothing was estimated.
+func (b TagBias) Ratio() float64 {
+	if b.Estimated <= 0 {
+		return 0
+	}
+	return float64(b.Actual) / float64(b.Estimated)
+}
+
+// BiasByTag rolls estimation bias up each tag's subtree, so infra includes entries tagged
+// infra/k8s. Only finished entries with an estimate count, and each counts once per tag
+// however many of its tags sit beneath it. Results are in tag order.
+func BiasByTag(entries map[string]core.Entry) []TagBias {
+	byTag := make(map[string]*TagBias)
+	for _, entry := range entries {
+		if entry.EstimatedDuration <= 0 {
+			continue
+		}
+		actual, ok := actualDuration(entry)
+		if !ok {
+			continue
+		}
+
+		for _, tag := range rolledUpTags(entry) {
+			bias := byTag[tag]
+			if bias == nil {
+				bias = &TagBias{Tag: tag}
+				byTag[tag] = bias
+			}
+			bias.Entries++
+			bias.Estimated += entry.EstimatedDuration
+			bias.Actual += actual
+		}
+	}
+
+	biases := make([]TagBias, 0, len(byTag))
+	for _, bias := range byTag {
+		biases = append(biases, *bias)
+	}
+	slices.SortFunc(biases, func(a, b TagBias) int {
+		return CompareTags(a.Tag, b.Tag)
+	})
+	return biases
+}
+
+// Period is the span of time stats are grouped by, keyed on when entries started
+type Period string
+
+const (
+	PeriodWeek  Period = "week" // Monday to Sunday, UTC
+	PeriodMonth Period = "month"
+)
+
+// ParsePeriod returns the period named by s; blank is PeriodWeek
+func ParsePeriod(s string) (Period, error) {
+	switch period := Period(strings.ToLower(strings.TrimSpace(s))); period {
+	case "":
+		return PeriodWeek, nil
+	case PeriodWeek, PeriodMonth:
+		return period, nil
+	default:
+		return "", fmt.Errorf("%w: period must be %s or %s", ErrInvalidQuery, PeriodWeek, PeriodMonth)
+	}
+}
+
+// Start returns the start of the period containing t, in UTC
+func (p Period) Start(t time.Time) time.Time {
+	t = t.UTC()
+	if p == PeriodMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// EstimateSize is a band of estimates, from Min inclusive to Max exclusive; a zero Max is unbounded
+type EstimateSize struct {
+	Label string
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// EstimateSizes are the bands BiasBreakdown groups estimates into, smallest first
+var EstimateSizes = []EstimateSize{
+	{Label: "under_1h", Max: time.Hour},
+	{Label: "1h_to_4h", Min: time.Hour, Max: 4 * time.Hour},
+	{Label: "4h_to_1d", Min: 4 * time.Hour, Max: core.DAY},
+	{Label: "1d_or_more", Min: core.DAY},
+}
+
+// Contains reports whether an estimate falls within the band
+func (s EstimateSize) Contains(estimate time.Duration) bool {
+	return estimate >= s.Min && (s.Max == 0 || estimate < s.Max)
+}
+
+// RatioStats summarises the actual-over-estimated ratios of a group of finished entries.
+// Above 1 means the work took longer than estimated.
+type RatioStats struct {
+	Entries   int
+	Estimated time.Duration // Sum of the estimates
+	Actual    time.Duration // Sum of the actual durations
+	Mean      float64       // Mean of each entry's ratio, so one long task doesn't dominate
+	Median    float64
+	P25       float64
+	P75       float64
+	P90       float64
+	Trend     float64 // Change in ratio per week of start time, by least squares; 0 until there's a spread to fit
+}
+
+// TagRatios are the ratios of the entries under a tag
+type TagRatios struct {
+	Tag string
+	RatioStats
+}
+
+// PeriodRatios are the ratios of the entries started in a period
+type PeriodRatios struct {
+	Start time.Time
+	RatioStats
+}
+
+// SizeRatios are the ratios of the entries with an estimate in a band
+type SizeRatios struct {
+	Size EstimateSize
+	RatioStats
+}
+
+// BiasReport breaks estimation bias down every way the stats endpoint reports it
+type BiasReport struct {
+	Overall  RatioStats
+	ByTag    []TagRatios    // Rolled up each tag's subtree, in tag order
+	ByPeriod []PeriodRatios // Oldest first
+	BySize   []SizeRatios   // In EstimateSizes order, skipping empty bands
+}
+
+// ratioSample is one finished, estimated entry
+type ratioSample struct {
+	started   time.Time
+	estimated time.Duration
+	actual    time.Duration
+}
+
+func (s ratioSample) ratio() float64 {
+	return float64(s.actual) / float64(s.estimated)
+}
+
+// BiasBreakdown summarises the estimation bias of finished entries with an estimate: overall,
+// by tag like BiasByTag, by the period they started in and by the size of their estimate
+func BiasBreakdown(entries map[string]core.Entry, period Period) BiasReport {
+	var all []ratioSample
+	byTag := make(map[string][]ratioSample)
+	byPeriod := make(map[time.Time][]ratioSample)
+	bySize := make([][]ratioSample, len(EstimateSizes))
+
+	for _, entry := range entries {
+		if entry.EstimatedDuration <= 0 {
+			continue
+		}
+		actual, ok := actualDuration(entry)
+		if !ok {
+			continue
+		}
+
+		sample := ratioSample{started: entry.StartedAtTimestamp, estimated: entry.EstimatedDuration, actual: actual}
+		all = append(all, sample)
+		for _, tag := range rolledUpTags(entry) {
+			byTag[tag] = append(byTag[tag], sample)
+		}
+		start := period.Start(entry.StartedAtTimestamp)
+		byPeriod[start] = append(byPeriod[start], sample)
+		for i, size := range EstimateSizes {
+			if size.Contains(entry.EstimatedDuration) {
+				bySize[i] = append(bySize[i], sample)
+				break
+			}
+		}
+	}
+
+	report := BiasReport{
+		Overall:  summariseRatios(all),
+		ByTag:    make([]TagRatios, 0, len(byTag)),
+		ByPeriod: make([]PeriodRatios, 0, len(byPeriod)),
+		BySize:   []SizeRatios{},
+	}
+	for tag, samples := range byTag {
+		report.ByTag = append(report.ByTag, TagRatios{Tag: tag, RatioStats: summariseRatios(samples)})
+	}
+	slices.SortFunc(report.ByTag, func(a, b TagRatios) int {
+		return CompareTags(a.Tag, b.Tag)
+	})
+	for start, samples := range byPeriod {
+		report.ByPeriod = append(report.ByPeriod, PeriodRatios{Start: start, RatioStats: summariseRatios(samples)})
+	}
+	slices.SortFunc(report.ByPeriod, func(a, b PeriodRatios) int {
+		return a.Start.Compare(b.Start)
+	})
+	for i, samples := range bySize {
+		if len(samples) > 0 {
+			report.BySize = append(report.BySize, SizeRatios{Size: EstimateSizes[i], RatioStats: summariseRatios(samples)})
+		}
+	}
+	return report
+}
+
+// summariseRatios computes the stats of a group of samples; none gives the zero value
+func summariseRatios(samples []ratioSample) RatioStats {
+	stats := RatioStats{Entries: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	ratios := make([]float64, len(samples))
+	sum := 0.0
+	for i, sample := range samples {
+		stats.Estimated += sample.estimated
+		stats.Actual += sample.actual
+		ratios[i] = sample.ratio()
+		sum += ratios[i]
+	}
+	slices.Sort(ratios)
+
+	stats.Mean = sum / float64(len(ratios))
+	stats.Median = percentile(ratios, 0.5)
+	stats.P25 = percentile(ratios, 0.25)
+	stats.P75 = percentile(ratios, 0.75)
+	stats.P90 = percentile(ratios, 0.9)
+	stats.Trend = ratioTrend(samples)
+	return stats
+}
+
+// percentile interpolates linearly between the closest ranks of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// ratioTrend fits a line through the samples' ratios against their start times, in weeks
+func ratioTrend(samples []ratioSample) float64 {
+	first := samples[0].started
+	for _, sample := range samples {
+		if sample.started.Before(first) {
+			first = sample.started
+		}
+	}
+
+	var meanX, meanY float64
+	for _, sample := range samples {
+		meanX += float64(sample.started.Sub(first)) / float64(core.WEEK)
+		meanY += sample.ratio()
+	}
+	meanX /= float64(len(samples))
+	meanY /= float64(len(samples))
+
+	var covariance, variance float64
+	for _, sample := range samples {
+		dx := float64(sample.started.Sub(first))/float64(core.WEEK) - meanX
+		covariance += dx * (sample.ratio() - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
+
+// TagTime is the actual time spent on the entries under a tag
+type TagTime struct {
+	Tag     string
+	Entries int
+	Actual  time.Duration
+}
+
+// PeriodTime is the actual time spent on the entries started in a period
+type PeriodTime struct {
+	Start   time.Time
+	Entries int           // Every entry counts once, tagged or not
+	Actual  time.Duration // So this is the period's total
+	ByTag   []TagTime     // Rolled up each tag's subtree, in tag order
+}
+
+// TimeByPeriod totals the actual time of finished entries by the period they started in,
+// oldest first. Like BiasByTag, an entry counts once toward each tag above its own, so a
+// period's tags can add up to more than its total.
+func TimeByPeriod(entries map[string]core.Entry, period Period) []PeriodTime {
+	type allocation struct {
+		total PeriodTime
+		byTag map[string]*TagTime
+	}
+	byPeriod := make(map[time.Time]*allocation)
+
+	for _, entry := range entries {
+		actual, ok := actualDuration(entry)
+		if !ok {
+			continue
+		}
+
+		start := period.Start(entry.StartedAtTimestamp)
+		alloc := byPeriod[start]
+		if alloc == nil {
+			alloc = &allocation{total: PeriodTime{Start: start}, byTag: make(map[string]*TagTime)}
+			byPeriod[start] = alloc
+		}
+		alloc.total.Entries++
+		alloc.total.Actual += actual
+
+		for _, tag := range rolledUpTags(entry) {
+			spent := alloc.byTag[tag]
+			if spent == nil {
+				spent = &TagTime{Tag: tag}
+				alloc.byTag[tag] = spent
+			}
+			spent.Entries++
+			spent.Actual += actual
+		}
+	}
+
+	periods := make([]PeriodTime, 0, len(byPeriod))
+	for _, alloc := range byPeriod {
+		spent := alloc.total
+		spent.ByTag = make([]TagTime, 0, len(alloc.byTag))
+		for _, tag := range alloc.byTag {
+			spent.ByTag = append(spent.ByTag, *tag)
+		}
+		slices.SortFunc(spent.ByTag, func(a, b TagTime) int {
+			return CompareTags(a.Tag, b.Tag)
+		})
+		periods = append(periods, spent)
+	}
+	slices.SortFunc(periods, func(a, b PeriodTime) int {
+		return a.Start.Compare(b.Start)
+	})
+	return periods
+}
+
+// actualDuration is how long a finished entry took; unfinished entries and those with no
+// start time have none
+func actualDuration(entry core.Entry) (time.Duration, bool) {
+	if entry.InProgress() || entry.StartedAtTimestamp.IsZero() {
+		return 0, false
+	}
+	return entry.EndedAtTimestamp.Sub(entry.StartedAtTimestamp), true
+}
+
+// rolledUpTags returns an entry's tags and every tag above them, each once
+func rolledUpTags(entry core.Entry) []string {
+	var tags []string
+	counted := make(map[string]bool)
+	for _, tag := range entry.Tags {
+		for _, ancestor := range TagAncestors(tag) {
+			if !counted[ancestor] {
+				counted[ancestor] = true
+				tags = append(tags, ancestor)
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestBiasByTag(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	finished := func(id string, estimate, actual time.Duration, tags ...string) core.Entry {
+		return core.Entry{ID: id, Tags: tags, StartedAtTimestamp: start, EndedAtTimestamp: start.Add(actual), EstimatedDuration: estimate}
+	}
+
+	biases := BiasByTag(map[string]core.Entry{
+		"1": finished("1", time.Hour, 2*time.Hour, "infra/k8s"),
+		"2": finished("2", 2*time.Hour, time.Hour, "infra/terraform", "go"),
+		"3": finished("3", time.Hour, 3*time.Hour, "infra/k8s/helm", "infra/k8s"),                        // Counts once for infra/k8s
+		"4": finished("4", 0, time.Hour, "infra"),                                                        // No estimate
+		"5": {ID: "5", Tags: []string{"infra"}, StartedAtTimestamp: start, EstimatedDuration: time.Hour}, // In progress
+	})
+
+	byTag := make(map[string]TagBias)
+	var order []string
+	for _, bias := range biases {
+		byTag[bias.Tag] = bias
+		order = append(order, bias.Tag)
+	}
+
+	assertEquality(t, order, []string{"go", "infra", "infra/k8s", "infra/k8s/helm", "infra/terraform"})
+	assertEquality(t, byTag["infra"], TagBias{Tag: "infra", Entries: 3, Estimated: 4 * time.Hour, Actual: 6 * time.Hour})
+	assertEquality(t, byTag["infra/k8s"], TagBias{Tag: "infra/k8s", Entries: 2, Estimated: 2 * time.Hour, Actual: 5 * time.Hour})
+	assertEquality(t, byTag["infra"].Ratio(), 1.5)
+	assertEquality(t, byTag["go"].Ratio(), 0.5)
+	assertEquality(t, TagBias{}.Ratio(), 0.0)
+}
+
+func TestPeriodStart(t *testing.T) {
+	sunday := time.Date(2025, 3, 9, 23, 0, 0, 0, time.UTC)
+	assertEquality(t, PeriodWeek.Start(sunday), time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC))
+	assertEquality(t, PeriodWeek.Start(sunday.Add(time.Hour)), time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC))
+	assertEquality(t, PeriodMonth.Start(sunday), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	if _, err := ParsePeriod("fortnight"); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("ParsePeriod(fortnight) error = %v; want ErrInvalidQuery", err)
+	}
+}
+
+func TestBiasBreakdown(t *testing.T) {
+	week1 := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	week2 := week1.Add(core.WEEK)
+	finished := func(id string, start time.Time, estimate, actual time.Duration, tags ...string) core.Entry {
+		return core.Entry{ID: id, Tags: tags, StartedAtTimestamp: start, EndedAtTimestamp: start.Add(actual), EstimatedDuration: estimate}
+	}
+
+	report := BiasBreakdown(map[string]core.Entry{
+		"1": finished("1", week1, 30*time.Minute, time.Hour, "infra/k8s"), // 2
+		"2": finished("2", week1, 2*time.Hour, 2*time.Hour, "infra"),      // 1
+		"3": finished("3", week2, 2*time.Hour, 6*time.Hour, "go"),         // 3
+		"4": finished("4", week2, 2*core.DAY, core.DAY, "go"),             // 0.5
+		"5": finished("5", week2, 0, time.Hour, "go"),                     // No estimate
+	}, PeriodWeek)
+
+	overall := report.Overall
+	assertEquality(t, overall.Entries, 4)
+	assertEquality(t, overall.Mean, 1.625)
+	assertEquality(t, overall.Median, 1.5)
+	assertEquality(t, overall.P25, 0.875)
+	assertEquality(t, overall.P90, 2.7)
+	assertEquality(t, overall.Trend, 0.25) // Mean ratio 1.5 in week 1, 1.75 in week 2
+
+	var tags []string
+	for _, byTag := range report.ByTag {
+		tags = append(tags, byTag.Tag)
+	}
+	assertEquality(t, tags, []string{"go", "infra", "infra/k8s"})
+	assertEquality(t, report.ByTag[1].Mean, 1.5)
+
+	assertEquality(t, len(report.ByPeriod), 2)
+	assertEquality(t, report.ByPeriod[0].Start, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC))
+	assertEquality(t, report.ByPeriod[1].Actual, 6*time.Hour+core.DAY)
+
+	var sizes []string
+	for _, bySize := range report.BySize {
+		sizes = append(sizes, bySize.Size.Label)
+	}
+	assertEquality(t, sizes, []string{"under_1h", "1h_to_4h", "1d_or_more"})
+	assertEquality(t, report.BySize[1].Entries, 2)
+
+	empty := BiasBreakdown(nil, PeriodMonth)
+	assertEquality(t, empty.Overall, RatioStats{})
+}
+
+func TestTimeByPeriod(t *testing.T) {
+	monday := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	finished := func(id string, start time.Time, actual time.Duration, tags ...string) core.Entry {
+		return core.Entry{ID: id, Tags: tags, StartedAtTimestamp: start, EndedAtTimestamp: start.Add(actual)}
+	}
+
+	periods := TimeByPeriod(map[string]core.Entry{
+		"1": finished("1", monday, time.Hour, "infra/k8s"),
+		"2": finished("2", monday.Add(2*core.DAY), 2*time.Hour, "infra/k8s/helm", "infra/k8s"),
+		"3": finished("3", monday.Add(3*core.DAY), 30*time.Minute),
+		"4": finished("4", monday.Add(core.WEEK), time.Hour, "go"),
+		"5": {ID: "5", Tags: []string{"go"}, StartedAtTimestamp: monday}, // In progress
+	}, PeriodWeek)
+
+	assertEquality(t, len(periods), 2)
+	assertEquality(t, periods[0], PeriodTime{
+		Start:   time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		Entries: 3,
+		Actual:  3*time.Hour + 30*time.Minute,
+		ByTag: []TagTime{
+			{Tag: "infra", Entries: 2, Actual: 3 * time.Hour},
+			{Tag: "infra/k8s", Entries: 2, Actual: 3 * time.Hour},
+			{Tag: "infra/k8s/helm", Entries: 1, Actual: 2 * time.Hour},
+		},
+	})
+	assertEquality(t, periods[1].ByTag, []TagTime{{Tag: "go", Entries: 1, Actual: time.Hour}})
+}
@@ -0,0 +1,1170 @@
+// Package storetest is a conformance suite for service.Store implementations.
+//
+// Each backend runs it from its own tests:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) service.Store {
+//			return service.NewMemoryStore()
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// Factory returns a new, empty store for a single subtest
+type Factory func(t *testing.T) service.Store
+
+// Run exercises the behaviour every Store must share
+func Run(t *testing.T, newStore Factory) {
+	t.Run("empty store", func(t *testing.T) {
+		store := newStore(t)
+
+		entries := getAll(t, store)
+		if len(entries) != 0 {
+			t.Errorf("expected empty store, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		store := newStore(t)
+		want := fullEntry()
+
+		save(t, store, want)
+
+		entries := getAll(t, store)
+		got, ok := entries[want.ID]
+		if !ok {
+			t.Fatalf("entry %s not found after save", want.ID)
+		}
+		AssertEntryEqual(t, got, want)
+	})
+
+	t.Run("upsert replaces existing entry", func(t *testing.T) {
+		store := newStore(t)
+		original := fullEntry()
+		save(t, store, original)
+
+		updated := original
+		updated.Title = "Updated title"
+		updated.Tags = []string{"changed"}
+		updated.EndedAtTimestamp = time.Time{}
+		updated.EstimatedDuration = 45 * time.Minute
+		updated.Body = "Rewritten body"
+		updated.LastModifiedTimestamp = original.LastModifiedTimestamp.Add(time.Hour)
+		save(t, store, updated)
+
+		entries := getAll(t, store)
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 entry after upsert, got %d", len(entries))
+		}
+		AssertEntryEqual(t, entries[original.ID], updated)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		store := newStore(t)
+		keep := fullEntry()
+		remove := fullEntry()
+		remove.ID = "remove-me"
+		save(t, store, keep)
+		save(t, store, remove)
+
+		if err := store.DeleteEntry(context.Background(), remove.ID); err != nil {
+			t.Fatalf("DeleteEntry() error = %v", err)
+		}
+
+		entries := getAll(t, store)
+		if _, ok := entries[remove.ID]; ok {
+			t.Errorf("entry %s still present after delete", remove.ID)
+		}
+		if _, ok := entries[keep.ID]; !ok {
+			t.Errorf("entry %s was deleted but should have been kept", keep.ID)
+		}
+	})
+
+	t.Run("delete missing ID", func(t *testing.T) {
+		store := newStore(t)
+
+		if err := store.DeleteEntry(context.Background(), "does-not-exist"); err != nil {
+			t.Errorf("DeleteEntry() of missing ID error = %v; want nil", err)
+		}
+	})
+
+	t.Run("zero timestamps", func(t *testing.T) {
+		store := newStore(t)
+		want := core.Entry{
+			ID:    "zero",
+			Title: "No timestamps",
+		}
+		save(t, store, want)
+
+		got := getAll(t, store)[want.ID]
+		if !got.StartedAtTimestamp.IsZero() {
+			t.Errorf("StartedAtTimestamp = %v; want zero", got.StartedAtTimestamp)
+		}
+		if !got.EndedAtTimestamp.IsZero() {
+			t.Errorf("EndedAtTimestamp = %v; want zero", got.EndedAtTimestamp)
+		}
+		if !got.InProgress() {
+			t.Errorf("InProgress() = false; want true for zero EndedAtTimestamp")
+		}
+	})
+
+	t.Run("nil tags", func(t *testing.T) {
+		store := newStore(t)
+		want := fullEntry()
+		want.Tags = nil
+		save(t, store, want)
+
+		got := getAll(t, store)[want.ID]
+		if len(got.Tags) != 0 {
+			t.Errorf("Tags = %v; want none", got.Tags)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		store := newStore(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := store.GetAll(ctx); err == nil {
+			t.Errorf("GetAll() with cancelled context error = nil; want error")
+		}
+		if err := store.SaveEntry(ctx, fullEntry()); err == nil {
+			t.Errorf("SaveEntry() with cancelled context error = nil; want error")
+		}
+	})
+
+	t.Run("query filters", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		day2 := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+		inProgress, finished := true, false
+		tests := []struct {
+			name  string
+			query service.Query
+			want  []string
+		}{
+			{"no filter", service.Query{}, []string{"e3", "e2", "e1", "e4"}},
+			{"IDs", service.Query{IDs: []string{"e1", "e4"}}, []string{"e1", "e4"}},
+			{"one tag", service.Query{Tags: []string{"go"}}, []string{"e2", "e1"}},
+			{"every tag", service.Query{Tags: []string{"go", "work"}}, []string{"e1"}},
+			{"text is case-insensitive", service.Query{Text: "GO"}, []string{"e2", "e1"}},
+			{"text searches body", service.Query{Text: "type param"}, []string{"e1"}},
+			{"text wildcards are literal", service.Query{Text: "100%"}, []string{"e2"}},
+			{"in progress", service.Query{InProgress: &inProgress}, []string{"e2", "e4"}},
+			{"finished", service.Query{InProgress: &finished}, []string{"e3", "e1"}},
+			{"started from", service.Query{StartedAt: service.TimeRange{From: day2}}, []string{"e3", "e2"}},
+			{"started before", service.Query{StartedAt: service.TimeRange{To: day2}}, []string{"e1"}},
+			{"ended from", service.Query{EndedAt: service.TimeRange{From: day2}}, []string{"e3"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result := query(t, store, tt.query)
+				assertIDs(t, result.Entries, tt.want)
+				if result.NextCursor != "" {
+					t.Errorf("NextCursor = %q; want none without a limit", result.NextCursor)
+				}
+			})
+		}
+	})
+
+	t.Run("query tag subtree", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, tagTreeEntries())
+
+		tests := []struct {
+			filter string
+			want   []string
+		}{
+			{"infra", []string{"t1", "t2", "t3"}},
+			{"infra/k8s", []string{"t2", "t3"}},
+			{"infra/k8s/helm", []string{"t3"}},
+			{"infra/k", nil},            // Segments match whole
+			{"infra-x", []string{"t4"}}, // Not beneath infra
+			{"INFRA", []string{"t5"}},   // Case-sensitive, like tags themselves
+		}
+		for _, tt := range tests {
+			t.Run(tt.filter, func(t *testing.T) {
+				result := query(t, store, service.Query{Tags: []string{tt.filter}, SortBy: service.SortByStartedAt, Ascending: true})
+				assertIDs(t, result.Entries, tt.want)
+			})
+		}
+	})
+
+	t.Run("query sort", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		tests := []struct {
+			name  string
+			query service.Query
+			want  []string
+		}{
+			{"started newest first", service.Query{SortBy: service.SortByStartedAt}, []string{"e3", "e2", "e1", "e4"}},
+			{"started oldest first", service.Query{SortBy: service.SortByStartedAt, Ascending: true}, []string{"e1", "e2", "e3", "e4"}},
+			{"ended newest first", service.Query{SortBy: service.SortByEndedAt}, []string{"e3", "e1", "e4", "e2"}},
+			{"last modified oldest first", service.Query{SortBy: service.SortByLastModified, Ascending: true}, []string{"e4", "e3", "e2", "e1"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assertIDs(t, query(t, store, tt.query).Entries, tt.want)
+			})
+		}
+	})
+
+	t.Run("query pages", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		for _, ascending := range []bool{false, true} {
+			q := service.Query{Ascending: ascending, Limit: 1}
+			want := query(t, store, service.Query{Ascending: ascending}).Entries
+
+			var got []core.Entry
+			for page := 0; ; page++ {
+				if page > len(want) {
+					t.Fatalf("pagination did not terminate")
+				}
+				result := query(t, store, q)
+				got = append(got, result.Entries...)
+				if result.NextCursor == "" {
+					break
+				}
+				if len(result.Entries) != q.Limit {
+					t.Fatalf("page %d has %d entries; want %d", page, len(result.Entries), q.Limit)
+				}
+				q.Cursor = result.NextCursor
+			}
+			assertIDs(t, got, entryIDs(want))
+		}
+	})
+
+	t.Run("query counts every page", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		q := service.Query{Tags: []string{"go"}, Limit: 1, Count: true}
+		for page := 0; page < 2; page++ {
+			result := query(t, store, q)
+			if result.Total != 2 {
+				t.Errorf("page %d Total = %d; want 2", page, result.Total)
+			}
+			q.Cursor = result.NextCursor
+		}
+		if q.Cursor != "" {
+			t.Errorf("NextCursor = %q after the last page; want none", q.Cursor)
+		}
+
+		if result := query(t, store, service.Query{}); result.Total != 0 {
+			t.Errorf("Total = %d without Count; want 0", result.Total)
+		}
+	})
+
+	t.Run("query rejects unknown sort field", func(t *testing.T) {
+		store := newStore(t)
+
+		_, err := store.Query(context.Background(), service.Query{SortBy: "title; DROP TABLE entries"})
+		if !errors.Is(err, service.ErrInvalidQuery) {
+			t.Errorf("Query() error = %v; want ErrInvalidQuery", err)
+		}
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		store := newStore(t)
+		entry := fullEntry()
+		save(t, store, entry)
+
+		stored := getAll(t, store)[entry.ID]
+		if stored.Version != 1 {
+			t.Fatalf("new entry Version = %d; want 1", stored.Version)
+		}
+
+		// A save at the current version succeeds and bumps it
+		stored.Title = "First edit"
+		save(t, store, stored)
+		if got := getAll(t, store)[entry.ID].Version; got != 2 {
+			t.Errorf("Version after conditional save = %d; want 2", got)
+		}
+
+		// Saving the copy read at version 1 again would lose the first edit
+		stored.Title = "Stale edit"
+		if err := store.SaveEntry(context.Background(), stored); !errors.Is(err, service.ErrConflict) {
+			t.Errorf("stale SaveEntry() error = %v; want ErrConflict", err)
+		}
+		if got := getAll(t, store)[entry.ID].Title; got != "First edit" {
+			t.Errorf("stale save changed Title to %q", got)
+		}
+
+		// A zero version overwrites whatever is stored
+		stored.Version = 0
+		save(t, store, stored)
+		got := getAll(t, store)[entry.ID]
+		if got.Title != "Stale edit" || got.Version != 3 {
+			t.Errorf("unconditional save = (%q, %d); want (%q, 3)", got.Title, got.Version, "Stale edit")
+		}
+	})
+
+	t.Run("save entry version", func(t *testing.T) {
+		store := newStore(t)
+		entry := fullEntry()
+		ctx := context.Background()
+
+		// Each save reports the version it stored, unversioned or not
+		for want := int64(1); want <= 2; want++ {
+			version, err := store.SaveEntryVersion(ctx, entry)
+			if err != nil || version != want {
+				t.Fatalf("SaveEntryVersion() = %d, %v; want %d", version, err, want)
+			}
+		}
+		entry.Version = 2
+		if version, err := store.SaveEntryVersion(ctx, entry); err != nil || version != 3 {
+			t.Errorf("SaveEntryVersion(at 2) = %d, %v; want 3", version, err)
+		}
+		if _, err := store.SaveEntryVersion(ctx, entry); !errors.Is(err, service.ErrConflict) {
+			t.Errorf("stale SaveEntryVersion() error = %v; want ErrConflict", err)
+		}
+		if got := getAll(t, store)[entry.ID].Version; got != 3 {
+			t.Errorf("stored Version = %d; want 3", got)
+		}
+	})
+
+	t.Run("versioned save of missing entry conflicts", func(t *testing.T) {
+		store := newStore(t)
+		entry := fullEntry()
+		entry.Version = 1
+
+		// The entry was deleted since it was read
+		if err := store.SaveEntry(context.Background(), entry); !errors.Is(err, service.ErrConflict) {
+			t.Errorf("SaveEntry() error = %v; want ErrConflict", err)
+		}
+		if entries := getAll(t, store); len(entries) != 0 {
+			t.Errorf("expected the entry not to be recreated, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("save entries", func(t *testing.T) {
+		store := newStore(t)
+		existing := fullEntry()
+		save(t, store, existing)
+
+		updated := getAll(t, store)[existing.ID]
+		updated.Title = "Updated in a batch"
+		added := fullEntry()
+		added.ID = "added"
+
+		if err := store.SaveEntries(context.Background(), []core.Entry{updated, added}); err != nil {
+			t.Fatalf("SaveEntries() error = %v", err)
+		}
+
+		entries := getAll(t, store)
+		if got := entries[existing.ID]; got.Title != "Updated in a batch" || got.Version != 2 {
+			t.Errorf("updated entry = (%q, %d); want (%q, 2)", got.Title, got.Version, "Updated in a batch")
+		}
+		if got := entries[added.ID]; got.Version != 1 {
+			t.Errorf("added entry Version = %d; want 1", got.Version)
+		}
+	})
+
+	t.Run("save entries rolls back on conflict", func(t *testing.T) {
+		store := newStore(t)
+		existing := fullEntry()
+		save(t, store, existing)
+
+		stale := getAll(t, store)[existing.ID]
+		save(t, store, stale) // Moves the stored version on
+		stale.Title = "Stale edit"
+		added := fullEntry()
+		added.ID = "added"
+
+		err := store.SaveEntries(context.Background(), []core.Entry{added, stale})
+		if !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("SaveEntries() error = %v; want ErrConflict", err)
+		}
+
+		entries := getAll(t, store)
+		if _, ok := entries[added.ID]; ok {
+			t.Errorf("entry saved alongside a conflict was not rolled back")
+		}
+		if got := entries[existing.ID].Title; got != existing.Title {
+			t.Errorf("conflicting entry Title = %q; want %q", got, existing.Title)
+		}
+	})
+
+	t.Run("save entries keeps last copy of repeated ID", func(t *testing.T) {
+		store := newStore(t)
+		first := fullEntry()
+		last := first
+		last.Title = "Last copy"
+
+		if err := store.SaveEntries(context.Background(), []core.Entry{first, last}); err != nil {
+			t.Fatalf("SaveEntries() error = %v", err)
+		}
+
+		got := getAll(t, store)[first.ID]
+		if got.Title != "Last copy" || got.Version != 1 {
+			t.Errorf("entry = (%q, %d); want (%q, 1)", got.Title, got.Version, "Last copy")
+		}
+	})
+
+	t.Run("delete entries", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		if err := store.DeleteEntries(context.Background(), []string{"e1", "e3", "missing"}); err != nil {
+			t.Fatalf("DeleteEntries() error = %v", err)
+		}
+
+		assertIDs(t, query(t, store, service.Query{SortBy: service.SortByStartedAt, Ascending: true}).Entries, []string{"e2", "e4"})
+	})
+
+	t.Run("delete entry version", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+		current := getAll(t, store)["e1"].Version
+
+		// A stale version leaves the entry alone
+		err := store.DeleteEntryVersion(context.Background(), "e1", current+1)
+		if !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("DeleteEntryVersion(stale) error = %v; want ErrConflict", err)
+		}
+		if _, ok := getAll(t, store)["e1"]; !ok {
+			t.Fatalf("entry deleted at a stale version")
+		}
+
+		if err := store.DeleteEntryVersion(context.Background(), "e1", current); err != nil {
+			t.Fatalf("DeleteEntryVersion() error = %v", err)
+		}
+		if err := store.DeleteEntryVersion(context.Background(), "e1", current); !errors.Is(err, service.ErrConflict) {
+			t.Errorf("DeleteEntryVersion(deleted) error = %v; want ErrConflict", err)
+		}
+
+		if err := store.DeleteEntryVersion(context.Background(), "e2", 0); err != nil {
+			t.Fatalf("DeleteEntryVersion(any) error = %v", err)
+		}
+		if err := store.DeleteEntryVersion(context.Background(), "e2", 0); !errors.Is(err, service.ErrEntryNotFound) {
+			t.Errorf("DeleteEntryVersion(missing) error = %v; want ErrEntryNotFound", err)
+		}
+		assertIDs(t, query(t, store, service.Query{SortBy: service.SortByStartedAt, Ascending: true}).Entries, []string{"e3", "e4"})
+	})
+
+	t.Run("write entries", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+		added := fullEntry()
+		added.ID = "added"
+
+		if err := store.WriteEntries(context.Background(), []core.Entry{added}, []string{"e1", "missing"}); err != nil {
+			t.Fatalf("WriteEntries() error = %v", err)
+		}
+
+		entries := getAll(t, store)
+		if _, ok := entries["e1"]; ok {
+			t.Errorf("entry e1 was not deleted")
+		}
+		if got := entries[added.ID]; got.Title != added.Title || got.Version != 1 {
+			t.Errorf("added entry = (%q, %d); want (%q, 1)", got.Title, got.Version, added.Title)
+		}
+	})
+
+	t.Run("write entries rolls back on conflict", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+		stale := getAll(t, store)["e2"]
+		stale.Version++
+
+		err := store.WriteEntries(context.Background(), []core.Entry{stale}, []string{"e1"})
+		if !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("WriteEntries() error = %v; want ErrConflict", err)
+		}
+		if _, ok := getAll(t, store)["e1"]; !ok {
+			t.Errorf("entry deleted alongside a conflict was not rolled back")
+		}
+	})
+
+	t.Run("empty batches", func(t *testing.T) {
+		store := newStore(t)
+
+		if err := store.SaveEntries(context.Background(), nil); err != nil {
+			t.Errorf("SaveEntries(nil) error = %v", err)
+		}
+		if err := store.DeleteEntries(context.Background(), nil); err != nil {
+			t.Errorf("DeleteEntries(nil) error = %v", err)
+		}
+		if err := store.WriteEntries(context.Background(), nil, nil); err != nil {
+			t.Errorf("WriteEntries(nil, nil) error = %v", err)
+		}
+	})
+
+	t.Run("list tags", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		tags, err := service.ListTags(context.Background(), store)
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		assertTags(t, tags, "go:2,work:2")
+	})
+
+	t.Run("rename tag", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		changed, err := service.RenameTag(context.Background(), store, "work", "job")
+		if err != nil {
+			t.Fatalf("RenameTag() error = %v", err)
+		}
+		assertIDs(t, changed, []string{"e3", "e1"}) // In the default, newest-first order
+
+		entries := getAll(t, store)
+		if got := strings.Join(entries["e1"].Tags, ","); got != "go,job" {
+			t.Errorf("e1 Tags = %s; want go,job", got)
+		}
+		if got := entries["e1"].Version; got != changed[1].Version {
+			t.Errorf("e1 Version = %d; want the returned %d", got, changed[1].Version)
+		}
+		tags, err := service.ListTags(context.Background(), store)
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		assertTags(t, tags, "go:2,job:2")
+	})
+
+	t.Run("merge tags", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		// e1 has both tags, so it keeps a single copy of the target
+		if _, err := service.MergeTags(context.Background(), store, []string{"go", "work"}, "go"); err != nil {
+			t.Fatalf("MergeTags() error = %v", err)
+		}
+
+		entries := getAll(t, store)
+		if got := strings.Join(entries["e1"].Tags, ","); got != "go" {
+			t.Errorf("e1 Tags = %s; want go", got)
+		}
+		tags, err := service.ListTags(context.Background(), store)
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		assertTags(t, tags, "go:3")
+	})
+
+	t.Run("rename tag subtree", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, tagTreeEntries())
+
+		changed, err := service.RenameTag(context.Background(), store, "infra", "platform")
+		if err != nil {
+			t.Fatalf("RenameTag() error = %v", err)
+		}
+		if len(changed) != 3 {
+			t.Errorf("RenameTag() changed %d entries; want 3", len(changed))
+		}
+
+		entries := getAll(t, store)
+		if got := strings.Join(entries["t3"].Tags, ","); got != "platform/k8s/helm,platform/k8s" {
+			t.Errorf("t3 Tags = %s; want platform/k8s/helm,platform/k8s", got)
+		}
+		if got := strings.Join(entries["t4"].Tags, ","); got != "infra-x" {
+			t.Errorf("t4 Tags = %s; want infra-x untouched", got)
+		}
+	})
+
+	t.Run("tag metadata", func(t *testing.T) {
+		store := newStore(t)
+		if _, ok := store.(service.TagStore); !ok {
+			t.Skip("store does not keep tag metadata")
+		}
+		ctx := context.Background()
+		saveAll(t, store, queryEntries())
+
+		if err := service.SaveTag(ctx, store, service.Tag{Name: "work", Description: "Day job", Color: "#FF8800"}); err != nil {
+			t.Fatalf("SaveTag() error = %v", err)
+		}
+		if err := service.SaveTag(ctx, store, service.Tag{Name: "unused", Color: "#000000"}); err != nil {
+			t.Fatalf("SaveTag() error = %v", err)
+		}
+
+		tags, err := service.ListTags(ctx, store)
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		assertTags(t, tags, "go:2,unused:0,work:2")
+		if got := tags[2]; got.Description != "Day job" || got.Color != "#FF8800" {
+			t.Errorf("work metadata = (%q, %q); want (%q, %q)", got.Description, got.Color, "Day job", "#FF8800")
+		}
+
+		// Renaming carries the metadata over to the new name
+		if _, err := service.RenameTag(ctx, store, "work", "job"); err != nil {
+			t.Fatalf("RenameTag() error = %v", err)
+		}
+		tags, err = service.ListTags(ctx, store)
+		if err != nil {
+			t.Fatalf("ListTags() error = %v", err)
+		}
+		assertTags(t, tags, "go:2,job:2,unused:0")
+		if got := tags[1]; got.Description != "Day job" || got.Color != "#FF8800" {
+			t.Errorf("job metadata = (%q, %q); want (%q, %q)", got.Description, got.Color, "Day job", "#FF8800")
+		}
+	})
+
+	t.Run("merge tags rolls back on conflict", func(t *testing.T) {
+		store := newStore(t)
+		saveAll(t, store, queryEntries())
+
+		wrapped := staleStore{Store: store, stale: "e3"}
+		_, err := service.MergeTags(context.Background(), wrapped, []string{"work"}, "job")
+		if !errors.Is(err, service.ErrConflict) {
+			t.Fatalf("MergeTags() error = %v; want ErrConflict", err)
+		}
+
+		entries := getAll(t, store)
+		if got := strings.Join(entries["e1"].Tags, ","); got != "go,work" {
+			t.Errorf("e1 Tags = %s; want go,work", got)
+		}
+	})
+
+	t.Run("saved entry is not aliased", func(t *testing.T) {
+		store := newStore(t)
+		entry := fullEntry()
+		save(t, store, entry)
+
+		entry.Tags[0] = "mutated"
+		got := getAll(t, store)[entry.ID]
+		if got.Tags[0] == "mutated" {
+			t.Errorf("store shares the caller's tags slice")
+		}
+	})
+
+	t.Run("owners are isolated", func(t *testing.T) {
+		store := newStore(t)
+		alice := service.WithOwner(context.Background(), "alice")
+		bob := service.WithOwner(context.Background(), "bob")
+
+		// The same ID under two owners is two entries
+		err := store.SaveEntry(alice, core.Entry{ID: "shared", Title: "Alice's", Tags: []string{"work"}})
+		if errors.Is(err, service.ErrOwnersUnsupported) {
+			t.Skip("store does not support owners")
+		}
+		if err != nil {
+			t.Fatalf("SaveEntry(alice) error = %v", err)
+		}
+		if err := store.SaveEntry(bob, core.Entry{ID: "shared", Title: "Bob's"}); err != nil {
+			t.Fatalf("SaveEntry(bob) error = %v", err)
+		}
+		if err := store.SaveEntry(bob, core.Entry{ID: "bobs-only", Title: "Bob's other"}); err != nil {
+			t.Fatalf("SaveEntry(bob) error = %v", err)
+		}
+
+		entries, err := store.GetAll(alice)
+		if err != nil {
+			t.Fatalf("GetAll(alice) error = %v", err)
+		}
+		if len(entries) != 1 || entries["shared"].Title != "Alice's" || entries["shared"].Version != 1 {
+			t.Errorf("GetAll(alice) = %+v; want only Alice's entry at version 1", entries)
+		}
+		if len(getAll(t, store)) != 0 {
+			t.Errorf("the default owner sees other owners' entries")
+		}
+
+		result, err := store.Query(alice, service.Query{IDs: []string{"shared", "bobs-only"}})
+		if err != nil {
+			t.Fatalf("Query(alice) error = %v", err)
+		}
+		if len(result.Entries) != 1 || result.Entries[0].Title != "Alice's" {
+			t.Errorf("Query(alice) = %+v; want only Alice's entry", result.Entries)
+		}
+
+		// Neither versioned saves nor deletes reach another owner's entries
+		if err := store.SaveEntry(alice, core.Entry{ID: "bobs-only", Title: "Taken", Version: 1}); !errors.Is(err, service.ErrConflict) {
+			t.Errorf("versioned SaveEntry of another owner's entry error = %v; want ErrConflict", err)
+		}
+		if err := store.DeleteEntries(alice, []string{"shared", "bobs-only"}); err != nil {
+			t.Fatalf("DeleteEntries(alice) error = %v", err)
+		}
+		entries, err = store.GetAll(bob)
+		if err != nil {
+			t.Fatalf("GetAll(bob) error = %v", err)
+		}
+		if len(entries) != 2 || entries["shared"].Title != "Bob's" || entries["bobs-only"].Title != "Bob's other" {
+			t.Errorf("GetAll(bob) = %+v; want both of Bob's entries untouched", entries)
+		}
+
+		if _, ok := store.(service.TagStore); !ok {
+			return
+		}
+		if err := service.SaveTag(bob, store, service.Tag{Name: "work", Color: "#123456"}); err != nil {
+			t.Fatalf("SaveTag(bob) error = %v", err)
+		}
+		store.SaveEntry(alice, core.Entry{ID: "tagged", Tags: []string{"work"}})
+		tags, err := service.ListTags(alice, store)
+		if err != nil {
+			t.Fatalf("ListTags(alice) error = %v", err)
+		}
+		if len(tags) != 1 || tags[0].Count != 1 || tags[0].Color != "" {
+			t.Errorf("ListTags(alice) = %+v; want work used once, without Bob's colour", tags)
+		}
+	})
+
+	t.Run("workspaces", func(t *testing.T) {
+		store := newStore(t)
+		workspaces, err := service.Workspaces(store)
+		if err != nil {
+			t.Skip("store does not support workspaces")
+		}
+		alice := service.WithPrincipal(context.Background(), service.Principal{ID: "alice"})
+		bob := service.WithPrincipal(context.Background(), service.Principal{ID: "bob"})
+
+		team, err := service.CreateWorkspace(alice, workspaces, "Team")
+		if err != nil {
+			t.Fatalf("CreateWorkspace() error = %v", err)
+		}
+		listed, err := service.ListWorkspaces(alice, workspaces)
+		if err != nil {
+			t.Fatalf("ListWorkspaces(alice) error = %v", err)
+		}
+		if len(listed) != 1 || listed[0].Workspace.ID != team.ID || listed[0].Workspace.Name != "Team" || listed[0].Role != service.RoleAdmin {
+			t.Errorf("ListWorkspaces(alice) = %+v; want Team as admin", listed)
+		}
+		assertTimeEqual(t, "CreatedAt", listed[0].Workspace.CreatedAt, team.CreatedAt)
+
+		// Non-members can't tell the workspace exists
+		if _, _, err := service.OpenWorkspace(bob, workspaces, team.ID, service.RoleViewer); !errors.Is(err, service.ErrWorkspaceNotFound) {
+			t.Errorf("OpenWorkspace(bob) error = %v; want ErrWorkspaceNotFound", err)
+		}
+
+		if _, err := service.SetMember(alice, workspaces, team.ID, "bob", service.RoleViewer); err != nil {
+			t.Fatalf("SetMember(bob) error = %v", err)
+		}
+		if _, _, err := service.OpenWorkspace(bob, workspaces, team.ID, service.RoleEditor); !errors.Is(err, service.ErrForbidden) {
+			t.Errorf("OpenWorkspace(bob, editor) error = %v; want ErrForbidden", err)
+		}
+
+		// Entries saved in the workspace are shared with its members, and only them
+		aliceTeam, _, err := service.OpenWorkspace(alice, workspaces, team.ID, service.RoleEditor)
+		if err != nil {
+			t.Fatalf("OpenWorkspace(alice) error = %v", err)
+		}
+		if err := store.SaveEntry(aliceTeam, core.Entry{ID: "plan", Title: "Team plan"}); err != nil {
+			t.Fatalf("SaveEntry(team) error = %v", err)
+		}
+		bobTeam, role, err := service.OpenWorkspace(bob, workspaces, team.ID, service.RoleViewer)
+		if err != nil || role != service.RoleViewer {
+			t.Fatalf("OpenWorkspace(bob) = %s, %v; want viewer", role, err)
+		}
+		entries, err := store.GetAll(bobTeam)
+		if err != nil {
+			t.Fatalf("GetAll(team) error = %v", err)
+		}
+		if len(entries) != 1 || entries["plan"].Title != "Team plan" {
+			t.Errorf("GetAll(team) = %+v; want the team plan", entries)
+		}
+		if entries, err := store.GetAll(alice); err != nil || len(entries) != 0 {
+			t.Errorf("GetAll(alice) = %+v, %v; want workspace entries kept out of her own", entries, err)
+		}
+
+		// Viewers can't write, and only admins manage members
+		if err := service.SaveTag(bobTeam, store, service.Tag{Name: "plans"}); !errors.Is(err, service.ErrForbidden) {
+			t.Errorf("SaveTag(viewer) error = %v; want ErrForbidden", err)
+		}
+		if _, err := service.SetMember(bob, workspaces, team.ID, "bob", service.RoleAdmin); !errors.Is(err, service.ErrForbidden) {
+			t.Errorf("SetMember(by viewer) error = %v; want ErrForbidden", err)
+		}
+
+		// The last admin can neither step down nor leave
+		if _, err := service.SetMember(alice, workspaces, team.ID, "alice", service.RoleEditor); !errors.Is(err, service.ErrLastAdmin) {
+			t.Errorf("SetMember(last admin) error = %v; want ErrLastAdmin", err)
+		}
+		if err := service.RemoveMember(alice, workspaces, team.ID, "alice"); !errors.Is(err, service.ErrLastAdmin) {
+			t.Errorf("RemoveMember(last admin) error = %v; want ErrLastAdmin", err)
+		}
+
+		if _, err := service.SetMember(alice, workspaces, team.ID, "bob", service.RoleEditor); err != nil {
+			t.Fatalf("SetMember(bob, editor) error = %v", err)
+		}
+		members, err := service.ListMembers(bob, workspaces, team.ID)
+		if err != nil {
+			t.Fatalf("ListMembers() error = %v", err)
+		}
+		if len(members) != 2 || members[0].PrincipalID != "alice" || members[1].PrincipalID != "bob" || members[1].Role != service.RoleEditor {
+			t.Errorf("ListMembers() = %+v; want alice and bob as editor", members)
+		}
+		if members[1].AddedAt.IsZero() {
+			t.Errorf("bob's AddedAt is zero")
+		}
+
+		// Members may leave on their own
+		if err := service.RemoveMember(bob, workspaces, team.ID, "bob"); err != nil {
+			t.Fatalf("RemoveMember(bob) error = %v", err)
+		}
+		if listed, err := service.ListWorkspaces(bob, workspaces); err != nil || len(listed) != 0 {
+			t.Errorf("ListWorkspaces(bob) = %+v, %v; want none after leaving", listed, err)
+		}
+	})
+
+	t.Run("timers", func(t *testing.T) {
+		store := newStore(t)
+		timers, err := service.Timers(store)
+		if err != nil {
+			t.Skip("store does not support timers")
+		}
+		alice := service.WithOwner(context.Background(), "alice")
+		bob := service.WithOwner(context.Background(), "bob")
+
+		if _, ok, err := timers.GetTimer(alice); err != nil || ok {
+			t.Fatalf("GetTimer() = %v, %v; want none", ok, err)
+		}
+
+		since := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+		if err := timers.SaveTimer(alice, service.Timer{EntryID: "1", State: service.TimerRunning, Since: since}); err != nil {
+			t.Fatalf("SaveTimer() error = %v", err)
+		}
+		if err := timers.SaveTimer(alice, service.Timer{EntryID: "2", State: service.TimerPaused, Since: since.Add(time.Hour)}); err != nil {
+			t.Fatalf("SaveTimer(replace) error = %v", err)
+		}
+		got, ok, err := timers.GetTimer(alice)
+		if err != nil || !ok {
+			t.Fatalf("GetTimer() = %v, %v; want a timer", ok, err)
+		}
+		if got.EntryID != "2" || got.State != service.TimerPaused || !got.Since.Equal(since.Add(time.Hour)) {
+			t.Errorf("GetTimer() = %+v; want entry 2 paused at %v", got, since.Add(time.Hour))
+		}
+
+		// Timers belong to their owner
+		if _, ok, err := timers.GetTimer(bob); err != nil || ok {
+			t.Errorf("GetTimer(bob) = %v, %v; want none", ok, err)
+		}
+
+		if err := timers.DeleteTimer(alice); err != nil {
+			t.Fatalf("DeleteTimer() error = %v", err)
+		}
+		if _, ok, err := timers.GetTimer(alice); err != nil || ok {
+			t.Errorf("GetTimer() after delete = %v, %v; want none", ok, err)
+		}
+		if err := timers.DeleteTimer(alice); err != nil {
+			t.Errorf("DeleteTimer() of no timer error = %v; want nil", err)
+		}
+
+		// Claiming fails while another entry's timer runs, unless it is the one being replaced
+		if err := timers.ClaimTimer(alice, service.Timer{EntryID: "1", State: service.TimerRunning, Since: since}, ""); err != nil {
+			t.Fatalf("ClaimTimer() error = %v", err)
+		}
+		if err := timers.ClaimTimer(alice, service.Timer{EntryID: "2", State: service.TimerRunning, Since: since}, ""); !errors.Is(err, service.ErrTimerRunning) {
+			t.Errorf("ClaimTimer(2) error = %v; want ErrTimerRunning", err)
+		}
+		if err := timers.ClaimTimer(alice, service.Timer{EntryID: "1", State: service.TimerRunning, Since: since.Add(time.Hour)}, ""); err != nil {
+			t.Errorf("ClaimTimer(1 again) error = %v", err)
+		}
+		if err := timers.ClaimTimer(alice, service.Timer{EntryID: "2", State: service.TimerRunning, Since: since}, "1"); err != nil {
+			t.Errorf("ClaimTimer(2 replacing 1) error = %v", err)
+		}
+		if got, _, _ := timers.GetTimer(alice); got.EntryID != "2" {
+			t.Errorf("GetTimer() after claims = %+v; want entry 2", got)
+		}
+		if err := timers.ClaimTimer(bob, service.Timer{EntryID: "3", State: service.TimerRunning, Since: since}, ""); err != nil {
+			t.Errorf("ClaimTimer(bob) error = %v; want owners' timers kept apart", err)
+		}
+		if err := timers.SaveTimer(alice, service.Timer{EntryID: "2", State: service.TimerPaused, Since: since}); err != nil {
+			t.Fatalf("SaveTimer(pause) error = %v", err)
+		}
+		if err := timers.ClaimTimer(alice, service.Timer{EntryID: "1", State: service.TimerRunning, Since: since}, ""); err != nil {
+			t.Errorf("ClaimTimer() over a paused timer error = %v", err)
+		}
+		for _, ctx := range []context.Context{alice, bob} {
+			if err := timers.DeleteTimer(ctx); err != nil {
+				t.Fatalf("DeleteTimer() error = %v", err)
+			}
+		}
+
+		// The service drives entries' timestamps through the store
+		if err := store.SaveEntry(alice, core.Entry{ID: "1", Title: "Deploy"}); err != nil {
+			t.Fatalf("SaveEntry() error = %v", err)
+		}
+		_, started, err := service.StartTimer(alice, store, "1", service.TimerPolicyReject)
+		if err != nil {
+			t.Fatalf("StartTimer() error = %v", err)
+		}
+		if started.StartedAtTimestamp.IsZero() || !started.InProgress() {
+			t.Errorf("StartTimer() = %+v; want started and in progress", started)
+		}
+		stopped, err := service.StopTimer(alice, store, "1")
+		if err != nil {
+			t.Fatalf("StopTimer() error = %v", err)
+		}
+		if stopped.InProgress() {
+			t.Errorf("StopTimer() = %+v; want ended", stopped)
+		}
+		if _, _, ok, err := service.CurrentTimer(alice, store); err != nil || ok {
+			t.Errorf("CurrentTimer() after stop = %v, %v; want none", ok, err)
+		}
+	})
+
+	t.Run("webhook deliveries", func(t *testing.T) {
+		store := newStore(t)
+		queue, err := service.Deliveries(store)
+		if err != nil {
+			t.Skip("store does not queue webhook deliveries")
+		}
+		// Unique per run, since the queue is shared by every owner and kept between runs
+		owner := fmt.Sprintf("alice-%d", time.Now().UnixNano())
+		alice := service.WithOwner(context.Background(), owner)
+
+		queued := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+		due := service.Delivery{ID: owner + "-1", SubscriptionID: "chat", Event: service.WebhookCompleted, Owner: owner, EntryID: "1",
+			Payload: []byte(`{"event":"completed"}`), Status: service.DeliveryPending, NextAttemptAt: queued, CreatedAt: queued}
+		later := due
+		later.ID, later.Event, later.NextAttemptAt, later.CreatedAt = owner+"-2", service.WebhookUpdated, queued.Add(time.Hour), queued.Add(time.Second)
+		if err := queue.EnqueueDeliveries(alice, []service.Delivery{due, later}); err != nil {
+			t.Fatalf("EnqueueDeliveries() error = %v", err)
+		}
+		// Queueing the same delivery again changes nothing
+		repeat := due
+		repeat.Payload = []byte(`{"event":"repeat"}`)
+		if err := queue.EnqueueDeliveries(alice, []service.Delivery{repeat}); err != nil {
+			t.Fatalf("EnqueueDeliveries(repeat) error = %v", err)
+		}
+
+		now := queued.Add(time.Minute)
+		claimed := ownDeliveries(t, queue, now, owner)
+		if len(claimed) != 1 || claimed[0].ID != due.ID || string(claimed[0].Payload) != string(due.Payload) {
+			t.Fatalf("ClaimDeliveries() = %+v; want only %s as queued", claimed, due.ID)
+		}
+		// Claimed deliveries are held for the lease
+		if again := ownDeliveries(t, queue, now, owner); len(again) != 0 {
+			t.Errorf("ClaimDeliveries() during lease = %+v; want none", again)
+		}
+
+		delivered := claimed[0]
+		delivered.Status, delivered.Attempts, delivered.ResponseStatus, delivered.DeliveredAt = service.DeliveryDelivered, 1, 200, now
+		if err := queue.UpdateDelivery(alice, delivered); err != nil {
+			t.Fatalf("UpdateDelivery() error = %v", err)
+		}
+		if again := ownDeliveries(t, queue, now.Add(time.Hour), owner); len(again) != 1 || again[0].ID != later.ID {
+			t.Errorf("ClaimDeliveries() after delivery = %+v; want only %s", again, later.ID)
+		}
+
+		log, err := queue.ListDeliveries(alice, 10)
+		if err != nil {
+			t.Fatalf("ListDeliveries() error = %v", err)
+		}
+		if len(log) != 2 || log[0].ID != later.ID || log[1].ID != due.ID {
+			t.Fatalf("ListDeliveries() = %+v; want %s then %s", log, later.ID, due.ID)
+		}
+		got := log[1]
+		if got.Status != service.DeliveryDelivered || got.Attempts != 1 || got.ResponseStatus != 200 || got.Event != service.WebhookCompleted || got.EntryID != "1" {
+			t.Errorf("ListDeliveries()[1] = %+v; want delivered on the first attempt", got)
+		}
+		assertTimeEqual(t, "DeliveredAt", got.DeliveredAt, now)
+		assertTimeEqual(t, "CreatedAt", got.CreatedAt, queued)
+
+		// The log belongs to its owner
+		if others, err := queue.ListDeliveries(context.Background(), 10); err != nil || len(others) != 0 {
+			t.Errorf("ListDeliveries(default owner) = %+v, %v; want none", others, err)
+		}
+	})
+}
+
+// ownDeliveries claims the deliveries due by now and keeps owner's, leaving the rest to lapse
+func ownDeliveries(t *testing.T, queue service.DeliveryQueue, now time.Time, owner string) []service.Delivery {
+	t.Helper()
+	claimed, err := queue.ClaimDeliveries(context.Background(), now, time.Minute, 100)
+	if err != nil {
+		t.Fatalf("ClaimDeliveries() error = %v", err)
+	}
+	var own []service.Delivery
+	for _, delivery := range claimed {
+		if delivery.Owner == owner {
+			own = append(own, delivery)
+		}
+	}
+	return own
+}
+
+// staleStore edits an entry after each Query, as if another writer got in before the save
+type staleStore struct {
+	service.Store
+	stale string
+}
+
+func (s staleStore) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	result, err := s.Store.Query(ctx, q)
+	if err != nil {
+		return result, err
+	}
+	entries, err := s.Store.GetAll(ctx)
+	if err != nil {
+		return result, err
+	}
+	if entry, ok := entries[s.stale]; ok {
+		entry.Body += " (edited)"
+		err = s.Store.SaveEntry(ctx, entry)
+	}
+	return result, err
+}
+
+// AssertEntryEqual compares entries field by field.
+// Timestamps are compared as instants and nil/empty tags are treated alike,
+// since backends may normalise both.
+func AssertEntryEqual(t *testing.T, got, want core.Entry) {
+	t.Helper()
+
+	if got.ID != want.ID {
+		t.Errorf("ID = %q; want %q", got.ID, want.ID)
+	}
+	if got.Title != want.Title {
+		t.Errorf("Title = %q; want %q", got.Title, want.Title)
+	}
+	if len(got.Tags) != len(want.Tags) {
+		t.Errorf("Tags = %v; want %v", got.Tags, want.Tags)
+	} else {
+		for i := range want.Tags {
+			if got.Tags[i] != want.Tags[i] {
+				t.Errorf("Tags = %v; want %v", got.Tags, want.Tags)
+				break
+			}
+		}
+	}
+	assertTimeEqual(t, "StartedAtTimestamp", got.StartedAtTimestamp, want.StartedAtTimestamp)
+	assertTimeEqual(t, "EndedAtTimestamp", got.EndedAtTimestamp, want.EndedAtTimestamp)
+	assertTimeEqual(t, "LastModifiedTimestamp", got.LastModifiedTimestamp, want.LastModifiedTimestamp)
+	if got.EstimatedDuration != want.EstimatedDuration {
+		t.Errorf("EstimatedDuration = %v; want %v", got.EstimatedDuration, want.EstimatedDuration)
+	}
+	if got.Body != want.Body {
+		t.Errorf("Body = %q; want %q", got.Body, want.Body)
+	}
+}
+
+func assertTimeEqual(t *testing.T, field string, got, want time.Time) {
+	t.Helper()
+
+	if got.IsZero() && want.IsZero() {
+		return
+	}
+	if !got.Equal(want) {
+		t.Errorf("%s = %v; want %v", field, got, want)
+	}
+}
+
+// fullEntry sets every field. Timestamps use microsecond precision (the
+// Postgres limit) and a non-UTC zone to catch backends that drop offsets.
+func fullEntry() core.Entry {
+	zone := time.FixedZone("UTC+9", 9*60*60)
+	return core.Entry{
+		ID:                    "conformance-1",
+		Title:                 "K8s: \"quoted\" title",
+		Tags:                  []string{"learning", "open-source", "with space"},
+		StartedAtTimestamp:    time.Date(2025, 12, 20, 9, 0, 0, 123456000, zone),
+		EndedAtTimestamp:      time.Date(2025, 12, 20, 11, 30, 0, 0, zone),
+		LastModifiedTimestamp: time.Date(2025, 12, 21, 8, 15, 30, 654321000, time.UTC),
+		EstimatedDuration:     1*time.Hour + 30*time.Minute + 15*time.Second,
+		Body:                  "The journey has just begun.\n\n---\n\n- unicode: 全然\n",
+	}
+}
+
+// queryEntries is a small data set for the query subtests.
+// e2 and e3 share a start time so ties are broken by ID; e4 has no timestamps.
+func queryEntries() []core.Entry {
+	day := func(d, h int) time.Time { return time.Date(2025, 1, d, h, 0, 0, 0, time.UTC) }
+	return []core.Entry{
+		{ID: "e1", Title: "Go generics", Tags: []string{"go", "work"}, Body: "type params",
+			StartedAtTimestamp: day(1, 9), EndedAtTimestamp: day(1, 10), LastModifiedTimestamp: day(5, 0)},
+		{ID: "e2", Title: "Rust vs Go", Tags: []string{"go"}, Body: "100% safe",
+			StartedAtTimestamp: day(2, 9), LastModifiedTimestamp: day(4, 0)},
+		{ID: "e3", Title: "Standup", Tags: []string{"work"}, Body: "1000 things",
+			StartedAtTimestamp: day(2, 9), EndedAtTimestamp: day(2, 12), LastModifiedTimestamp: day(3, 0)},
+		{ID: "e4", Title: "Someday", Body: "maybe", LastModifiedTimestamp: day(2, 0)},
+	}
+}
+
+// tagTreeEntries carry hierarchical tags, plus near misses that aren't beneath infra
+func tagTreeEntries() []core.Entry {
+	day := func(d int) time.Time { return time.Date(2025, 2, d, 9, 0, 0, 0, time.UTC) }
+	return []core.Entry{
+		{ID: "t1", Title: "Infra", Tags: []string{"infra"}, StartedAtTimestamp: day(1), LastModifiedTimestamp: day(1)},
+		{ID: "t2", Title: "K8s", Tags: []string{"infra/k8s"}, StartedAtTimestamp: day(2), LastModifiedTimestamp: day(2)},
+		{ID: "t3", Title: "Helm", Tags: []string{"infra/k8s/helm", "infra/k8s"}, StartedAtTimestamp: day(3), LastModifiedTimestamp: day(3)},
+		{ID: "t4", Title: "Other", Tags: []string{"infra-x"}, StartedAtTimestamp: day(4), LastModifiedTimestamp: day(4)},
+		{ID: "t5", Title: "Shouting", Tags: []string{"INFRA/k8s"}, StartedAtTimestamp: day(5), LastModifiedTimestamp: day(5)},
+	}
+}
+
+func saveAll(t *testing.T, store service.Store, entries []core.Entry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		save(t, store, entry)
+	}
+}
+
+func query(t *testing.T, store service.Store, q service.Query) service.QueryResult {
+	t.Helper()
+
+	result, err := store.Query(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Query(%+v) error = %v", q, err)
+	}
+	return result
+}
+
+func assertIDs(t *testing.T, entries []core.Entry, want []string) {
+	t.Helper()
+
+	got := entryIDs(entries)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v; want %v", got, want)
+	}
+}
+
+// assertTags compares tags as "name:count" pairs
+func assertTags(t *testing.T, tags []service.Tag, want string) {
+	t.Helper()
+
+	got := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		got = append(got, fmt.Sprintf("%s:%d", tag.Name, tag.Count))
+	}
+	if strings.Join(got, ",") != want {
+		t.Errorf("tags = %v; want %s", got, want)
+	}
+}
+
+func entryIDs(entries []core.Entry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func save(t *testing.T, store service.Store, entry core.Entry) {
+	t.Helper()
+
+	if err := store.SaveEntry(context.Background(), entry); err != nil {
+		t.Fatalf("SaveEntry(%s) error = %v", entry.ID, err)
+	}
+}
+
+func getAll(t *testing.T, store service.Store) map[string]core.Entry {
+	t.Helper()
+
+	entries, err := store.GetAll(context.Background())
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	return entries
+}
@@ -1,18 +1,37 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/turnerem/zenzen/core"
 	"github.com/turnerem/zenzen/logger"
 )
 
-// SyncService handles background synchronization between local and cloud storage
+const (
+	// changeDebounce gathers a burst of change events into one sync
+	changeDebounce = 500 * time.Millisecond
+
+	// echoWindow is how long change events for entries the sync itself wrote are ignored
+	echoWindow = 10 * time.Second
+)
+
+// SyncService handles background synchronization between local and cloud storage.
+// Stores that implement ChangeFeed trigger a sync as soon as they change, between the
+// regular interval syncs.
 type SyncService struct {
 	local    Store
 	cloud    Store
+	owner    string
 	interval time.Duration
-	stopChan chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
 	lastSync time.Time
+	history  SyncLog
+	written  map[string]time.Time // Entries the sync wrote, and when, so their change events aren't echoed
 }
 
 // NewSyncService creates a new sync service
@@ -21,60 +40,164 @@ func NewSyncService(local, cloud Store, interval time.Duration) *SyncService {
 		local:    local,
 		cloud:    cloud,
 		interval: interval,
-		stopChan: make(chan struct{}),
+		written:  make(map[string]time.Time),
 	}
 }
 
-// Start begins the background sync process
-func (s *SyncService) Start() {
+// SetOwner sets whose entries are synced from the cloud store, which may hold many owners'.
+// The local store always holds DefaultOwner's entries.
+func (s *SyncService) SetOwner(owner string) {
+	s.owner = owner
+}
+
+// cloudContext scopes ctx to the synced owner's entries in the cloud store
+func (s *SyncService) cloudContext(ctx context.Context) context.Context {
+	return WithOwner(ctx, s.owner)
+}
+
+// SetSyncLog sets where sync runs are recorded (optional)
+func (s *SyncService) SetSyncLog(history SyncLog) {
+	s.history = history
+}
+
+// Start begins the background sync process.
+// The sync loop and any in-flight queries stop when ctx is cancelled or Stop is called.
+func (s *SyncService) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
 	logger.Info("sync_service_started", "interval", s.interval)
-	go s.run()
+	go s.run(ctx)
 }
 
-// Stop halts the background sync process
+// Stop halts the background sync process, cancelling any sync in progress,
+// and waits for the sync loop to exit
 func (s *SyncService) Stop() {
-	close(s.stopChan)
+	s.cancel()
+	<-s.done
 	logger.Info("sync_service_stopped")
 }
 
 // run is the main sync loop
-func (s *SyncService) run() {
+func (s *SyncService) run(ctx context.Context) {
+	defer close(s.done)
+
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
+	// Nil when neither store has a change feed, leaving only the ticker
+	changes := s.subscribe(ctx)
+	var debounce <-chan time.Time
+
 	// Perform initial sync
-	s.performSync()
+	s.performSync(ctx)
 
 	for {
 		select {
 		case <-ticker.C:
-			s.performSync()
-		case <-s.stopChan:
+			s.performSync(ctx)
+		case event, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			if debounce == nil && !s.isEcho(event) {
+				debounce = time.After(changeDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			logger.Info("sync_triggered_by_change")
+			s.performSync(ctx)
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// subscribe merges the change feeds of whichever stores have one
+func (s *SyncService) subscribe(ctx context.Context) <-chan ChangeEvent {
+	var feeds []<-chan ChangeEvent
+	for name, store := range map[string]Store{"local": s.local, "cloud": s.cloud} {
+		feed, ok := store.(ChangeFeed)
+		if !ok {
+			continue
+		}
+		scoped := ctx
+		if name == "cloud" {
+			scoped = s.cloudContext(ctx)
+		}
+		changes, err := feed.Subscribe(scoped)
+		if err != nil {
+			if !errors.Is(err, ErrChangeFeedUnsupported) {
+				logger.Warn("sync_change_feed_unavailable", "store", name, "error", err.Error())
+			}
+			continue
+		}
+		logger.Info("sync_change_feed_subscribed", "store", name)
+		feeds = append(feeds, changes)
+	}
+	if len(feeds) == 0 {
+		return nil
+	}
+
+	merged := make(chan ChangeEvent)
+	var wg sync.WaitGroup
+	for _, feed := range feeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range feed {
+				select {
+				case merged <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged
+}
+
+// isEcho reports whether an event is most likely the sync's own write coming back.
+// A real edit to the same entry within echoWindow waits for the next interval sync.
+func (s *SyncService) isEcho(event ChangeEvent) bool {
+	if event.Op != ChangeInsert && event.Op != ChangeUpdate {
+		return false
+	}
+	writtenAt, ok := s.written[event.ID]
+	return ok && time.Since(writtenAt) < echoWindow
+}
+
 // performSync synchronizes entries between local and cloud storage
-func (s *SyncService) performSync() {
+func (s *SyncService) performSync(ctx context.Context) {
 	startTime := time.Now()
 	logger.Info("sync_started")
 
+	run := SyncRun{StartedAt: startTime}
+	defer s.recordRun(ctx, &run)
+
 	// Get all entries from both stores
-	localEntries, err := s.local.GetAll()
+	localEntries, err := s.local.GetAll(ctx)
 	if err != nil {
 		logger.Error("sync_get_local_failed", "error", err.Error())
+		run.Errors = append(run.Errors, fmt.Sprintf("get local entries: %v", err))
 		return
 	}
 
-	cloudEntries, err := s.cloud.GetAll()
+	cloudEntries, err := s.cloud.GetAll(s.cloudContext(ctx))
 	if err != nil {
 		logger.Error("sync_get_cloud_failed", "error", err.Error())
+		run.Errors = append(run.Errors, fmt.Sprintf("get cloud entries: %v", err))
 		return
 	}
 
-	syncedCount := 0
-	conflictCount := 0
+	// Versions are per store. Writes are conditional on the target version read above,
+	// so an edit made while the sync runs is never overwritten; it's picked up next time.
+	var pushes, pulls []core.Entry
 
 	// Sync local → cloud and resolve conflicts
 	for id, localEntry := range localEntries {
@@ -82,27 +205,20 @@ func (s *SyncService) performSync() {
 
 		if !existsInCloud {
 			// Entry only exists locally - push to cloud
-			if err := s.cloud.SaveEntry(localEntry); err != nil {
-				logger.Error("sync_push_failed", "entry_id", id, "error", err.Error())
-			} else {
-				syncedCount++
-			}
+			localEntry.Version = 0
+			pushes = append(pushes, localEntry)
 		} else {
 			// Entry exists in both - resolve conflict using LastModifiedTimestamp
 			if localEntry.LastModifiedTimestamp.After(cloudEntry.LastModifiedTimestamp) {
 				// Local is newer - push to cloud
-				if err := s.cloud.SaveEntry(localEntry); err != nil {
-					logger.Error("sync_update_cloud_failed", "entry_id", id, "error", err.Error())
-				} else {
-					syncedCount++
-				}
+				run.Conflicts++
+				localEntry.Version = cloudEntry.Version
+				pushes = append(pushes, localEntry)
 			} else if cloudEntry.LastModifiedTimestamp.After(localEntry.LastModifiedTimestamp) {
 				// Cloud is newer - pull to local
-				if err := s.local.SaveEntry(cloudEntry); err != nil {
-					logger.Error("sync_update_local_failed", "entry_id", id, "error", err.Error())
-				} else {
-					conflictCount++
-				}
+				run.Conflicts++
+				cloudEntry.Version = localEntry.Version
+				pulls = append(pulls, cloudEntry)
 			}
 			// If timestamps are equal, no sync needed
 		}
@@ -112,26 +228,95 @@ func (s *SyncService) performSync() {
 	for id, cloudEntry := range cloudEntries {
 		if _, existsLocally := localEntries[id]; !existsLocally {
 			// Entry only exists in cloud - pull to local
-			if err := s.local.SaveEntry(cloudEntry); err != nil {
-				logger.Error("sync_pull_failed", "entry_id", id, "error", err.Error())
-			} else {
-				syncedCount++
-			}
+			cloudEntry.Version = 0
+			pulls = append(pulls, cloudEntry)
 		}
 	}
 
+	s.saveAll(s.cloudContext(ctx), s.cloud, pushes, SyncDirectionPush, &run)
+	s.saveAll(ctx, s.local, pulls, SyncDirectionPull, &run)
+
 	s.lastSync = time.Now()
 	duration := time.Since(startTime)
 
 	logger.Info("sync_completed",
-		"synced_count", syncedCount,
-		"conflict_count", conflictCount,
+		"synced_count", run.Pushed+run.Pulled,
+		"conflict_count", run.Conflicts,
 		"duration_ms", duration.Milliseconds())
 }
 
+// saveAll writes entries to store in one batch. If the batch fails, each entry is
+// retried on its own so one bad entry, such as a version conflict, doesn't hold back the rest.
+func (s *SyncService) saveAll(ctx context.Context, store Store, entries []core.Entry, direction string, run *SyncRun) {
+	if len(entries) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for id, writtenAt := range s.written {
+		if now.Sub(writtenAt) >= echoWindow {
+			delete(s.written, id)
+		}
+	}
+
+	err := store.SaveEntries(ctx, entries)
+	if err == nil {
+		for _, entry := range entries {
+			s.written[entry.ID] = now
+			run.addEntry(entry.ID, direction, nil)
+		}
+		return
+	}
+	logger.Warn("sync_batch_failed", "direction", direction, "count", len(entries), "error", err.Error())
+
+	for _, entry := range entries {
+		err := store.SaveEntry(ctx, entry)
+		if err != nil {
+			logger.Error("sync_"+direction+"_failed", "entry_id", entry.ID, "error", err.Error())
+		} else {
+			s.written[entry.ID] = now
+		}
+		run.addEntry(entry.ID, direction, err)
+	}
+}
+
+// addEntry records an entry touched by the run and updates the counters
+func (r *SyncRun) addEntry(id, direction string, err error) {
+	record := SyncRunEntry{
+		EntryID:   id,
+		Direction: direction,
+		SyncedAt:  time.Now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+		r.Errors = append(r.Errors, fmt.Sprintf("%s %s: %v", direction, id, err))
+	} else if direction == SyncDirectionPush {
+		r.Pushed++
+	} else {
+		r.Pulled++
+	}
+	r.Entries = append(r.Entries, record)
+}
+
+// recordRun stores the finished run in the sync log, if one is configured.
+// Runs cut short by cancellation are still recorded.
+func (s *SyncService) recordRun(ctx context.Context, run *SyncRun) {
+	run.EndedAt = time.Now()
+	if s.history == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := s.history.RecordSyncRun(ctx, *run); err != nil {
+		logger.Error("sync_run_record_failed", "error", err.Error())
+	}
+}
+
 // SyncNow triggers an immediate sync
-func (s *SyncService) SyncNow() {
-	s.performSync()
+func (s *SyncService) SyncNow(ctx context.Context) {
+	s.performSync(ctx)
 }
 
 // LastSyncTime returns when the last sync occurred
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+type recordingSyncLog struct {
+	runs []SyncRun
+}
+
+func (r *recordingSyncLog) RecordSyncRun(ctx context.Context, run SyncRun) error {
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *recordingSyncLog) ListSyncRuns(ctx context.Context, limit int) ([]SyncRun, error) {
+	return r.runs, nil
+}
+
+func (r *recordingSyncLog) EntrySyncHistory(ctx context.Context, entryID string, limit int) ([]SyncRunEntry, error) {
+	return nil, nil
+}
+
+func (r *recordingSyncLog) SyncRunEntries(ctx context.Context, runIDs []int64) ([]SyncRunEntry, error) {
+	return nil, nil
+}
+
+func TestSyncRecordsRun(t *testing.T) {
+	older := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	local := NewMemoryStore(
+		core.Entry{ID: "local-only", LastModifiedTimestamp: older},
+		core.Entry{ID: "both", Title: "local", LastModifiedTimestamp: older},
+	)
+	cloud := NewMemoryStore(
+		core.Entry{ID: "cloud-only", LastModifiedTimestamp: older},
+		core.Entry{ID: "both", Title: "cloud", LastModifiedTimestamp: newer},
+	)
+	history := &recordingSyncLog{}
+
+	sync := NewSyncService(local, cloud, 0)
+	sync.SetSyncLog(history)
+	sync.SyncNow(context.Background())
+
+	if len(history.runs) != 1 {
+		t.Fatalf("expected 1 recorded run, got %d", len(history.runs))
+	}
+	run := history.runs[0]
+
+	assertEquality(t, run.Pushed, 1)
+	assertEquality(t, run.Pulled, 2)
+	assertEquality(t, run.Conflicts, 1)
+	assertEquality(t, len(run.Errors), 0)
+	assertEquality(t, len(run.Entries), 3)
+	localEntries, _ := local.GetAll(context.Background())
+	assertEquality(t, localEntries["both"].Title, "cloud")
+
+	if run.EndedAt.Before(run.StartedAt) {
+		t.Errorf("expected run to end after it started, got %v -> %v", run.StartedAt, run.EndedAt)
+	}
+
+	directions := map[string]string{}
+	for _, entry := range run.Entries {
+		directions[entry.EntryID] = entry.Direction
+	}
+	assertEquality(t, directions, map[string]string{
+		"local-only": SyncDirectionPush,
+		"cloud-only": SyncDirectionPull,
+		"both":       SyncDirectionPull,
+	})
+}
+
+// editDuringSync makes a concurrent edit right after the sync reads the store
+type editDuringSync struct {
+	*MemoryStore
+	edit core.Entry
+}
+
+func (s *editDuringSync) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	entries, err := s.MemoryStore.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return entries, s.MemoryStore.SaveEntry(ctx, s.edit)
+}
+
+func TestSyncDoesNotOverwriteConcurrentEdit(t *testing.T) {
+	older := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	local := &editDuringSync{
+		MemoryStore: NewMemoryStore(core.Entry{ID: "both", Title: "local", LastModifiedTimestamp: older, Version: 1}),
+		edit:        core.Entry{ID: "both", Title: "edited in the TUI", LastModifiedTimestamp: newer.Add(time.Hour), Version: 1},
+	}
+	cloud := NewMemoryStore(core.Entry{ID: "both", Title: "cloud", LastModifiedTimestamp: newer})
+	history := &recordingSyncLog{}
+
+	sync := NewSyncService(local, cloud, 0)
+	sync.SetSyncLog(history)
+	sync.SyncNow(context.Background())
+
+	localEntries, _ := local.MemoryStore.GetAll(context.Background())
+	assertEquality(t, localEntries["both"].Title, "edited in the TUI")
+
+	run := history.runs[0]
+	if len(run.Errors) != 1 || !strings.Contains(run.Errors[0], ErrConflict.Error()) {
+		t.Errorf("expected the pull to be recorded as a conflict, got %v", run.Errors)
+	}
+}
+
+// feedStore is a MemoryStore with a change feed the test publishes to
+type feedStore struct {
+	*MemoryStore
+	changes chan ChangeEvent
+}
+
+func (f *feedStore) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	return f.changes, nil
+}
+
+func TestSyncRunsOnChange(t *testing.T) {
+	ctx := context.Background()
+	local := NewMemoryStore()
+	cloud := &feedStore{
+		MemoryStore: NewMemoryStore(core.Entry{ID: "seed", LastModifiedTimestamp: time.Now()}),
+		changes:     make(chan ChangeEvent, 1),
+	}
+	history := &recordingSyncLog{}
+
+	sync := NewSyncService(local, cloud, time.Hour)
+	sync.SetSyncLog(history)
+	sync.Start(ctx)
+	waitForEntry(t, local, "seed") // The initial sync
+
+	// Another process saves to the cloud; without the feed it would wait an hour
+	cloud.SaveEntry(ctx, core.Entry{ID: "api", Title: "From the API", LastModifiedTimestamp: time.Now()})
+	cloud.changes <- ChangeEvent{Op: ChangeInsert, ID: "api", Version: 1}
+	waitForEntry(t, local, "api")
+
+	// The change event for the sync's own push is not synced again
+	local.SaveEntry(ctx, core.Entry{ID: "tui", LastModifiedTimestamp: time.Now()})
+	cloud.changes <- ChangeEvent{Op: ChangeUpdate, ID: "api", Version: 2}
+	time.Sleep(3 * changeDebounce)
+	sync.Stop()
+
+	assertEquality(t, len(history.runs), 2) // The initial sync and the one for the change
+	entries, _ := cloud.GetAll(ctx)
+	if _, ok := entries["tui"]; ok {
+		t.Errorf("expected the echoed event not to trigger a sync")
+	}
+}
+
+// waitForEntry waits for an entry to reach store
+func waitForEntry(t *testing.T, store Store, id string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, _ := store.GetAll(context.Background())
+		if _, ok := entries[id]; ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entry %s was not synced", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
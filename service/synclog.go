@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	SyncDirectionPush = "push" // local → cloud
+	SyncDirectionPull = "pull" // cloud → local
+)
+
+// SyncRun records the outcome of a single performSync pass
+type SyncRun struct {
+	ID        int64
+	StartedAt time.Time
+	EndedAt   time.Time
+	Pushed    int
+	Pulled    int
+	Conflicts int // Entries present on both sides with differing timestamps
+	Errors    []string
+	Entries   []SyncRunEntry
+}
+
+// SyncRunEntry records a single entry touched by a sync run
+type SyncRunEntry struct {
+	RunID     int64
+	EntryID   string
+	Direction string // SyncDirectionPush or SyncDirectionPull
+	SyncedAt  time.Time
+	Error     string // Empty when the entry was synced successfully
+}
+
+// Duration returns how long the sync run took
+func (r SyncRun) Duration() time.Duration {
+	if r.EndedAt.IsZero() {
+		return 0
+	}
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// SyncLog persists the history of sync runs. SyncRunEntries reads the records of many runs
+// at once, for callers that need the whole history.
+type SyncLog interface {
+	RecordSyncRun(ctx context.Context, run SyncRun) error
+	ListSyncRuns(ctx context.Context, limit int) ([]SyncRun, error)
+	EntrySyncHistory(ctx context.Context, entryID string, limit int) ([]SyncRunEntry, error)
+	SyncRunEntries(ctx context.Context, runIDs []int64) ([]SyncRunEntry, error)
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+var (
+	// ErrInvalidTag is returned for blank tag names and malformed colours
+	ErrInvalidTag = errors.New("invalid tag")
+
+	// ErrTagMetadataUnsupported is returned when saving metadata to a store without a TagStore
+	ErrTagMetadataUnsupported = errors.New("store does not keep tag metadata")
+)
+
+// TagSeparator splits hierarchical tags into segments: infra/k8s sits beneath infra
+const TagSeparator = "/"
+
+// tagColor matches the #RRGGBB colours the TUI and API accept
+var tagColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Tag is a tag with its usage count and optional metadata
+type Tag struct {
+	Name        string
+	Count       int    // Entries using the tag
+	Description string // Optional
+	Color       string // Optional, as #RRGGBB
+}
+
+// Validate rejects blank names or segments and colours that aren't #RRGGBB
+func (t Tag) Validate() error {
+	for _, segment := range strings.Split(t.Name, TagSeparator) {
+		if strings.TrimSpace(segment) == "" || segment != strings.TrimSpace(segment) {
+			return fmt.Errorf("%w: name %q", ErrInvalidTag, t.Name)
+		}
+	}
+	if t.Color != "" && !tagColor.MatchString(t.Color) {
+		return fmt.Errorf("%w: colour %q must be #RRGGBB", ErrInvalidTag, t.Color)
+	}
+	return nil
+}
+
+// TagStore is implemented by stores that count tags natively and keep tag metadata.
+// ListTags returns every tag in use or with metadata, by name. SaveTag and DeleteTag
+// only touch metadata; the tags on entries are changed by saving entries.
+type TagStore interface {
+	ListTags(ctx context.Context) ([]Tag, error)
+	SaveTag(ctx context.Context, tag Tag) error
+	DeleteTag(ctx context.Context, name string) error
+}
+
+// ListTags returns every tag with its usage count, in tag order.
+// Stores that don't implement TagStore fall back to CountTags.
+func ListTags(ctx context.Context, store Store) ([]Tag, error) {
+	if tagStore, ok := store.(TagStore); ok {
+		tags, err := tagStore.ListTags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sortTags(tags)
+		return tags, nil
+	}
+
+	entries, err := store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return CountTags(entries), nil
+}
+
+// SaveTag sets a tag's description and colour
+func SaveTag(ctx context.Context, store Store, tag Tag) error {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return err
+	}
+	if err := tag.Validate(); err != nil {
+		return err
+	}
+	tagStore, ok := store.(TagStore)
+	if !ok {
+		return ErrTagMetadataUnsupported
+	}
+	return tagStore.SaveTag(ctx, tag)
+}
+
+// CountTags counts how many entries use each tag, in tag order
+func CountTags(entries map[string]core.Entry) []Tag {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		for _, tag := range uniqueTags(entry.Tags) {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, Tag{Name: name, Count: count})
+	}
+	sortTags(tags)
+	return tags
+}
+
+// TagTree adds the namespaces above each tag that aren't tags themselves, with a count of 0,
+// so the result can be shown as a tree
+func TagTree(tags []Tag) []Tag {
+	tree := slices.Clone(tags)
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		seen[tag.Name] = true
+	}
+	for _, tag := range tags {
+		for _, ancestor := range TagAncestors(tag.Name) {
+			if !seen[ancestor] {
+				seen[ancestor] = true
+				tree = append(tree, Tag{Name: ancestor})
+			}
+		}
+	}
+	sortTags(tree)
+	return tree
+}
+
+// TagMatches reports whether tag is filter or sits beneath it: infra matches infra/k8s but not infra-x
+func TagMatches(tag, filter string) bool {
+	return tag == filter || strings.HasPrefix(tag, filter+TagSeparator)
+}
+
+// TagAncestors returns every namespace above tag followed by tag itself, outermost first
+func TagAncestors(tag string) []string {
+	var ancestors []string
+	for i, r := range tag {
+		if string(r) == TagSeparator {
+			ancestors = append(ancestors, tag[:i])
+		}
+	}
+	return append(ancestors, tag)
+}
+
+// TagDepth is the number of namespaces above tag
+func TagDepth(tag string) int {
+	return strings.Count(tag, TagSeparator)
+}
+
+// CompareTags orders tags segment by segment, so each namespace is followed by its own subtree
+func CompareTags(a, b string) int {
+	return slices.Compare(strings.Split(a, TagSeparator), strings.Split(b, TagSeparator))
+}
+
+// CompleteTag completes input one segment at a time: "in" offers infra, and "infra/" offers
+// infra/k8s and infra/terraform. Matching is case-insensitive; results are in tag order.
+func CompleteTag(tags []string, input string) []string {
+	parent := 0
+	if i := strings.LastIndex(input, TagSeparator); i >= 0 {
+		parent = i + len(TagSeparator)
+	}
+
+	var completions []string
+	for _, tag := range tags {
+		if len(tag) < len(input) || !strings.EqualFold(tag[:len(input)], input) {
+			continue
+		}
+		completion := tag
+		if i := strings.Index(tag[parent:], TagSeparator); i >= 0 {
+			completion = tag[:parent+i]
+		}
+		if !slices.Contains(completions, completion) {
+			completions = append(completions, completion)
+		}
+	}
+
+	slices.SortFunc(completions, CompareTags)
+	return completions
+}
+
+// sortTags puts tags in tag order
+func sortTags(tags []Tag) {
+	slices.SortFunc(tags, func(a, b Tag) int {
+		return CompareTags(a.Name, b.Name)
+	})
+}
+
+// RenameTag renames a tag on every entry, moving the tags beneath it along with it.
+// Renaming onto a tag already in use merges the two.
+func RenameTag(ctx context.Context, store Store, from, to string) ([]core.Entry, error) {
+	return MergeTags(ctx, store, []string{from}, to)
+}
+
+// MergeTags replaces each of the from tags with to on every entry, and moves their subtrees
+// beneath to (infra/k8s merged into platform becomes platform/k8s), in one SaveEntries call so
+// either every entry is rewritten or none is. Each save is conditional on the version read, so
+// an entry edited meanwhile fails the merge with ErrConflict rather than losing the edit.
+// Rewritten entries are stamped as modified now, so sync carries the change to the other store.
+// It returns the rewritten entries as stored. Metadata moves with each tag unless its new name
+// has metadata of its own. It moves after the entries are written, not in their transaction:
+// if that fails the entries stay merged and the error is returned, and merging again moves
+// the rest.
+func MergeTags(ctx context.Context, store Store, from []string, to string) ([]core.Entry, error) {
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return nil, err
+	}
+	if err := (Tag{Name: to}).Validate(); err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(from))
+	for _, name := range from {
+		if err := (Tag{Name: name}).Validate(); err != nil {
+			return nil, err
+		}
+		if name != to && !slices.Contains(sources, name) {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	// Only fetch the entries that carry a source tag or one beneath it
+	var changed []core.Entry
+	seen := make(map[string]bool)
+	now := time.Now()
+	for _, source := range sources {
+		result, err := store.Query(ctx, Query{Tags: []string{source}})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range result.Entries {
+			if seen[entry.ID] {
+				continue
+			}
+			seen[entry.ID] = true
+			entry.Tags = replaceTags(entry.Tags, sources, to)
+			entry.LastModifiedTimestamp = now
+			changed = append(changed, entry)
+		}
+	}
+
+	if err := store.SaveEntries(ctx, changed); err != nil {
+		return nil, err
+	}
+	for i := range changed {
+		changed[i].Version++
+	}
+
+	if tagStore, ok := store.(TagStore); ok {
+		if err := mergeTagMetadata(ctx, tagStore, sources, to); err != nil {
+			return changed, err
+		}
+	}
+
+	return changed, nil
+}
+
+// mergeTagMetadata moves metadata from each tag in the sources' subtrees to its new name,
+// unless that name already has metadata; the first source to claim a name wins
+func mergeTagMetadata(ctx context.Context, store TagStore, sources []string, to string) error {
+	tags, err := store.ListTags(ctx)
+	if err != nil {
+		return err
+	}
+	hasMeta := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		hasMeta[tag.Name] = tag.Description != "" || tag.Color != ""
+	}
+
+	for _, source := range sources {
+		for _, tag := range tags {
+			if !hasMeta[tag.Name] || !TagMatches(tag.Name, source) {
+				continue
+			}
+			renamed := renameTag(tag.Name, source, to)
+			if !hasMeta[renamed] {
+				if err := store.SaveTag(ctx, Tag{Name: renamed, Description: tag.Description, Color: tag.Color}); err != nil {
+					return err
+				}
+				hasMeta[renamed] = true
+			}
+			if err := store.DeleteTag(ctx, tag.Name); err != nil {
+				return err
+			}
+			hasMeta[tag.Name] = false
+		}
+	}
+	return nil
+}
+
+// replaceTags moves any tag in the sources' subtrees beneath to, keeping the first position
+// and dropping repeats
+func replaceTags(tags, sources []string, to string) []string {
+	replaced := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		for _, source := range sources {
+			if TagMatches(tag, source) {
+				tag = renameTag(tag, source, to)
+				break
+			}
+		}
+		if !slices.Contains(replaced, tag) {
+			replaced = append(replaced, tag)
+		}
+	}
+	return replaced
+}
+
+// renameTag moves tag, which is from or beneath it, to the same place beneath to
+func renameTag(tag, from, to string) string {
+	return to + strings.TrimPrefix(tag, from)
+}
+
+// uniqueTags drops repeated tags, keeping the first of each
+func uniqueTags(tags []string) []string {
+	unique := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !slices.Contains(unique, tag) {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestTagValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     Tag
+		wantErr bool
+	}{
+		{"name only", Tag{Name: "go"}, false},
+		{"with colour", Tag{Name: "go", Color: "#00add8"}, false},
+		{"blank name", Tag{Name: "  "}, true},
+		{"padded name", Tag{Name: " go"}, true},
+		{"nested", Tag{Name: "infra/k8s"}, false},
+		{"empty segment", Tag{Name: "infra//k8s"}, true},
+		{"trailing separator", Tag{Name: "infra/"}, true},
+		{"padded segment", Tag{Name: "infra / k8s"}, true},
+		{"short colour", Tag{Name: "go", Color: "#fff"}, true},
+		{"named colour", Tag{Name: "go", Color: "blue"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tag.Validate()
+			if tt.wantErr != errors.Is(err, ErrInvalidTag) {
+				t.Errorf("Validate() error = %v; wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCountTags(t *testing.T) {
+	tags := CountTags(map[string]core.Entry{
+		"1": {ID: "1", Tags: []string{"work", "go", "work"}},
+		"2": {ID: "2", Tags: []string{"go"}},
+		"3": {ID: "3"},
+	})
+
+	assertEquality(t, len(tags), 2)
+	assertEquality(t, tags[0], Tag{Name: "go", Count: 2})
+	assertEquality(t, tags[1], Tag{Name: "work", Count: 1}) // Repeats on one entry count once
+}
+
+func TestTagMatches(t *testing.T) {
+	assertEquality(t, TagMatches("infra", "infra"), true)
+	assertEquality(t, TagMatches("infra/k8s", "infra"), true)
+	assertEquality(t, TagMatches("infra-x", "infra"), false)
+	assertEquality(t, TagMatches("infra", "infra/k8s"), false)
+}
+
+func TestTagAncestors(t *testing.T) {
+	assertEquality(t, TagAncestors("infra/k8s/helm"), []string{"infra", "infra/k8s", "infra/k8s/helm"})
+	assertEquality(t, TagAncestors("go"), []string{"go"})
+}
+
+func TestCompareTags(t *testing.T) {
+	tags := []string{"infra-x", "infra/k8s", "go", "infra", "infra/k8s/helm", "infra/aws"}
+	slices.SortFunc(tags, CompareTags)
+
+	// Each namespace is followed by its subtree, even though '-' sorts before '/'
+	assertEquality(t, tags, []string{"go", "infra", "infra/aws", "infra/k8s", "infra/k8s/helm", "infra-x"})
+}
+
+func TestCompleteTag(t *testing.T) {
+	tags := []string{"infra/k8s/helm", "infra/terraform", "infra-x", "go", "work"}
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", []string{"go", "infra", "infra-x", "work"}},
+		{"in", []string{"infra", "infra-x"}},
+		{"INFRA/", []string{"infra/k8s", "infra/terraform"}},
+		{"infra/k", []string{"infra/k8s"}},
+		{"infra/k8s/", []string{"infra/k8s/helm"}},
+		{"rust", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assertEquality(t, CompleteTag(tags, tt.input), tt.want)
+		})
+	}
+}
+
+func TestTagTree(t *testing.T) {
+	tree := TagTree([]Tag{{Name: "infra/k8s/helm", Count: 2}, {Name: "go", Count: 1}})
+
+	assertEquality(t, tree, []Tag{
+		{Name: "go", Count: 1},
+		{Name: "infra"},
+		{Name: "infra/k8s"},
+		{Name: "infra/k8s/helm", Count: 2},
+	})
+}
+
+func TestNotesMergeTags(t *testing.T) {
+	store := newSeededStore()
+	notes := NewNotes(store)
+	assertNilError(t, notes.LoadAll(context.Background()))
+
+	assertNilError(t, notes.MergeTags(context.Background(), []string{"learning"}, "study"))
+
+	entry := notes.Entries[k8sLog.ID]
+	stored, _ := store.GetAll(context.Background())
+	assertEquality(t, entry.Tags[0], "study")
+	assertEquality(t, entry.Version, stored[k8sLog.ID].Version)
+
+	// The loaded copy is current, so it can be saved again without a conflict
+	assertNilError(t, notes.SaveEntry(context.Background(), entry))
+}
+
+func TestMergedTagsSync(t *testing.T) {
+	ctx := context.Background()
+	local := newSeededStore()
+	cloud := NewMemoryStore()
+	history := &recordingSyncLog{}
+	sync := NewSyncService(local, cloud, 0)
+	sync.SetSyncLog(history)
+	sync.SyncNow(ctx)
+
+	if _, err := MergeTags(ctx, local, []string{"learning"}, "study"); err != nil {
+		t.Fatalf("MergeTags() error = %v", err)
+	}
+	sync.SyncNow(ctx)
+
+	// The merge is newer than the cloud copy, so it's pushed rather than skipped or pulled back
+	cloudEntries, _ := cloud.GetAll(ctx)
+	assertEquality(t, cloudEntries[k8sLog.ID].Tags[0], "study")
+	localEntries, _ := local.GetAll(ctx)
+	assertEquality(t, localEntries[k8sLog.ID].Tags[0], "study")
+
+	pushed := history.runs[1].Entries
+	if len(pushed) != 1 || pushed[0].EntryID != k8sLog.ID || pushed[0].Direction != SyncDirectionPush {
+		t.Errorf("second sync run entries = %+v; want the merged entry pushed", pushed)
+	}
+}
+
+func TestSaveTagWithoutTagStore(t *testing.T) {
+	err := SaveTag(context.Background(), plainStore{NewMemoryStore()}, Tag{Name: "go"})
+	if !errors.Is(err, ErrTagMetadataUnsupported) {
+		t.Errorf("SaveTag() error = %v; want ErrTagMetadataUnsupported", err)
+	}
+}
+
+// plainStore hides a store's optional interfaces
+type plainStore struct {
+	Store
+}
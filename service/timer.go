@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+var (
+	// ErrTimersUnsupported is returned by stores that don't keep timers
+	ErrTimersUnsupported = errors.New("store does not support timers")
+
+	// ErrEntryNotFound is returned when a timer is asked to act on an entry that doesn't exist
+	ErrEntryNotFound = errors.New("entry not found")
+
+	// ErrTimerRunning is returned by StartTimer under TimerPolicyReject while another entry's timer
+	// runs, and by either policy when another start wins a race to the timer
+	ErrTimerRunning = errors.New("another timer is running")
+
+	// ErrTimerNotRunning is returned for pausing or stopping an entry that isn't being timed
+	ErrTimerNotRunning = errors.New("timer is not running")
+)
+
+// TimerState is whether the owner's timer is counting
+type TimerState string
+
+const (
+	TimerRunning TimerState = "running"
+	TimerPaused  TimerState = "paused"
+)
+
+// Timer is the entry an owner is timing. Each owner has at most one.
+type Timer struct {
+	EntryID string
+	State   TimerState
+	Since   time.Time // When it last started or paused
+}
+
+// TimerStore is implemented by stores that keep each owner's timer
+type TimerStore interface {
+	// GetTimer returns the owner's timer, reporting whether there is one
+	GetTimer(ctx context.Context) (Timer, bool, error)
+	// SaveTimer replaces the owner's timer
+	SaveTimer(ctx context.Context, timer Timer) error
+	// ClaimTimer saves a running timer unless the stored one is running on an entry other
+	// than timer.EntryID and replacing, returning ErrTimerRunning then. The check is made
+	// as it saves, so of two starts racing only one gets the timer.
+	ClaimTimer(ctx context.Context, timer Timer, replacing string) error
+	// DeleteTimer removes the owner's timer; removing none is not an error
+	DeleteTimer(ctx context.Context) error
+}
+
+// Timers returns the store's timers, or ErrTimersUnsupported if it keeps none
+func Timers(store Store) (TimerStore, error) {
+	timers, ok := store.(TimerStore)
+	if !ok {
+		return nil, ErrTimersUnsupported
+	}
+	return timers, nil
+}
+
+// TimerPolicy decides what starting a timer does while another entry's timer runs
+type TimerPolicy string
+
+const (
+	TimerPolicyPause  TimerPolicy = "pause"  // Pause the running entry first
+	TimerPolicyReject TimerPolicy = "reject" // Refuse with ErrTimerRunning
+)
+
+// ParseTimerPolicy returns the policy named s; blank is TimerPolicyPause
+func ParseTimerPolicy(s string) (TimerPolicy, error) {
+	switch policy := TimerPolicy(strings.ToLower(strings.TrimSpace(s))); policy {
+	case "":
+		return TimerPolicyPause, nil
+	case TimerPolicyPause, TimerPolicyReject:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown timer policy %q: must be %s or %s", s, TimerPolicyPause, TimerPolicyReject)
+	}
+}
+
+// StartTimer starts timing an entry. An entry that never started starts now; one that was
+// paused or finished resumes, its start moved forward by the time it spent stopped so its
+// duration only counts time worked. Starting the running entry again changes nothing.
+func StartTimer(ctx context.Context, store Store, id string, policy TimerPolicy) (Timer, core.Entry, error) {
+	timers, err := Timers(store)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+
+	entry, err := timedEntry(ctx, store, id)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+
+	current, ok, err := timers.GetTimer(ctx)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	replacing := ""
+	if ok && current.State == TimerRunning {
+		if current.EntryID == id && entry.InProgress() {
+			return current, entry, nil
+		}
+		if current.EntryID != id {
+			if policy == TimerPolicyReject {
+				return Timer{}, core.Entry{}, fmt.Errorf("%w: entry %s", ErrTimerRunning, current.EntryID)
+			}
+			replacing = current.EntryID
+		}
+	}
+
+	// Claim the timer before touching entries: it only succeeds while the timer is as read
+	now := time.Now().UTC()
+	timer := Timer{EntryID: id, State: TimerRunning, Since: now}
+	if err := timers.ClaimTimer(ctx, timer, replacing); err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	if replacing != "" {
+		if err := pauseEntry(ctx, store, replacing); err != nil {
+			return Timer{}, core.Entry{}, err
+		}
+	}
+
+	switch {
+	case entry.StartedAtTimestamp.IsZero():
+		entry.StartedAtTimestamp = now
+		entry.EndedAtTimestamp = time.Time{}
+	case !entry.InProgress():
+		entry.StartedAtTimestamp = entry.StartedAtTimestamp.Add(now.Sub(entry.EndedAtTimestamp))
+		entry.EndedAtTimestamp = time.Time{}
+	}
+
+	saved, err := SaveEntry(ctx, store, entry)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	return timer, saved, nil
+}
+
+// PauseTimer pauses the running timer on an entry, ending the entry now until it resumes
+func PauseTimer(ctx context.Context, store Store, id string) (Timer, core.Entry, error) {
+	timers, err := Timers(store)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+
+	current, ok, err := timers.GetTimer(ctx)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	if !ok || current.EntryID != id || current.State != TimerRunning {
+		return Timer{}, core.Entry{}, fmt.Errorf("%w: entry %s", ErrTimerNotRunning, id)
+	}
+
+	entry, err := timedEntry(ctx, store, id)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	if !entry.InProgress() {
+		return Timer{}, core.Entry{}, fmt.Errorf("%w: entry %s has already ended", ErrTimerNotRunning, id)
+	}
+
+	now := time.Now().UTC()
+	entry.EndedAtTimestamp = now
+	saved, err := SaveEntry(ctx, store, entry)
+	if err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	timer := Timer{EntryID: id, State: TimerPaused, Since: now}
+	if err := timers.SaveTimer(ctx, timer); err != nil {
+		return Timer{}, core.Entry{}, err
+	}
+	return timer, saved, nil
+}
+
+// StopTimer finishes an entry. A running entry ends now; a paused one keeps the end it was
+// paused at. Either way the owner's timer is cleared if it was on this entry.
+func StopTimer(ctx context.Context, store Store, id string) (core.Entry, error) {
+	timers, err := Timers(store)
+	if err != nil {
+		return core.Entry{}, err
+	}
+	if err := Authorize(ctx, RoleEditor); err != nil {
+		return core.Entry{}, err
+	}
+
+	entry, err := timedEntry(ctx, store, id)
+	if err != nil {
+		return core.Entry{}, err
+	}
+	current, ok, err := timers.GetTimer(ctx)
+	if err != nil {
+		return core.Entry{}, err
+	}
+	timing := ok && current.EntryID == id
+
+	if entry.InProgress() {
+		if entry.StartedAtTimestamp.IsZero() {
+			return core.Entry{}, fmt.Errorf("%w: entry %s has not started", ErrTimerNotRunning, id)
+		}
+		entry.EndedAtTimestamp = time.Now().UTC()
+		if entry, err = SaveEntry(ctx, store, entry); err != nil {
+			return core.Entry{}, err
+		}
+	} else if !timing {
+		return core.Entry{}, fmt.Errorf("%w: entry %s has already ended", ErrTimerNotRunning, id)
+	}
+
+	if timing {
+		if err := timers.DeleteTimer(ctx); err != nil {
+			return core.Entry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// CurrentTimer returns the owner's timer and its entry, reporting whether there is one.
+// A timer whose entry was deleted, or ended elsewhere while running, no longer counts.
+func CurrentTimer(ctx context.Context, store Store) (Timer, core.Entry, bool, error) {
+	timers, err := Timers(store)
+	if err != nil {
+		return Timer{}, core.Entry{}, false, err
+	}
+
+	current, ok, err := timers.GetTimer(ctx)
+	if err != nil || !ok {
+		return Timer{}, core.Entry{}, false, err
+	}
+
+	entry, err := timedEntry(ctx, store, current.EntryID)
+	if errors.Is(err, ErrEntryNotFound) {
+		return Timer{}, core.Entry{}, false, nil
+	}
+	if err != nil {
+		return Timer{}, core.Entry{}, false, err
+	}
+	if current.State == TimerRunning && !entry.InProgress() {
+		return Timer{}, core.Entry{}, false, nil
+	}
+	return current, entry, true, nil
+}
+
+// pauseEntry ends a running entry now, for StartTimer to switch to another.
+// An entry that has gone or already ended is left alone.
+func pauseEntry(ctx context.Context, store Store, id string) error {
+	entry, err := timedEntry(ctx, store, id)
+	if errors.Is(err, ErrEntryNotFound) {
+		return nil
+	}
+	if err != nil || !entry.InProgress() {
+		return err
+	}
+
+	entry.EndedAtTimestamp = time.Now().UTC()
+	if _, err := SaveEntry(ctx, store, entry); err != nil {
+		return fmt.Errorf("failed to pause entry %s: %w", id, err)
+	}
+	return nil
+}
+
+// timedEntry looks up the entry a timer acts on
+func timedEntry(ctx context.Context, store Store, id string) (core.Entry, error) {
+	result, err := store.Query(ctx, Query{IDs: []string{id}, Limit: 1})
+	if err != nil {
+		return core.Entry{}, err
+	}
+	if len(result.Entries) == 0 {
+		return core.Entry{}, fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+	return result.Entries[0], nil
+}
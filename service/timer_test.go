@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestTimer_PauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	hourAgo := time.Now().UTC().Add(-time.Hour)
+	store := NewMemoryStore(core.Entry{ID: "1", Title: "Deploy", StartedAtTimestamp: hourAgo.Add(-time.Hour), EndedAtTimestamp: hourAgo})
+
+	// Resuming moves the start forward past the hour spent stopped
+	_, entry, err := StartTimer(ctx, store, "1", TimerPolicyPause)
+	assertNilError(t, err)
+	if !entry.InProgress() {
+		t.Fatalf("StartTimer() left the entry ended: %+v", entry)
+	}
+	if worked := time.Since(entry.StartedAtTimestamp); worked < time.Hour || worked > time.Hour+time.Minute {
+		t.Errorf("time worked after resuming = %v; want about 1h", worked)
+	}
+
+	_, entry, err = PauseTimer(ctx, store, "1")
+	assertNilError(t, err)
+	if entry.InProgress() {
+		t.Errorf("PauseTimer() left the entry in progress")
+	}
+	timer, _, ok, err := CurrentTimer(ctx, store)
+	assertNilError(t, err)
+	assertEquality(t, ok, true)
+	assertEquality(t, timer.State, TimerPaused)
+
+	if _, _, err := PauseTimer(ctx, store, "1"); !errors.Is(err, ErrTimerNotRunning) {
+		t.Errorf("PauseTimer(paused) error = %v; want ErrTimerNotRunning", err)
+	}
+
+	// Stopping a paused entry keeps the end it was paused at
+	stopped, err := StopTimer(ctx, store, "1")
+	assertNilError(t, err)
+	assertEquality(t, stopped.EndedAtTimestamp, entry.EndedAtTimestamp)
+	if _, _, ok, _ := CurrentTimer(ctx, store); ok {
+		t.Errorf("CurrentTimer() after stop found a timer")
+	}
+	if _, err := StopTimer(ctx, store, "1"); !errors.Is(err, ErrTimerNotRunning) {
+		t.Errorf("StopTimer(stopped) error = %v; want ErrTimerNotRunning", err)
+	}
+}
+
+func TestTimer_OneRunningAtATime(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(core.Entry{ID: "1", Title: "Deploy"}, core.Entry{ID: "2", Title: "Review"})
+
+	_, _, err := StartTimer(ctx, store, "1", TimerPolicyReject)
+	assertNilError(t, err)
+	if _, _, err := StartTimer(ctx, store, "2", TimerPolicyReject); !errors.Is(err, ErrTimerRunning) {
+		t.Errorf("StartTimer(2, reject) error = %v; want ErrTimerRunning", err)
+	}
+
+	_, _, err = StartTimer(ctx, store, "2", TimerPolicyPause)
+	assertNilError(t, err)
+	entries, _ := store.GetAll(ctx)
+	if first := entries["1"]; first.InProgress() {
+		t.Errorf("entry 1 still in progress after starting entry 2")
+	}
+	timer, entry, ok, err := CurrentTimer(ctx, store)
+	assertNilError(t, err)
+	assertEquality(t, ok, true)
+	assertEquality(t, timer.EntryID, "2")
+	assertEquality(t, entry.ID, "2")
+
+	// Ending the entry elsewhere, such as in the TUI, stops its timer
+	ended := entries["2"]
+	ended.EndedAtTimestamp = time.Now().UTC()
+	assertNilError(t, store.SaveEntry(ctx, ended))
+	if _, _, ok, _ := CurrentTimer(ctx, store); ok {
+		t.Errorf("CurrentTimer() reported an entry that has ended")
+	}
+
+	if _, _, err := StartTimer(ctx, store, "missing", TimerPolicyPause); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("StartTimer(missing) error = %v; want ErrEntryNotFound", err)
+	}
+}
+
+// startAfterRead starts another entry's timer right after StartTimer reads the timer
+type startAfterRead struct {
+	*MemoryStore
+	other string
+}
+
+func (s *startAfterRead) GetTimer(ctx context.Context) (Timer, bool, error) {
+	timer, ok, err := s.MemoryStore.GetTimer(ctx)
+	if err != nil {
+		return timer, ok, err
+	}
+	return timer, ok, s.MemoryStore.SaveTimer(ctx, Timer{EntryID: s.other, State: TimerRunning, Since: time.Now().UTC()})
+}
+
+func TestTimer_StartChecksTheTimerAsItClaims(t *testing.T) {
+	ctx := context.Background()
+	store := &startAfterRead{MemoryStore: NewMemoryStore(core.Entry{ID: "1", Title: "Deploy"}, core.Entry{ID: "2", Title: "Review"}), other: "2"}
+
+	// No timer was running when it was read, but one was by the time it was claimed
+	for _, policy := range []TimerPolicy{TimerPolicyPause, TimerPolicyReject} {
+		if _, _, err := StartTimer(ctx, store, "1", policy); !errors.Is(err, ErrTimerRunning) {
+			t.Errorf("StartTimer(%s) error = %v; want ErrTimerRunning", policy, err)
+		}
+	}
+	entries, _ := store.GetAll(ctx)
+	if !entries["1"].StartedAtTimestamp.IsZero() {
+		t.Errorf("entry 1 = %+v; want it left unstarted", entries["1"])
+	}
+}
+
+func TestTimer_ConcurrentStarts(t *testing.T) {
+	ctx := context.Background()
+	var seeded []core.Entry
+	for i := range 20 {
+		seeded = append(seeded, core.Entry{ID: fmt.Sprint(i), Title: "Entry"})
+	}
+	store := NewMemoryStore(seeded...)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var started []string
+	for _, entry := range seeded {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := StartTimer(ctx, store, entry.ID, TimerPolicyReject)
+			if err != nil && !errors.Is(err, ErrTimerRunning) {
+				t.Errorf("StartTimer(%s) error = %v", entry.ID, err)
+			}
+			if err == nil {
+				mu.Lock()
+				started = append(started, entry.ID)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// However the starts interleave, exactly one wins the timer and only its entry runs
+	if len(started) != 1 {
+		t.Fatalf("started = %v; want exactly one", started)
+	}
+	timer, _, ok, err := CurrentTimer(ctx, store)
+	assertNilError(t, err)
+	assertEquality(t, ok, true)
+	assertEquality(t, timer.EntryID, started[0])
+
+	entries, _ := store.GetAll(ctx)
+	for id, entry := range entries {
+		if running := !entry.StartedAtTimestamp.IsZero(); running != (id == started[0]) {
+			t.Errorf("entry %s started = %v; want only entry %s started", id, running, started[0])
+		}
+	}
+}
+
+func TestParseTimerPolicy(t *testing.T) {
+	if policy, err := ParseTimerPolicy(""); err != nil || policy != TimerPolicyPause {
+		t.Errorf("ParseTimerPolicy(\"\") = %q, %v; want pause", policy, err)
+	}
+	if _, err := ParseTimerPolicy("queue"); err == nil {
+		t.Errorf("ParseTimerPolicy(queue) error = nil; want error")
+	}
+}
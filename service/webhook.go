@@ -0,0 +1,716 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/logger"
+)
+
+// ErrWebhooksUnsupported is returned by stores that can't queue webhook deliveries
+var ErrWebhooksUnsupported = errors.New("store does not support webhook deliveries")
+
+// WebhookEvent is a change to an entry that subscriptions can ask to hear about
+type WebhookEvent string
+
+const (
+	WebhookCreated   WebhookEvent = "created"
+	WebhookUpdated   WebhookEvent = "updated"
+	WebhookCompleted WebhookEvent = "completed" // The entry ended; sent alongside created or updated
+	WebhookDeleted   WebhookEvent = "deleted"
+)
+
+// WebhookEvents lists every event, in the order they are sent for one change
+var WebhookEvents = []WebhookEvent{WebhookCreated, WebhookUpdated, WebhookCompleted, WebhookDeleted}
+
+// ParseWebhookEvent returns the event named s
+func ParseWebhookEvent(s string) (WebhookEvent, error) {
+	event := WebhookEvent(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(WebhookEvents, event) {
+		return "", fmt.Errorf("unknown webhook event %q: must be created, updated, completed or deleted", s)
+	}
+	return event, nil
+}
+
+// Subscription is an endpoint that receives signed deliveries of the events it asks for
+type Subscription struct {
+	ID     string // Names the subscription in the delivery log
+	URL    string
+	Secret string         // Signs each delivery's body; see SignWebhook
+	Events []WebhookEvent // Empty subscribes to every event
+}
+
+// Wants reports whether the subscription asked for event
+func (s Subscription) Wants(event WebhookEvent) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, event)
+}
+
+// DeliveryStatus is where a delivery is in its lifecycle
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // Waiting for its first or next attempt
+	DeliveryDelivered DeliveryStatus = "delivered" // The endpoint answered 2xx
+	DeliveryFailed    DeliveryStatus = "failed"    // Gave up after the last attempt
+)
+
+// Delivery is one event queued for one subscription, and the record of its attempts
+type Delivery struct {
+	ID             string // Stable for the same change, so receivers can drop repeats
+	SubscriptionID string
+	Event          WebhookEvent
+	Owner          string
+	EntryID        string
+	Payload        []byte // The JSON body sent, as signed
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string // Empty once delivered
+	ResponseStatus int    // HTTP status of the last attempt; 0 if it got no response
+	CreatedAt      time.Time
+	DeliveredAt    time.Time
+}
+
+// DeliveryQueue is implemented by stores that persist webhook deliveries until they succeed.
+// Deliveries of every owner share the queue; the log is read per owner.
+type DeliveryQueue interface {
+	// EnqueueDeliveries adds pending deliveries, skipping any whose ID is already queued
+	EnqueueDeliveries(ctx context.Context, deliveries []Delivery) error
+	// ClaimDeliveries returns up to limit pending deliveries due by now, oldest first, and
+	// holds them until now plus lease so another dispatcher doesn't send them meanwhile
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// UpdateDelivery records the outcome of an attempt
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	// ListDeliveries returns the owner's most recent deliveries, newest first
+	ListDeliveries(ctx context.Context, limit int) ([]Delivery, error)
+}
+
+// DeliveryOutbox is implemented by stores that queue webhook deliveries in the same transaction
+// as the entry write they describe. When the context of WriteEntries, SaveEntryVersion or
+// DeleteEntryVersion carries deliveries from WithDeliveries, they are queued in Outbox along
+// with the write, and if either fails neither is kept.
+type DeliveryOutbox interface {
+	// Outbox returns the queue deliveries are written to; nil if there is none
+	Outbox() DeliveryQueue
+}
+
+type deliveriesKey struct{}
+
+// WithDeliveries returns a context whose entry write on a DeliveryOutbox also queues deliveries
+func WithDeliveries(ctx context.Context, deliveries []Delivery) context.Context {
+	return context.WithValue(ctx, deliveriesKey{}, deliveries)
+}
+
+// DeliveriesFromContext returns the deliveries to queue with a write, if any
+func DeliveriesFromContext(ctx context.Context) []Delivery {
+	deliveries, _ := ctx.Value(deliveriesKey{}).([]Delivery)
+	return deliveries
+}
+
+// Deliveries returns the store's delivery queue, or ErrWebhooksUnsupported if it has none
+func Deliveries(store Store) (DeliveryQueue, error) {
+	queue, ok := store.(DeliveryQueue)
+	if !ok {
+		return nil, ErrWebhooksUnsupported
+	}
+	return queue, nil
+}
+
+// WebhookPayload is the JSON body of a delivery
+type WebhookPayload struct {
+	ID         string       `json:"id"` // The delivery ID, also sent as X-Zenzen-Delivery
+	Event      WebhookEvent `json:"event"`
+	OccurredAt string       `json:"occurred_at"`
+	Owner      string       `json:"owner,omitempty"`
+	Entry      WebhookEntry `json:"entry"`
+}
+
+// WebhookEntry is the entry a delivery is about, as it was after the change; deleted
+// entries are sent as they were before
+type WebhookEntry struct {
+	ID               string   `json:"id"`
+	Title            string   `json:"title"`
+	Tags             []string `json:"tags"`
+	StartedAt        string   `json:"started_at,omitempty"`
+	EndedAt          string   `json:"ended_at,omitempty"`
+	LastModified     string   `json:"last_modified,omitempty"`
+	EstimatedMinutes int64    `json:"estimated_minutes,omitempty"`
+	ActualMinutes    int64    `json:"actual_minutes,omitempty"`
+	Body             string   `json:"body"`
+	InProgress       bool     `json:"in_progress"`
+}
+
+// SignWebhook returns the X-Zenzen-Signature of a delivery body: "sha256=" and the hex
+// HMAC-SHA256 of the body keyed with the subscription's secret
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookStore fires webhooks for every entry written through it, so the TUI, the API and
+// sync all send them. It reads each entry before writing to tell created from updated, and
+// queues a delivery per subscription that wants the event; a WebhookDispatcher sends them.
+// When the wrapped store is a DeliveryOutbox for the queue, as the SQL stores are for their
+// own, deliveries are queued in the write's transaction, so a failure to queue them fails
+// the write too. Otherwise they are queued once the write succeeds, and a failure is logged
+// as webhook_enqueue_failed without failing the write. Everything else is passed on to the
+// wrapped store.
+//
+// A delivery's ID is derived from the subscription, event, owner, entry and the entry's
+// LastModifiedTimestamp, which sync preserves. The same change seen again, such as when
+// sync copies it, is queued once per queue, and receivers can drop repeats from another.
+type WebhookStore struct {
+	store         Store
+	queue         DeliveryQueue
+	outbox        bool // Whether store queues deliveries in queue with each write
+	subscriptions []Subscription
+}
+
+// NewWebhookStore wraps store, queueing deliveries for subscriptions in queue, usually
+// the same database as store
+func NewWebhookStore(store Store, queue DeliveryQueue, subscriptions []Subscription) *WebhookStore {
+	outbox, ok := store.(DeliveryOutbox)
+	return &WebhookStore{
+		store:         store,
+		queue:         queue,
+		outbox:        ok && outbox.Outbox() == queue,
+		subscriptions: subscriptions,
+	}
+}
+
+// GetAll returns every entry from the wrapped store
+func (w *WebhookStore) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	return w.store.GetAll(ctx)
+}
+
+// Query queries the wrapped store
+func (w *WebhookStore) Query(ctx context.Context, q Query) (QueryResult, error) {
+	return w.store.Query(ctx, q)
+}
+
+// SaveEntry saves through to the wrapped store and queues created, updated and completed events
+func (w *WebhookStore) SaveEntry(ctx context.Context, entry core.Entry) error {
+	return w.SaveEntries(ctx, []core.Entry{entry})
+}
+
+// SaveEntries saves through to the wrapped store and queues created, updated and completed events
+func (w *WebhookStore) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	return w.WriteEntries(ctx, entries, nil)
+}
+
+// DeleteEntry deletes through the wrapped store and queues a deleted event if the entry existed
+func (w *WebhookStore) DeleteEntry(ctx context.Context, id string) error {
+	return w.DeleteEntries(ctx, []string{id})
+}
+
+// DeleteEntries deletes through the wrapped store and queues a deleted event per entry that existed
+func (w *WebhookStore) DeleteEntries(ctx context.Context, ids []string) error {
+	return w.WriteEntries(ctx, nil, ids)
+}
+
+// SaveEntryVersion saves through to the wrapped store, queues the entry's events and returns its new version
+func (w *WebhookStore) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	var version int64
+	err := w.notify(ctx, []core.Entry{entry}, nil, func(ctx context.Context) (err error) {
+		version, err = w.store.SaveEntryVersion(ctx, entry)
+		return err
+	})
+	return version, err
+}
+
+// DeleteEntryVersion deletes through the wrapped store and queues a deleted event if it did
+func (w *WebhookStore) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	return w.notify(ctx, nil, []string{id}, func(ctx context.Context) error {
+		return w.store.DeleteEntryVersion(ctx, id, version)
+	})
+}
+
+// WriteEntries writes through to the wrapped store, then queues the events of every save
+// and a deleted event per deleted entry that existed
+func (w *WebhookStore) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	if len(saves) == 0 && len(deletes) == 0 {
+		return w.store.WriteEntries(ctx, saves, deletes)
+	}
+
+	return w.notify(ctx, saves, deletes, func(ctx context.Context) error {
+		switch {
+		case len(DeliveriesFromContext(ctx)) > 0:
+			return w.store.WriteEntries(ctx, saves, deletes) // The only batch write that queues them
+		case len(saves) == 1 && len(deletes) == 0:
+			return w.store.SaveEntry(ctx, saves[0])
+		case len(saves) == 0 && len(deletes) == 1:
+			return w.store.DeleteEntry(ctx, deletes[0])
+		default:
+			return w.store.WriteEntries(ctx, saves, deletes)
+		}
+	})
+}
+
+// notify runs write, which saves saves and deletes deletes in the wrapped store, and queues
+// the events of every save and a deleted event per deleted entry that existed
+func (w *WebhookStore) notify(ctx context.Context, saves []core.Entry, deletes []string, write func(context.Context) error) error {
+	ids := make([]string, 0, len(saves)+len(deletes))
+	for _, entry := range saves {
+		ids = append(ids, entry.ID)
+	}
+	previous, err := w.lookup(ctx, append(ids, deletes...))
+	if err != nil {
+		return err
+	}
+
+	var deliveries []Delivery
+	for _, entry := range UniqueEntries(saves) {
+		before, existed := previous[entry.ID]
+		switch {
+		case !existed:
+			deliveries = w.appendDeliveries(ctx, deliveries, WebhookCreated, entry, entry.LastModifiedTimestamp)
+		case entrySent(before) == entrySent(entry):
+			continue
+		default:
+			deliveries = w.appendDeliveries(ctx, deliveries, WebhookUpdated, entry, entry.LastModifiedTimestamp)
+		}
+		if !entry.InProgress() && (!existed || before.InProgress()) {
+			deliveries = w.appendDeliveries(ctx, deliveries, WebhookCompleted, entry, entry.LastModifiedTimestamp)
+		}
+	}
+
+	deletedAt := time.Now().UTC()
+	for _, id := range deletes {
+		if entry, existed := previous[id]; existed {
+			deliveries = w.appendDeliveries(ctx, deliveries, WebhookDeleted, entry, deletedAt)
+		}
+	}
+
+	if w.outbox && len(deliveries) > 0 {
+		return write(WithDeliveries(ctx, deliveries))
+	}
+	if err := write(ctx); err != nil {
+		return err
+	}
+	w.enqueue(ctx, deliveries)
+	return nil
+}
+
+// lookup reads the stored copies of the entries about to change, by ID
+func (w *WebhookStore) lookup(ctx context.Context, ids []string) (map[string]core.Entry, error) {
+	result, err := w.store.Query(ctx, Query{IDs: ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries before writing: %w", err)
+	}
+
+	previous := make(map[string]core.Entry, len(result.Entries))
+	for _, entry := range result.Entries {
+		previous[entry.ID] = entry
+	}
+	return previous, nil
+}
+
+// appendDeliveries adds a delivery of event for each subscription that wants it
+func (w *WebhookStore) appendDeliveries(ctx context.Context, deliveries []Delivery, event WebhookEvent, entry core.Entry, at time.Time) []Delivery {
+	owner := OwnerFromContext(ctx)
+	now := time.Now().UTC()
+	if at.IsZero() {
+		at = now
+	}
+
+	for _, subscription := range w.subscriptions {
+		if !subscription.Wants(event) {
+			continue
+		}
+
+		id := deliveryID(subscription.ID, event, owner, entry.ID, at)
+		payload, err := json.Marshal(WebhookPayload{
+			ID:         id,
+			Event:      event,
+			OccurredAt: at.UTC().Format(time.RFC3339Nano),
+			Owner:      owner,
+			Entry:      toWebhookEntry(entry),
+		})
+		if err != nil {
+			logger.Error("webhook_payload_failed", "subscription", subscription.ID, "entry_id", entry.ID, "error", err.Error())
+			continue
+		}
+
+		deliveries = append(deliveries, Delivery{
+			ID:             id,
+			SubscriptionID: subscription.ID,
+			Event:          event,
+			Owner:          owner,
+			EntryID:        entry.ID,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+	return deliveries
+}
+
+// enqueue queues deliveries for an entry write that has already succeeded, when the wrapped
+// store can't queue them with it. A failure is logged rather than returned, since the write
+// itself stands; the write's error is for the write alone.
+func (w *WebhookStore) enqueue(ctx context.Context, deliveries []Delivery) {
+	if len(deliveries) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := w.queue.EnqueueDeliveries(ctx, deliveries); err != nil {
+		logger.Error("webhook_enqueue_failed", "count", len(deliveries), "error", err.Error())
+	}
+}
+
+// Search passes on the wrapped store's full-text search, or else searches every entry
+func (w *WebhookStore) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+	if searcher, ok := w.store.(Searcher); ok {
+		return searcher.Search(ctx, text, limit)
+	}
+
+	entries, err := w.store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return SearchEntries(entries, text, limit), nil
+}
+
+// ListTags uses the wrapped store's tag counts, or else counts every entry
+func (w *WebhookStore) ListTags(ctx context.Context) ([]Tag, error) {
+	if tagStore, ok := w.store.(TagStore); ok {
+		return tagStore.ListTags(ctx)
+	}
+
+	entries, err := w.store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return CountTags(entries), nil
+}
+
+// SaveTag saves tag metadata to the wrapped store; entries are unaffected
+func (w *WebhookStore) SaveTag(ctx context.Context, tag Tag) error {
+	tagStore, ok := w.store.(TagStore)
+	if !ok {
+		return ErrTagMetadataUnsupported
+	}
+	return tagStore.SaveTag(ctx, tag)
+}
+
+// DeleteTag deletes tag metadata from the wrapped store; entries are unaffected
+func (w *WebhookStore) DeleteTag(ctx context.Context, name string) error {
+	tagStore, ok := w.store.(TagStore)
+	if !ok {
+		return ErrTagMetadataUnsupported
+	}
+	return tagStore.DeleteTag(ctx, name)
+}
+
+// CreateWorkspace creates a workspace in the wrapped store
+func (w *WebhookStore) CreateWorkspace(ctx context.Context, workspace Workspace, admin Member) error {
+	workspaces, err := Workspaces(w.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.CreateWorkspace(ctx, workspace, admin)
+}
+
+// ListWorkspaces lists principal's workspaces from the wrapped store
+func (w *WebhookStore) ListWorkspaces(ctx context.Context, principal string) ([]Membership, error) {
+	workspaces, err := Workspaces(w.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListWorkspaces(ctx, principal)
+}
+
+// ListMembers lists a workspace's members from the wrapped store
+func (w *WebhookStore) ListMembers(ctx context.Context, workspaceID string) ([]Member, error) {
+	workspaces, err := Workspaces(w.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListMembers(ctx, workspaceID)
+}
+
+// SaveMember saves a workspace member to the wrapped store
+func (w *WebhookStore) SaveMember(ctx context.Context, workspaceID string, member Member) error {
+	workspaces, err := Workspaces(w.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.SaveMember(ctx, workspaceID, member)
+}
+
+// DeleteMember removes a workspace member from the wrapped store
+func (w *WebhookStore) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	workspaces, err := Workspaces(w.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.DeleteMember(ctx, workspaceID, principal)
+}
+
+// GetTimer reads the owner's timer from the wrapped store
+func (w *WebhookStore) GetTimer(ctx context.Context) (Timer, bool, error) {
+	timers, err := Timers(w.store)
+	if err != nil {
+		return Timer{}, false, err
+	}
+	return timers.GetTimer(ctx)
+}
+
+// SaveTimer saves the owner's timer to the wrapped store
+func (w *WebhookStore) SaveTimer(ctx context.Context, timer Timer) error {
+	timers, err := Timers(w.store)
+	if err != nil {
+		return err
+	}
+	return timers.SaveTimer(ctx, timer)
+}
+
+// ClaimTimer claims the owner's timer in the wrapped store
+func (w *WebhookStore) ClaimTimer(ctx context.Context, timer Timer, replacing string) error {
+	timers, err := Timers(w.store)
+	if err != nil {
+		return err
+	}
+	return timers.ClaimTimer(ctx, timer, replacing)
+}
+
+// DeleteTimer removes the owner's timer from the wrapped store
+func (w *WebhookStore) DeleteTimer(ctx context.Context) error {
+	timers, err := Timers(w.store)
+	if err != nil {
+		return err
+	}
+	return timers.DeleteTimer(ctx)
+}
+
+// Subscribe passes on the wrapped store's change feed
+func (w *WebhookStore) Subscribe(ctx context.Context) (<-chan ChangeEvent, error) {
+	feed, ok := w.store.(ChangeFeed)
+	if !ok {
+		return nil, ErrChangeFeedUnsupported
+	}
+	return feed.Subscribe(ctx)
+}
+
+// WebhookOptions configures a WebhookDispatcher; zero fields take the defaults
+type WebhookOptions struct {
+	MaxAttempts  int           // Attempts before a delivery fails (default 8)
+	BaseDelay    time.Duration // Wait before the first retry, doubling after each (default 30s)
+	MaxDelay     time.Duration // Longest wait between retries (default 1h)
+	PollInterval time.Duration // How often the queue is checked (default 5s)
+	Timeout      time.Duration // Per request (default 10s)
+	BatchSize    int           // Deliveries claimed per check (default 50)
+}
+
+// withDefaults fills in the zero fields
+func (o WebhookOptions) withDefaults() WebhookOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 8
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = 30 * time.Second
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = time.Hour
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 50
+	}
+	return o
+}
+
+// RetryDelay returns how long to wait after a delivery's attempts'th failed attempt:
+// BaseDelay, doubling each time, up to MaxDelay
+func (o WebhookOptions) RetryDelay(attempts int) time.Duration {
+	o = o.withDefaults()
+	delay := o.BaseDelay
+	for i := 1; i < attempts && delay < o.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxDelay)
+}
+
+// WebhookDispatcher sends queued deliveries, retrying failures with exponential backoff
+type WebhookDispatcher struct {
+	queue         DeliveryQueue
+	subscriptions map[string]Subscription // By ID
+	opts          WebhookOptions
+	client        *http.Client
+}
+
+// NewWebhookDispatcher creates a dispatcher sending queue's deliveries to subscriptions
+func NewWebhookDispatcher(queue DeliveryQueue, subscriptions []Subscription, opts WebhookOptions) *WebhookDispatcher {
+	opts = opts.withDefaults()
+	byID := make(map[string]Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		byID[subscription.ID] = subscription
+	}
+	return &WebhookDispatcher{
+		queue:         queue,
+		subscriptions: byID,
+		opts:          opts,
+		client:        &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+// Run sends due deliveries every PollInterval until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("webhook_dispatch_failed", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one attempt at each delivery that is due, returning how many it attempted
+func (d *WebhookDispatcher) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	// Held for longer than an attempt can take, so a dispatcher that dies mid-attempt retries
+	lease := d.opts.Timeout + time.Minute
+
+	deliveries, err := d.queue.ClaimDeliveries(ctx, now, lease, d.opts.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(ctx, &delivery)
+		if err := d.queue.UpdateDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends a delivery once and records the outcome on it
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	delivery.Attempts++
+	delivery.ResponseStatus = 0
+
+	status, err := d.send(ctx, *delivery)
+	delivery.ResponseStatus = status
+	if err == nil {
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = time.Now().UTC()
+		logger.Info("webhook_delivered", "delivery_id", delivery.ID, "subscription", delivery.SubscriptionID, "event", string(delivery.Event), "attempts", delivery.Attempts)
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.opts.MaxAttempts || errors.Is(err, errUnknownSubscription) {
+		delivery.Status = DeliveryFailed
+		logger.Error("webhook_delivery_failed", "delivery_id", delivery.ID, "subscription", delivery.SubscriptionID, "attempts", delivery.Attempts, "error", err.Error())
+		return
+	}
+	delivery.NextAttemptAt = time.Now().UTC().Add(d.opts.RetryDelay(delivery.Attempts))
+	logger.Warn("webhook_delivery_retrying", "delivery_id", delivery.ID, "subscription", delivery.SubscriptionID, "attempts", delivery.Attempts, "next_attempt_at", delivery.NextAttemptAt, "error", err.Error())
+}
+
+// errUnknownSubscription fails deliveries whose subscription has been removed from the config
+var errUnknownSubscription = errors.New("subscription is no longer configured")
+
+// send POSTs a delivery to its subscription, returning the response status
+func (d *WebhookDispatcher) send(ctx context.Context, delivery Delivery) (int, error) {
+	subscription, ok := d.subscriptions[delivery.SubscriptionID]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUnknownSubscription, delivery.SubscriptionID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zenzen-webhooks")
+	req.Header.Set("X-Zenzen-Event", string(delivery.Event))
+	req.Header.Set("X-Zenzen-Delivery", delivery.ID)
+	req.Header.Set("X-Zenzen-Attempt", strconv.Itoa(delivery.Attempts))
+	req.Header.Set("X-Zenzen-Signature", SignWebhook(subscription.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// deliveryID derives a delivery's ID from the change it reports
+func deliveryID(subscription string, event WebhookEvent, owner, entryID string, at time.Time) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{subscription, string(event), owner, entryID, strconv.FormatInt(at.UnixNano(), 10)}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// entrySent is the part of an entry a payload carries, to skip writes that change nothing
+// a subscriber sees, such as sync copying an entry back unchanged
+func entrySent(entry core.Entry) string {
+	sent, _ := json.Marshal(toWebhookEntry(entry))
+	return string(sent)
+}
+
+// toWebhookEntry converts an entry for a payload
+func toWebhookEntry(entry core.Entry) WebhookEntry {
+	sent := WebhookEntry{
+		ID:               entry.ID,
+		Title:            entry.Title,
+		Tags:             entry.Tags,
+		EstimatedMinutes: int64(entry.EstimatedDuration / time.Minute),
+		Body:             entry.Body,
+		InProgress:       entry.InProgress(),
+	}
+	if sent.Tags == nil {
+		sent.Tags = []string{}
+	}
+	if !entry.StartedAtTimestamp.IsZero() {
+		sent.StartedAt = entry.StartedAtTimestamp.UTC().Format(time.RFC3339)
+	}
+	if !entry.EndedAtTimestamp.IsZero() {
+		sent.EndedAt = entry.EndedAtTimestamp.UTC().Format(time.RFC3339)
+	}
+	if !entry.LastModifiedTimestamp.IsZero() {
+		sent.LastModified = entry.LastModifiedTimestamp.UTC().Format(time.RFC3339)
+	}
+	if actual, ok := actualDuration(entry); ok {
+		sent.ActualMinutes = int64(actual / time.Minute)
+	}
+	return sent
+}
@@ -0,0 +1,257 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func TestWebhookStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) service.Store {
+		memory := service.NewMemoryStore()
+		return service.NewWebhookStore(memory, memory, []service.Subscription{{ID: "all", URL: "http://localhost"}})
+	})
+}
+
+func TestWebhookStore_Events(t *testing.T) {
+	ctx := service.WithOwner(context.Background(), "alice")
+	memory := service.NewMemoryStore()
+	store := service.NewWebhookStore(memory, memory, []service.Subscription{
+		{ID: "all", URL: "http://localhost/all"},
+		{ID: "done", URL: "http://localhost/done", Events: []service.WebhookEvent{service.WebhookCompleted}},
+	})
+	events := func() []string {
+		t.Helper()
+		log, err := memory.ListDeliveries(ctx, 0)
+		if err != nil {
+			t.Fatalf("ListDeliveries() error = %v", err)
+		}
+		var got []string
+		for i := len(log) - 1; i >= 0; i-- {
+			got = append(got, log[i].SubscriptionID+":"+string(log[i].Event))
+		}
+		return got
+	}
+
+	entry, err := service.SaveEntry(ctx, store, core.Entry{ID: "1", Title: "Deploy", StartedAtTimestamp: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	entry.Title = "Deploy v2"
+	if entry, err = service.SaveEntry(ctx, store, entry); err != nil {
+		t.Fatalf("SaveEntry(update) error = %v", err)
+	}
+	// Writing the entry back unchanged, as sync can, sends nothing
+	if err := store.SaveEntry(ctx, entry); err != nil {
+		t.Fatalf("SaveEntry(unchanged) error = %v", err)
+	}
+	entry.EndedAtTimestamp = time.Now()
+	entry.Version = 0 // The unchanged write moved the version on
+	if _, err = service.SaveEntry(ctx, store, entry); err != nil {
+		t.Fatalf("SaveEntry(finish) error = %v", err)
+	}
+	if err := store.DeleteEntries(ctx, []string{"1", "missing"}); err != nil {
+		t.Fatalf("DeleteEntries() error = %v", err)
+	}
+
+	assertEqualStrings(t, events(), []string{
+		"all:created",
+		"all:updated",
+		"all:updated", "all:completed", "done:completed",
+		"all:deleted",
+	})
+
+	// A failed write sends nothing
+	stale := entry
+	stale.ID, stale.Version = "2", 3
+	if err := store.SaveEntry(ctx, stale); err == nil {
+		t.Fatalf("SaveEntry(stale) error = nil; want conflict")
+	}
+	if got := events(); len(got) != 6 {
+		t.Errorf("deliveries after failed write = %v; want none added", got)
+	}
+}
+
+// failingQueue is a delivery queue that can't be written to
+type failingQueue struct {
+	*service.MemoryStore
+}
+
+func (q failingQueue) EnqueueDeliveries(ctx context.Context, deliveries []service.Delivery) error {
+	return errors.New("database is locked")
+}
+
+func TestWebhookStore_EnqueueFails(t *testing.T) {
+	ctx := context.Background()
+	memory := service.NewMemoryStore()
+	store := service.NewWebhookStore(memory, failingQueue{memory}, []service.Subscription{{ID: "all", URL: "http://localhost"}})
+
+	// The queue isn't in the entry's transaction, so the write stands and the failure is only logged
+	if err := store.SaveEntry(ctx, core.Entry{ID: "1", Title: "Deploy"}); err != nil {
+		t.Errorf("SaveEntry() error = %v; want nil", err)
+	}
+	entries, _ := memory.GetAll(ctx)
+	if _, ok := entries["1"]; !ok {
+		t.Errorf("entry 1 wasn't saved")
+	}
+
+	if err := store.DeleteEntryVersion(ctx, "1", 0); err != nil {
+		t.Errorf("DeleteEntryVersion() error = %v; want nil", err)
+	}
+	if err := store.WriteEntries(ctx, []core.Entry{{ID: "2", Title: "Review"}}, []string{"missing"}); err != nil {
+		t.Errorf("WriteEntries() error = %v; want nil", err)
+	}
+	if log, _ := memory.ListDeliveries(ctx, 0); len(log) != 0 {
+		t.Errorf("delivery log = %+v; want nothing queued", log)
+	}
+}
+
+func TestWebhookStore_Outbox(t *testing.T) {
+	ctx := context.Background()
+	memory := service.NewMemoryStore()
+	store := service.NewWebhookStore(memory, memory, []service.Subscription{{ID: "all", URL: "http://localhost"}})
+
+	// A batch goes through WriteEntries with its deliveries, so they're queued with the write
+	if err := store.WriteEntries(ctx, []core.Entry{{ID: "1", Title: "Deploy"}, {ID: "2", Title: "Review"}}, nil); err != nil {
+		t.Fatalf("WriteEntries() error = %v", err)
+	}
+	if log, _ := memory.ListDeliveries(ctx, 0); len(log) != 2 {
+		t.Errorf("delivery log = %+v; want 2 deliveries", log)
+	}
+
+	// A failed write queues nothing
+	if err := store.WriteEntries(ctx, []core.Entry{{ID: "3", Title: "Ship"}, {ID: "1", Title: "Deploy v2", Version: 7}}, nil); err == nil {
+		t.Fatalf("WriteEntries(stale) error = nil; want conflict")
+	}
+	if log, _ := memory.ListDeliveries(ctx, 0); len(log) != 2 {
+		t.Errorf("delivery log after failed write = %+v; want none added", log)
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	ctx := context.Background()
+	secret := "s3cret"
+
+	var mu sync.Mutex
+	var received []*http.Request
+	failures := 1
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Zenzen-Signature"), service.SignWebhook(secret, body); got != want {
+			t.Errorf("X-Zenzen-Signature = %q; want %q", got, want)
+		}
+		var payload service.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.Entry.ID != "1" || string(payload.Event) != r.Header.Get("X-Zenzen-Event") {
+			t.Errorf("payload = %s, %v; want entry 1's %s event", body, err, r.Header.Get("X-Zenzen-Event"))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	subscriptions := []service.Subscription{{ID: "chat", URL: receiver.URL, Secret: secret, Events: []service.WebhookEvent{service.WebhookCreated}}}
+	memory := service.NewMemoryStore()
+	store := service.NewWebhookStore(memory, memory, subscriptions)
+	dispatcher := service.NewWebhookDispatcher(memory, subscriptions, service.WebhookOptions{BaseDelay: time.Millisecond, MaxAttempts: 3})
+
+	if err := store.SaveEntry(ctx, core.Entry{ID: "1", Title: "Deploy"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+
+	// The first attempt fails and is retried after the backoff
+	if n, err := dispatcher.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("DeliverDue() = %d, %v; want 1 attempt", n, err)
+	}
+	log, _ := memory.ListDeliveries(ctx, 0)
+	if log[0].Status != service.DeliveryPending || log[0].Attempts != 1 || log[0].ResponseStatus != http.StatusServiceUnavailable || log[0].LastError == "" {
+		t.Fatalf("delivery after failure = %+v; want pending with the 503 recorded", log[0])
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if n, err := dispatcher.DeliverDue(ctx); err != nil || n != 1 {
+		t.Fatalf("DeliverDue(retry) = %d, %v; want 1 attempt", n, err)
+	}
+	log, _ = memory.ListDeliveries(ctx, 0)
+	if log[0].Status != service.DeliveryDelivered || log[0].Attempts != 2 || log[0].LastError != "" || log[0].DeliveredAt.IsZero() {
+		t.Errorf("delivery after retry = %+v; want delivered on the second attempt", log[0])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Header.Get("X-Zenzen-Delivery") != log[0].ID || received[1].Header.Get("X-Zenzen-Attempt") != "2" {
+		t.Errorf("receiver got %d requests; want both attempts of delivery %s", len(received), log[0].ID)
+	}
+}
+
+func TestWebhookDispatcher_GivesUp(t *testing.T) {
+	ctx := context.Background()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	subscriptions := []service.Subscription{{ID: "tracker", URL: receiver.URL}}
+	memory := service.NewMemoryStore()
+	store := service.NewWebhookStore(memory, memory, subscriptions)
+	dispatcher := service.NewWebhookDispatcher(memory, subscriptions, service.WebhookOptions{BaseDelay: time.Millisecond, MaxAttempts: 2})
+
+	if err := store.DeleteEntry(ctx, "missing"); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+	if err := store.SaveEntry(ctx, core.Entry{ID: "1", Title: "Deploy"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	for range 2 {
+		if _, err := dispatcher.DeliverDue(ctx); err != nil {
+			t.Fatalf("DeliverDue() error = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n, err := dispatcher.DeliverDue(ctx); err != nil || n != 0 {
+		t.Errorf("DeliverDue() after giving up = %d, %v; want nothing due", n, err)
+	}
+	log, _ := memory.ListDeliveries(ctx, 0)
+	if len(log) != 1 || log[0].Status != service.DeliveryFailed || log[0].Attempts != 2 || log[0].ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("delivery log = %+v; want one failed delivery after 2 attempts", log)
+	}
+}
+
+func TestWebhookOptions_RetryDelay(t *testing.T) {
+	opts := service.WebhookOptions{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := opts.RetryDelay(attempts); got != want {
+			t.Errorf("RetryDelay(%d) = %v; want %v", attempts, got, want)
+		}
+	}
+}
+
+func assertEqualStrings(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrWorkspacesUnsupported is returned by stores that don't keep workspaces
+	ErrWorkspacesUnsupported = errors.New("store does not support workspaces")
+
+	// ErrWorkspaceNotFound is returned for workspaces that don't exist, and to anyone who
+	// isn't a member, so non-members can't tell which workspaces exist
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+
+	// ErrForbidden is returned when a member's role doesn't allow what they asked for
+	ErrForbidden = errors.New("workspace role does not allow this")
+
+	// ErrInvalidWorkspace is returned for blank workspace names and unknown roles
+	ErrInvalidWorkspace = errors.New("invalid workspace")
+
+	// ErrLastAdmin is returned when a change would leave a workspace without an admin
+	ErrLastAdmin = errors.New("workspace must keep at least one admin")
+)
+
+// workspaceOwnerPrefix marks the owners workspaces store their entries under, keeping them
+// apart from users' own entries
+const workspaceOwnerPrefix = "workspace/"
+
+// Role is what a member may do in a workspace. Each role can do everything the ones
+// before it can: viewers read, editors also write entries and tags, and admins also
+// manage membership.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// roles orders the roles from least to most allowed
+var roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(roles, role) {
+		return "", fmt.Errorf("%w: role %q must be viewer, editor or admin", ErrInvalidWorkspace, s)
+	}
+	return role, nil
+}
+
+// Allows reports whether r may do what need may
+func (r Role) Allows(need Role) bool {
+	have := slices.Index(roles, r)
+	return have >= 0 && have >= slices.Index(roles, need)
+}
+
+// Workspace is a set of entries shared by its members
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+}
+
+// Member is someone with a role in a workspace
+type Member struct {
+	PrincipalID string // See Principal.ID
+	Role        Role
+	AddedAt     time.Time
+}
+
+// Membership is a workspace and the role a principal has in it
+type Membership struct {
+	Workspace Workspace
+	Role      Role
+}
+
+// WorkspaceStore is implemented by stores that keep workspaces and their members.
+// A workspace's entries and tags are ordinary entries and tags, stored under
+// WorkspaceOwner(id). WorkspaceStore doesn't check roles; the functions in this
+// file do, so call those rather than the store.
+type WorkspaceStore interface {
+	CreateWorkspace(ctx context.Context, workspace Workspace, admin Member) error
+	ListWorkspaces(ctx context.Context, principal string) ([]Membership, error)
+	ListMembers(ctx context.Context, workspaceID string) ([]Member, error)
+	SaveMember(ctx context.Context, workspaceID string, member Member) error
+	DeleteMember(ctx context.Context, workspaceID, principal string) error
+}
+
+// Workspaces returns store's WorkspaceStore, or ErrWorkspacesUnsupported if it has none
+func Workspaces(store Store) (WorkspaceStore, error) {
+	workspaces, ok := store.(WorkspaceStore)
+	if !ok {
+		return nil, ErrWorkspacesUnsupported
+	}
+	return workspaces, nil
+}
+
+type workspaceKey struct{}
+
+// workspaceAccess records the workspace a context was opened for, and the role it was opened with
+type workspaceAccess struct {
+	id   string
+	role Role
+}
+
+// WorkspaceOwner returns the owner a workspace's entries and tags are stored under
+func WorkspaceOwner(id string) string {
+	return workspaceOwnerPrefix + id
+}
+
+// actor returns who ctx acts for: its principal, or else its owner
+func actor(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.ID
+	}
+	return OwnerFromContext(ctx)
+}
+
+// OpenWorkspace checks that the principal in ctx has at least the role need in a workspace,
+// and returns a context scoped to the workspace's entries. Store calls made with it read and
+// write the workspace's entries; Authorize and the service functions that write check them
+// against the role the workspace was opened with.
+func OpenWorkspace(ctx context.Context, store WorkspaceStore, workspaceID string, need Role) (context.Context, Role, error) {
+	who := actor(ctx)
+	role, err := memberRole(ctx, store, workspaceID, who)
+	if err != nil {
+		return nil, "", err
+	}
+	if !role.Allows(need) {
+		return nil, "", fmt.Errorf("%w: %s needs %s, not %s", ErrForbidden, workspaceID, need, role)
+	}
+
+	// Keep acting for the same principal once the owner is the workspace
+	if _, ok := PrincipalFromContext(ctx); !ok {
+		ctx = context.WithValue(ctx, principalKey{}, Principal{ID: who})
+	}
+	ctx = context.WithValue(ctx, workspaceKey{}, workspaceAccess{id: workspaceID, role: role})
+	return WithOwner(ctx, WorkspaceOwner(workspaceID)), role, nil
+}
+
+// WorkspaceFromContext returns the workspace ctx was opened for with OpenWorkspace, and the
+// principal's role in it
+func WorkspaceFromContext(ctx context.Context) (string, Role, bool) {
+	access, ok := ctx.Value(workspaceKey{}).(workspaceAccess)
+	return access.id, access.role, ok
+}
+
+// Authorize returns ErrForbidden if ctx was opened for a workspace with a role below need.
+// A principal's own entries are theirs to change, so outside a workspace it always succeeds.
+func Authorize(ctx context.Context, need Role) error {
+	id, role, ok := WorkspaceFromContext(ctx)
+	if ok && !role.Allows(need) {
+		return fmt.Errorf("%w: %s needs %s, not %s", ErrForbidden, id, need, role)
+	}
+	return nil
+}
+
+// CreateWorkspace creates a workspace with the principal in ctx as its only admin
+func CreateWorkspace(ctx context.Context, store WorkspaceStore, name string) (Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Workspace{}, fmt.Errorf("%w: blank name", ErrInvalidWorkspace)
+	}
+
+	id, err := newWorkspaceID()
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	now := time.Now().UTC()
+	workspace := Workspace{ID: id, Name: name, CreatedAt: now}
+	admin := Member{PrincipalID: actor(ctx), Role: RoleAdmin, AddedAt: now}
+	if err := store.CreateWorkspace(ctx, workspace, admin); err != nil {
+		return Workspace{}, err
+	}
+	return workspace, nil
+}
+
+// ListWorkspaces returns the workspaces the principal in ctx belongs to, by name
+func ListWorkspaces(ctx context.Context, store WorkspaceStore) ([]Membership, error) {
+	memberships, err := store.ListWorkspaces(ctx, actor(ctx))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(memberships, func(a, b Membership) int {
+		if c := strings.Compare(strings.ToLower(a.Workspace.Name), strings.ToLower(b.Workspace.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Workspace.ID, b.Workspace.ID)
+	})
+	return memberships, nil
+}
+
+// ListMembers returns a workspace's members by principal ID; any member may list them
+func ListMembers(ctx context.Context, store WorkspaceStore, workspaceID string) ([]Member, error) {
+	members, err := store.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findMember(members, actor(ctx)); !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+	slices.SortFunc(members, func(a, b Member) int {
+		return strings.Compare(a.PrincipalID, b.PrincipalID)
+	})
+	return members, nil
+}
+
+// SetMember adds principal to a workspace, or changes their role. Only admins may, and the
+// last admin can't be demoted.
+func SetMember(ctx context.Context, store WorkspaceStore, workspaceID, principal string, role Role) (Member, error) {
+	if _, err := ParseRole(string(role)); err != nil {
+		return Member{}, err
+	}
+
+	members, err := adminMembers(ctx, store, workspaceID)
+	if err != nil {
+		return Member{}, err
+	}
+
+	member, exists := findMember(members, principal)
+	if exists && member.Role == RoleAdmin && role != RoleAdmin && countAdmins(members) == 1 {
+		return Member{}, ErrLastAdmin
+	}
+	if !exists {
+		member = Member{PrincipalID: principal, AddedAt: time.Now().UTC()}
+	}
+	member.Role = role
+
+	if err := store.SaveMember(ctx, workspaceID, member); err != nil {
+		return Member{}, err
+	}
+	return member, nil
+}
+
+// RemoveMember takes principal out of a workspace. Admins may remove anyone and members may
+// remove themselves, but the last admin can't leave.
+func RemoveMember(ctx context.Context, store WorkspaceStore, workspaceID, principal string) error {
+	members, err := store.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	self, ok := findMember(members, actor(ctx))
+	if !ok {
+		return ErrWorkspaceNotFound
+	}
+	if principal != self.PrincipalID && !self.Role.Allows(RoleAdmin) {
+		return fmt.Errorf("%w: %s needs admin, not %s", ErrForbidden, workspaceID, self.Role)
+	}
+
+	member, ok := findMember(members, principal)
+	if !ok {
+		return nil
+	}
+	if member.Role == RoleAdmin && countAdmins(members) == 1 {
+		return ErrLastAdmin
+	}
+
+	return store.DeleteMember(ctx, workspaceID, principal)
+}
+
+// memberRole returns principal's role in a workspace, or ErrWorkspaceNotFound if they have none
+func memberRole(ctx context.Context, store WorkspaceStore, workspaceID, principal string) (Role, error) {
+	members, err := store.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	member, ok := findMember(members, principal)
+	if !ok {
+		return "", ErrWorkspaceNotFound
+	}
+	return member.Role, nil
+}
+
+// adminMembers returns a workspace's members if the principal in ctx is one of its admins
+func adminMembers(ctx context.Context, store WorkspaceStore, workspaceID string) ([]Member, error) {
+	members, err := store.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	self, ok := findMember(members, actor(ctx))
+	if !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+	if !self.Role.Allows(RoleAdmin) {
+		return nil, fmt.Errorf("%w: %s needs admin, not %s", ErrForbidden, workspaceID, self.Role)
+	}
+	return members, nil
+}
+
+func findMember(members []Member, principal string) (Member, bool) {
+	for _, member := range members {
+		if member.PrincipalID == principal {
+			return member, true
+		}
+	}
+	return Member{}, false
+}
+
+func countAdmins(members []Member) int {
+	admins := 0
+	for _, member := range members {
+		if member.Role == RoleAdmin {
+			admins++
+		}
+	}
+	return admins
+}
+
+// newWorkspaceID returns a random 16-character hex ID
+func newWorkspaceID() (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate workspace ID: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
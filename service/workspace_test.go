@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		have, need Role
+		want       bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleEditor, false},
+		{RoleEditor, RoleViewer, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleAdmin, RoleEditor, true},
+		{Role("owner"), RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := tt.have.Allows(tt.need); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v; want %v", tt.have, tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if role, err := ParseRole(" Editor "); err != nil || role != RoleEditor {
+		t.Errorf("ParseRole(Editor) = %q, %v; want editor", role, err)
+	}
+	if _, err := ParseRole("owner"); !errors.Is(err, ErrInvalidWorkspace) {
+		t.Errorf("ParseRole(owner) error = %v; want ErrInvalidWorkspace", err)
+	}
+}
+
+func TestNotes_ViewerCannotWrite(t *testing.T) {
+	store := NewMemoryStore()
+	alice := WithPrincipal(context.Background(), Principal{ID: "alice"})
+	bob := WithPrincipal(context.Background(), Principal{ID: "bob"})
+
+	team, err := CreateWorkspace(alice, store, "Team")
+	if err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+	if _, err := SetMember(alice, store, team.ID, "bob", RoleViewer); err != nil {
+		t.Fatalf("SetMember() error = %v", err)
+	}
+	ctx, _, err := OpenWorkspace(bob, store, team.ID, RoleViewer)
+	if err != nil {
+		t.Fatalf("OpenWorkspace() error = %v", err)
+	}
+
+	notes := NewNotes(store)
+	if err := notes.LoadAll(ctx); err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+	if err := notes.SaveEntry(ctx, core.Entry{ID: "1", Title: "Sneaky"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("SaveEntry() error = %v; want ErrForbidden", err)
+	}
+	if err := notes.Delete(ctx, "1"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Delete() error = %v; want ErrForbidden", err)
+	}
+	if entries, _ := store.GetAll(ctx); len(entries) != 0 {
+		t.Errorf("viewer's save reached the store: %+v", entries)
+	}
+
+	// Outside a workspace their own entries are theirs to change
+	if err := Authorize(bob, RoleAdmin); err != nil {
+		t.Errorf("Authorize(personal) error = %v; want nil", err)
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	// ENTRY_CHANGES_CHANNEL is the channel the entries trigger notifies on
+	ENTRY_CHANGES_CHANNEL = "zenzen_entries"
+
+	// changeBufferSize lets a burst of changes queue while the subscriber is busy
+	changeBufferSize = 64
+
+	// Reconnect delays after the listening connection drops
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+// changePayload is the JSON the entries trigger sends with pg_notify
+type changePayload struct {
+	Op      string `json:"op"`
+	Owner   string `json:"owner"` // Absent before migration 0008, when every entry was DefaultOwner's
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+// Subscribe reports the context owner's entry changes committed by any connection to the
+// database, including other zenzen processes, using LISTEN on a connection of its own.
+// If that connection drops it reconnects with backoff and sends a ChangeResync, since
+// changes may have been missed.
+func (s *SQLStorage) Subscribe(ctx context.Context) (<-chan service.ChangeEvent, error) {
+	if s.connString == "" {
+		return nil, service.ErrChangeFeedUnsupported
+	}
+
+	conn, err := s.listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan service.ChangeEvent, changeBufferSize)
+	go s.forwardChanges(ctx, conn, changes, changeFilter(ctx))
+	return changes, nil
+}
+
+// listen opens a connection listening on the entry changes channel
+func (s *SQLStorage) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, s.connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+ENTRY_CHANGES_CHANNEL); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to listen for entry changes: %w", err)
+	}
+	return conn, nil
+}
+
+// forwardChanges sends notifications that pass visible to changes until ctx is cancelled,
+// then closes it
+func (s *SQLStorage) forwardChanges(ctx context.Context, conn *pgx.Conn, changes chan<- service.ChangeEvent, visible func(service.ChangeEvent) bool) {
+	defer close(changes)
+	defer func() {
+		if conn != nil {
+			conn.Close(context.Background())
+		}
+	}()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			if conn = s.reconnect(ctx); conn == nil {
+				return
+			}
+			if !sendChange(ctx, changes, service.ChangeEvent{Op: service.ChangeResync}) {
+				return
+			}
+			continue
+		}
+
+		event := parseChangeEvent(notification.Payload)
+		if !visible(event) {
+			continue
+		}
+		if !sendChange(ctx, changes, event) {
+			return
+		}
+	}
+}
+
+// reconnect retries listen with backoff, returning nil once ctx is cancelled
+func (s *SQLStorage) reconnect(ctx context.Context) *pgx.Conn {
+	retry := listenRetryMin
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+
+		if conn, err := s.listen(ctx); err == nil {
+			return conn
+		}
+		retry = min(retry*2, listenRetryMax)
+	}
+}
+
+// changeFilter reports which events a subscription made with ctx may see: its owner's
+// changes and resyncs, or everything for a context made by service.WithAllOwners
+func changeFilter(ctx context.Context) func(service.ChangeEvent) bool {
+	if service.SeesAllOwners(ctx) {
+		return func(service.ChangeEvent) bool { return true }
+	}
+	owner := service.OwnerFromContext(ctx)
+	return func(event service.ChangeEvent) bool {
+		return event.Op == service.ChangeResync || event.Owner == owner
+	}
+}
+
+// sendChange delivers an event, returning false if ctx was cancelled first
+func sendChange(ctx context.Context, changes chan<- service.ChangeEvent, event service.ChangeEvent) bool {
+	select {
+	case changes <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// parseChangeEvent decodes a trigger payload. One it can't read still means something
+// changed, so it becomes a ChangeResync rather than being dropped.
+func parseChangeEvent(payload string) service.ChangeEvent {
+	var change changePayload
+	if err := json.Unmarshal([]byte(payload), &change); err != nil || change.ID == "" {
+		return service.ChangeEvent{Op: service.ChangeResync}
+	}
+
+	switch op := service.ChangeOp(change.Op); op {
+	case service.ChangeInsert, service.ChangeUpdate, service.ChangeDelete:
+		return service.ChangeEvent{Op: op, Owner: change.Owner, ID: change.ID, Version: change.Version}
+	}
+	return service.ChangeEvent{Op: service.ChangeResync}
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+func TestParseChangeEvent(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    service.ChangeEvent
+	}{
+		{`{"op":"insert","id":"1","version":1}`, service.ChangeEvent{Op: service.ChangeInsert, ID: "1", Version: 1}},
+		{`{"op":"insert","owner":"alice","id":"1","version":1}`, service.ChangeEvent{Op: service.ChangeInsert, Owner: "alice", ID: "1", Version: 1}},
+		{`{"op":"update","id":"1","version":2}`, service.ChangeEvent{Op: service.ChangeUpdate, ID: "1", Version: 2}},
+		{`{"op":"delete","id":"1","version":2}`, service.ChangeEvent{Op: service.ChangeDelete, ID: "1", Version: 2}},
+		{`{"op":"truncate"}`, service.ChangeEvent{Op: service.ChangeResync}},
+		{`not json`, service.ChangeEvent{Op: service.ChangeResync}},
+	}
+
+	for _, tt := range tests {
+		if got := parseChangeEvent(tt.payload); got != tt.want {
+			t.Errorf("parseChangeEvent(%s) = %+v; want %+v", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestChangeFilter(t *testing.T) {
+	ctx := context.Background()
+	alice := service.ChangeEvent{Op: service.ChangeUpdate, Owner: "alice", ID: "1", Version: 2}
+	unowned := service.ChangeEvent{Op: service.ChangeUpdate, ID: "1", Version: 2}
+	resync := service.ChangeEvent{Op: service.ChangeResync}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []bool // alice, unowned, resync
+	}{
+		{"default owner", ctx, []bool{false, true, true}},
+		{"alice", service.WithOwner(ctx, "alice"), []bool{true, false, true}},
+		{"bob", service.WithOwner(ctx, "bob"), []bool{false, false, true}},
+		{"all owners", service.WithAllOwners(ctx), []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		visible := changeFilter(tt.ctx)
+		for i, event := range []service.ChangeEvent{alice, unowned, resync} {
+			if got := visible(event); got != tt.want[i] {
+				t.Errorf("%s: visible(%+v) = %v; want %v", tt.name, event, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSubscribeWithoutConnectionString(t *testing.T) {
+	storage := &SQLStorage{}
+	if _, err := storage.Subscribe(context.Background()); !errors.Is(err, service.ErrChangeFeedUnsupported) {
+		t.Errorf("Subscribe() error = %v; want ErrChangeFeedUnsupported", err)
+	}
+}
+
+// TestSQLStorage_Subscribe runs against a real database when ZENZEN_TEST_DATABASE_URL is set
+func TestSQLStorage_Subscribe(t *testing.T) {
+	connString := os.Getenv("ZENZEN_TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("ZENZEN_TEST_DATABASE_URL not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage, err := NewSQLStorage(ctx, connString)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer storage.Close(context.Background())
+
+	changes, err := storage.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	// Written by another connection, as the API server would
+	writer, err := NewSQLStorage(ctx, connString)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer writer.Close(context.Background())
+
+	id := "subscribe-" + time.Now().Format("150405.000000")
+	if err := writer.SaveEntry(ctx, core.Entry{ID: id, Title: "Notified"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	if err := writer.DeleteEntry(ctx, id); err != nil {
+		t.Fatalf("DeleteEntry() error = %v", err)
+	}
+
+	for _, want := range []service.ChangeOp{service.ChangeInsert, service.ChangeDelete} {
+		select {
+		case event := <-changes:
+			if event.Op != want || event.ID != id {
+				t.Errorf("event = %+v; want %s of %s", event, want, id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+
+	cancel()
+	for range changes {
+	}
+}
@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	// encryptedPrefix marks an encrypted field: enc1:<key id>:<base64 nonce and ciphertext>
+	encryptedPrefix = "enc1:"
+
+	encryptionKeySize = 32 // AES-256
+
+	// Passphrases are stretched with PBKDF2-SHA256. The salt is fixed so every device derives
+	// the same key from the same passphrase; key files avoid that trade-off.
+	passphraseSalt       = "zenzen-cloud-encryption-v1"
+	passphraseIterations = 600_000
+)
+
+// ErrUnknownKey is returned when a field was encrypted with a key that isn't configured
+var ErrUnknownKey = errors.New("entry encrypted with an unknown key")
+
+// EncryptionKey is a 256-bit AES-GCM key
+type EncryptionKey struct {
+	id   string // Identifies the key in ciphertexts without revealing it
+	aead cipher.AEAD
+}
+
+// NewEncryptionKey wraps 32 bytes of key material
+func NewEncryptionKey(raw []byte) (EncryptionKey, error) {
+	if len(raw) != encryptionKeySize {
+		return EncryptionKey{}, fmt.Errorf("encryption key must be %d bytes, got %d", encryptionKeySize, len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	sum := sha256.Sum256(append([]byte("zenzen-key-id:"), raw...))
+	return EncryptionKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// KeyFromPassphrase derives a key from a passphrase
+func KeyFromPassphrase(passphrase string) (EncryptionKey, error) {
+	if passphrase == "" {
+		return EncryptionKey{}, fmt.Errorf("empty encryption passphrase")
+	}
+	raw, err := pbkdf2.Key(sha256.New, passphrase, []byte(passphraseSalt), passphraseIterations, encryptionKeySize)
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return NewEncryptionKey(raw)
+}
+
+// ReadKeyFile loads a base64-encoded key, e.g. one made with `openssl rand -base64 32`
+func ReadKeyFile(path string) (EncryptionKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to decode key file %s: %w", path, err)
+	}
+	return NewEncryptionKey(raw)
+}
+
+// EncryptionOptions configures an EncryptedStore
+type EncryptionOptions struct {
+	Key         EncryptionKey   // Encrypts every write
+	OldKeys     []EncryptionKey // Retired keys, still used to decrypt until Rekey has run
+	EncryptTags bool            // Also encrypt tags; tag filters then run in memory
+}
+
+// EncryptedStore encrypts entry titles, bodies and optionally tags before they reach the
+// wrapped store, and decrypts them on the way back. IDs, timestamps, estimates and versions
+// stay in the clear so the wrapped store can still filter, sort and page on them.
+// Fields stored before encryption was enabled are read as plaintext until Rekey runs.
+type EncryptedStore struct {
+	store       service.Store
+	key         EncryptionKey
+	keys        map[string]EncryptionKey // By ID, including the current key
+	encryptTags bool
+}
+
+// NewEncryptedStore wraps store so entries are encrypted at rest
+func NewEncryptedStore(store service.Store, opts EncryptionOptions) *EncryptedStore {
+	keys := map[string]EncryptionKey{opts.Key.id: opts.Key}
+	for _, key := range opts.OldKeys {
+		if _, ok := keys[key.id]; !ok {
+			keys[key.id] = key
+		}
+	}
+	return &EncryptedStore{
+		store:       store,
+		key:         opts.Key,
+		keys:        keys,
+		encryptTags: opts.EncryptTags,
+	}
+}
+
+// GetAll returns every entry, decrypted
+func (e *EncryptedStore) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	stored, err := e.store.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]core.Entry, len(stored))
+	for id, entry := range stored {
+		if entries[id], err = e.decryptEntry(entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Query pushes filters on clear fields down to the wrapped store. Text filters, and tag
+// filters when tags are encrypted, can't match ciphertext, so those queries run in memory.
+func (e *EncryptedStore) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	if q.Text != "" || (e.encryptTags && len(q.Tags) > 0) {
+		entries, err := e.GetAll(ctx)
+		if err != nil {
+			return service.QueryResult{}, err
+		}
+		return service.QueryEntries(entries, q)
+	}
+
+	result, err := e.store.Query(ctx, q)
+	if err != nil {
+		return service.QueryResult{}, err
+	}
+	for i, entry := range result.Entries {
+		if result.Entries[i], err = e.decryptEntry(entry); err != nil {
+			return service.QueryResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// SaveEntry encrypts and saves a single entry
+func (e *EncryptedStore) SaveEntry(ctx context.Context, entry core.Entry) error {
+	encrypted, err := e.encryptEntry(entry)
+	if err != nil {
+		return err
+	}
+	return e.store.SaveEntry(ctx, encrypted)
+}
+
+// SaveEntryVersion encrypts and saves an entry, returning its new version
+func (e *EncryptedStore) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	encrypted, err := e.encryptEntry(entry)
+	if err != nil {
+		return 0, err
+	}
+	return e.store.SaveEntryVersion(ctx, encrypted)
+}
+
+// SaveEntries encrypts and saves several entries, all or nothing
+func (e *EncryptedStore) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	encrypted, err := e.encryptEntries(entries)
+	if err != nil {
+		return err
+	}
+	return e.store.SaveEntries(ctx, encrypted)
+}
+
+// WriteEntries encrypts saves and writes them with deletes, all or nothing
+func (e *EncryptedStore) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	encrypted, err := e.encryptEntries(saves)
+	if err != nil {
+		return err
+	}
+	return e.store.WriteEntries(ctx, encrypted, deletes)
+}
+
+// encryptEntries encrypts several entries
+func (e *EncryptedStore) encryptEntries(entries []core.Entry) ([]core.Entry, error) {
+	encrypted := make([]core.Entry, 0, len(entries))
+	for _, entry := range entries {
+		enc, err := e.encryptEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		encrypted = append(encrypted, enc)
+	}
+	return encrypted, nil
+}
+
+// DeleteEntry deletes an entry; IDs are never encrypted
+func (e *EncryptedStore) DeleteEntry(ctx context.Context, id string) error {
+	return e.store.DeleteEntry(ctx, id)
+}
+
+// DeleteEntryVersion deletes an entry if it is at version
+func (e *EncryptedStore) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	return e.store.DeleteEntryVersion(ctx, id, version)
+}
+
+// DeleteEntries deletes several entries
+func (e *EncryptedStore) DeleteEntries(ctx context.Context, ids []string) error {
+	return e.store.DeleteEntries(ctx, ids)
+}
+
+// Outbox returns the queue the wrapped store writes deliveries to along with entries, if any.
+// Delivery payloads are stored in the clear.
+func (e *EncryptedStore) Outbox() service.DeliveryQueue {
+	outbox, ok := e.store.(service.DeliveryOutbox)
+	if !ok {
+		return nil
+	}
+	return outbox.Outbox()
+}
+
+// Subscribe passes on the wrapped store's change feed; events carry only IDs and versions
+func (e *EncryptedStore) Subscribe(ctx context.Context) (<-chan service.ChangeEvent, error) {
+	feed, ok := e.store.(service.ChangeFeed)
+	if !ok {
+		return nil, service.ErrChangeFeedUnsupported
+	}
+	return feed.Subscribe(ctx)
+}
+
+// CreateWorkspace creates a workspace in the wrapped store. Workspace names and
+// membership are stored in the clear.
+func (e *EncryptedStore) CreateWorkspace(ctx context.Context, workspace service.Workspace, admin service.Member) error {
+	workspaces, err := service.Workspaces(e.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.CreateWorkspace(ctx, workspace, admin)
+}
+
+// ListWorkspaces lists principal's workspaces from the wrapped store
+func (e *EncryptedStore) ListWorkspaces(ctx context.Context, principal string) ([]service.Membership, error) {
+	workspaces, err := service.Workspaces(e.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListWorkspaces(ctx, principal)
+}
+
+// ListMembers lists a workspace's members from the wrapped store
+func (e *EncryptedStore) ListMembers(ctx context.Context, workspaceID string) ([]service.Member, error) {
+	workspaces, err := service.Workspaces(e.store)
+	if err != nil {
+		return nil, err
+	}
+	return workspaces.ListMembers(ctx, workspaceID)
+}
+
+// SaveMember saves a workspace member to the wrapped store
+func (e *EncryptedStore) SaveMember(ctx context.Context, workspaceID string, member service.Member) error {
+	workspaces, err := service.Workspaces(e.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.SaveMember(ctx, workspaceID, member)
+}
+
+// DeleteMember removes a workspace member from the wrapped store
+func (e *EncryptedStore) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	workspaces, err := service.Workspaces(e.store)
+	if err != nil {
+		return err
+	}
+	return workspaces.DeleteMember(ctx, workspaceID, principal)
+}
+
+// GetTimer reads the owner's timer from the wrapped store. Timers hold only entry IDs and
+// times, so they are stored in the clear.
+func (e *EncryptedStore) GetTimer(ctx context.Context) (service.Timer, bool, error) {
+	timers, err := service.Timers(e.store)
+	if err != nil {
+		return service.Timer{}, false, err
+	}
+	return timers.GetTimer(ctx)
+}
+
+// SaveTimer saves the owner's timer to the wrapped store
+func (e *EncryptedStore) SaveTimer(ctx context.Context, timer service.Timer) error {
+	timers, err := service.Timers(e.store)
+	if err != nil {
+		return err
+	}
+	return timers.SaveTimer(ctx, timer)
+}
+
+// ClaimTimer claims the owner's timer in the wrapped store
+func (e *EncryptedStore) ClaimTimer(ctx context.Context, timer service.Timer, replacing string) error {
+	timers, err := service.Timers(e.store)
+	if err != nil {
+		return err
+	}
+	return timers.ClaimTimer(ctx, timer, replacing)
+}
+
+// DeleteTimer removes the owner's timer from the wrapped store
+func (e *EncryptedStore) DeleteTimer(ctx context.Context) error {
+	timers, err := service.Timers(e.store)
+	if err != nil {
+		return err
+	}
+	return timers.DeleteTimer(ctx)
+}
+
+// Rekey re-encrypts every entry that isn't stored exactly as the current key and options would
+// store it: entries under a retired key, plaintext entries from before encryption was enabled,
+// and tags after EncryptTags changes. Saves are one conditional batch, so an entry edited
+// meanwhile fails the whole run with ErrConflict and it can simply be retried.
+// It returns the number of entries rewritten.
+func (e *EncryptedStore) Rekey(ctx context.Context) (int, error) {
+	stored, err := e.store.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var stale []core.Entry
+	for _, entry := range stored {
+		if e.isCurrent(entry) {
+			continue
+		}
+		plain, err := e.decryptEntry(entry)
+		if err != nil {
+			return 0, err
+		}
+		stale = append(stale, plain)
+	}
+
+	if err := e.SaveEntries(ctx, stale); err != nil {
+		return 0, fmt.Errorf("failed to rekey entries: %w", err)
+	}
+	return len(stale), nil
+}
+
+// isCurrent reports whether an entry as stored needs no re-encryption
+func (e *EncryptedStore) isCurrent(entry core.Entry) bool {
+	currentPrefix := encryptedPrefix + e.key.id + ":"
+	if !strings.HasPrefix(entry.Title, currentPrefix) || !strings.HasPrefix(entry.Body, currentPrefix) {
+		return false
+	}
+	for _, tag := range entry.Tags {
+		if e.encryptTags && !strings.HasPrefix(tag, currentPrefix) {
+			return false
+		}
+		if !e.encryptTags && strings.HasPrefix(tag, encryptedPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// encryptEntry returns a copy of entry with its private fields encrypted
+func (e *EncryptedStore) encryptEntry(entry core.Entry) (core.Entry, error) {
+	var err error
+	if entry.Title, err = e.encrypt(entry.ID, "title", entry.Title); err != nil {
+		return core.Entry{}, err
+	}
+	if entry.Body, err = e.encrypt(entry.ID, "body", entry.Body); err != nil {
+		return core.Entry{}, err
+	}
+	if e.encryptTags && entry.Tags != nil {
+		tags := make([]string, len(entry.Tags))
+		for i, tag := range entry.Tags {
+			if tags[i], err = e.encrypt(entry.ID, "tag", tag); err != nil {
+				return core.Entry{}, err
+			}
+		}
+		entry.Tags = tags
+	}
+	return entry, nil
+}
+
+// decryptEntry returns a copy of entry with its private fields decrypted
+func (e *EncryptedStore) decryptEntry(entry core.Entry) (core.Entry, error) {
+	var err error
+	if entry.Title, err = e.decrypt(entry.ID, "title", entry.Title); err != nil {
+		return core.Entry{}, err
+	}
+	if entry.Body, err = e.decrypt(entry.ID, "body", entry.Body); err != nil {
+		return core.Entry{}, err
+	}
+	if entry.Tags != nil {
+		tags := make([]string, len(entry.Tags))
+		for i, tag := range entry.Tags {
+			if tags[i], err = e.decrypt(entry.ID, "tag", tag); err != nil {
+				return core.Entry{}, err
+			}
+		}
+		entry.Tags = tags
+	}
+	return entry, nil
+}
+
+// encrypt seals one field. The entry ID and field name are authenticated with it, so a
+// ciphertext moved to another entry or field fails to decrypt rather than being misread.
+func (e *EncryptedStore) encrypt(id, field, plaintext string) (string, error) {
+	nonce := make([]byte, e.key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := e.key.aead.Seal(nonce, nonce, []byte(plaintext), fieldAAD(id, field))
+	return encryptedPrefix + e.key.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens one field; values without the encrypted prefix predate encryption and pass through
+func (e *EncryptedStore) decrypt(id, field, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	keyID, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted %s in entry %s", field, id)
+	}
+	key, ok := e.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s in entry %s uses key %s", ErrUnknownKey, field, id, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted %s in entry %s", field, id)
+	}
+	nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, fieldAAD(id, field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s in entry %s: %w", field, id, err)
+	}
+	return string(plaintext), nil
+}
+
+// fieldAAD binds a ciphertext to its entry and field
+func fieldAAD(id, field string) []byte {
+	return []byte(id + "\x00" + field)
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func testKey(t *testing.T, fill byte) EncryptionKey {
+	t.Helper()
+
+	key, err := NewEncryptionKey(bytes.Repeat([]byte{fill}, encryptionKeySize))
+	if err != nil {
+		t.Fatalf("NewEncryptionKey() error = %v", err)
+	}
+	return key
+}
+
+func TestEncryptedStore_Conformance(t *testing.T) {
+	for _, encryptTags := range []bool{false, true} {
+		name := "clear tags"
+		if encryptTags {
+			name = "encrypted tags"
+		}
+		t.Run(name, func(t *testing.T) {
+			storetest.Run(t, func(t *testing.T) service.Store {
+				return NewEncryptedStore(newTestSQLiteStorage(t), EncryptionOptions{Key: testKey(t, 1), EncryptTags: encryptTags})
+			})
+		})
+	}
+}
+
+func TestEncryptedStore_EncryptsAtRest(t *testing.T) {
+	ctx := context.Background()
+	inner := service.NewMemoryStore()
+	store := NewEncryptedStore(inner, EncryptionOptions{Key: testKey(t, 1), EncryptTags: true})
+
+	entry := core.Entry{ID: "1", Title: "Acme renewal", Tags: []string{"customers/acme"}, Body: "Call Acme about pricing"}
+	if err := store.SaveEntry(ctx, entry); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+
+	stored, _ := inner.GetAll(ctx)
+	for _, field := range []string{stored["1"].Title, stored["1"].Body, stored["1"].Tags[0]} {
+		if strings.Contains(field, "Acme") || strings.Contains(field, "acme") || !strings.HasPrefix(field, encryptedPrefix) {
+			t.Errorf("stored field %q is not encrypted", field)
+		}
+	}
+
+	entries, err := store.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	storetest.AssertEntryEqual(t, entries["1"], entry)
+}
+
+func TestEncryptedStore_FieldsAreBoundToTheirEntry(t *testing.T) {
+	ctx := context.Background()
+	inner := service.NewMemoryStore()
+	store := NewEncryptedStore(inner, EncryptionOptions{Key: testKey(t, 1)})
+
+	store.SaveEntry(ctx, core.Entry{ID: "1", Title: "One", Body: "Secret one"})
+	store.SaveEntry(ctx, core.Entry{ID: "2", Title: "Two", Body: "Secret two"})
+
+	// Someone with database access swaps the bodies
+	stored, _ := inner.GetAll(ctx)
+	swapped := stored["2"]
+	swapped.Body = stored["1"].Body
+	inner.SaveEntry(ctx, swapped)
+
+	if _, err := store.GetAll(ctx); err == nil {
+		t.Errorf("expected a body moved to another entry to fail to decrypt")
+	}
+}
+
+func TestEncryptedStore_ReadsPlaintextFromBeforeEncryption(t *testing.T) {
+	ctx := context.Background()
+	inner := service.NewMemoryStore(core.Entry{ID: "1", Title: "Old", Tags: []string{"work"}, Body: "Written in the clear"})
+	store := NewEncryptedStore(inner, EncryptionOptions{Key: testKey(t, 1), EncryptTags: true})
+
+	entries, err := store.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if got := entries["1"].Body; got != "Written in the clear" {
+		t.Errorf("Body = %q; want the plaintext", got)
+	}
+}
+
+func TestEncryptedStore_Rekey(t *testing.T) {
+	ctx := context.Background()
+	inner := service.NewMemoryStore(core.Entry{ID: "plain", Title: "Plain", Body: "From before encryption"})
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+
+	old := NewEncryptedStore(inner, EncryptionOptions{Key: oldKey})
+	if err := old.SaveEntry(ctx, core.Entry{ID: "old", Title: "Old", Tags: []string{"work"}, Body: "Under the old key"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+
+	// Without the old key its entries can't be read
+	if _, err := NewEncryptedStore(inner, EncryptionOptions{Key: newKey}).GetAll(ctx); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("GetAll() error = %v; want ErrUnknownKey", err)
+	}
+
+	// With it, they can, and Rekey moves everything to the new key
+	rotating := NewEncryptedStore(inner, EncryptionOptions{Key: newKey, OldKeys: []EncryptionKey{oldKey}, EncryptTags: true})
+	n, err := rotating.Rekey(ctx)
+	if err != nil {
+		t.Fatalf("Rekey() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Rekey() rewrote %d entries; want 2", n)
+	}
+	if n, _ := rotating.Rekey(ctx); n != 0 {
+		t.Errorf("second Rekey() rewrote %d entries; want 0", n)
+	}
+
+	// The old key is no longer needed
+	entries, err := NewEncryptedStore(inner, EncryptionOptions{Key: newKey}).GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() after rekey error = %v", err)
+	}
+	if entries["old"].Body != "Under the old key" || entries["plain"].Body != "From before encryption" || entries["old"].Tags[0] != "work" {
+		t.Errorf("entries after rekey = %+v", entries)
+	}
+	stored, _ := inner.GetAll(ctx)
+	if !strings.HasPrefix(stored["plain"].Body, encryptedPrefix+newKey.id+":") || !strings.HasPrefix(stored["old"].Tags[0], encryptedPrefix+newKey.id+":") {
+		t.Errorf("stored entries not under the new key: %+v", stored)
+	}
+}
+
+func TestEncryptionKeySources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cloud.key")
+	os.WriteFile(path, []byte("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n"), 0600)
+
+	fromFile, err := ReadKeyFile(path)
+	if err != nil {
+		t.Fatalf("ReadKeyFile() error = %v", err)
+	}
+	if fromFile.id != testKey(t, 1).id {
+		t.Errorf("key file decoded to a different key")
+	}
+
+	// The same passphrase gives the same key on every device
+	a, err := KeyFromPassphrase("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("KeyFromPassphrase() error = %v", err)
+	}
+	b, _ := KeyFromPassphrase("correct horse battery staple")
+	c, _ := KeyFromPassphrase("another passphrase")
+	if a.id != b.id || a.id == c.id {
+		t.Errorf("passphrase keys = %s, %s, %s; want the first two equal", a.id, b.id, c.id)
+	}
+
+	if _, err := NewEncryptionKey([]byte("too short")); err == nil {
+		t.Errorf("expected a short key to be rejected")
+	}
+}
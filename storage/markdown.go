@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	markdownExt          = ".md"
+	frontmatterDelimiter = "---"
+)
+
+// MarkdownStorage stores each entry as a Markdown file with YAML frontmatter.
+// The directory is re-read on every GetAll so edits made outside zenzen are picked up.
+type MarkdownStorage struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// frontmatter is the YAML header of an entry file
+type frontmatter struct {
+	ID           string    `yaml:"id"`
+	Title        string    `yaml:"title"`
+	Tags         []string  `yaml:"tags,omitempty,flow"`
+	StartedAt    time.Time `yaml:"started_at,omitempty"`
+	EndedAt      time.Time `yaml:"ended_at,omitempty"`
+	LastModified time.Time `yaml:"last_modified,omitempty"`
+	Estimate     string    `yaml:"estimate,omitempty"`
+	Version      int64     `yaml:"version,omitempty"`
+}
+
+// NewMarkdownStorage creates a Markdown storage rooted at dir, creating the directory if needed
+func NewMarkdownStorage(dir string) (*MarkdownStorage, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no markdown directory configured")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return &MarkdownStorage{dir: dir}, nil
+}
+
+// Close is a no-op; files are written atomically on every save
+func (s *MarkdownStorage) Close(ctx context.Context) error {
+	return nil
+}
+
+// GetAll reads every entry file in the directory
+func (s *MarkdownStorage) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkMarkdownOwner(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths, err := s.entryFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]core.Entry, len(paths))
+	for _, path := range paths {
+		entry, err := readMarkdownEntry(path)
+		if err != nil {
+			return nil, err
+		}
+		entries[entry.ID] = entry
+	}
+
+	return entries, nil
+}
+
+// Query filters, sorts and pages the entries in memory
+func (s *MarkdownStorage) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	entries, err := s.GetAll(ctx)
+	if err != nil {
+		return service.QueryResult{}, err
+	}
+	return service.QueryEntries(entries, q)
+}
+
+// SaveEntry writes a single entry to <id>.md, replacing any existing file for that ID.
+// A non-zero Version must match the file's, so edits made since it was read aren't lost.
+// Note: LastModifiedTimestamp should be set by the caller before calling this method
+func (s *MarkdownStorage) SaveEntry(ctx context.Context, entry core.Entry) error {
+	return s.SaveEntries(ctx, []core.Entry{entry})
+}
+
+// SaveEntryVersion writes an entry as SaveEntry does, returning its new version
+func (s *MarkdownStorage) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	versions, err := s.write(ctx, []core.Entry{entry}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return versions[0], nil
+}
+
+// SaveEntries writes several entries, changing nothing if any fails
+func (s *MarkdownStorage) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	return s.WriteEntries(ctx, entries, nil)
+}
+
+// DeleteEntry removes the file for an entry; deleting a missing entry is not an error
+func (s *MarkdownStorage) DeleteEntry(ctx context.Context, id string) error {
+	return s.DeleteEntries(ctx, []string{id})
+}
+
+// DeleteEntries removes the files for several entries, skipping missing ones
+func (s *MarkdownStorage) DeleteEntries(ctx context.Context, ids []string) error {
+	return s.WriteEntries(ctx, nil, ids)
+}
+
+// DeleteEntryVersion removes the file for an entry if it is at version, or at any version
+// when it is 0. The version is read and the file removed under the store's lock.
+func (s *MarkdownStorage) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkMarkdownOwner(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.findPath(id)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return service.NotDeletedError(id, version)
+	}
+	if version > 0 {
+		stored, err := readMarkdownEntry(path)
+		if err != nil {
+			return err
+		}
+		if stored.Version != version {
+			return service.NotDeletedError(id, version)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return service.NotDeletedError(id, version)
+		}
+		return fmt.Errorf("failed to delete entry: %w", err)
+	}
+	return nil
+}
+
+// WriteEntries writes saves and removes the files of deletes. Every version is checked,
+// every file written to a temp file and every deleted file located before any is renamed
+// into place or removed, so a conflict or write error changes nothing. Files can't be
+// changed together, so a rename or remove failing part way leaves the earlier ones done.
+func (s *MarkdownStorage) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	_, err := s.write(ctx, saves, deletes)
+	return err
+}
+
+// write applies WriteEntries, returning the new version of each save, in UniqueEntries order
+func (s *MarkdownStorage) write(ctx context.Context, saves []core.Entry, deletes []string) ([]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := checkMarkdownOwner(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saves = service.UniqueEntries(saves)
+	versions := make([]int64, 0, len(saves))
+	paths := make([]string, 0, len(saves))
+	tmpPaths := make([]string, 0, len(saves))
+	defer func() {
+		for _, tmpPath := range tmpPaths {
+			os.Remove(tmpPath) // No-op after a successful rename
+		}
+	}()
+
+	for _, entry := range saves {
+		if err := validateMarkdownID(entry.ID); err != nil {
+			return nil, err
+		}
+
+		// Reuse the existing file if the user renamed it
+		path, err := s.findPath(entry.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var storedVersion int64
+		if path == "" {
+			path = filepath.Join(s.dir, entry.ID+markdownExt)
+		} else {
+			stored, err := readMarkdownEntry(path)
+			if err != nil {
+				return nil, err
+			}
+			storedVersion = stored.Version
+		}
+		if entry.Version > 0 && entry.Version != storedVersion {
+			return nil, fmt.Errorf("%w: entry %s", service.ErrConflict, entry.ID)
+		}
+		entry.Version = storedVersion + 1
+		versions = append(versions, entry.Version)
+
+		data, err := encodeMarkdownEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		tmpPath, err := writeTempFile(path, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save entry: %w", err)
+		}
+		paths = append(paths, path)
+		tmpPaths = append(tmpPaths, tmpPath)
+	}
+
+	deletePaths := make([]string, 0, len(deletes))
+	for _, id := range deletes {
+		path, err := s.findPath(id)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			deletePaths = append(deletePaths, path)
+		}
+	}
+
+	for i, path := range paths {
+		if err := os.Rename(tmpPaths[i], path); err != nil {
+			return nil, fmt.Errorf("failed to save entry: %w", err)
+		}
+	}
+
+	for _, path := range deletePaths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to delete entry: %w", err)
+		}
+	}
+
+	return versions, nil
+}
+
+// entryFiles lists the Markdown files in the storage directory
+func (s *MarkdownStorage) entryFiles() ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var paths []string
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != markdownExt {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.dir, name))
+	}
+
+	return paths, nil
+}
+
+// findPath returns the file holding the entry with the given ID, or "" if there is none
+func (s *MarkdownStorage) findPath(id string) (string, error) {
+	// Fast path: files are normally named after their ID
+	path := filepath.Join(s.dir, id+markdownExt)
+	if entry, err := readMarkdownEntry(path); err == nil && entry.ID == id {
+		return path, nil
+	}
+
+	paths, err := s.entryFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		entry, err := readMarkdownEntry(path)
+		if err != nil {
+			continue
+		}
+		if entry.ID == id {
+			return path, nil
+		}
+	}
+
+	return "", nil
+}
+
+// readMarkdownEntry parses an entry file.
+// Files without frontmatter are treated as a body, with the file name as ID and title.
+func readMarkdownEntry(path string) (core.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return core.Entry{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), markdownExt)
+	header, body, hasHeader := splitFrontmatter(data)
+
+	var meta frontmatter
+	if hasHeader {
+		if err := yaml.Unmarshal(header, &meta); err != nil {
+			return core.Entry{}, fmt.Errorf("failed to parse frontmatter in %s: %w", path, err)
+		}
+	}
+
+	entry := core.Entry{
+		ID:                    meta.ID,
+		Title:                 meta.Title,
+		Tags:                  meta.Tags,
+		StartedAtTimestamp:    meta.StartedAt,
+		EndedAtTimestamp:      meta.EndedAt,
+		LastModifiedTimestamp: meta.LastModified,
+		EstimatedDuration:     parseEstimate(meta.Estimate),
+		Body:                  string(body),
+		Version:               meta.Version,
+	}
+
+	if entry.ID == "" {
+		entry.ID = name
+	}
+	if entry.Title == "" {
+		entry.Title = name
+	}
+	// Fall back to the file's mtime for hand-written files
+	if entry.LastModifiedTimestamp.IsZero() {
+		if info, err := os.Stat(path); err == nil {
+			entry.LastModifiedTimestamp = info.ModTime()
+		}
+	}
+	// Hand-written files count as saved once, like rows added before versioning
+	if entry.Version == 0 {
+		entry.Version = 1
+	}
+
+	return entry, nil
+}
+
+// encodeMarkdownEntry renders an entry as frontmatter followed by its body
+func encodeMarkdownEntry(entry core.Entry) ([]byte, error) {
+	meta := frontmatter{
+		ID:           entry.ID,
+		Title:        entry.Title,
+		Tags:         entry.Tags,
+		StartedAt:    entry.StartedAtTimestamp,
+		EndedAt:      entry.EndedAtTimestamp,
+		LastModified: entry.LastModifiedTimestamp,
+		Estimate:     formatEstimate(entry.EstimatedDuration),
+		Version:      entry.Version,
+	}
+
+	header, err := yaml.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode frontmatter: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontmatterDelimiter + "\n")
+	buf.Write(header)
+	buf.WriteString(frontmatterDelimiter + "\n")
+	buf.WriteString(entry.Body)
+
+	return buf.Bytes(), nil
+}
+
+// splitFrontmatter separates the YAML header from the Markdown body
+func splitFrontmatter(data []byte) (header, body []byte, ok bool) {
+	opening := []byte(frontmatterDelimiter + "\n")
+	if !bytes.HasPrefix(data, opening) {
+		return nil, data, false
+	}
+
+	rest := data[len(opening):]
+	closing := []byte("\n" + frontmatterDelimiter + "\n")
+	idx := bytes.Index(rest, closing)
+	if idx < 0 {
+		// Closing delimiter at end of file with no body
+		if bytes.HasSuffix(rest, []byte("\n"+frontmatterDelimiter)) {
+			return rest[:len(rest)-len(frontmatterDelimiter)], nil, true
+		}
+		return nil, data, false
+	}
+
+	return rest[:idx+1], rest[idx+len(closing):], true
+}
+
+// formatEstimate uses the TUI's duration syntax, falling back to Go's for sub-minute precision
+func formatEstimate(d time.Duration) string {
+	if d%time.Minute != 0 {
+		return d.String()
+	}
+	return core.FormatDuration(d)
+}
+
+// parseEstimate accepts both Go durations ("1h30m15s") and the TUI syntax ("2d", "1w3d")
+func parseEstimate(s string) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	return core.ParseDuration(s)
+}
+
+// checkMarkdownOwner refuses other owners' calls; a directory of files holds one person's entries
+func checkMarkdownOwner(ctx context.Context) error {
+	if service.OwnerFromContext(ctx) != service.DefaultOwner {
+		return service.ErrOwnersUnsupported
+	}
+	return nil
+}
+
+// validateMarkdownID rejects IDs that can't be used as file names
+func validateMarkdownID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid entry ID for markdown storage: %q", id)
+	}
+	return nil
+}
+
+// writeTempFile writes data to a temp file next to path, ready to be renamed into place
+func writeTempFile(path string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path)+"-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func TestMarkdownStorage_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) service.Store {
+		storage, err := NewMarkdownStorage(t.TempDir())
+		if err != nil {
+			t.Fatalf("failed to create markdown storage: %v", err)
+		}
+		return storage
+	})
+}
+
+func TestMarkdownStorage_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage, err := NewMarkdownStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create markdown storage: %v", err)
+	}
+
+	entry := core.Entry{
+		ID:                    "1",
+		Title:                 "K8s",
+		Tags:                  []string{"learning", "open-source"},
+		StartedAtTimestamp:    time.Date(2025, 12, 20, 9, 0, 0, 0, time.UTC),
+		EndedAtTimestamp:      time.Date(2025, 12, 20, 11, 30, 0, 0, time.UTC),
+		LastModifiedTimestamp: time.Date(2025, 12, 20, 11, 30, 0, 0, time.UTC),
+		EstimatedDuration:     2 * core.DAY,
+		Body:                  "# Notes\n\n---\n\nThe journey has just begun.\n",
+	}
+
+	if err := storage.SaveEntry(ctx, entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "1.md"))
+	if err != nil {
+		t.Fatalf("expected 1.md to exist: %v", err)
+	}
+	if !strings.Contains(string(data), "estimate: 2d") {
+		t.Errorf("expected human-readable estimate in frontmatter, got:\n%s", data)
+	}
+
+	entries, err := storage.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	got := entries["1"]
+	if got.Title != entry.Title || got.Body != entry.Body || got.EstimatedDuration != entry.EstimatedDuration ||
+		!got.EndedAtTimestamp.Equal(entry.EndedAtTimestamp) || strings.Join(got.Tags, ",") != "learning,open-source" {
+		t.Errorf("want %+v but got %+v", entry, got)
+	}
+
+	// No temp files left behind
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected only 1.md in directory, got %d files", len(files))
+	}
+}
+
+func TestMarkdownStorage_ExternalEdits(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage, err := NewMarkdownStorage(dir)
+	if err != nil {
+		t.Fatalf("failed to create markdown storage: %v", err)
+	}
+
+	// A file written by hand, renamed away from its ID
+	handWritten := "---\nid: abc\ntitle: Written in vim\ntags: [notes]\n---\nEdited outside zenzen.\n"
+	if err := os.WriteFile(filepath.Join(dir, "my-notes.md"), []byte(handWritten), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A plain Markdown file with no frontmatter
+	if err := os.WriteFile(filepath.Join(dir, "scratch.md"), []byte("just text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := storage.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if entries["abc"].Title != "Written in vim" || entries["abc"].Body != "Edited outside zenzen.\n" {
+		t.Errorf("unexpected entry %+v", entries["abc"])
+	}
+	if entries["scratch"].Body != "just text" || entries["scratch"].LastModifiedTimestamp.IsZero() {
+		t.Errorf("unexpected entry %+v", entries["scratch"])
+	}
+
+	// Saving keeps the renamed file rather than creating abc.md
+	entry := entries["abc"]
+	entry.Title = "Updated"
+	if err := storage.SaveEntry(ctx, entry); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "abc.md")); !os.IsNotExist(err) {
+		t.Errorf("expected abc.md not to be created")
+	}
+
+	if err := storage.DeleteEntry(ctx, "abc"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "my-notes.md")); !os.IsNotExist(err) {
+		t.Errorf("expected my-notes.md to be deleted")
+	}
+}
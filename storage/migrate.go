@@ -0,0 +1,494 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	SCHEMA_MIGRATIONS_TABLE = "schema_migrations"
+
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+
+	// migrationLockKey identifies zenzen's Postgres advisory lock for migrations ("zenzen" in hex)
+	migrationLockKey int64 = 0x7a656e7a656e
+)
+
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer version of zenzen
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of zenzen supports")
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a known migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// schemaDB is the database access a Migrator needs
+type schemaDB interface {
+	// ensureMigrationsTable creates schema_migrations if needed
+	ensureMigrationsTable(ctx context.Context) error
+	// applied lists the rows of schema_migrations, lowest version first
+	applied(ctx context.Context) ([]appliedMigration, error)
+	// runScript executes a migration together with its bookkeeping atomically
+	runScript(ctx context.Context, script string) error
+	// lock waits for the database's migration lock and returns a schemaDB holding it until unlock
+	lock(ctx context.Context) (locked schemaDB, unlock func(), err error)
+	close(ctx context.Context) error
+}
+
+// Migrator applies and rolls back the embedded schema migrations
+type Migrator struct {
+	db         schemaDB
+	migrations []Migration
+}
+
+// newMigrator loads the migrations for a dialect
+func newMigrator(db schemaDB, dialect string) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// NewSQLMigrator connects to a Postgres database for running migrations by hand.
+// Unlike NewSQLStorage it makes no schema changes on connect.
+func NewSQLMigrator(ctx context.Context, connString string) (*Migrator, error) {
+	conn, err := pgx.Connect(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	migrator, err := newMigrator(&pgSchema{conn: conn}, dialectPostgres)
+	if err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return migrator, nil
+}
+
+// NewSQLiteMigrator opens a SQLite database for running migrations by hand
+func NewSQLiteMigrator(ctx context.Context, path string) (*Migrator, error) {
+	db, err := openSQLite(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := newMigrator(&sqliteSchema{db: db}, dialectSQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return migrator, nil
+}
+
+// Close closes the migrator's database connection
+func (m *Migrator) Close(ctx context.Context) error {
+	return m.db.close(ctx)
+}
+
+// LatestVersion is the highest migration version known to this binary
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// CurrentVersion is the highest migration version applied to the database
+func (m *Migrator) CurrentVersion(ctx context.Context) (int, error) {
+	if err := m.db.ensureMigrationsTable(ctx); err != nil {
+		return 0, fmt.Errorf("failed to create %s table: %w", SCHEMA_MIGRATIONS_TABLE, err)
+	}
+	applied, err := m.db.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// CheckVersion returns ErrSchemaTooNew if the database has migrations this binary doesn't know
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	current, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > m.LatestVersion() {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d; upgrade zenzen",
+			ErrSchemaTooNew, current, m.LatestVersion())
+	}
+	return nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.db.ensureMigrationsTable(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create %s table: %w", SCHEMA_MIGRATIONS_TABLE, err)
+	}
+	applied, err := m.db.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := appliedAt[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+
+	return statuses, nil
+}
+
+// withLock runs fn with the migration lock held, so processes migrating the same database
+// take turns and each sees what the last one applied
+func (m *Migrator) withLock(ctx context.Context, fn func(locked *Migrator) error) error {
+	db, unlock, err := m.db.lock(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer unlock()
+
+	return fn(&Migrator{db: db, migrations: m.migrations})
+}
+
+// Up applies every pending migration in order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) (applied []Migration, err error) {
+	err = m.withLock(ctx, func(locked *Migrator) error {
+		applied, err = locked.up(ctx)
+		return err
+	})
+	return applied, err
+}
+
+// up applies pending migrations; the caller holds the migration lock
+func (m *Migrator) up(ctx context.Context) ([]Migration, error) {
+	if err := m.CheckVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+
+		script := joinScript(status.Up, fmt.Sprintf("INSERT INTO %s (version, name) VALUES (%d, %s)",
+			SCHEMA_MIGRATIONS_TABLE, status.Version, quoteLiteral(status.Name)))
+		if err := m.db.runScript(ctx, script); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %04d_%s: %w", status.Version, status.Name, err)
+		}
+		applied = append(applied, status.Migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recent steps migrations and returns the ones rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (rolledBack []Migration, err error) {
+	err = m.withLock(ctx, func(locked *Migrator) error {
+		rolledBack, err = locked.down(ctx, steps)
+		return err
+	})
+	return rolledBack, err
+}
+
+// down rolls back migrations; the caller holds the migration lock
+func (m *Migrator) down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.CheckVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(statuses) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+
+		script := joinScript(status.Down, fmt.Sprintf("DELETE FROM %s WHERE version = %d",
+			SCHEMA_MIGRATIONS_TABLE, status.Version))
+		if err := m.db.runScript(ctx, script); err != nil {
+			return rolledBack, fmt.Errorf("failed to roll back migration %04d_%s: %w", status.Version, status.Name, err)
+		}
+		rolledBack = append(rolledBack, status.Migration)
+	}
+
+	return rolledBack, nil
+}
+
+// loadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs for a dialect
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	files, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s migrations: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		name := file.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, migrationName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", name, direction)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %w", name, err)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = migration
+		} else if migration.Name != migrationName {
+			return nil, fmt.Errorf("migration %04d has conflicting names %q and %q", version, migration.Name, migrationName)
+		}
+
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// joinScript joins SQL statements into one script, terminating each with a semicolon
+func joinScript(statements ...string) string {
+	var b strings.Builder
+	for _, statement := range statements {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+		b.WriteString(statement)
+		if !strings.HasSuffix(statement, ";") {
+			b.WriteString(";")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// quoteLiteral quotes a string for inlining into a migration script
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// pgSchema runs migrations on Postgres
+type pgSchema struct {
+	conn DBConn
+}
+
+func (p *pgSchema) ensureMigrationsTable(ctx context.Context) error {
+	_, err := p.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	return err
+}
+
+func (p *pgSchema) applied(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := p.conn.Query(ctx, "SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", SCHEMA_MIGRATIONS_TABLE, err)
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// runScript sends the script as one simple-protocol query, which Postgres
+// runs in a single implicit transaction
+func (p *pgSchema) runScript(ctx context.Context, script string) error {
+	_, err := p.conn.Exec(ctx, script)
+	return err
+}
+
+// lock takes a session advisory lock. Session locks belong to a connection, so a pool lends
+// one connection for the migration; if the unlock fails it is closed rather than returned.
+func (p *pgSchema) lock(ctx context.Context) (schemaDB, func(), error) {
+	conn := p.conn
+	release := func(unlocked bool) {}
+	if pool, ok := p.conn.(poolConn); ok {
+		acquired, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		conn = acquiredConn{acquired}
+		release = func(unlocked bool) {
+			if unlocked {
+				acquired.Release()
+				return
+			}
+			acquired.Hijack().Close(context.WithoutCancel(ctx))
+		}
+	}
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		release(true)
+		return nil, nil, err
+	}
+
+	unlock := func() {
+		_, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		release(err == nil)
+	}
+	return &pgSchema{conn: conn}, unlock, nil
+}
+
+func (p *pgSchema) close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
+
+// sqliteSchema runs migrations on SQLite
+type sqliteSchema struct {
+	db *sql.DB
+}
+
+func (s *sqliteSchema) ensureMigrationsTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+func (s *sqliteSchema) applied(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", SCHEMA_MIGRATIONS_TABLE, err)
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		var appliedAt string
+		if err := rows.Scan(&a.Version, &a.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		// CURRENT_TIMESTAMP is UTC in "YYYY-MM-DD HH:MM:SS" form
+		a.AppliedAt, _ = time.Parse(time.DateTime, appliedAt)
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+func (s *sqliteSchema) runScript(ctx context.Context, script string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lock is a no-op: SQLite has no advisory locks, and each migration's row in schema_migrations
+// is written in the migration's transaction, so a second process fails rather than applying it twice
+func (s *sqliteSchema) lock(ctx context.Context) (schemaDB, func(), error) {
+	return s, func() {}, nil
+}
+
+func (s *sqliteSchema) close(ctx context.Context) error {
+	return s.db.Close()
+}
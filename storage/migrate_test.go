@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{dialectPostgres, dialectSQLite} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := loadMigrations(dialect)
+			if err != nil {
+				t.Fatalf("loadMigrations() error = %v", err)
+			}
+			for i, migration := range migrations {
+				if migration.Version != i+1 {
+					t.Errorf("migration %d has version %d; versions must be contiguous from 1", i, migration.Version)
+				}
+			}
+		})
+	}
+
+	// Both dialects must describe the same schema history
+	postgres, _ := loadMigrations(dialectPostgres)
+	sqlite, _ := loadMigrations(dialectSQLite)
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite has %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d is %q for postgres but %q for sqlite", i+1, postgres[i].Name, sqlite[i].Name)
+		}
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "zenzen.db")
+
+	migrator, err := NewSQLiteMigrator(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteMigrator() error = %v", err)
+	}
+	defer migrator.Close(ctx)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(applied) != migrator.LatestVersion() {
+		t.Errorf("Up() applied %d migrations; want %d", len(applied), migrator.LatestVersion())
+	}
+
+	// Up is idempotent
+	applied, err = migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("second Up() = %d, %v; want 0, nil", len(applied), err)
+	}
+
+	rolledBack, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0].Version != migrator.LatestVersion() {
+		t.Errorf("Down(1) rolled back %+v; want latest migration", rolledBack)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for _, status := range statuses {
+		wantApplied := status.Version < migrator.LatestVersion()
+		if status.Applied != wantApplied {
+			t.Errorf("migration %d applied = %v; want %v", status.Version, status.Applied, wantApplied)
+		}
+	}
+
+	// Opening a storage re-applies the rolled back migration
+	storage, err := NewSQLiteStorage(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	storage.Close(ctx)
+
+	current, err := migrator.CurrentVersion(ctx)
+	if err != nil || current != migrator.LatestVersion() {
+		t.Errorf("CurrentVersion() = %d, %v; want %d", current, err, migrator.LatestVersion())
+	}
+}
+
+func TestMigrator_RefusesNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "zenzen.db")
+
+	migrator, err := NewSQLiteMigrator(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteMigrator() error = %v", err)
+	}
+	defer migrator.Close(ctx)
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	// Simulate a migration applied by a newer zenzen
+	if err := migrator.db.runScript(ctx, "INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewSQLiteStorage(ctx, path)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("NewSQLiteStorage() error = %v; want ErrSchemaTooNew", err)
+	}
+}
+
+func TestMigrator_UpHoldsLock(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(ctx)
+
+	migrator, err := newMigrator(&pgSchema{conn: mock}, dialectPostgres)
+	if err != nil {
+		t.Fatalf("newMigrator() error = %v", err)
+	}
+	latest := migrator.migrations[len(migrator.migrations)-1]
+
+	// Another process applied everything but the latest migration while we waited for the lock
+	applied := func() *pgxmock.Rows {
+		rows := pgxmock.NewRows([]string{"version", "name", "applied_at"})
+		for _, migration := range migrator.migrations[:len(migrator.migrations)-1] {
+			rows.AddRow(migration.Version, migration.Name, time.Now())
+		}
+		return rows
+	}
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	for range 2 { // CheckVersion, then Status
+		mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+		mock.ExpectQuery(`SELECT version, name, applied_at FROM schema_migrations`).WillReturnRows(applied())
+	}
+	mock.ExpectExec(`INSERT INTO schema_migrations \(version, name\) VALUES \(` + strconv.Itoa(latest.Version)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(migrationLockKey).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	got, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Version != latest.Version {
+		t.Errorf("Up() applied %+v; want only migration %d", got, latest.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
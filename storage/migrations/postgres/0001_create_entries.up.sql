@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS entries (
+	id VARCHAR(255) PRIMARY KEY,
+	title TEXT NOT NULL,
+	tags TEXT[],
+	started_at_timestamp TIMESTAMPTZ,
+	ended_at_timestamp TIMESTAMPTZ,
+	last_modified_timestamp TIMESTAMPTZ,
+	estimated_duration BIGINT,
+	body TEXT
+);
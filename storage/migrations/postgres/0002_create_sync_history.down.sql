@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sync_run_entries;
+DROP TABLE IF EXISTS sync_runs;
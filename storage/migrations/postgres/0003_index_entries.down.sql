@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS entries_tags_idx;
+DROP INDEX IF EXISTS entries_last_modified_idx;
+DROP INDEX IF EXISTS entries_ended_at_idx;
+DROP INDEX IF EXISTS entries_started_at_idx;
@@ -0,0 +1,11 @@
+-- Zero timestamps used to be written as 0001-01-01; store them as NULL so they
+-- sort last and in-progress entries match "ended_at_timestamp IS NULL"
+UPDATE entries SET started_at_timestamp = NULL WHERE started_at_timestamp = '0001-01-01 00:00:00+00';
+UPDATE entries SET ended_at_timestamp = NULL WHERE ended_at_timestamp = '0001-01-01 00:00:00+00';
+UPDATE entries SET last_modified_timestamp = NULL WHERE last_modified_timestamp = '0001-01-01 00:00:00+00';
+
+-- Match the default newest-first ordering used by Query
+CREATE INDEX IF NOT EXISTS entries_started_at_idx ON entries (started_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_ended_at_idx ON entries (ended_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_last_modified_idx ON entries (last_modified_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_tags_idx ON entries USING GIN (tags);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS entries_search_vector_idx;
+ALTER TABLE entries DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,8 @@
+-- Title matches outrank body matches
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(body, '')), 'B')
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS entries_search_vector_idx ON entries USING GIN (search_vector);
@@ -0,0 +1,2 @@
+-- Bumped on every save; conditional saves compare it to detect concurrent edits
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,6 @@
+-- Optional tag metadata. Entries keep their tags in entries.tags; usage is counted from there.
+CREATE TABLE IF NOT EXISTS tags (
+	name TEXT PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	color TEXT NOT NULL DEFAULT ''
+);
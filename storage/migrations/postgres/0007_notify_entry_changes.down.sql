@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS entries_notify_change ON entries;
+DROP FUNCTION IF EXISTS zenzen_notify_entry_change();
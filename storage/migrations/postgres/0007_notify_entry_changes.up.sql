@@ -0,0 +1,17 @@
+-- Publishes every entry change on the zenzen_entries channel for SQLStorage.Subscribe.
+-- The payload stays small, well under pg_notify's 8000 byte limit; listeners read the entry itself.
+CREATE OR REPLACE FUNCTION zenzen_notify_entry_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('zenzen_entries', json_build_object('op', 'delete', 'id', OLD.id, 'version', OLD.version)::text);
+		RETURN OLD;
+	END IF;
+	PERFORM pg_notify('zenzen_entries', json_build_object('op', lower(TG_OP), 'id', NEW.id, 'version', NEW.version)::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS entries_notify_change ON entries;
+CREATE TRIGGER entries_notify_change
+	AFTER INSERT OR UPDATE OR DELETE ON entries
+	FOR EACH ROW EXECUTE FUNCTION zenzen_notify_entry_change();
@@ -0,0 +1,26 @@
+-- Fails if two owners use the same entry ID or tag name; claim or delete those first
+CREATE OR REPLACE FUNCTION zenzen_notify_entry_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('zenzen_entries', json_build_object('op', 'delete', 'id', OLD.id, 'version', OLD.version)::text);
+		RETURN OLD;
+	END IF;
+	PERFORM pg_notify('zenzen_entries', json_build_object('op', lower(TG_OP), 'id', NEW.id, 'version', NEW.version)::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS entries_owner_started_at_idx;
+DROP INDEX IF EXISTS entries_owner_ended_at_idx;
+DROP INDEX IF EXISTS entries_owner_last_modified_idx;
+CREATE INDEX IF NOT EXISTS entries_started_at_idx ON entries (started_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_ended_at_idx ON entries (ended_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_last_modified_idx ON entries (last_modified_timestamp DESC NULLS LAST, id DESC);
+
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_pkey;
+ALTER TABLE tags DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE tags ADD PRIMARY KEY (name);
+
+ALTER TABLE entries DROP CONSTRAINT IF EXISTS entries_pkey;
+ALTER TABLE entries DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE entries ADD PRIMARY KEY (id);
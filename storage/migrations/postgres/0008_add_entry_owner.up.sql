@@ -0,0 +1,30 @@
+-- Entries and tag metadata belong to an owner, e.g. a Cognito user's sub. Rows from before
+-- owners belong to '' (service.DefaultOwner) until `zenzen claim` hands them to someone.
+-- IDs only need to be unique per owner, so one owner can never overwrite another's entry.
+ALTER TABLE entries ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE entries DROP CONSTRAINT IF EXISTS entries_pkey;
+ALTER TABLE entries ADD PRIMARY KEY (owner_id, id);
+
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_pkey;
+ALTER TABLE tags ADD PRIMARY KEY (owner_id, name);
+
+-- Every query filters on the owner first
+DROP INDEX IF EXISTS entries_started_at_idx;
+DROP INDEX IF EXISTS entries_ended_at_idx;
+DROP INDEX IF EXISTS entries_last_modified_idx;
+CREATE INDEX IF NOT EXISTS entries_owner_started_at_idx ON entries (owner_id, started_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_owner_ended_at_idx ON entries (owner_id, ended_at_timestamp DESC NULLS LAST, id DESC);
+CREATE INDEX IF NOT EXISTS entries_owner_last_modified_idx ON entries (owner_id, last_modified_timestamp DESC NULLS LAST, id DESC);
+
+-- Change notifications name the owner so subscribers can ignore other owners' entries
+CREATE OR REPLACE FUNCTION zenzen_notify_entry_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('zenzen_entries', json_build_object('op', 'delete', 'owner', OLD.owner_id, 'id', OLD.id, 'version', OLD.version)::text);
+		RETURN OLD;
+	END IF;
+	PERFORM pg_notify('zenzen_entries', json_build_object('op', lower(TG_OP), 'owner', NEW.owner_id, 'id', NEW.id, 'version', NEW.version)::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS entries (
+	id VARCHAR(255) PRIMARY KEY,
+	title TEXT NOT NULL,
+	tags TEXT,
+	started_at_timestamp TEXT,
+	ended_at_timestamp TEXT,
+	last_modified_timestamp TEXT,
+	estimated_duration INTEGER,
+	body TEXT
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS sync_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at TEXT NOT NULL,
+	ended_at TEXT,
+	pushed_count INTEGER NOT NULL DEFAULT 0,
+	pulled_count INTEGER NOT NULL DEFAULT 0,
+	conflict_count INTEGER NOT NULL DEFAULT 0,
+	errors TEXT
+);
+
+CREATE TABLE IF NOT EXISTS sync_run_entries (
+	run_id INTEGER NOT NULL REFERENCES sync_runs (id) ON DELETE CASCADE,
+	entry_id VARCHAR(255) NOT NULL,
+	direction TEXT NOT NULL,
+	synced_at TEXT NOT NULL,
+	error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS sync_run_entries_entry_id_idx ON sync_run_entries (entry_id, synced_at DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS entries_last_modified_idx;
+DROP INDEX IF EXISTS entries_ended_at_idx;
+DROP INDEX IF EXISTS entries_started_at_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS entries_started_at_idx ON entries (started_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_ended_at_idx ON entries (ended_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_last_modified_idx ON entries (last_modified_timestamp, id);
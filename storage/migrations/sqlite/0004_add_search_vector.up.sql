@@ -0,0 +1,2 @@
+-- Full-text search is Postgres-only; SQLite falls back to service.SearchEntries
+SELECT 1;
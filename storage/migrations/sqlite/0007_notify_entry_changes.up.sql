@@ -0,0 +1,2 @@
+-- Change notifications are Postgres-only; SQLite has no LISTEN/NOTIFY
+SELECT 1;
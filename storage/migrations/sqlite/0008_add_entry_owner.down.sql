@@ -0,0 +1,29 @@
+-- Fails if two owners use the same entry ID or tag name; claim or delete those first
+CREATE TABLE entries_unowned (
+	id VARCHAR(255) PRIMARY KEY,
+	title TEXT NOT NULL,
+	tags TEXT,
+	started_at_timestamp TEXT,
+	ended_at_timestamp TEXT,
+	last_modified_timestamp TEXT,
+	estimated_duration INTEGER,
+	body TEXT,
+	version INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO entries_unowned (id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body, version)
+	SELECT id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body, version FROM entries;
+DROP TABLE entries;
+ALTER TABLE entries_unowned RENAME TO entries;
+
+CREATE INDEX IF NOT EXISTS entries_started_at_idx ON entries (started_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_ended_at_idx ON entries (ended_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_last_modified_idx ON entries (last_modified_timestamp, id);
+
+CREATE TABLE tags_unowned (
+	name TEXT PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	color TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO tags_unowned (name, description, color) SELECT name, description, color FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_unowned RENAME TO tags;
@@ -0,0 +1,34 @@
+-- Entries and tag metadata belong to an owner; rows from before owners belong to ''.
+-- SQLite can't change a primary key in place, so both tables are rebuilt.
+CREATE TABLE entries_owned (
+	owner_id TEXT NOT NULL DEFAULT '',
+	id VARCHAR(255) NOT NULL,
+	title TEXT NOT NULL,
+	tags TEXT,
+	started_at_timestamp TEXT,
+	ended_at_timestamp TEXT,
+	last_modified_timestamp TEXT,
+	estimated_duration INTEGER,
+	body TEXT,
+	version INTEGER NOT NULL DEFAULT 1,
+	PRIMARY KEY (owner_id, id)
+);
+INSERT INTO entries_owned (id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body, version)
+	SELECT id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body, version FROM entries;
+DROP TABLE entries;
+ALTER TABLE entries_owned RENAME TO entries;
+
+CREATE INDEX IF NOT EXISTS entries_owner_started_at_idx ON entries (owner_id, started_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_owner_ended_at_idx ON entries (owner_id, ended_at_timestamp, id);
+CREATE INDEX IF NOT EXISTS entries_owner_last_modified_idx ON entries (owner_id, last_modified_timestamp, id);
+
+CREATE TABLE tags_owned (
+	owner_id TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	color TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (owner_id, name)
+);
+INSERT INTO tags_owned (name, description, color) SELECT name, description, color FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_owned RENAME TO tags;
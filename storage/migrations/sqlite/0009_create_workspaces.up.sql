@@ -0,0 +1,17 @@
+-- Shared workspaces. A workspace's entries and tags are stored under the owner
+-- 'workspace/<id>'; membership decides who may read and write them.
+CREATE TABLE IF NOT EXISTS workspaces (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+	workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+	principal_id TEXT NOT NULL,
+	role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'admin')),
+	added_at TEXT NOT NULL,
+	PRIMARY KEY (workspace_id, principal_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_principal_id_idx ON workspace_members (principal_id);
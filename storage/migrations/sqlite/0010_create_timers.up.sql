@@ -0,0 +1,8 @@
+-- Each owner times at most one entry at once. A paused entry keeps its timer so it can
+-- resume; stopping clears it. Timers only change entries' timestamps, which is what syncs.
+CREATE TABLE IF NOT EXISTS timers (
+	owner_id TEXT PRIMARY KEY,
+	entry_id TEXT NOT NULL,
+	state TEXT NOT NULL CHECK (state IN ('running', 'paused')),
+	since TEXT NOT NULL
+);
@@ -0,0 +1,20 @@
+-- Outgoing webhook deliveries, kept after they finish as the delivery log. The payload is
+-- the exact body that was signed.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	subscription_id TEXT NOT NULL,
+	event TEXT NOT NULL,
+	owner_id TEXT NOT NULL DEFAULT '',
+	entry_id TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TEXT NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	response_status INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL,
+	delivered_at TEXT
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_owner_idx ON webhook_deliveries (owner_id, created_at DESC);
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// reassignOwnerQuery moves rows of table from one owner to another, except those whose
+// key the new owner already uses, which would collide with the new owner's own
+func reassignOwnerQuery(builder sq.StatementBuilderType, table, key, from, to string) sq.UpdateBuilder {
+	return builder.
+		Update(table).
+		Set("owner_id", to).
+		Where(sq.Eq{"owner_id": from}).
+		Where(fmt.Sprintf("%[2]s NOT IN (SELECT %[2]s FROM %[1]s WHERE owner_id = ?)", table, key), to)
+}
+
+// ReassignOwner moves from's entries and tag metadata to to in one transaction, returning
+// how many entries moved. Entries whose ID to already uses stay with from.
+func (s *SQLStorage) ReassignOwner(ctx context.Context, from, to string) (int, error) {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op once committed
+
+	var moved int64
+	for _, table := range []struct{ name, key string }{{ENTRIES_TABLE, "id"}, {TAGS_TABLE, "name"}} {
+		query, args, err := reassignOwnerQuery(s.psql, table.name, table.key, from, to).ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("failed to build update query: %w", err)
+		}
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reassign %s: %w", table.name, err)
+		}
+		if table.name == ENTRIES_TABLE {
+			moved = tag.RowsAffected()
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit reassignment: %w", err)
+	}
+
+	return int(moved), nil
+}
+
+// ReassignOwner moves from's entries and tag metadata to to in one transaction, returning
+// how many entries moved. Entries whose ID to already uses stay with from.
+func (s *SQLiteStorage) ReassignOwner(ctx context.Context, from, to string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	var moved int64
+	for _, table := range []struct{ name, key string }{{ENTRIES_TABLE, "id"}, {TAGS_TABLE, "name"}} {
+		query, args, err := reassignOwnerQuery(s.psql, table.name, table.key, from, to).ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("failed to build update query: %w", err)
+		}
+		var result sql.Result
+		if result, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return 0, fmt.Errorf("failed to reassign %s: %w", table.name, err)
+		}
+		if table.name == ENTRIES_TABLE {
+			if moved, err = result.RowsAffected(); err != nil {
+				return 0, fmt.Errorf("failed to read rows affected: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit reassignment: %w", err)
+	}
+
+	return int(moved), nil
+}
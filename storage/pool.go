@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig sizes a connection pool; zero values keep pgxpool's defaults
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// pgxPool is the subset of *pgxpool.Pool used by SQLStorage (allows mocking)
+type pgxPool interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+	Close()
+}
+
+// poolConn adapts a connection pool to DBConn.
+// Every call borrows its own connection, so unlike a pgx.Conn it is safe for concurrent use.
+type poolConn struct {
+	pgxPool
+}
+
+// Close closes every connection in the pool
+func (p poolConn) Close(ctx context.Context) error {
+	p.pgxPool.Close()
+	return nil
+}
+
+// acquiredConn adapts a single connection borrowed from the pool to DBConn; Close returns it
+type acquiredConn struct {
+	*pgxpool.Conn
+}
+
+// Close returns the connection to the pool
+func (c acquiredConn) Close(ctx context.Context) error {
+	c.Release()
+	return nil
+}
+
+// NewPooledSQLStorage creates a SQL storage backed by a connection pool and ensures the tables exist.
+// Use it wherever the storage is shared between goroutines, such as the API server.
+func NewPooledSQLStorage(ctx context.Context, connString string, poolCfg PoolConfig) (*SQLStorage, error) {
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	if poolCfg.MaxConns > 0 {
+		cfg.MaxConns = poolCfg.MaxConns
+	}
+	if poolCfg.MinConns > 0 {
+		cfg.MinConns = poolCfg.MinConns
+	}
+	if poolCfg.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	}
+	if poolCfg.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = poolCfg.MaxConnIdleTime
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	// The pool connects lazily; fail fast like NewSQLStorage does
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	storage := &SQLStorage{
+		conn:       poolConn{pool},
+		psql:       sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		connString: connString,
+	}
+
+	// Bring the schema up to date, refusing databases migrated by a newer zenzen
+	if err := storage.migrate(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return storage, nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/turnerem/zenzen/core"
+)
+
+func TestSQLStorage_ConcurrentRequestsOnPool(t *testing.T) {
+	const requests = 20
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	storage := &SQLStorage{
+		conn: poolConn{mock},
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// Half the requests read, half write, all at once
+	for i := 0; i < requests/2; i++ {
+		mock.ExpectQuery(`SELECT (.+) FROM entries`).
+			WithArgs("").
+			WillReturnRows(pgxmock.NewRows([]string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body", "version"}).
+				AddRow("1", "K8s", []string{"learning"}, nil, nil, time.Now(), int64(0), "Test body", int64(1))).
+			WillDelayFor(5 * time.Millisecond)
+		mock.ExpectQuery(`INSERT INTO entries (.+) RETURNING entries.version`).
+			WithArgs(fmt.Sprintf("entry-%d", i), pgxmock.AnyArg(), pgxmock.AnyArg(), nil, nil, pgxmock.AnyArg(), int64(0), pgxmock.AnyArg(), int64(1), "").
+			WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(int64(1))).
+			WillDelayFor(5 * time.Millisecond)
+	}
+
+	ctx := context.Background()
+	errs := make(chan error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests/2; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			entries, err := storage.GetAll(ctx)
+			if err == nil && len(entries) != 1 {
+				err = fmt.Errorf("expected 1 entry, got %d", len(entries))
+			}
+			errs <- err
+		}()
+		go func(i int) {
+			defer wg.Done()
+			errs <- storage.SaveEntry(ctx, core.Entry{ID: fmt.Sprintf("entry-%d", i), Title: "Concurrent", LastModifiedTimestamp: time.Now()})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent request failed: %v", err)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+// TestNewPooledSQLStorage_Concurrent runs against a real database when ZENZEN_TEST_DATABASE_URL is set
+func TestNewPooledSQLStorage_Concurrent(t *testing.T) {
+	connString := os.Getenv("ZENZEN_TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("ZENZEN_TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	storage, err := NewPooledSQLStorage(ctx, connString, PoolConfig{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer storage.Close(ctx)
+
+	if _, err := storage.conn.Exec(ctx, "DELETE FROM "+ENTRIES_TABLE); err != nil {
+		t.Fatalf("failed to reset entries table: %v", err)
+	}
+
+	// More goroutines than connections, so requests queue for the pool
+	const requests = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs <- storage.SaveEntry(ctx, core.Entry{ID: fmt.Sprintf("pool-%d", i), Title: "Concurrent", LastModifiedTimestamp: time.Now()})
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := storage.GetAll(ctx)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent request failed: %v", err)
+		}
+	}
+
+	entries, err := storage.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(entries) != requests {
+		t.Errorf("expected %d entries, got %d", requests, len(entries))
+	}
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+)
+
+// entryValueColumns are the columns a caller sets; the store manages version
+var entryValueColumns = []string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body"}
+
+// entryColumns are selected, in scan order, by every entry query
+var entryColumns = append(slices.Clone(entryValueColumns), "version")
+
+// ownedEntryColumns are written by every insert; the owner comes from the context, not the entry
+var ownedEntryColumns = append(slices.Clone(entryColumns), "owner_id")
+
+// sortColumns maps service sort fields to indexed columns
+var sortColumns = map[string]string{
+	service.SortByStartedAt:    "started_at_timestamp",
+	service.SortByEndedAt:      "ended_at_timestamp",
+	service.SortByLastModified: "last_modified_timestamp",
+}
+
+// likeEscaper makes user text match literally inside a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// buildEntryQuery translates a service.Query into a SELECT on the owner's entries.
+// One row beyond the limit is fetched so pageEntries can tell whether another page exists.
+func buildEntryQuery(builder sq.StatementBuilderType, dialect, owner string, q *service.Query) (sq.SelectBuilder, error) {
+	if err := q.Validate(); err != nil {
+		return sq.SelectBuilder{}, err
+	}
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return sq.SelectBuilder{}, err
+	}
+
+	query := filterEntries(builder.Select(entryColumns...), dialect, owner, *q)
+
+	// Keyset pagination on (sort column, id), with NULL sort values last in either direction
+	column := sortColumns[q.SortBy]
+	direction, op := "DESC", "<"
+	if q.Ascending {
+		direction, op = "ASC", ">"
+	}
+
+	if cursor != nil {
+		if cursor.Time.IsZero() {
+			query = query.Where(fmt.Sprintf("(%s IS NULL AND id %s ?)", column, op), cursor.ID)
+		} else {
+			after := queryTime(dialect, cursor.Time)
+			query = query.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?) OR %[1]s IS NULL)", column, op), after, after, cursor.ID)
+		}
+	}
+
+	query = query.OrderBy(fmt.Sprintf("%s %s NULLS LAST", column, direction), "id "+direction)
+
+	if q.Limit > 0 {
+		query = query.Limit(uint64(q.Limit) + 1)
+	}
+
+	return query, nil
+}
+
+// buildCountQuery counts every entry of the owner's that passes the query's filters,
+// on any page. The query must already have been validated by buildEntryQuery.
+func buildCountQuery(builder sq.StatementBuilderType, dialect, owner string, q service.Query) sq.SelectBuilder {
+	return filterEntries(builder.Select("count(*)"), dialect, owner, q)
+}
+
+// filterEntries restricts a SELECT to the owner's entries that pass the query's filters
+func filterEntries(query sq.SelectBuilder, dialect, owner string, q service.Query) sq.SelectBuilder {
+	query = query.From(ENTRIES_TABLE).Where(sq.Eq{"owner_id": owner})
+
+	if len(q.IDs) > 0 {
+		query = query.Where(sq.Eq{"id": q.IDs})
+	}
+
+	// A tag filter also matches the tags beneath it, so infra matches infra/k8s
+	for _, tag := range q.Tags {
+		prefix := tag + service.TagSeparator
+		if dialect == dialectSQLite {
+			// substr rather than LIKE, which is case-insensitive in SQLite
+			query = query.Where("EXISTS (SELECT 1 FROM json_each(entries.tags) WHERE json_each.value = ? OR substr(json_each.value, 1, ?) = ?)",
+				tag, utf8.RuneCountInString(prefix), prefix)
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM unnest(tags) AS tag WHERE tag = ? OR starts_with(tag, ?))", tag, prefix)
+		}
+	}
+
+	if q.Text != "" {
+		pattern := "%" + likeEscaper.Replace(q.Text) + "%"
+		if dialect == dialectSQLite {
+			// SQLite's LIKE is case-insensitive for ASCII only
+			query = query.Where(`(title LIKE ? ESCAPE '\' OR body LIKE ? ESCAPE '\')`, pattern, pattern)
+		} else {
+			query = query.Where("(title ILIKE ? OR body ILIKE ?)", pattern, pattern)
+		}
+	}
+
+	ranges := []struct {
+		column string
+		bounds service.TimeRange
+	}{
+		{"started_at_timestamp", q.StartedAt},
+		{"ended_at_timestamp", q.EndedAt},
+	}
+	for _, r := range ranges {
+		if !r.bounds.From.IsZero() {
+			query = query.Where(sq.GtOrEq{r.column: queryTime(dialect, r.bounds.From)})
+		}
+		if !r.bounds.To.IsZero() {
+			query = query.Where(sq.Lt{r.column: queryTime(dialect, r.bounds.To)})
+		}
+	}
+
+	if q.InProgress != nil {
+		if *q.InProgress {
+			query = query.Where(sq.Eq{"ended_at_timestamp": nil})
+		} else {
+			query = query.Where(sq.NotEq{"ended_at_timestamp": nil})
+		}
+	}
+
+	return query
+}
+
+// queryTime converts a time for comparison with a timestamp column. SQLite stores
+// timestamps as fixed-width UTC text, which compares in time order.
+func queryTime(dialect string, t time.Time) any {
+	if dialect == dialectSQLite {
+		return formatSQLiteTime(t)
+	}
+	return t
+}
+
+// pageEntries drops the extra row fetched by buildEntryQuery and sets the next cursor
+func pageEntries(q service.Query, entries []core.Entry) service.QueryResult {
+	var result service.QueryResult
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		result.NextCursor = q.EncodeCursor(entries[len(entries)-1])
+	}
+	result.Entries = entries
+	return result
+}
+
+// saveEntryQuery builds the statement that saves the owner's entry, given values for
+// entryValueColumns, and returns the version it stored. A zero Version upserts; otherwise
+// only the row still at that version is updated, so the caller must treat no row returned
+// as service.ErrConflict.
+func saveEntryQuery(builder sq.StatementBuilderType, owner string, entry core.Entry, values []any) sq.Sqlizer {
+	if entry.Version > 0 {
+		update := builder.Update(ENTRIES_TABLE)
+		for i, column := range entryValueColumns[1:] {
+			update = update.Set(column, values[i+1])
+		}
+		return update.
+			Set("version", sq.Expr("version + 1")).
+			Where(sq.Eq{"owner_id": owner, "id": entry.ID, "version": entry.Version}).
+			Suffix("RETURNING version")
+	}
+
+	return builder.
+		Insert(ENTRIES_TABLE).
+		Columns(ownedEntryColumns...).
+		Values(append(values, int64(1), owner)...).
+		Suffix(upsertSuffix() + " RETURNING " + ENTRIES_TABLE + ".version")
+}
+
+// upsertBatchQuery saves the unversioned rows of the Postgres staging table unconditionally
+func upsertBatchQuery(builder sq.StatementBuilderType) sq.Sqlizer {
+	return builder.
+		Insert(ENTRIES_TABLE).
+		Columns(ownedEntryColumns...).
+		Select(builder.
+			Select(append(slices.Clone(entryValueColumns), "1", "owner_id")...).
+			From(ENTRY_BATCH_TABLE).
+			Where(sq.Eq{"version": 0})).
+		Suffix(upsertSuffix())
+}
+
+// updateBatchQuery saves the versioned rows of the Postgres staging table that still match
+// the stored version, returning the IDs it updated
+func updateBatchQuery(builder sq.StatementBuilderType) sq.Sqlizer {
+	update := builder.Update(ENTRIES_TABLE)
+	for _, column := range entryValueColumns[1:] {
+		update = update.Set(column, sq.Expr("b."+column))
+	}
+	return update.
+		Set("version", sq.Expr(ENTRIES_TABLE+".version + 1")).
+		From(ENTRY_BATCH_TABLE + " b").
+		Where(ENTRIES_TABLE + ".owner_id = b.owner_id").
+		Where(ENTRIES_TABLE + ".id = b.id").
+		Where("b.version > 0").
+		Where(ENTRIES_TABLE + ".version = b.version").
+		Suffix("RETURNING " + ENTRIES_TABLE + ".id")
+}
+
+// upsertSuffix turns an INSERT into entries into an upsert that bumps the version
+func upsertSuffix() string {
+	updates := make([]string, 0, len(entryValueColumns))
+	for _, column := range entryValueColumns[1:] {
+		updates = append(updates, fmt.Sprintf("%[1]s = excluded.%[1]s", column))
+	}
+	updates = append(updates, "version = entries.version + 1")
+
+	return "ON CONFLICT (owner_id, id) DO UPDATE SET " + strings.Join(updates, ", ")
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	// searchConfig is the text search configuration used by the search_vector column
+	searchConfig = "english"
+
+	headlineOptions = "StartSel=" + service.HighlightStart + ", StopSel=" + service.HighlightEnd +
+		", MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+)
+
+// Search runs a full-text search over the context owner's titles and bodies, most relevant first.
+// The text uses web search syntax: quoted phrases, OR, and -excluded words.
+func (s *SQLStorage) Search(ctx context.Context, text string, limit int) ([]service.SearchResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	builder := s.psql.
+		Select(entryColumns...).
+		Column("ts_rank(search_vector, search_query) AS rank").
+		Column(fmt.Sprintf("ts_headline('%s', %s, search_query, '%s') AS snippet", searchConfig, escapeHTML("coalesce(body, '')"), headlineOptions)).
+		From(ENTRIES_TABLE).
+		CrossJoin(fmt.Sprintf("websearch_to_tsquery('%s', ?) AS search_query", searchConfig), text).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx)}).
+		Where("search_vector @@ search_query").
+		OrderBy("rank DESC", "id")
+
+	if limit > 0 {
+		builder = builder.Limit(uint64(limit))
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search entries: %w", err)
+	}
+	defer rows.Close()
+
+	var results []service.SearchResult
+	for rows.Next() {
+		var result service.SearchResult
+		var rank float32
+
+		result.Entry, err = scanEntry(rows, &rank, &result.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		result.Rank = float64(rank)
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return results, nil
+}
+
+// escapeHTML wraps a SQL text expression to escape it as html.EscapeString does, so the
+// markup ts_headline adds is the only markup in a snippet
+func escapeHTML(expr string) string {
+	// & goes first so the entities added after it aren't escaped again
+	for _, r := range [][2]string{{"&", "&amp;"}, {"'", "&#39;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&#34;"}} {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+	return expr
+}
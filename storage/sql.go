@@ -10,22 +10,32 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
 )
 
 const (
-	ENTRIES_TABLE = "entries"
+	ENTRIES_TABLE     = "entries"
+	ENTRY_BATCH_TABLE = "entry_batch" // Per-transaction staging table for SaveEntries
 )
 
 // DBConn is an interface for database connections (allows mocking)
 type DBConn interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 	Close(ctx context.Context) error
 }
 
+// pgxExecer is implemented by both DBConn and pgx.Tx
+type pgxExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
 type SQLStorage struct {
-	conn DBConn
-	psql sq.StatementBuilderType
+	conn       DBConn
+	psql       sq.StatementBuilderType
+	connString string // For the dedicated connection Subscribe listens on
 }
 
 // NewSQLStorage creates a new SQL storage and ensures the table exists
@@ -36,35 +46,30 @@ func NewSQLStorage(ctx context.Context, connString string) (*SQLStorage, error)
 	}
 
 	storage := &SQLStorage{
-		conn: conn,
-		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		conn:       conn,
+		psql:       sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		connString: connString,
 	}
 
-	// Create table if it doesn't exist
-	if err := storage.createTableIfNotExists(ctx); err != nil {
+	// Bring the schema up to date, refusing databases migrated by a newer zenzen
+	if err := storage.migrate(ctx); err != nil {
 		conn.Close(ctx)
-		return nil, fmt.Errorf("failed to create table: %w", err)
+		return nil, err
 	}
 
 	return storage, nil
 }
 
-// createTableIfNotExists creates the entries table if it doesn't already exist
-func (s *SQLStorage) createTableIfNotExists(ctx context.Context) error {
-	query := `
-		CREATE TABLE IF NOT EXISTS entries (
-			id VARCHAR(255) PRIMARY KEY,
-			title TEXT NOT NULL,
-			tags TEXT[],
-			started_at_timestamp TIMESTAMPTZ,
-			ended_at_timestamp TIMESTAMPTZ,
-			last_modified_timestamp TIMESTAMPTZ,
-			estimated_duration BIGINT,
-			body TEXT
-		)
-	`
-	_, err := s.conn.Exec(ctx, query)
-	return err
+// migrate applies any pending migrations
+func (s *SQLStorage) migrate(ctx context.Context) error {
+	migrator, err := newMigrator(&pgSchema{conn: s.conn}, dialectPostgres)
+	if err != nil {
+		return err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
 }
 
 // Close closes the database connection
@@ -72,13 +77,12 @@ func (s *SQLStorage) Close(ctx context.Context) error {
 	return s.conn.Close(ctx)
 }
 
-// GetAll retrieves all entries from the database
-func (s *SQLStorage) GetAll() (map[string]core.Entry, error) {
-	ctx := context.Background()
-
+// GetAll retrieves all of the context owner's entries from the database
+func (s *SQLStorage) GetAll(ctx context.Context) (map[string]core.Entry, error) {
 	query, args, err := s.psql.
-		Select("id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body").
+		Select(entryColumns...).
 		From(ENTRIES_TABLE).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx)}).
 		ToSql()
 
 	if err != nil {
@@ -94,87 +98,313 @@ func (s *SQLStorage) GetAll() (map[string]core.Entry, error) {
 	entries := make(map[string]core.Entry)
 
 	for rows.Next() {
-		var entry core.Entry
-		var tags []string
-		var startedAt, endedAt, lastModified pgtype.Timestamptz
-		var estimatedDuration int64
-
-		err := rows.Scan(
-			&entry.ID,
-			&entry.Title,
-			&tags,
-			&startedAt,
-			&endedAt,
-			&lastModified,
-			&estimatedDuration,
-			&entry.Body,
-		)
+		entry, err := scanEntry(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return nil, err
 		}
+		entries[entry.ID] = entry
+	}
 
-		entry.Tags = tags
-		if startedAt.Valid {
-			entry.StartedAtTimestamp = startedAt.Time
-		}
-		if endedAt.Valid {
-			entry.EndedAtTimestamp = endedAt.Time
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Query returns one page of entries, filtered and sorted by the database
+func (s *SQLStorage) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	owner := service.OwnerFromContext(ctx)
+	builder, err := buildEntryQuery(s.psql, dialectPostgres, owner, &q)
+	if err != nil {
+		return service.QueryResult{}, err
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return service.QueryResult{}, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return service.QueryResult{}, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []core.Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return service.QueryResult{}, err
 		}
-		if lastModified.Valid {
-			entry.LastModifiedTimestamp = lastModified.Time
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return service.QueryResult{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	result := pageEntries(q, entries)
+	if q.Count {
+		if result.Total, err = s.countEntries(ctx, owner, q); err != nil {
+			return service.QueryResult{}, err
 		}
-		entry.EstimatedDuration = time.Duration(estimatedDuration)
+	}
+	return result, nil
+}
 
-		entries[entry.ID] = entry
+// countEntries counts the owner's entries that pass the query's filters, on any page
+func (s *SQLStorage) countEntries(ctx context.Context, owner string, q service.Query) (int, error) {
+	query, args, err := buildCountQuery(s.psql, dialectPostgres, owner, q).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count entries: %w", err)
 	}
+	defer rows.Close()
 
+	var total int
+	if rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			return 0, fmt.Errorf("failed to scan entry count: %w", err)
+		}
+	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		return 0, fmt.Errorf("failed to count entries: %w", err)
 	}
+	return total, nil
+}
 
-	return entries, nil
+// scanEntry reads a row selected with entryColumns, followed by any extra columns
+func scanEntry(rows pgx.Rows, extra ...any) (core.Entry, error) {
+	var entry core.Entry
+	var tags []string
+	var startedAt, endedAt, lastModified pgtype.Timestamptz
+	var estimatedDuration int64
+
+	dest := []any{
+		&entry.ID,
+		&entry.Title,
+		&tags,
+		&startedAt,
+		&endedAt,
+		&lastModified,
+		&estimatedDuration,
+		&entry.Body,
+		&entry.Version,
+	}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return core.Entry{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	entry.Tags = tags
+	if startedAt.Valid {
+		entry.StartedAtTimestamp = startedAt.Time
+	}
+	if endedAt.Valid {
+		entry.EndedAtTimestamp = endedAt.Time
+	}
+	if lastModified.Valid {
+		entry.LastModifiedTimestamp = lastModified.Time
+	}
+	entry.EstimatedDuration = time.Duration(estimatedDuration)
+
+	return entry, nil
 }
 
-// SaveEntry inserts or updates a single entry
+// SaveEntry inserts or updates a single entry, checking its version when set
 // Note: LastModifiedTimestamp should be set by the caller before calling this method
-func (s *SQLStorage) SaveEntry(entry core.Entry) error {
-	ctx := context.Background()
+func (s *SQLStorage) SaveEntry(ctx context.Context, entry core.Entry) error {
+	_, err := s.SaveEntryVersion(ctx, entry)
+	return err
+}
 
-	query, args, err := s.psql.
-		Insert(ENTRIES_TABLE).
-		Columns("id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body").
-		Values(
-			entry.ID,
-			entry.Title,
-			entry.Tags,
-			entry.StartedAtTimestamp,
-			entry.EndedAtTimestamp,
-			entry.LastModifiedTimestamp,
-			int64(entry.EstimatedDuration),
-			entry.Body,
-		).
-		Suffix("ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, tags = EXCLUDED.tags, started_at_timestamp = EXCLUDED.started_at_timestamp, ended_at_timestamp = EXCLUDED.ended_at_timestamp, last_modified_timestamp = EXCLUDED.last_modified_timestamp, estimated_duration = EXCLUDED.estimated_duration, body = EXCLUDED.body").
-		ToSql()
+// SaveEntryVersion saves an entry as SaveEntry does, returning the version the statement stored
+func (s *SQLStorage) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	query, args, err := saveEntryQuery(s.psql, service.OwnerFromContext(ctx), entry, pgEntryValues(entry)).ToSql()
 
 	if err != nil {
-		return fmt.Errorf("failed to build insert query: %w", err)
+		return 0, fmt.Errorf("failed to build save query: %w", err)
 	}
 
-	_, err = s.conn.Exec(ctx, query, args...)
+	var version int64
+	err = s.writeWithDeliveries(ctx, func(db pgxExecer) error {
+		rows, err := db.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to save entry: %w", err)
+		}
+		defer rows.Close()
+
+		saved := false
+		if rows.Next() {
+			if err := rows.Scan(&version); err != nil {
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			saved = true
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to save entry: %w", err)
+		}
+		if !saved {
+			return fmt.Errorf("%w: entry %s", service.ErrConflict, entry.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// SaveEntries saves entries in one transaction. They are copied into a temporary table,
+// then written with one upsert for zero versions and one conditional update for the rest.
+// If any stored version has moved on, nothing is saved and the error names the entry.
+func (s *SQLStorage) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	return s.WriteEntries(ctx, entries, nil)
+}
+
+// WriteEntries saves and deletes in one transaction, saving as SaveEntries does. If any
+// statement or version check fails, nothing is written.
+func (s *SQLStorage) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	saves = service.UniqueEntries(saves)
+	if len(saves) == 0 && len(deletes) == 0 {
+		return nil
+	}
+
+	tx, err := s.conn.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to save entry: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op once committed
+
+	if len(saves) > 0 {
+		if err := s.saveEntries(ctx, tx, saves); err != nil {
+			return err
+		}
+	}
+
+	if len(deletes) > 0 {
+		query, args, err := deleteEntriesQuery(s.psql, service.OwnerFromContext(ctx), deletes).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build delete query: %w", err)
+		}
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+	}
+
+	if err := s.enqueueDeliveries(ctx, tx, service.DeliveriesFromContext(ctx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteEntry removes an entry from the database
-func (s *SQLStorage) DeleteEntry(id string) error {
-	ctx := context.Background()
+// writeWithDeliveries runs write on the connection or, when ctx carries deliveries to queue
+// with it, in a transaction that queues them too
+func (s *SQLStorage) writeWithDeliveries(ctx context.Context, write func(db pgxExecer) error) error {
+	deliveries := service.DeliveriesFromContext(ctx)
+	if len(deliveries) == 0 {
+		return write(s.conn)
+	}
 
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op once committed
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := s.enqueueDeliveries(ctx, tx, deliveries); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
+	}
+
+	return nil
+}
+
+// saveEntries stages entries and writes them within tx
+func (s *SQLStorage) saveEntries(ctx context.Context, tx pgx.Tx, entries []core.Entry) error {
+	_, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s) ON COMMIT DROP", ENTRY_BATCH_TABLE, ENTRIES_TABLE))
+	if err != nil {
+		return fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	owner := service.OwnerFromContext(ctx)
+	rows := make([][]any, 0, len(entries))
+	versioned := make(map[string]bool)
+	for _, entry := range entries {
+		rows = append(rows, append(pgEntryValues(entry), entry.Version, owner))
+		if entry.Version > 0 {
+			versioned[entry.ID] = true
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{ENTRY_BATCH_TABLE}, ownedEntryColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy entries: %w", err)
+	}
+
+	if len(versioned) < len(entries) {
+		query, args, err := upsertBatchQuery(s.psql).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build save query: %w", err)
+		}
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to save entries: %w", err)
+		}
+	}
+
+	if len(versioned) > 0 {
+		query, args, err := updateBatchQuery(s.psql).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build save query: %w", err)
+		}
+		updated, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to save entries: %w", err)
+		}
+		for updated.Next() {
+			var id string
+			if err := updated.Scan(&id); err != nil {
+				updated.Close()
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			delete(versioned, id)
+		}
+		updated.Close()
+		if err := updated.Err(); err != nil {
+			return fmt.Errorf("failed to save entries: %w", err)
+		}
+
+		// Whatever wasn't updated has moved on, or was deleted
+		for _, entry := range entries {
+			if versioned[entry.ID] {
+				return fmt.Errorf("%w: entry %s", service.ErrConflict, entry.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeleteEntry removes one of the context owner's entries from the database
+func (s *SQLStorage) DeleteEntry(ctx context.Context, id string) error {
 	query, args, err := s.psql.
 		Delete(ENTRIES_TABLE).
-		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx), "id": id}).
 		ToSql()
 
 	if err != nil {
@@ -188,3 +418,82 @@ func (s *SQLStorage) DeleteEntry(id string) error {
 
 	return nil
 }
+
+// DeleteEntryVersion removes one of the context owner's entries in a single statement,
+// only while its version matches when version is non-zero
+func (s *SQLStorage) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	query, args, err := deleteEntryVersionQuery(s.psql, service.OwnerFromContext(ctx), id, version).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	return s.writeWithDeliveries(ctx, func(db pgxExecer) error {
+		tag, err := db.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete entry: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return service.NotDeletedError(id, version)
+		}
+		return nil
+	})
+}
+
+// DeleteEntries removes every listed entry of the context owner in a single statement
+func (s *SQLStorage) DeleteEntries(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := deleteEntriesQuery(s.psql, service.OwnerFromContext(ctx), ids).ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	_, err = s.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete entries: %w", err)
+	}
+
+	return nil
+}
+
+// deleteEntryVersionQuery deletes the owner's entry, only at version when it is non-zero
+func deleteEntryVersionQuery(builder sq.StatementBuilderType, owner, id string, version int64) sq.DeleteBuilder {
+	where := sq.Eq{"owner_id": owner, "id": id}
+	if version > 0 {
+		where["version"] = version
+	}
+	return builder.Delete(ENTRIES_TABLE).Where(where)
+}
+
+// deleteEntriesQuery deletes the owner's entries with the listed IDs in a single statement
+func deleteEntriesQuery(builder sq.StatementBuilderType, owner string, ids []string) sq.DeleteBuilder {
+	return builder.
+		Delete(ENTRIES_TABLE).
+		Where(sq.Eq{"owner_id": owner}).
+		Where("id = ANY(?)", ids)
+}
+
+// pgEntryValues lists an entry's values for entryValueColumns
+func pgEntryValues(entry core.Entry) []any {
+	return []any{
+		entry.ID,
+		entry.Title,
+		entry.Tags,
+		nullTime(entry.StartedAtTimestamp),
+		nullTime(entry.EndedAtTimestamp),
+		nullTime(entry.LastModifiedTimestamp),
+		int64(entry.EstimatedDuration),
+		entry.Body,
+	}
+}
+
+// nullTime stores zero timestamps as NULL so they sort last and match IS NULL
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
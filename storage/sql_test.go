@@ -2,15 +2,45 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
 	"github.com/pashagolub/pgxmock/v3"
 	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
 )
 
+// TestSQLStorage_Conformance runs against a real database when ZENZEN_TEST_DATABASE_URL is set.
+// The entries table is emptied before each subtest, so never point it at real data.
+func TestSQLStorage_Conformance(t *testing.T) {
+	connString := os.Getenv("ZENZEN_TEST_DATABASE_URL")
+	if connString == "" {
+		t.Skip("ZENZEN_TEST_DATABASE_URL not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) service.Store {
+		ctx := context.Background()
+		storage, err := NewSQLStorage(ctx, connString)
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		t.Cleanup(func() { storage.Close(ctx) })
+
+		if _, err := storage.conn.Exec(ctx, "DELETE FROM "+ENTRIES_TABLE); err != nil {
+			t.Fatalf("failed to reset entries table: %v", err)
+		}
+		return storage
+	})
+}
+
 func TestSQLStorage_GetAll(t *testing.T) {
+	ctx := context.Background()
 	mock, err := pgxmock.NewConn()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -23,15 +53,16 @@ func TestSQLStorage_GetAll(t *testing.T) {
 	}
 
 	// Mock the query
-	rows := pgxmock.NewRows([]string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body"}).
-		AddRow("1", "K8s", []string{"learning"}, time.Time{}, time.Time{}, time.Now(), int64(0), "Test body").
-		AddRow("2", "System Design", []string{"interviews"}, time.Time{}, time.Time{}, time.Now(), int64(0), "Test body 2")
+	rows := pgxmock.NewRows([]string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body", "version"}).
+		AddRow("1", "K8s", []string{"learning"}, time.Time{}, time.Time{}, time.Now(), int64(0), "Test body", int64(1)).
+		AddRow("2", "System Design", []string{"interviews"}, time.Time{}, time.Time{}, time.Now(), int64(0), "Test body 2", int64(1))
 
-	mock.ExpectQuery(`SELECT id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body FROM entries`).
+	mock.ExpectQuery(`SELECT id, title, tags, started_at_timestamp, ended_at_timestamp, last_modified_timestamp, estimated_duration, body, version FROM entries WHERE owner_id = \$1`).
+		WithArgs(service.DefaultOwner).
 		WillReturnRows(rows)
 
 	// Execute
-	entries, err := storage.GetAll()
+	entries, err := storage.GetAll(ctx)
 
 	// Verify
 	if err != nil {
@@ -52,6 +83,7 @@ func TestSQLStorage_GetAll(t *testing.T) {
 }
 
 func TestSQLStorage_SaveEntry(t *testing.T) {
+	ctx := context.Background()
 	mock, err := pgxmock.NewConn()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -71,17 +103,198 @@ func TestSQLStorage_SaveEntry(t *testing.T) {
 	}
 
 	// Mock the insert/update query - use AnyArg() for LastModifiedTimestamp since it's set dynamically
-	mock.ExpectExec(`INSERT INTO entries`).
-		WithArgs("1", "Test Entry", []string{"test"}, entry.StartedAtTimestamp, entry.EndedAtTimestamp, pgxmock.AnyArg(), int64(0), "Test body").
-		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	// Zero timestamps are stored as NULL
+	// The upsert reports the version it stored, so a concurrent write can't be mistaken for ours
+	mock.ExpectQuery(`INSERT INTO entries (.+) ON CONFLICT \(owner_id, id\) DO UPDATE SET (.+) RETURNING entries.version`).
+		WithArgs("1", "Test Entry", []string{"test"}, nil, nil, pgxmock.AnyArg(), int64(0), "Test body", int64(1), service.DefaultOwner).
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(int64(4)))
 
 	// Execute
-	err = storage.SaveEntry(entry)
+	version, err := storage.SaveEntryVersion(ctx, entry)
 
 	// Verify
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	if version != 4 {
+		t.Errorf("SaveEntryVersion() = %d; want the version returned, 4", version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_SaveEntryWithDeliveries(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// The deliveries are queued in the entry's transaction, and a queue failure rolls the entry back
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO entries (.+) RETURNING entries.version`).
+		WithArgs("1", "Test Entry", pgxmock.AnyArg(), nil, nil, pgxmock.AnyArg(), int64(0), "", int64(1), service.DefaultOwner).
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(int64(1)))
+	mock.ExpectExec(`INSERT INTO webhook_deliveries`).
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	ctx := service.WithDeliveries(context.Background(), []service.Delivery{{ID: "d1", SubscriptionID: "all", Event: service.WebhookCreated, EntryID: "1"}})
+	if _, err := storage.SaveEntryVersion(ctx, core.Entry{ID: "1", Title: "Test Entry"}); err == nil {
+		t.Fatalf("SaveEntryVersion() error = nil; want the queue failure")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_SaveEntryConflict(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	entry := core.Entry{ID: "1", Title: "Stale edit", Version: 3}
+
+	// A versioned save only updates the row still at that version; no rows means someone else won
+	mock.ExpectQuery(`UPDATE entries SET title = \$1, (.+), version = version \+ 1 WHERE id = \$8 AND owner_id = \$9 AND version = \$10 RETURNING version`).
+		WithArgs("Stale edit", []string(nil), nil, nil, nil, int64(0), "", "1", service.DefaultOwner, int64(3)).
+		WillReturnRows(pgxmock.NewRows([]string{"version"}))
+
+	err = storage.SaveEntry(ctx, entry)
+	if !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_SaveEntries(t *testing.T) {
+	ctx := context.Background()
+
+	newStorage := func(t *testing.T) (*SQLStorage, pgxmock.PgxConnIface) {
+		mock, err := pgxmock.NewConn()
+		if err != nil {
+			t.Fatalf("failed to create mock: %v", err)
+		}
+		t.Cleanup(func() { mock.Close(context.TODO()) })
+
+		return &SQLStorage{
+			conn: mock,
+			psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		}, mock
+	}
+
+	entries := []core.Entry{
+		{ID: "new", Title: "Imported"},
+		{ID: "1", Title: "Renamed tag", Version: 2},
+	}
+
+	// Every entry goes through one COPY, then one upsert and one conditional update
+	expectStaging := func(mock pgxmock.PgxConnIface) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`CREATE TEMP TABLE entry_batch \(LIKE entries\) ON COMMIT DROP`).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		mock.ExpectCopyFrom(pgx.Identifier{ENTRY_BATCH_TABLE}, ownedEntryColumns).
+			WillReturnResult(2)
+		mock.ExpectExec(`INSERT INTO entries \((.+)\) SELECT (.+), 1, owner_id FROM entry_batch WHERE version = \$1 ON CONFLICT \(owner_id, id\) DO UPDATE SET (.+), version = entries.version \+ 1`).
+			WithArgs(0).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	}
+	updateBatch := `UPDATE entries SET title = b.title, (.+), version = entries.version \+ 1 FROM entry_batch b WHERE entries.owner_id = b.owner_id AND entries.id = b.id AND b.version > 0 AND entries.version = b.version RETURNING entries.id`
+
+	t.Run("commits", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		expectStaging(mock)
+		mock.ExpectQuery(updateBatch).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow("1"))
+		mock.ExpectCommit()
+
+		if err := storage.SaveEntries(ctx, entries); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("rolls back on conflict", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		expectStaging(mock)
+		mock.ExpectQuery(updateBatch).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		err := storage.SaveEntries(ctx, entries)
+		if !errors.Is(err, service.ErrConflict) || !strings.Contains(err.Error(), "entry 1") {
+			t.Fatalf("Expected ErrConflict for entry 1, got %v", err)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("write entries rolls back when the delete fails", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		expectStaging(mock)
+		mock.ExpectQuery(updateBatch).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow("1"))
+		mock.ExpectExec(`DELETE FROM entries WHERE owner_id = \$1 AND id = ANY\(\$2\)`).
+			WithArgs(service.DefaultOwner, []string{"2"}).
+			WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		if err := storage.WriteEntries(ctx, entries, []string{"2"}); err == nil {
+			t.Fatalf("Expected the delete's error, got nil")
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestSQLStorage_DeleteEntries(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// One statement, however many IDs
+	mock.ExpectExec(`DELETE FROM entries WHERE owner_id = \$1 AND id = ANY\(\$2\)`).
+		WithArgs(service.DefaultOwner, []string{"1", "2", "3"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+
+	if err := storage.DeleteEntries(ctx, []string{"1", "2", "3"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("unfulfilled expectations: %v", err)
@@ -89,6 +302,7 @@ func TestSQLStorage_SaveEntry(t *testing.T) {
 }
 
 func TestSQLStorage_DeleteEntry(t *testing.T) {
+	ctx := context.Background()
 	mock, err := pgxmock.NewConn()
 	if err != nil {
 		t.Fatalf("failed to create mock: %v", err)
@@ -100,13 +314,13 @@ func TestSQLStorage_DeleteEntry(t *testing.T) {
 		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
 	}
 
-	// Mock the delete query
-	mock.ExpectExec(`DELETE FROM entries WHERE id = \$1`).
-		WithArgs("1").
+	// Only the owner's entry with that ID is deleted
+	mock.ExpectExec(`DELETE FROM entries WHERE id = \$1 AND owner_id = \$2`).
+		WithArgs("1", "alice").
 		WillReturnResult(pgxmock.NewResult("DELETE", 1))
 
 	// Execute
-	err = storage.DeleteEntry("1")
+	err = storage.DeleteEntry(service.WithOwner(ctx, "alice"), "1")
 
 	// Verify
 	if err != nil {
@@ -117,3 +331,446 @@ func TestSQLStorage_DeleteEntry(t *testing.T) {
 		t.Errorf("unfulfilled expectations: %v", err)
 	}
 }
+
+func TestSQLStorage_DeleteEntryVersion(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// The version check is part of the delete, so nothing can change in between
+	mock.ExpectExec(`DELETE FROM entries WHERE id = \$1 AND owner_id = \$2 AND version = \$3`).
+		WithArgs("1", service.DefaultOwner, int64(3)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	if err := storage.DeleteEntryVersion(ctx, "1", 3); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_Query(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	columns := []string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body", "version"}
+	started := time.Date(2025, 12, 20, 9, 0, 0, 0, time.UTC)
+	rows := pgxmock.NewRows(columns).
+		AddRow("3", "K8s operators", []string{"learning"}, started, nil, started, int64(0), "", int64(1)).
+		AddRow("2", "K8s networking", []string{"learning"}, started.Add(-time.Hour), nil, started, int64(0), "", int64(1)).
+		AddRow("1", "K8s basics", []string{"learning"}, started.Add(-2*time.Hour), nil, started, int64(0), "", int64(1))
+
+	// Filters, ordering and the page size (plus one) are all pushed down to SQL
+	inProgress := true
+	mock.ExpectQuery(`SELECT (.+) FROM entries WHERE owner_id = \$1 AND EXISTS \(SELECT 1 FROM unnest\(tags\) AS tag WHERE tag = \$2 OR starts_with\(tag, \$3\)\) AND \(title ILIKE \$4 OR body ILIKE \$5\) AND ended_at_timestamp IS NULL ORDER BY started_at_timestamp DESC NULLS LAST, id DESC LIMIT 3`).
+		WithArgs(service.DefaultOwner, "learning", "learning/", `%k8s\_%`, `%k8s\_%`).
+		WillReturnRows(rows)
+
+	// The total counts every match, so it ignores the page size
+	mock.ExpectQuery(`SELECT count\(\*\) FROM entries WHERE owner_id = \$1 AND EXISTS (.+) AND ended_at_timestamp IS NULL$`).
+		WithArgs(service.DefaultOwner, "learning", "learning/", `%k8s\_%`, `%k8s\_%`).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(3))
+
+	q := service.Query{Tags: []string{"learning"}, Text: "k8s_", InProgress: &inProgress, Limit: 2, Count: true}
+	result, err := storage.Query(ctx, q)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Total != 3 {
+		t.Errorf("Expected a total of 3, got %d", result.Total)
+	}
+
+	if len(result.Entries) != 2 || result.Entries[0].ID != "3" || result.Entries[1].ID != "2" {
+		t.Fatalf("Expected entries 3 and 2, got %+v", result.Entries)
+	}
+	if result.NextCursor == "" {
+		t.Fatalf("Expected a cursor for the next page")
+	}
+
+	// The next page resumes after the last entry returned
+	mock.ExpectQuery(`AND \(started_at_timestamp < \$6 OR \(started_at_timestamp = \$7 AND id < \$8\) OR started_at_timestamp IS NULL\)`).
+		WithArgs(service.DefaultOwner, "learning", "learning/", `%k8s\_%`, `%k8s\_%`, started.Add(-time.Hour), started.Add(-time.Hour), "2").
+		WillReturnRows(pgxmock.NewRows(columns).
+			AddRow("1", "K8s basics", []string{"learning"}, started.Add(-2*time.Hour), nil, started, int64(0), "", int64(1)))
+
+	q.Cursor = result.NextCursor
+	q.Count = false
+	result, err = storage.Query(ctx, q)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result.Entries) != 1 || result.NextCursor != "" {
+		t.Errorf("Expected a final page with one entry, got %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_Search(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// The body is escaped as HTML before highlighting, so the snippet's only markup is <mark>
+	rows := pgxmock.NewRows([]string{"id", "title", "tags", "started_at_timestamp", "ended_at_timestamp", "last_modified_timestamp", "estimated_duration", "body", "version", "rank", "snippet"}).
+		AddRow("1", "K8s", []string{"learning"}, nil, nil, time.Now(), int64(0), "Migrated services to Kubernetes", int64(1), float32(0.6), "Migrated services to <mark>Kubernetes</mark>").
+		AddRow("2", "Helm", []string{"learning"}, nil, nil, time.Now(), int64(0), "Charts for kubernetes", int64(1), float32(0.2), "Charts for <mark>kubernetes</mark>")
+
+	mock.ExpectQuery(`SELECT (.+), ts_rank\(search_vector, search_query\) AS rank, ts_headline\('english', (.+)replace\(coalesce\(body, ''\), '&', '&amp;'\)(.+)\) AS snippet FROM entries CROSS JOIN websearch_to_tsquery\('english', \$1\) AS search_query WHERE owner_id = \$2 AND search_vector @@ search_query ORDER BY rank DESC, id LIMIT 10`).
+		WithArgs("kubernetes", service.DefaultOwner).
+		WillReturnRows(rows)
+
+	results, err := storage.Search(ctx, "kubernetes", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(results) != 2 || results[0].Entry.ID != "1" || results[1].Entry.ID != "2" {
+		t.Fatalf("Expected entries 1 then 2, got %+v", results)
+	}
+	if results[0].Snippet != "Migrated services to <mark>Kubernetes</mark>" {
+		t.Errorf("Snippet = %q", results[0].Snippet)
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("Expected results ordered by rank, got %v then %v", results[0].Rank, results[1].Rank)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_ListTags(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// Counts come from unnesting entries.tags; metadata is joined from the tags table
+	mock.ExpectQuery(`SELECT coalesce\(u.name, t.name\) AS name, (.+) FROM \(SELECT tag AS name, count\(DISTINCT id\) AS count FROM entries CROSS JOIN unnest\(tags\) AS tag WHERE owner_id = \$1 GROUP BY tag\) AS u FULL OUTER JOIN \(SELECT name, description, color FROM tags WHERE owner_id = \$2\) AS t ON t.name = u.name ORDER BY name`).
+		WithArgs(service.DefaultOwner, service.DefaultOwner).
+		WillReturnRows(pgxmock.NewRows([]string{"name", "count", "description", "color"}).
+			AddRow("go", int64(3), "", "").
+			AddRow("work", int64(0), "Day job", "#FF8800"))
+
+	tags, err := storage.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(tags) != 2 {
+		t.Fatalf("Expected 2 tags, got %d", len(tags))
+	}
+	if tags[0].Name != "go" || tags[0].Count != 3 {
+		t.Errorf("Expected go with 3 entries, got %+v", tags[0])
+	}
+	if tags[1].Count != 0 || tags[1].Description != "Day job" || tags[1].Color != "#FF8800" {
+		t.Errorf("Expected unused work tag with metadata, got %+v", tags[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_GetAllHonoursDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// A slow query must be abandoned once the deadline passes
+	mock.ExpectQuery(`SELECT (.+) FROM entries`).
+		WithArgs(service.DefaultOwner).
+		WillReturnRows(pgxmock.NewRows([]string{"id"})).
+		WillDelayFor(time.Second)
+
+	start := time.Now()
+	_, err = storage.GetAll(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("GetAll returned after %v; expected it to stop at the deadline", elapsed)
+	}
+}
+
+func TestSQLStorage_RecordSyncRun(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	started := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	run := service.SyncRun{
+		StartedAt: started,
+		EndedAt:   started.Add(time.Second),
+		Pushed:    1,
+		Entries: []service.SyncRunEntry{
+			{EntryID: "1", Direction: service.SyncDirectionPush, SyncedAt: started},
+		},
+	}
+
+	// The run and its entries are written together, the entries with one COPY
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO sync_runs \(started_at,ended_at,pushed_count,pulled_count,conflict_count,errors\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\) RETURNING id`).
+		WithArgs(run.StartedAt, run.EndedAt, 1, 0, 0, run.Errors).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int64(7)))
+	mock.ExpectCopyFrom(pgx.Identifier{SYNC_RUN_ENTRIES_TABLE}, syncRunEntryColumns).
+		WillReturnResult(1)
+	mock.ExpectCommit()
+
+	// Execute
+	err = storage.RecordSyncRun(ctx, run)
+
+	// Verify
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_SyncRunEntries(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// Every run's records in one query, not one per entry
+	synced := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT run_id, entry_id, direction, synced_at, error FROM sync_run_entries WHERE run_id = ANY\(\$1\) ORDER BY run_id, synced_at`).
+		WithArgs([]int64{3, 7}).
+		WillReturnRows(pgxmock.NewRows([]string{"run_id", "entry_id", "direction", "synced_at", "error"}).
+			AddRow(int64(3), "1", service.SyncDirectionPush, synced, nil).
+			AddRow(int64(7), "2", service.SyncDirectionPull, synced, "boom"))
+
+	records, err := storage.SyncRunEntries(ctx, []int64{3, 7})
+	if err != nil || len(records) != 2 || records[1].Error != "boom" {
+		t.Fatalf("SyncRunEntries() = %+v, %v; want both runs' records", records, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_ReassignOwner(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	// Entries and tag metadata move together, skipping keys the new owner already has
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE entries SET owner_id = \$1 WHERE owner_id = \$2 AND id NOT IN \(SELECT id FROM entries WHERE owner_id = \$3\)`).
+		WithArgs("alice", service.DefaultOwner, "alice").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 5))
+	mock.ExpectExec(`UPDATE tags SET owner_id = \$1 WHERE owner_id = \$2 AND name NOT IN \(SELECT name FROM tags WHERE owner_id = \$3\)`).
+		WithArgs("alice", service.DefaultOwner, "alice").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+	mock.ExpectCommit()
+
+	moved, err := storage.ReassignOwner(ctx, service.DefaultOwner, "alice")
+	if err != nil || moved != 5 {
+		t.Fatalf("ReassignOwner() = %d, %v; want 5 entries moved", moved, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_CreateWorkspace(t *testing.T) {
+	ctx := context.Background()
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	workspace := service.Workspace{ID: "ab12", Name: "Team", CreatedAt: now}
+	admin := service.Member{PrincipalID: "alice", Role: service.RoleAdmin, AddedAt: now}
+
+	// The workspace is never left without its admin
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO workspaces \(id,name,created_at\) VALUES \(\$1,\$2,\$3\)`).
+		WithArgs("ab12", "Team", now).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(`INSERT INTO workspace_members \(workspace_id,principal_id,role,added_at\) VALUES \(\$1,\$2,\$3,\$4\) ON CONFLICT \(workspace_id, principal_id\) DO UPDATE SET role = excluded.role`).
+		WithArgs("ab12", "alice", "admin", now).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	if err := storage.CreateWorkspace(ctx, workspace, admin); err == nil {
+		t.Fatal("CreateWorkspace() error = nil; want the failed admin insert")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_Timer(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	ctx := service.WithOwner(context.Background(), "alice")
+	since := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	// Saving replaces the owner's one timer
+	mock.ExpectExec(`INSERT INTO timers \(owner_id,entry_id,state,since\) VALUES \(\$1,\$2,\$3,\$4\) ON CONFLICT \(owner_id\) DO UPDATE SET entry_id = excluded.entry_id, state = excluded.state, since = excluded.since`).
+		WithArgs("alice", "42", "running", since).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	if err := storage.SaveTimer(ctx, service.Timer{EntryID: "42", State: service.TimerRunning, Since: since}); err != nil {
+		t.Fatalf("SaveTimer() error = %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT entry_id, state, since FROM timers WHERE owner_id = \$1`).
+		WithArgs("alice").
+		WillReturnRows(pgxmock.NewRows([]string{"entry_id", "state", "since"}).AddRow("42", "paused", since))
+	timer, ok, err := storage.GetTimer(ctx)
+	if err != nil || !ok {
+		t.Fatalf("GetTimer() = %v, %v; want a timer", ok, err)
+	}
+	if timer.EntryID != "42" || timer.State != service.TimerPaused || !timer.Since.Equal(since) {
+		t.Errorf("GetTimer() = %+v; want entry 42 paused", timer)
+	}
+
+	// Claiming is an upsert that leaves another entry's running timer alone
+	claim := `INSERT INTO timers \(owner_id,entry_id,state,since\) VALUES \(\$1,\$2,\$3,\$4\) ON CONFLICT \(owner_id\) DO UPDATE SET entry_id = excluded.entry_id, state = excluded.state, since = excluded.since WHERE timers.state <> \$5 OR timers.entry_id IN \(\$6, \$7\)`
+	mock.ExpectExec(claim).
+		WithArgs("alice", "7", "running", since, "running", "7", "42").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	if err := storage.ClaimTimer(ctx, service.Timer{EntryID: "7", State: service.TimerRunning, Since: since}, "42"); err != nil {
+		t.Errorf("ClaimTimer() error = %v", err)
+	}
+	mock.ExpectExec(claim).
+		WithArgs("alice", "8", "running", since, "running", "8", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	if err := storage.ClaimTimer(ctx, service.Timer{EntryID: "8", State: service.TimerRunning, Since: since}, ""); !errors.Is(err, service.ErrTimerRunning) {
+		t.Errorf("ClaimTimer() error = %v; want ErrTimerRunning", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestSQLStorage_ClaimDeliveries(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	if err != nil {
+		t.Fatalf("failed to create mock: %v", err)
+	}
+	defer mock.Close(context.TODO())
+
+	storage := &SQLStorage{
+		conn: mock,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	queued := now.Add(-time.Minute)
+
+	// Due deliveries are leased in one statement, skipping rows another dispatcher holds
+	mock.ExpectQuery(`UPDATE webhook_deliveries SET next_attempt_at = \$1 WHERE id IN \(SELECT id FROM webhook_deliveries WHERE status = \$2 AND next_attempt_at <= \$3 ORDER BY next_attempt_at, created_at LIMIT 10 FOR UPDATE SKIP LOCKED\) RETURNING id, subscription_id, event, owner_id, entry_id, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at`).
+		WithArgs(now.Add(time.Minute), "pending", now).
+		WillReturnRows(pgxmock.NewRows(deliveryColumns).
+			AddRow("d2", "chat", "updated", "alice", "42", `{"event":"updated"}`, "pending", 1, now.Add(time.Minute), "endpoint answered 503", 503, queued.Add(time.Second), nil).
+			AddRow("d1", "chat", "created", "alice", "42", `{"event":"created"}`, "pending", 0, now.Add(time.Minute), "", 0, queued, nil))
+
+	deliveries, err := storage.ClaimDeliveries(context.Background(), now, time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimDeliveries() error = %v", err)
+	}
+	if len(deliveries) != 2 || deliveries[0].ID != "d1" || deliveries[1].Event != service.WebhookUpdated || deliveries[1].ResponseStatus != 503 {
+		t.Errorf("ClaimDeliveries() = %+v; want d1 then d2, oldest first", deliveries)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
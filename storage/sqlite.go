@@ -0,0 +1,672 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// sqliteTimeFormat is fixed-width so timestamps sort correctly as text
+	sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+	// sqliteMaxParams keeps statements well under SQLite's bound parameter limit
+	sqliteMaxParams = 500
+)
+
+// SQLiteStorage stores entries in a local SQLite file.
+// It uses the same table layout as SQLStorage so the two are interchangeable.
+type SQLiteStorage struct {
+	db   *sql.DB
+	psql sq.StatementBuilderType
+}
+
+// NewSQLiteStorage opens (or creates) the SQLite database at path and ensures the tables exist
+func NewSQLiteStorage(ctx context.Context, path string) (*SQLiteStorage, error) {
+	db, err := openSQLite(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := &SQLiteStorage{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Question),
+	}
+
+	// Bring the schema up to date, refusing databases migrated by a newer zenzen
+	migrator, err := newMigrator(&sqliteSchema{db: db}, dialectSQLite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	return storage, nil
+}
+
+// openSQLite opens the database file with the pragmas zenzen relies on
+func openSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no sqlite database path configured")
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; serialise access through one connection
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// GetAll retrieves all of the context owner's entries from the database
+func (s *SQLiteStorage) GetAll(ctx context.Context) (map[string]core.Entry, error) {
+	query, args, err := s.psql.
+		Select(entryColumns...).
+		From(ENTRIES_TABLE).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx)}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[string]core.Entry)
+
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries[entry.ID] = entry
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Query returns one page of entries, filtered and sorted by the database
+func (s *SQLiteStorage) Query(ctx context.Context, q service.Query) (service.QueryResult, error) {
+	owner := service.OwnerFromContext(ctx)
+	builder, err := buildEntryQuery(s.psql, dialectSQLite, owner, &q)
+	if err != nil {
+		return service.QueryResult{}, err
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return service.QueryResult{}, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return service.QueryResult{}, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []core.Entry
+	for rows.Next() {
+		entry, err := scanSQLiteEntry(rows)
+		if err != nil {
+			return service.QueryResult{}, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return service.QueryResult{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	result := pageEntries(q, entries)
+	if q.Count {
+		if result.Total, err = s.countEntries(ctx, owner, q); err != nil {
+			return service.QueryResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// countEntries counts the owner's entries that pass the query's filters, on any page
+func (s *SQLiteStorage) countEntries(ctx context.Context, owner string, q service.Query) (int, error) {
+	query, args, err := buildCountQuery(s.psql, dialectSQLite, owner, q).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count entries: %w", err)
+	}
+	return total, nil
+}
+
+// scanSQLiteEntry reads a row selected with entryColumns
+func scanSQLiteEntry(rows *sql.Rows) (core.Entry, error) {
+	var entry core.Entry
+	var tags, startedAt, endedAt, lastModified, body sql.NullString
+	var estimatedDuration sql.NullInt64
+
+	err := rows.Scan(
+		&entry.ID,
+		&entry.Title,
+		&tags,
+		&startedAt,
+		&endedAt,
+		&lastModified,
+		&estimatedDuration,
+		&body,
+		&entry.Version,
+	)
+	if err != nil {
+		return core.Entry{}, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &entry.Tags); err != nil {
+			return core.Entry{}, fmt.Errorf("failed to decode tags for entry %s: %w", entry.ID, err)
+		}
+	}
+	if entry.StartedAtTimestamp, err = parseSQLiteTime(startedAt); err != nil {
+		return core.Entry{}, err
+	}
+	if entry.EndedAtTimestamp, err = parseSQLiteTime(endedAt); err != nil {
+		return core.Entry{}, err
+	}
+	if entry.LastModifiedTimestamp, err = parseSQLiteTime(lastModified); err != nil {
+		return core.Entry{}, err
+	}
+	entry.EstimatedDuration = time.Duration(estimatedDuration.Int64)
+	entry.Body = body.String
+
+	return entry, nil
+}
+
+// sqliteExecer is implemented by both *sql.DB and *sql.Tx
+type sqliteExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// SaveEntry inserts or updates a single entry, checking its version when set
+// Note: LastModifiedTimestamp should be set by the caller before calling this method
+func (s *SQLiteStorage) SaveEntry(ctx context.Context, entry core.Entry) error {
+	_, err := s.saveEntry(ctx, s.db, entry)
+	return err
+}
+
+// SaveEntryVersion saves an entry as SaveEntry does, returning the version the statement stored
+func (s *SQLiteStorage) SaveEntryVersion(ctx context.Context, entry core.Entry) (int64, error) {
+	var version int64
+	err := s.writeWithDeliveries(ctx, func(db sqliteExecer) (err error) {
+		version, err = s.saveEntry(ctx, db, entry)
+		return err
+	})
+	return version, err
+}
+
+// SaveEntries saves entries in one transaction, rolling back if any version check fails
+func (s *SQLiteStorage) SaveEntries(ctx context.Context, entries []core.Entry) error {
+	return s.WriteEntries(ctx, entries, nil)
+}
+
+// WriteEntries saves and deletes in one transaction, rolling back if any statement or
+// version check fails. IDs are deleted in chunks to stay under SQLite's limit on bound parameters.
+func (s *SQLiteStorage) WriteEntries(ctx context.Context, saves []core.Entry, deletes []string) error {
+	saves = service.UniqueEntries(saves)
+	if len(saves) == 0 && len(deletes) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	for _, entry := range saves {
+		if _, err := s.saveEntry(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+
+	for chunk := range slices.Chunk(deletes, sqliteMaxParams) {
+		query, args, err := s.psql.
+			Delete(ENTRIES_TABLE).
+			Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx), "id": chunk}).
+			ToSql()
+
+		if err != nil {
+			return fmt.Errorf("failed to build delete query: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to delete entries: %w", err)
+		}
+	}
+
+	if err := s.enqueueDeliveries(ctx, tx, service.DeliveriesFromContext(ctx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
+	}
+
+	return nil
+}
+
+// writeWithDeliveries runs write on the database or, when ctx carries deliveries to queue
+// with it, in a transaction that queues them too
+func (s *SQLiteStorage) writeWithDeliveries(ctx context.Context, write func(db sqliteExecer) error) error {
+	deliveries := service.DeliveriesFromContext(ctx)
+	if len(deliveries) == 0 {
+		return write(s.db)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	if err := write(tx); err != nil {
+		return err
+	}
+	if err := s.enqueueDeliveries(ctx, tx, deliveries); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit entries: %w", err)
+	}
+
+	return nil
+}
+
+// saveEntry writes one entry through db, which may be a transaction, returning its new version
+func (s *SQLiteStorage) saveEntry(ctx context.Context, db sqliteExecer, entry core.Entry) (int64, error) {
+	tags, err := encodeSQLiteTags(entry.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err := saveEntryQuery(s.psql, service.OwnerFromContext(ctx), entry, []any{
+		entry.ID,
+		entry.Title,
+		tags,
+		formatSQLiteTime(entry.StartedAtTimestamp),
+		formatSQLiteTime(entry.EndedAtTimestamp),
+		formatSQLiteTime(entry.LastModifiedTimestamp),
+		int64(entry.EstimatedDuration),
+		entry.Body,
+	}).ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to build save query: %w", err)
+	}
+
+	var version int64
+	err = db.QueryRowContext(ctx, query, args...).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: entry %s", service.ErrConflict, entry.ID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save entry: %w", err)
+	}
+
+	return version, nil
+}
+
+// DeleteEntry removes one of the context owner's entries from the database
+func (s *SQLiteStorage) DeleteEntry(ctx context.Context, id string) error {
+	query, args, err := s.psql.
+		Delete(ENTRIES_TABLE).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx), "id": id}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete entry: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteEntryVersion removes one of the context owner's entries in a single statement,
+// only while its version matches when version is non-zero
+func (s *SQLiteStorage) DeleteEntryVersion(ctx context.Context, id string, version int64) error {
+	query, args, err := deleteEntryVersionQuery(s.psql, service.OwnerFromContext(ctx), id, version).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	return s.writeWithDeliveries(ctx, func(db sqliteExecer) error {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to delete entry: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to read rows affected: %w", err)
+		}
+		if affected == 0 {
+			return service.NotDeletedError(id, version)
+		}
+		return nil
+	})
+}
+
+// DeleteEntries removes every listed entry of the context owner in one transaction
+func (s *SQLiteStorage) DeleteEntries(ctx context.Context, ids []string) error {
+	return s.WriteEntries(ctx, nil, ids)
+}
+
+// RecordSyncRun stores a finished sync run and the entries it touched in one transaction.
+// Entry records are inserted in chunks to stay under SQLite's limit on bound parameters.
+func (s *SQLiteStorage) RecordSyncRun(ctx context.Context, run service.SyncRun) error {
+	errs, err := encodeSQLiteTags(run.Errors)
+	if err != nil {
+		return err
+	}
+
+	query, args, err := s.psql.
+		Insert(SYNC_RUNS_TABLE).
+		Columns("started_at", "ended_at", "pushed_count", "pulled_count", "conflict_count", "errors").
+		Values(formatSQLiteTime(run.StartedAt), formatSQLiteTime(run.EndedAt), run.Pushed, run.Pulled, run.Conflicts, errs).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to save sync run: %w", err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read sync run id: %w", err)
+	}
+
+	for chunk := range slices.Chunk(run.Entries, sqliteMaxParams/len(syncRunEntryColumns)) {
+		insert := s.psql.
+			Insert(SYNC_RUN_ENTRIES_TABLE).
+			Columns(syncRunEntryColumns...)
+		for _, entry := range chunk {
+			insert = insert.Values(runID, entry.EntryID, entry.Direction, formatSQLiteTime(entry.SyncedAt), entry.Error)
+		}
+
+		query, args, err := insert.ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build insert query: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to save sync run entries: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sync run: %w", err)
+	}
+
+	return nil
+}
+
+// ListSyncRuns returns the most recent sync runs, newest first
+func (s *SQLiteStorage) ListSyncRuns(ctx context.Context, limit int) ([]service.SyncRun, error) {
+	query, args, err := s.psql.
+		Select("id", "started_at", "ended_at", "pushed_count", "pulled_count", "conflict_count", "errors").
+		From(SYNC_RUNS_TABLE).
+		OrderBy("started_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []service.SyncRun
+	for rows.Next() {
+		var run service.SyncRun
+		var startedAt, endedAt, errs sql.NullString
+
+		if err := rows.Scan(&run.ID, &startedAt, &endedAt, &run.Pushed, &run.Pulled, &run.Conflicts, &errs); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if run.StartedAt, err = parseSQLiteTime(startedAt); err != nil {
+			return nil, err
+		}
+		if run.EndedAt, err = parseSQLiteTime(endedAt); err != nil {
+			return nil, err
+		}
+		if errs.Valid {
+			if err := json.Unmarshal([]byte(errs.String), &run.Errors); err != nil {
+				return nil, fmt.Errorf("failed to decode errors for sync run %d: %w", run.ID, err)
+			}
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+// EntrySyncHistory returns the sync history of a single entry, newest first
+func (s *SQLiteStorage) EntrySyncHistory(ctx context.Context, entryID string, limit int) ([]service.SyncRunEntry, error) {
+	query, args, err := s.psql.
+		Select(syncRunEntryColumns...).
+		From(SYNC_RUN_ENTRIES_TABLE).
+		Where(sq.Eq{"entry_id": entryID}).
+		OrderBy("synced_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return s.querySyncRunEntries(ctx, query, args)
+}
+
+// SyncRunEntries returns the entry records of the listed runs, querying run IDs in chunks
+// to stay under SQLite's limit on bound parameters
+func (s *SQLiteStorage) SyncRunEntries(ctx context.Context, runIDs []int64) ([]service.SyncRunEntry, error) {
+	var history []service.SyncRunEntry
+	for chunk := range slices.Chunk(runIDs, sqliteMaxParams) {
+		query, args, err := s.psql.
+			Select(syncRunEntryColumns...).
+			From(SYNC_RUN_ENTRIES_TABLE).
+			Where(sq.Eq{"run_id": chunk}).
+			OrderBy("run_id", "synced_at").
+			ToSql()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		records, err := s.querySyncRunEntries(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, records...)
+	}
+	return history, nil
+}
+
+// querySyncRunEntries runs a query returning syncRunEntryColumns
+func (s *SQLiteStorage) querySyncRunEntries(ctx context.Context, query string, args []any) ([]service.SyncRunEntry, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []service.SyncRunEntry
+	for rows.Next() {
+		var record service.SyncRunEntry
+		var syncedAt, errMsg sql.NullString
+
+		if err := rows.Scan(&record.RunID, &record.EntryID, &record.Direction, &syncedAt, &errMsg); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if record.SyncedAt, err = parseSQLiteTime(syncedAt); err != nil {
+			return nil, err
+		}
+		record.Error = errMsg.String
+
+		history = append(history, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return history, nil
+}
+
+// ListTags counts every tag the context owner uses and adds any with metadata, by name
+func (s *SQLiteStorage) ListTags(ctx context.Context) ([]service.Tag, error) {
+	query, args, err := listTagsQuery(s.psql, dialectSQLite, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []service.Tag
+	for rows.Next() {
+		var tag service.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count, &tag.Description, &tag.Color); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+// SaveTag sets a tag's description and colour
+func (s *SQLiteStorage) SaveTag(ctx context.Context, tag service.Tag) error {
+	query, args, err := saveTagQuery(s.psql, service.OwnerFromContext(ctx), tag).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save tag: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTag drops a tag's metadata; entries keep the tag
+func (s *SQLiteStorage) DeleteTag(ctx context.Context, name string) error {
+	query, args, err := s.psql.
+		Delete(TAGS_TABLE).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx), "name": name}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	return nil
+}
+
+// formatSQLiteTime stores zero timestamps as NULL and everything else in UTC
+func formatSQLiteTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// parseSQLiteTime is the inverse of formatSQLiteTime
+func parseSQLiteTime(value sql.NullString) (time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(sqliteTimeFormat, value.String)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse timestamp %q: %w", value.String, err)
+	}
+	return t, nil
+}
+
+// encodeSQLiteTags stores string slices as JSON arrays, keeping nil as NULL
+func encodeSQLiteTags(tags []string) (any, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tags: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/turnerem/zenzen/core"
+	"github.com/turnerem/zenzen/service"
+	"github.com/turnerem/zenzen/service/storetest"
+)
+
+func newTestSQLiteStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+
+	ctx := context.Background()
+	storage, err := NewSQLiteStorage(ctx, filepath.Join(t.TempDir(), "zenzen.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close(ctx) })
+
+	return storage
+}
+
+func TestSQLiteStorage_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) service.Store {
+		return newTestSQLiteStorage(t)
+	})
+}
+
+func TestSQLiteStorage_BatchRollsBackFailedDelete(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLiteStorage(t)
+
+	if err := storage.SaveEntry(ctx, core.Entry{ID: "locked", Title: "Can't be deleted"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	_, err := storage.db.ExecContext(ctx, `CREATE TRIGGER keep_locked BEFORE DELETE ON entries
+		WHEN OLD.id = 'locked' BEGIN SELECT RAISE(ABORT, 'locked'); END`)
+	if err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	// The delete fails after the save has been written; the save must not be kept
+	batch := service.NewBatch(storage)
+	batch.Save(core.Entry{ID: "new", Title: "Added"})
+	batch.Delete("locked")
+	if err := batch.Commit(ctx); err == nil {
+		t.Fatalf("Commit() error = nil; want the delete to fail")
+	}
+
+	entries, err := storage.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if _, ok := entries["new"]; ok || len(entries) != 1 {
+		t.Errorf("entries after failed commit = %v; want only the locked entry", entries)
+	}
+	if batch.Len() != 2 {
+		t.Errorf("batch.Len() = %d; want both changes still staged", batch.Len())
+	}
+}
+
+func TestSQLiteStorage_OutboxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLiteStorage(t)
+	store := service.NewWebhookStore(storage, storage, []service.Subscription{{ID: "all", URL: "http://localhost"}})
+
+	_, err := storage.db.ExecContext(ctx, `CREATE TRIGGER refuse_deliveries BEFORE INSERT ON `+WEBHOOK_DELIVERIES_TABLE+`
+		BEGIN SELECT RAISE(ABORT, 'queue is full'); END`)
+	if err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	// The deliveries are queued in the entry's transaction, so neither is kept
+	if err := store.SaveEntry(ctx, core.Entry{ID: "1", Title: "Deploy"}); err == nil {
+		t.Fatalf("SaveEntry() error = nil; want the queue failure")
+	}
+	if err := store.WriteEntries(ctx, []core.Entry{{ID: "2", Title: "Review"}, {ID: "3", Title: "Ship"}}, nil); err == nil {
+		t.Fatalf("WriteEntries() error = nil; want the queue failure")
+	}
+	entries, err := storage.GetAll(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("GetAll() = %v, %v; want no entries", entries, err)
+	}
+
+	if _, err := storage.db.ExecContext(ctx, "DROP TRIGGER refuse_deliveries"); err != nil {
+		t.Fatalf("failed to drop trigger: %v", err)
+	}
+	if err := store.SaveEntry(ctx, core.Entry{ID: "1", Title: "Deploy"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	if log, err := storage.ListDeliveries(ctx, 0); err != nil || len(log) != 1 {
+		t.Errorf("ListDeliveries() = %+v, %v; want the save's delivery", log, err)
+	}
+}
+
+func TestSQLiteStorage_SyncLog(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLiteStorage(t)
+
+	started := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	run := service.SyncRun{
+		StartedAt: started,
+		EndedAt:   started.Add(time.Second),
+		Pushed:    1,
+		Errors:    []string{"pull 2: boom"},
+		Entries: []service.SyncRunEntry{
+			{EntryID: "1", Direction: service.SyncDirectionPush, SyncedAt: started},
+			{EntryID: "2", Direction: service.SyncDirectionPull, SyncedAt: started, Error: "boom"},
+		},
+	}
+
+	if err := storage.RecordSyncRun(ctx, run); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	runs, err := storage.ListSyncRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("Expected 1 run, got %d", len(runs))
+	}
+	if runs[0].Duration() != time.Second || runs[0].Pushed != 1 || !reflect.DeepEqual(runs[0].Errors, run.Errors) {
+		t.Errorf("unexpected run %+v", runs[0])
+	}
+
+	history, err := storage.EntrySyncHistory(ctx, "2", 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(history) != 1 || history[0].Error != "boom" || history[0].RunID != runs[0].ID {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+func TestSQLiteStorage_SyncLogLargeRun(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLiteStorage(t)
+
+	// Far more entry records than SQLite binds in one statement
+	started := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	run := service.SyncRun{StartedAt: started, EndedAt: started.Add(time.Minute)}
+	for i := range 10000 {
+		run.Entries = append(run.Entries, service.SyncRunEntry{EntryID: strconv.Itoa(i), Direction: service.SyncDirectionPush, SyncedAt: started})
+	}
+
+	if err := storage.RecordSyncRun(ctx, run); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	history, err := storage.EntrySyncHistory(ctx, "9999", 10)
+	if err != nil || len(history) != 1 {
+		t.Errorf("EntrySyncHistory() = %+v, %v; want the last entry's record", history, err)
+	}
+}
+
+func TestSQLiteStorage_SyncLogRollsBack(t *testing.T) {
+	ctx := context.Background()
+	storage := newTestSQLiteStorage(t)
+
+	if _, err := storage.db.ExecContext(ctx, "DROP TABLE "+SYNC_RUN_ENTRIES_TABLE); err != nil {
+		t.Fatalf("failed to drop table: %v", err)
+	}
+
+	run := service.SyncRun{
+		StartedAt: time.Now(),
+		Entries:   []service.SyncRunEntry{{EntryID: "1", Direction: service.SyncDirectionPush, SyncedAt: time.Now()}},
+	}
+	if err := storage.RecordSyncRun(ctx, run); err == nil {
+		t.Fatalf("Expected an error saving entry records")
+	}
+
+	// The run isn't kept without its entries
+	runs, err := storage.ListSyncRuns(ctx, 10)
+	if err != nil || len(runs) != 0 {
+		t.Errorf("ListSyncRuns() = %+v, %v; want none", runs, err)
+	}
+}
+
+func TestSQLiteStorage_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newTestSQLiteStorage(t)
+
+	started := time.Date(2025, 12, 20, 10, 0, 0, 0, time.UTC)
+	if err := source.SaveEntry(ctx, core.Entry{ID: "1", Title: "Synced", Tags: []string{"work"}, LastModifiedTimestamp: started}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+	if err := source.SaveTag(ctx, service.Tag{Name: "work", Color: "#336699"}); err != nil {
+		t.Fatalf("SaveTag() error = %v", err)
+	}
+	run := service.SyncRun{
+		StartedAt: started,
+		EndedAt:   started.Add(time.Second),
+		Pushed:    1,
+		Entries: []service.SyncRunEntry{
+			{EntryID: "1", Direction: service.SyncDirectionPush, SyncedAt: started},
+			{EntryID: "deleted-since", Direction: service.SyncDirectionPush, SyncedAt: started},
+		},
+	}
+	if err := source.RecordSyncRun(ctx, run); err != nil {
+		t.Fatalf("RecordSyncRun() error = %v", err)
+	}
+
+	path, err := service.WriteBackupFile(ctx, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteBackupFile() error = %v", err)
+	}
+	backup, err := service.ReadBackupFile(path)
+	if err != nil {
+		t.Fatalf("ReadBackupFile() error = %v", err)
+	}
+	if len(backup.SyncRuns) != 1 || len(backup.SyncRuns[0].Entries) != 1 || backup.SyncRuns[0].Entries[0].EntryID != "1" {
+		t.Fatalf("backed up runs = %+v; want the run with entry 1's record only", backup.SyncRuns)
+	}
+
+	target := newTestSQLiteStorage(t)
+	for range 2 {
+		if _, err := service.Restore(ctx, target, backup, service.RestoreReplace); err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+	}
+
+	// Restoring twice doesn't duplicate the history
+	runs, err := target.ListSyncRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListSyncRuns() error = %v", err)
+	}
+	if len(runs) != 1 || runs[0].Pushed != 1 {
+		t.Errorf("runs = %+v; want the one backed up", runs)
+	}
+	history, err := target.EntrySyncHistory(ctx, "1", 10)
+	if err != nil {
+		t.Fatalf("EntrySyncHistory() error = %v", err)
+	}
+	if len(history) != 1 || history[0].RunID != runs[0].ID {
+		t.Errorf("history = %+v; want the push in the restored run", history)
+	}
+
+	tags, err := target.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != (service.Tag{Name: "work", Count: 1, Color: "#336699"}) {
+		t.Errorf("tags = %+v", tags)
+	}
+}
+
+func TestSQLiteStorage_ClaimEntriesFromBeforeOwners(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "zenzen.db")
+
+	// A single-user database from before entries had owners
+	migrator, err := NewSQLiteMigrator(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteMigrator() error = %v", err)
+	}
+	defer migrator.Close(ctx)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	// Back to 0007, the last version before 0008_add_entry_owner
+	if _, err := migrator.Down(ctx, migrator.LatestVersion()-7); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	err = migrator.db.runScript(ctx, joinScript(
+		"INSERT INTO entries (id, title, tags, version) VALUES ('1', 'Old', '[\"work\"]', 3), ('2', 'Taken', NULL, 1)",
+		"INSERT INTO tags (name, color) VALUES ('work', '#FF8800')",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upgrading keeps them, owned by the default owner
+	storage, err := NewSQLiteStorage(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	defer storage.Close(ctx)
+
+	entries, err := storage.GetAll(ctx)
+	if err != nil || len(entries) != 2 || entries["1"].Version != 3 {
+		t.Fatalf("GetAll() = %+v, %v; want both entries as they were", entries, err)
+	}
+
+	alice := service.WithOwner(ctx, "alice")
+	if err := storage.SaveEntry(alice, core.Entry{ID: "2", Title: "Alice's own"}); err != nil {
+		t.Fatalf("SaveEntry() error = %v", err)
+	}
+
+	moved, err := storage.ReassignOwner(ctx, service.DefaultOwner, "alice")
+	if err != nil || moved != 1 {
+		t.Fatalf("ReassignOwner() = %d, %v; want 1 moved", moved, err)
+	}
+
+	entries, err = storage.GetAll(alice)
+	if err != nil || len(entries) != 2 || entries["1"].Title != "Old" || entries["2"].Title != "Alice's own" {
+		t.Errorf("GetAll(alice) = %+v, %v; want the claimed entry alongside her own", entries, err)
+	}
+	tags, err := storage.ListTags(alice)
+	if err != nil || len(tags) != 1 || tags[0].Color != "#FF8800" {
+		t.Errorf("ListTags(alice) = %+v, %v; want the claimed metadata", tags, err)
+	}
+
+	// The colliding ID stays behind rather than overwriting hers
+	entries, err = storage.GetAll(ctx)
+	if err != nil || len(entries) != 1 || entries["2"].Title != "Taken" {
+		t.Errorf("GetAll() = %+v, %v; want only the colliding entry left", entries, err)
+	}
+}
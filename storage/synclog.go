@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	SYNC_RUNS_TABLE        = "sync_runs"
+	SYNC_RUN_ENTRIES_TABLE = "sync_run_entries"
+)
+
+// syncRunEntryColumns are written for every entry a sync run touched
+var syncRunEntryColumns = []string{"run_id", "entry_id", "direction", "synced_at", "error"}
+
+// RecordSyncRun stores a finished sync run and the entries it touched in one transaction.
+// Entry records are copied in, so a run can touch any number of entries.
+func (s *SQLStorage) RecordSyncRun(ctx context.Context, run service.SyncRun) error {
+	query, args, err := s.psql.
+		Insert(SYNC_RUNS_TABLE).
+		Columns("started_at", "ended_at", "pushed_count", "pulled_count", "conflict_count", "errors").
+		Values(run.StartedAt, run.EndedAt, run.Pushed, run.Pulled, run.Conflicts, run.Errors).
+		Suffix("RETURNING id").
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op once committed
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to save sync run: %w", err)
+	}
+	var runID int64
+	if rows.Next() {
+		if err := rows.Scan(&runID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan sync run id: %w", err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to save sync run: %w", err)
+	}
+
+	if len(run.Entries) > 0 {
+		records := make([][]any, 0, len(run.Entries))
+		for _, entry := range run.Entries {
+			records = append(records, []any{runID, entry.EntryID, entry.Direction, entry.SyncedAt, entry.Error})
+		}
+
+		_, err := tx.CopyFrom(ctx, pgx.Identifier{SYNC_RUN_ENTRIES_TABLE}, syncRunEntryColumns, pgx.CopyFromRows(records))
+		if err != nil {
+			return fmt.Errorf("failed to save sync run entries: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit sync run: %w", err)
+	}
+
+	return nil
+}
+
+// ListSyncRuns returns the most recent sync runs, newest first
+func (s *SQLStorage) ListSyncRuns(ctx context.Context, limit int) ([]service.SyncRun, error) {
+	query, args, err := s.psql.
+		Select("id", "started_at", "ended_at", "pushed_count", "pulled_count", "conflict_count", "errors").
+		From(SYNC_RUNS_TABLE).
+		OrderBy("started_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []service.SyncRun
+	for rows.Next() {
+		var run service.SyncRun
+		var endedAt pgtype.Timestamptz
+
+		if err := rows.Scan(&run.ID, &run.StartedAt, &endedAt, &run.Pushed, &run.Pulled, &run.Conflicts, &run.Errors); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if endedAt.Valid {
+			run.EndedAt = endedAt.Time
+		}
+
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+// EntrySyncHistory returns the sync history of a single entry, newest first
+func (s *SQLStorage) EntrySyncHistory(ctx context.Context, entryID string, limit int) ([]service.SyncRunEntry, error) {
+	query, args, err := s.psql.
+		Select(syncRunEntryColumns...).
+		From(SYNC_RUN_ENTRIES_TABLE).
+		Where(sq.Eq{"entry_id": entryID}).
+		OrderBy("synced_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return s.querySyncRunEntries(ctx, query, args)
+}
+
+// SyncRunEntries returns the entry records of the listed runs in a single query
+func (s *SQLStorage) SyncRunEntries(ctx context.Context, runIDs []int64) ([]service.SyncRunEntry, error) {
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := s.psql.
+		Select(syncRunEntryColumns...).
+		From(SYNC_RUN_ENTRIES_TABLE).
+		Where("run_id = ANY(?)", runIDs).
+		OrderBy("run_id", "synced_at").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	return s.querySyncRunEntries(ctx, query, args)
+}
+
+// querySyncRunEntries runs a query returning syncRunEntryColumns
+func (s *SQLStorage) querySyncRunEntries(ctx context.Context, query string, args []any) ([]service.SyncRunEntry, error) {
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []service.SyncRunEntry
+	for rows.Next() {
+		var record service.SyncRunEntry
+		var errMsg pgtype.Text
+
+		if err := rows.Scan(&record.RunID, &record.EntryID, &record.Direction, &record.SyncedAt, &errMsg); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		record.Error = errMsg.String
+
+		history = append(history, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return history, nil
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	TAGS_TABLE = "tags"
+)
+
+// listTagsQuery counts tag usage across the owner's entries and joins in their metadata,
+// so tags with metadata but no entries are listed too
+func listTagsQuery(builder sq.StatementBuilderType, dialect, owner string) sq.SelectBuilder {
+	usage := builder.
+		Select("tag AS name", "count(DISTINCT id) AS count").
+		From(ENTRIES_TABLE + " CROSS JOIN unnest(tags) AS tag").
+		Where(sq.Eq{"owner_id": owner}).
+		GroupBy("tag")
+	if dialect == dialectSQLite {
+		usage = builder.
+			Select("json_each.value AS name", "count(DISTINCT entries.id) AS count").
+			From(ENTRIES_TABLE + ", json_each(entries.tags)").
+			Where(sq.Eq{"entries.owner_id": owner}).
+			GroupBy("json_each.value")
+	}
+
+	// Filtered before the join; filtering in ON would still list other owners' tags unmatched
+	return builder.
+		Select("coalesce(u.name, t.name) AS name", "coalesce(u.count, 0)", "coalesce(t.description, '')", "coalesce(t.color, '')").
+		FromSelect(usage, "u").
+		JoinClause("FULL OUTER JOIN (SELECT name, description, color FROM "+TAGS_TABLE+" WHERE owner_id = ?) AS t ON t.name = u.name", owner).
+		OrderBy("name")
+}
+
+// saveTagQuery upserts the owner's metadata for a tag
+func saveTagQuery(builder sq.StatementBuilderType, owner string, tag service.Tag) sq.InsertBuilder {
+	return builder.
+		Insert(TAGS_TABLE).
+		Columns("name", "description", "color", "owner_id").
+		Values(tag.Name, tag.Description, tag.Color, owner).
+		Suffix("ON CONFLICT (owner_id, name) DO UPDATE SET description = excluded.description, color = excluded.color")
+}
+
+// ListTags counts every tag the context owner uses and adds any with metadata, by name
+func (s *SQLStorage) ListTags(ctx context.Context) ([]service.Tag, error) {
+	query, args, err := listTagsQuery(s.psql, dialectPostgres, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []service.Tag
+	for rows.Next() {
+		var tag service.Tag
+		var count int64
+		if err := rows.Scan(&tag.Name, &count, &tag.Description, &tag.Color); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		tag.Count = int(count)
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tags, nil
+}
+
+// SaveTag sets a tag's description and colour
+func (s *SQLStorage) SaveTag(ctx context.Context, tag service.Tag) error {
+	query, args, err := saveTagQuery(s.psql, service.OwnerFromContext(ctx), tag).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save tag: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTag drops a tag's metadata; entries keep the tag
+func (s *SQLStorage) DeleteTag(ctx context.Context, name string) error {
+	query, args, err := s.psql.
+		Delete(TAGS_TABLE).
+		Where(sq.Eq{"owner_id": service.OwnerFromContext(ctx), "name": name}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	return nil
+}
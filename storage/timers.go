@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/service"
+)
+
+const TIMERS_TABLE = "timers"
+
+// getTimerQuery selects the owner's timer
+func getTimerQuery(builder sq.StatementBuilderType, owner string) sq.SelectBuilder {
+	return builder.
+		Select("entry_id", "state", "since").
+		From(TIMERS_TABLE).
+		Where(sq.Eq{"owner_id": owner})
+}
+
+// saveTimerQuery upserts the owner's timer. since is the timer's Since as the dialect
+// stores timestamps.
+func saveTimerQuery(builder sq.StatementBuilderType, owner string, timer service.Timer, since any) sq.InsertBuilder {
+	return builder.
+		Insert(TIMERS_TABLE).
+		Columns("owner_id", "entry_id", "state", "since").
+		Values(owner, timer.EntryID, string(timer.State), since).
+		Suffix("ON CONFLICT (owner_id) DO UPDATE SET entry_id = excluded.entry_id, state = excluded.state, since = excluded.since")
+}
+
+// claimTimerQuery upserts a running timer only while the stored one isn't running on
+// another entry than timer.EntryID and replacing. The conflicting row is locked as it's
+// checked, so concurrent claims are serialized and the losers update nothing.
+func claimTimerQuery(builder sq.StatementBuilderType, owner string, timer service.Timer, since any, replacing string) sq.InsertBuilder {
+	return saveTimerQuery(builder, owner, timer, since).
+		Suffix("WHERE "+TIMERS_TABLE+".state <> ? OR "+TIMERS_TABLE+".entry_id IN (?, ?)", string(service.TimerRunning), timer.EntryID, replacing)
+}
+
+// deleteTimerQuery removes the owner's timer
+func deleteTimerQuery(builder sq.StatementBuilderType, owner string) sq.DeleteBuilder {
+	return builder.
+		Delete(TIMERS_TABLE).
+		Where(sq.Eq{"owner_id": owner})
+}
+
+// GetTimer returns the owner's timer, reporting whether there is one
+func (s *SQLStorage) GetTimer(ctx context.Context) (service.Timer, bool, error) {
+	query, args, err := getTimerQuery(s.psql, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return service.Timer{}, false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return service.Timer{}, false, fmt.Errorf("failed to query timer: %w", err)
+	}
+	defer rows.Close()
+
+	var timer service.Timer
+	found := false
+	if rows.Next() {
+		var state string
+		if err := rows.Scan(&timer.EntryID, &state, &timer.Since); err != nil {
+			return service.Timer{}, false, fmt.Errorf("failed to scan row: %w", err)
+		}
+		timer.State = service.TimerState(state)
+		found = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return service.Timer{}, false, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return timer, found, nil
+}
+
+// SaveTimer replaces the owner's timer
+func (s *SQLStorage) SaveTimer(ctx context.Context, timer service.Timer) error {
+	query, args, err := saveTimerQuery(s.psql, service.OwnerFromContext(ctx), timer, timer.Since).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save timer: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimTimer saves a running timer unless another entry's, other than replacing, is running
+func (s *SQLStorage) ClaimTimer(ctx context.Context, timer service.Timer, replacing string) error {
+	query, args, err := claimTimerQuery(s.psql, service.OwnerFromContext(ctx), timer, timer.Since, replacing).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	result, err := s.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to claim timer: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return service.ErrTimerRunning
+	}
+
+	return nil
+}
+
+// DeleteTimer removes the owner's timer
+func (s *SQLStorage) DeleteTimer(ctx context.Context) error {
+	query, args, err := deleteTimerQuery(s.psql, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete timer: %w", err)
+	}
+
+	return nil
+}
+
+// GetTimer returns the owner's timer, reporting whether there is one
+func (s *SQLiteStorage) GetTimer(ctx context.Context) (service.Timer, bool, error) {
+	query, args, err := getTimerQuery(s.psql, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return service.Timer{}, false, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return service.Timer{}, false, fmt.Errorf("failed to query timer: %w", err)
+	}
+	defer rows.Close()
+
+	var timer service.Timer
+	found := false
+	if rows.Next() {
+		var state string
+		var since sql.NullString
+		if err := rows.Scan(&timer.EntryID, &state, &since); err != nil {
+			return service.Timer{}, false, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if timer.Since, err = parseSQLiteTime(since); err != nil {
+			return service.Timer{}, false, err
+		}
+		timer.State = service.TimerState(state)
+		found = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return service.Timer{}, false, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return timer, found, nil
+}
+
+// SaveTimer replaces the owner's timer
+func (s *SQLiteStorage) SaveTimer(ctx context.Context, timer service.Timer) error {
+	query, args, err := saveTimerQuery(s.psql, service.OwnerFromContext(ctx), timer, formatSQLiteTime(timer.Since)).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save timer: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimTimer saves a running timer unless another entry's, other than replacing, is running
+func (s *SQLiteStorage) ClaimTimer(ctx context.Context, timer service.Timer, replacing string) error {
+	query, args, err := claimTimerQuery(s.psql, service.OwnerFromContext(ctx), timer, formatSQLiteTime(timer.Since), replacing).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to claim timer: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check claimed timer: %w", err)
+	}
+	if affected == 0 {
+		return service.ErrTimerRunning
+	}
+
+	return nil
+}
+
+// DeleteTimer removes the owner's timer
+func (s *SQLiteStorage) DeleteTimer(ctx context.Context) error {
+	query, args, err := deleteTimerQuery(s.psql, service.OwnerFromContext(ctx)).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete timer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/turnerem/zenzen/service"
+)
+
+const WEBHOOK_DELIVERIES_TABLE = "webhook_deliveries"
+
+// deliveryColumns are read by every delivery query, in scan order
+var deliveryColumns = []string{
+	"id", "subscription_id", "event", "owner_id", "entry_id", "payload", "status", "attempts",
+	"next_attempt_at", "last_error", "response_status", "created_at", "delivered_at",
+}
+
+// enqueueDeliveryParams is how many parameters enqueueDeliveriesQuery binds per delivery
+const enqueueDeliveryParams = 9
+
+// enqueueDeliveriesQuery inserts pending deliveries, skipping IDs already queued
+func enqueueDeliveriesQuery(builder sq.StatementBuilderType, dialect string, deliveries []service.Delivery) sq.InsertBuilder {
+	insert := builder.
+		Insert(WEBHOOK_DELIVERIES_TABLE).
+		Columns("id", "subscription_id", "event", "owner_id", "entry_id", "payload", "status", "next_attempt_at", "created_at")
+	for _, delivery := range deliveries {
+		insert = insert.Values(delivery.ID, delivery.SubscriptionID, string(delivery.Event), delivery.Owner, delivery.EntryID,
+			string(delivery.Payload), string(service.DeliveryPending), queryTime(dialect, delivery.NextAttemptAt), queryTime(dialect, delivery.CreatedAt))
+	}
+	return insert.Suffix("ON CONFLICT (id) DO NOTHING")
+}
+
+// claimDeliveriesQuery pushes back the next attempt of up to limit due deliveries and
+// returns them. Postgres skips rows another dispatcher is claiming; SQLite serializes writes.
+func claimDeliveriesQuery(builder sq.StatementBuilderType, dialect string, now time.Time, lease time.Duration, limit int) (string, []any, error) {
+	due := sq.
+		Select("id").
+		From(WEBHOOK_DELIVERIES_TABLE).
+		Where(sq.Eq{"status": string(service.DeliveryPending)}).
+		Where(sq.LtOrEq{"next_attempt_at": queryTime(dialect, now)}).
+		OrderBy("next_attempt_at", "created_at").
+		Limit(uint64(limit))
+	if dialect == dialectPostgres {
+		due = due.Suffix("FOR UPDATE SKIP LOCKED")
+	}
+	dueSQL, dueArgs, err := due.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return builder.
+		Update(WEBHOOK_DELIVERIES_TABLE).
+		Set("next_attempt_at", queryTime(dialect, now.Add(lease))).
+		Where("id IN ("+dueSQL+")", dueArgs...).
+		Suffix("RETURNING " + strings.Join(deliveryColumns, ", ")).
+		ToSql()
+}
+
+// updateDeliveryQuery records the outcome of an attempt
+func updateDeliveryQuery(builder sq.StatementBuilderType, dialect string, delivery service.Delivery) sq.UpdateBuilder {
+	var deliveredAt any
+	if !delivery.DeliveredAt.IsZero() {
+		deliveredAt = queryTime(dialect, delivery.DeliveredAt)
+	}
+	return builder.
+		Update(WEBHOOK_DELIVERIES_TABLE).
+		Set("status", string(delivery.Status)).
+		Set("attempts", delivery.Attempts).
+		Set("next_attempt_at", queryTime(dialect, delivery.NextAttemptAt)).
+		Set("last_error", delivery.LastError).
+		Set("response_status", delivery.ResponseStatus).
+		Set("delivered_at", deliveredAt).
+		Where(sq.Eq{"id": delivery.ID})
+}
+
+// listDeliveriesQuery selects the owner's most recent deliveries; a limit of 0 selects them all
+func listDeliveriesQuery(builder sq.StatementBuilderType, owner string, limit int) sq.SelectBuilder {
+	query := builder.
+		Select(deliveryColumns...).
+		From(WEBHOOK_DELIVERIES_TABLE).
+		Where(sq.Eq{"owner_id": owner}).
+		OrderBy("created_at DESC", "id DESC")
+	if limit > 0 {
+		query = query.Limit(uint64(limit))
+	}
+	return query
+}
+
+// sortDue orders claimed deliveries oldest first, since RETURNING doesn't keep the subquery's order
+func sortDue(deliveries []service.Delivery) {
+	slices.SortStableFunc(deliveries, func(a, b service.Delivery) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+}
+
+// EnqueueDeliveries adds pending deliveries, skipping any whose ID is already queued
+func (s *SQLStorage) EnqueueDeliveries(ctx context.Context, deliveries []service.Delivery) error {
+	return s.enqueueDeliveries(ctx, s.conn, deliveries)
+}
+
+// Outbox returns the store itself: entry writes queue the deliveries in their context in the
+// same transaction
+func (s *SQLStorage) Outbox() service.DeliveryQueue {
+	return s
+}
+
+// enqueueDeliveries inserts deliveries through db, which may be a transaction
+func (s *SQLStorage) enqueueDeliveries(ctx context.Context, db pgxExecer, deliveries []service.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	query, args, err := enqueueDeliveriesQuery(s.psql, dialectPostgres, deliveries).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to enqueue deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due by now, holding them until now plus lease
+func (s *SQLStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]service.Delivery, error) {
+	query, args, err := claimDeliveriesQuery(s.psql, dialectPostgres, now, lease, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	deliveries, err := s.queryDeliveries(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+	sortDue(deliveries)
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt
+func (s *SQLStorage) UpdateDelivery(ctx context.Context, delivery service.Delivery) error {
+	query, args, err := updateDeliveryQuery(s.psql, dialectPostgres, delivery).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the owner's most recent deliveries, newest first
+func (s *SQLStorage) ListDeliveries(ctx context.Context, limit int) ([]service.Delivery, error) {
+	query, args, err := listDeliveriesQuery(s.psql, service.OwnerFromContext(ctx), limit).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	deliveries, err := s.queryDeliveries(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// queryDeliveries runs a query returning deliveryColumns
+func (s *SQLStorage) queryDeliveries(ctx context.Context, query string, args []any) ([]service.Delivery, error) {
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []service.Delivery
+	for rows.Next() {
+		var delivery service.Delivery
+		var event, payload, status string
+		var deliveredAt pgtype.Timestamptz
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &event, &delivery.Owner, &delivery.EntryID, &payload, &status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.ResponseStatus, &delivery.CreatedAt, &deliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		delivery.Event = service.WebhookEvent(event)
+		delivery.Payload = []byte(payload)
+		delivery.Status = service.DeliveryStatus(status)
+		if deliveredAt.Valid {
+			delivery.DeliveredAt = deliveredAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// EnqueueDeliveries adds pending deliveries, skipping any whose ID is already queued
+func (s *SQLiteStorage) EnqueueDeliveries(ctx context.Context, deliveries []service.Delivery) error {
+	return s.enqueueDeliveries(ctx, s.db, deliveries)
+}
+
+// Outbox returns the store itself: entry writes queue the deliveries in their context in the
+// same transaction
+func (s *SQLiteStorage) Outbox() service.DeliveryQueue {
+	return s
+}
+
+// enqueueDeliveries inserts deliveries through db, which may be a transaction, in chunks to
+// stay under SQLite's limit on bound parameters
+func (s *SQLiteStorage) enqueueDeliveries(ctx context.Context, db sqliteExecer, deliveries []service.Delivery) error {
+	for chunk := range slices.Chunk(deliveries, sqliteMaxParams/enqueueDeliveryParams) {
+		query, args, err := enqueueDeliveriesQuery(s.psql, dialectSQLite, chunk).ToSql()
+		if err != nil {
+			return fmt.Errorf("failed to build insert query: %w", err)
+		}
+
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to enqueue deliveries: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries due by now, holding them until now plus lease
+func (s *SQLiteStorage) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]service.Delivery, error) {
+	query, args, err := claimDeliveriesQuery(s.psql, dialectSQLite, now, lease, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	deliveries, err := s.queryDeliveries(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+	sortDue(deliveries)
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of an attempt
+func (s *SQLiteStorage) UpdateDelivery(ctx context.Context, delivery service.Delivery) error {
+	query, args, err := updateDeliveryQuery(s.psql, dialectSQLite, delivery).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the owner's most recent deliveries, newest first
+func (s *SQLiteStorage) ListDeliveries(ctx context.Context, limit int) ([]service.Delivery, error) {
+	query, args, err := listDeliveriesQuery(s.psql, service.OwnerFromContext(ctx), limit).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	deliveries, err := s.queryDeliveries(ctx, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// queryDeliveries runs a query returning deliveryColumns
+func (s *SQLiteStorage) queryDeliveries(ctx context.Context, query string, args []any) ([]service.Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []service.Delivery
+	for rows.Next() {
+		var delivery service.Delivery
+		var event, payload, status string
+		var nextAttemptAt, createdAt, deliveredAt sql.NullString
+		if err := rows.Scan(&delivery.ID, &delivery.SubscriptionID, &event, &delivery.Owner, &delivery.EntryID, &payload, &status,
+			&delivery.Attempts, &nextAttemptAt, &delivery.LastError, &delivery.ResponseStatus, &createdAt, &deliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		delivery.Event = service.WebhookEvent(event)
+		delivery.Payload = []byte(payload)
+		delivery.Status = service.DeliveryStatus(status)
+
+		var err error
+		if delivery.NextAttemptAt, err = parseSQLiteTime(nextAttemptAt); err != nil {
+			return nil, err
+		}
+		if delivery.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+			return nil, err
+		}
+		if delivery.DeliveredAt, err = parseSQLiteTime(deliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return deliveries, nil
+}
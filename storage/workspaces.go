@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/turnerem/zenzen/service"
+)
+
+const (
+	WORKSPACES_TABLE        = "workspaces"
+	WORKSPACE_MEMBERS_TABLE = "workspace_members"
+)
+
+// listWorkspacesQuery selects every workspace principal belongs to, with their role in it
+func listWorkspacesQuery(builder sq.StatementBuilderType, principal string) sq.SelectBuilder {
+	return builder.
+		Select("w.id", "w.name", "w.created_at", "m.role").
+		From(WORKSPACE_MEMBERS_TABLE + " AS m").
+		Join(WORKSPACES_TABLE + " AS w ON w.id = m.workspace_id").
+		Where(sq.Eq{"m.principal_id": principal})
+}
+
+// listMembersQuery selects a workspace's members
+func listMembersQuery(builder sq.StatementBuilderType, workspaceID string) sq.SelectBuilder {
+	return builder.
+		Select("principal_id", "role", "added_at").
+		From(WORKSPACE_MEMBERS_TABLE).
+		Where(sq.Eq{"workspace_id": workspaceID})
+}
+
+// saveMemberQuery upserts a member's role, keeping when they were first added.
+// addedAt is the member's AddedAt as the dialect stores timestamps.
+func saveMemberQuery(builder sq.StatementBuilderType, workspaceID string, member service.Member, addedAt any) sq.InsertBuilder {
+	return builder.
+		Insert(WORKSPACE_MEMBERS_TABLE).
+		Columns("workspace_id", "principal_id", "role", "added_at").
+		Values(workspaceID, member.PrincipalID, string(member.Role), addedAt).
+		Suffix("ON CONFLICT (workspace_id, principal_id) DO UPDATE SET role = excluded.role")
+}
+
+// deleteMemberQuery removes a member from a workspace
+func deleteMemberQuery(builder sq.StatementBuilderType, workspaceID, principal string) sq.DeleteBuilder {
+	return builder.
+		Delete(WORKSPACE_MEMBERS_TABLE).
+		Where(sq.Eq{"workspace_id": workspaceID, "principal_id": principal})
+}
+
+// CreateWorkspace stores a workspace and its first admin in one transaction
+func (s *SQLStorage) CreateWorkspace(ctx context.Context, workspace service.Workspace, admin service.Member) error {
+	tx, err := s.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // No-op once committed
+
+	query, args, err := s.psql.
+		Insert(WORKSPACES_TABLE).
+		Columns("id", "name", "created_at").
+		Values(workspace.ID, workspace.Name, workspace.CreatedAt).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	query, args, err = saveMemberQuery(s.psql, workspace.ID, admin, admin.AddedAt).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to add workspace admin: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit workspace: %w", err)
+	}
+
+	return nil
+}
+
+// ListWorkspaces returns every workspace principal belongs to, with their role in it
+func (s *SQLStorage) ListWorkspaces(ctx context.Context, principal string) ([]service.Membership, error) {
+	query, args, err := listWorkspacesQuery(s.psql, principal).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []service.Membership
+	for rows.Next() {
+		var membership service.Membership
+		var role string
+		if err := rows.Scan(&membership.Workspace.ID, &membership.Workspace.Name, &membership.Workspace.CreatedAt, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		membership.Role = service.Role(role)
+		memberships = append(memberships, membership)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// ListMembers returns a workspace's members, none if it doesn't exist
+func (s *SQLStorage) ListMembers(ctx context.Context, workspaceID string) ([]service.Member, error) {
+	query, args, err := listMembersQuery(s.psql, workspaceID).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []service.Member
+	for rows.Next() {
+		var member service.Member
+		var role string
+		if err := rows.Scan(&member.PrincipalID, &role, &member.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		member.Role = service.Role(role)
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// SaveMember adds a member to a workspace or changes their role
+func (s *SQLStorage) SaveMember(ctx context.Context, workspaceID string, member service.Member) error {
+	query, args, err := saveMemberQuery(s.psql, workspaceID, member, member.AddedAt).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMember removes a member from a workspace
+func (s *SQLStorage) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	query, args, err := deleteMemberQuery(s.psql, workspaceID, principal).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.conn.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// CreateWorkspace stores a workspace and its first admin in one transaction
+func (s *SQLiteStorage) CreateWorkspace(ctx context.Context, workspace service.Workspace, admin service.Member) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // No-op once committed
+
+	query, args, err := s.psql.
+		Insert(WORKSPACES_TABLE).
+		Columns("id", "name", "created_at").
+		Values(workspace.ID, workspace.Name, formatSQLiteTime(workspace.CreatedAt)).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	query, args, err = saveMemberQuery(s.psql, workspace.ID, admin, formatSQLiteTime(admin.AddedAt)).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to add workspace admin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit workspace: %w", err)
+	}
+
+	return nil
+}
+
+// ListWorkspaces returns every workspace principal belongs to, with their role in it
+func (s *SQLiteStorage) ListWorkspaces(ctx context.Context, principal string) ([]service.Membership, error) {
+	query, args, err := listWorkspacesQuery(s.psql, principal).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []service.Membership
+	for rows.Next() {
+		var membership service.Membership
+		var createdAt sql.NullString
+		var role string
+		if err := rows.Scan(&membership.Workspace.ID, &membership.Workspace.Name, &createdAt, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if membership.Workspace.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+			return nil, err
+		}
+		membership.Role = service.Role(role)
+		memberships = append(memberships, membership)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// ListMembers returns a workspace's members, none if it doesn't exist
+func (s *SQLiteStorage) ListMembers(ctx context.Context, workspaceID string) ([]service.Member, error) {
+	query, args, err := listMembersQuery(s.psql, workspaceID).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []service.Member
+	for rows.Next() {
+		var member service.Member
+		var role string
+		var addedAt sql.NullString
+		if err := rows.Scan(&member.PrincipalID, &role, &addedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if member.AddedAt, err = parseSQLiteTime(addedAt); err != nil {
+			return nil, err
+		}
+		member.Role = service.Role(role)
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// SaveMember adds a member to a workspace or changes their role
+func (s *SQLiteStorage) SaveMember(ctx context.Context, workspaceID string, member service.Member) error {
+	addedAt := member.AddedAt
+	if addedAt.IsZero() {
+		addedAt = time.Now() // added_at is NOT NULL, and formatSQLiteTime stores zero as NULL
+	}
+	query, args, err := saveMemberQuery(s.psql, workspaceID, member, formatSQLiteTime(addedAt)).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build insert query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to save workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMember removes a member from a workspace
+func (s *SQLiteStorage) DeleteMember(ctx context.Context, workspaceID, principal string) error {
+	query, args, err := deleteMemberQuery(s.psql, workspaceID, principal).ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build delete query: %w", err)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete workspace member: %w", err)
+	}
+
+	return nil
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"os"
-	"sort"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/turnerem/zenzen/core"
 	"github.com/turnerem/zenzen/logger"
+	"github.com/turnerem/zenzen/service"
 	"golang.org/x/term"
 )
 
@@ -22,14 +24,43 @@ type SaveEntryFunc func(entry core.Entry) error
 // DeleteEntryFunc is a function that deletes a single entry from storage
 type DeleteEntryFunc func(id string) error
 
+// QueryEntriesFunc is a function that returns entries filtered and sorted by storage
+type QueryEntriesFunc func(q service.Query) ([]core.Entry, error)
+
+// SearchEntriesFunc is a function that runs a full-text search
+type SearchEntriesFunc func(text string) ([]service.SearchResult, error)
+
+// ListTagsFunc is a function that returns every tag with its usage count
+type ListTagsFunc func() ([]service.Tag, error)
+
+// RenameTagFunc is a function that renames a tag on every entry, merging it into to if to exists
+type RenameTagFunc func(from, to string) error
+
+// ListWorkspacesFunc is a function that returns the workspaces the user belongs to
+type ListWorkspacesFunc func() ([]service.Membership, error)
+
+// SwitchWorkspaceFunc is a function that loads a workspace's entries, or the user's own for "",
+// and points every other function at them. It returns the workspace's change feed, nil if none.
+type SwitchWorkspaceFunc func(workspaceID string) (map[string]core.Entry, <-chan service.ChangeEvent, error)
+
+// entryChangedMsg reports a change storage published, possibly made by another process
+type entryChangedMsg service.ChangeEvent
+
 // Model represents the TUI state
 type Model struct {
 	entries            map[string]core.Entry
-	orderedIDs         []string
+	orderedIDs         []string // Filtered and sorted IDs, refreshed when filters, sort or entries change
 	saveEntryFn        SaveEntryFunc
 	deleteEntryFn      DeleteEntryFunc
-	selectedIndex      int    // Index in OrderedIDs
-	view               string // "list", "detail", or "edit"
+	queryEntriesFn     QueryEntriesFunc
+	searchEntriesFn    SearchEntriesFunc
+	listTagsFn         ListTagsFunc
+	renameTagFn        RenameTagFunc
+	snippets           map[string]string // Search match context by entry ID, while a text filter is set
+	selectedIndex      int               // Index in OrderedIDs
+	editingID          string            // Entry open in the edit view
+	conflictEntry      core.Entry        // Edit rejected because the entry changed in storage
+	view               string            // "list", "detail", "edit", "conflict", "tags", or "workspaces"
 	titleInput         textinput.Model
 	tagsInput          textinput.Model
 	estimatedInput     textinput.Model
@@ -51,10 +82,26 @@ type Model struct {
 	filterTagInput     textinput.Model
 	filterInputMode    string // "", "text", "tag" - which filter input is active
 	pendingKeySequence string // Track multi-character key sequences like "cf", "ct"
+	// Tag manager state
+	tags           []service.Tag              // Tags and the namespaces above them, as a tree
+	tagBias        map[string]service.TagBias // Estimation bias rolled up each tag's subtree
+	tagIndex       int                        // Index in tags
+	tagStatus      string                     // Result of the last rename, shown in the tag manager
+	renameTagInput textinput.Model
+	renamingTag    bool // Whether the rename prompt is open
+	// Workspace switcher state
+	listWorkspacesFn  ListWorkspacesFunc
+	switchWorkspaceFn SwitchWorkspaceFunc
+	workspaces        []service.Membership // Listed after the user's own entries
+	workspaceIndex    int                  // Index in the switcher; 0 is the user's own entries
+	workspace         service.Membership   // Open workspace; zero for the user's own entries
+	workspaceStatus   string               // Result of the last switch, shown in the switcher
+	// Storage's change feed, nil if it has none
+	changes <-chan service.ChangeEvent
 }
 
 // NewModel creates a new TUI model
-func NewModel(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEntryFn DeleteEntryFunc, width, height int) *Model {
+func NewModel(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEntryFn DeleteEntryFunc, queryEntriesFn QueryEntriesFunc, searchEntriesFn SearchEntriesFunc, listTagsFn ListTagsFunc, renameTagFn RenameTagFunc, listWorkspacesFn ListWorkspacesFunc, switchWorkspaceFn SwitchWorkspaceFunc, changes <-chan service.ChangeEvent, width, height int) *Model {
 	// Initialize title input
 	titleInput := textinput.New()
 	titleInput.Placeholder = "Entry Title"
@@ -83,46 +130,22 @@ func NewModel(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEn
 	filterTagInput.Placeholder = "tag name..."
 	filterTagInput.CharLimit = 50
 
-	// Build initial ordering from entries sorted by StartedAtTimestamp (most recent first)
-	orderedIDs := make([]string, 0, len(entries))
-	for id := range entries {
-		orderedIDs = append(orderedIDs, id)
-	}
-
-	// Sort by StartedAtTimestamp, most recent first
-	sort.Slice(orderedIDs, func(i, j int) bool {
-		entryI := entries[orderedIDs[i]]
-		entryJ := entries[orderedIDs[j]]
-
-		// Handle zero timestamps - put entries without timestamps at the end
-		if entryI.StartedAtTimestamp.IsZero() && !entryJ.StartedAtTimestamp.IsZero() {
-			return false
-		}
-		if !entryI.StartedAtTimestamp.IsZero() && entryJ.StartedAtTimestamp.IsZero() {
-			return true
-		}
-
-		// Both have timestamps (or both are zero) - most recent first (descending order)
-		return entryI.StartedAtTimestamp.After(entryJ.StartedAtTimestamp)
-	})
-
-	// Collect all unique tags from all entries
-	tagSet := make(map[string]bool)
-	for _, entry := range entries {
-		for _, tag := range entry.Tags {
-			tagSet[tag] = true
-		}
-	}
-	availableTags := make([]string, 0, len(tagSet))
-	for tag := range tagSet {
-		availableTags = append(availableTags, tag)
-	}
+	// Initialize tag rename input
+	renameTagInput := textinput.New()
+	renameTagInput.Placeholder = "new tag name"
+	renameTagInput.CharLimit = 50
 
-	return &Model{
+	m := &Model{
 		entries:            entries,
-		orderedIDs:         orderedIDs,
 		saveEntryFn:        saveEntryFn,
 		deleteEntryFn:      deleteEntryFn,
+		queryEntriesFn:     queryEntriesFn,
+		searchEntriesFn:    searchEntriesFn,
+		listTagsFn:         listTagsFn,
+		renameTagFn:        renameTagFn,
+		listWorkspacesFn:   listWorkspacesFn,
+		switchWorkspaceFn:  switchWorkspaceFn,
+		changes:            changes,
 		selectedIndex:      0,
 		view:               "list",
 		titleInput:         titleInput,
@@ -130,7 +153,6 @@ func NewModel(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEn
 		estimatedInput:     estimatedInput,
 		bodyTextarea:       bodyTextarea,
 		focusIndex:         0,
-		availableTags:      availableTags,
 		tagSuggestions:     []string{},
 		selectedSuggest:    0,
 		showTagSuggestions: false,
@@ -145,18 +167,47 @@ func NewModel(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEn
 		filterTagInput:     filterTagInput,
 		filterInputMode:    "",
 		pendingKeySequence: "",
+		renameTagInput:     renameTagInput,
 	}
+
+	// Collect all unique tags for suggestions
+	m.availableTags = m.collectAllTags()
+
+	// Build initial ordering, most recent first
+	m.refreshOrderedIDs()
+
+	return m
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
+	if m.changes != nil {
+		return waitForChange(m.changes)
+	}
 	return nil
 }
 
+// waitForChange waits for the next change published by storage
+func waitForChange(changes <-chan service.ChangeEvent) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-changes
+		if !ok {
+			return nil
+		}
+		return entryChangedMsg(event)
+	}
+}
+
 // Update handles messages
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
+	// Changes arrive in every view and mode; keep listening for the next one
+	if msg, ok := msg.(entryChangedMsg); ok {
+		m.applyChange(service.ChangeEvent(msg))
+		return m, waitForChange(m.changes)
+	}
+
 	// Handle filter input mode
 	if m.filterInputMode != "" {
 		switch msg := msg.(type) {
@@ -183,6 +234,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.showTagSuggestions = false
 					m.tagSuggestions = []string{}
 					m.selectedIndex = 0
+					m.refreshOrderedIDs()
 					return m, nil
 				}
 			}
@@ -201,6 +253,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.showTagSuggestions = false
 				m.tagSuggestions = []string{}
 				m.selectedIndex = 0 // Reset selection
+				m.refreshOrderedIDs()
 				return m, nil
 			case "esc":
 				// Cancel filter input
@@ -229,6 +282,25 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	// When renaming a tag, the prompt takes all keys
+	if m.view == "tags" && m.renamingTag {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				m.renameSelectedTag(strings.TrimSpace(m.renameTagInput.Value()))
+				m.renamingTag = false
+				m.renameTagInput.Blur()
+				return m, nil
+			case "esc":
+				m.renamingTag = false
+				m.renameTagInput.Blur()
+				return m, nil
+			}
+		}
+		m.renameTagInput, cmd = m.renameTagInput.Update(msg)
+		return m, cmd
+	}
+
 	// When in edit mode, handle input updates
 	if m.view == "edit" {
 		switch msg := msg.(type) {
@@ -272,7 +344,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "esc":
 				// Save all fields
-				selectedID := m.orderedIDs[m.selectedIndex]
+				selectedID := m.editingID
 				entry := m.entries[selectedID]
 
 				// Save title
@@ -296,20 +368,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Parse estimated duration
 				estimatedStr := m.estimatedInput.Value()
 				if estimatedStr != "" {
-					entry.EstimatedDuration = parseDuration(estimatedStr)
+					entry.EstimatedDuration = core.ParseDuration(estimatedStr)
 				}
 
 				// Save body
 				entry.Body = m.bodyTextarea.Value()
 
+				original := m.entries[selectedID]
 				m.entries[selectedID] = entry
 				if err := m.saveEntryFn(entry); err != nil {
+					if errors.Is(err, service.ErrConflict) {
+						// Hold the edit until the user picks reload or overwrite
+						m.entries[selectedID] = original
+						m.conflictEntry = entry
+						m.view = "conflict"
+						m.showTagSuggestions = false
+						return m, nil
+					}
 					logger.Error("entry_save_failed", "error", err.Error())
 				}
 
 				// Rebuild available tags after save
 				m.availableTags = m.collectAllTags()
 
+				// The edit may change where the entry sorts, or whether it matches the filters
+				m.refreshOrderedIDs()
+				m.selectID(selectedID)
+
 				m.view = "list"
 				m.showTagSuggestions = false
 				return m, nil
@@ -390,6 +475,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
 
+	if m.view == "conflict" {
+		return m.resolveConflict(key)
+	}
+	if m.view == "tags" {
+		return m.handleTagsKey(key)
+	}
+	if m.view == "workspaces" {
+		return m.handleWorkspacesKey(key)
+	}
+
 	// Handle pending key sequences (like "cf", "ct", "cc")
 	if m.pendingKeySequence != "" {
 		if m.pendingKeySequence == "c" && m.view == "list" {
@@ -398,17 +493,20 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.filterText = ""
 				m.selectedIndex = 0
 				m.pendingKeySequence = ""
+				m.refreshOrderedIDs()
 				return m, nil
 			case "t": // ct - clear tag filter
 				m.filterTag = ""
 				m.selectedIndex = 0
 				m.pendingKeySequence = ""
+				m.refreshOrderedIDs()
 				return m, nil
 			case "c": // cc - clear all filters
 				m.filterText = ""
 				m.filterTag = ""
 				m.selectedIndex = 0
 				m.pendingKeySequence = ""
+				m.refreshOrderedIDs()
 				return m, nil
 			}
 		}
@@ -430,11 +528,13 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.sortBy = "started_at"
 			}
 			m.selectedIndex = 0 // Reset selection when sorting changes
+			m.refreshOrderedIDs()
 		}
 	case "r": // Reverse sort direction
 		if m.view == "list" {
 			m.sortDescending = !m.sortDescending
 			m.selectedIndex = 0
+			m.refreshOrderedIDs()
 		}
 	case "c": // Start clear filter sequence (c + f/t) or clear all
 		if m.view == "list" {
@@ -456,6 +556,19 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.filterTagInput.Focus()
 			m.updateFilterTagSuggestions() // Show tag suggestions
 		}
+	case "T": // Open the tag manager
+		if m.view == "list" {
+			m.tagIndex = 0
+			m.tagStatus = ""
+			m.loadTags()
+			m.view = "tags"
+		}
+	case "W": // Open the workspace switcher
+		if m.view == "list" && m.listWorkspacesFn != nil {
+			m.workspaceStatus = ""
+			m.loadWorkspaces()
+			m.view = "workspaces"
+		}
 	case "up", "k":
 		if m.view == "list" {
 			displayIDs := m.getFilteredAndSortedIDs()
@@ -477,8 +590,13 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if len(displayIDs) == 0 {
 				return m, nil
 			}
+			if m.readOnly() {
+				m.view = "detail"
+				return m, nil
+			}
 			selectedID := displayIDs[m.selectedIndex]
 			entry := m.entries[selectedID]
+			m.editingID = selectedID
 
 			// Load title
 			m.titleInput.SetValue(entry.Title)
@@ -488,7 +606,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 			// Load estimated duration
 			if entry.EstimatedDuration > 0 {
-				m.estimatedInput.SetValue(formatDuration(entry.EstimatedDuration))
+				m.estimatedInput.SetValue(core.FormatDuration(entry.EstimatedDuration))
 			} else {
 				m.estimatedInput.SetValue("")
 			}
@@ -509,7 +627,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.view = "edit"
 		}
 	case "d": // delete log
-		if m.view == "list" {
+		if m.view == "list" && !m.readOnly() {
 			displayIDs := m.getFilteredAndSortedIDs()
 			if len(displayIDs) == 0 {
 				return m, nil
@@ -520,18 +638,11 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Delete from entries map
 			delete(m.entries, selectedID)
 
-			// Remove from orderedIDs
-			for i, id := range m.orderedIDs {
-				if id == selectedID {
-					m.orderedIDs = append(m.orderedIDs[:i], m.orderedIDs[i+1:]...)
-					break
-				}
-			}
-
 			// Delete from storage
 			if err := m.deleteEntryFn(selectedID); err != nil {
 				logger.Error("entry_delete_failed", "error", err.Error())
 			}
+			m.refreshOrderedIDs()
 
 			// Adjust selectedIndex if needed
 			newDisplayIDs := m.getFilteredAndSortedIDs()
@@ -544,7 +655,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.view = "list"
 		}
 	case "n":
-		if m.view == "list" {
+		if m.view == "list" && !m.readOnly() {
 			// Create new entry
 			newID := fmt.Sprintf("%d", time.Now().UnixNano())
 			newEntry := core.Entry{
@@ -561,16 +672,16 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Add to entries map
 			m.entries[newID] = newEntry
 
-			// Add to ordered list at the beginning (most recent)
-			m.orderedIDs = append([]string{newID}, m.orderedIDs...)
-
 			// Save to storage
 			if err := m.saveEntryFn(newEntry); err != nil {
 				logger.Error("entry_create_failed", "error", err.Error())
 			}
+			m.refreshOrderedIDs()
 
 			// Select the new entry and switch to edit mode
 			m.selectedIndex = 0
+			m.selectID(newID)
+			m.editingID = newID
 			m.titleInput.SetValue("New Log Entry")
 			m.tagsInput.SetValue("")
 			m.estimatedInput.SetValue("")
@@ -596,6 +707,12 @@ func (m Model) View() string {
 		return m.renderDetailView()
 	case "edit":
 		return m.renderEditView()
+	case "conflict":
+		return m.renderConflictView()
+	case "tags":
+		return m.renderTagsView()
+	case "workspaces":
+		return m.renderWorkspacesView()
 	}
 	return ""
 }
@@ -616,92 +733,308 @@ func (m Model) applyBorder(content []string) string {
 
 // getFilteredAndSortedIDs returns entry IDs filtered and sorted according to current settings
 func (m Model) getFilteredAndSortedIDs() []string {
-	// Start with all IDs
-	ids := make([]string, 0, len(m.entries))
-	for id := range m.entries {
-		ids = append(ids, id)
-	}
-
-	// Apply filters
-	var filteredIDs []string
-	for _, id := range ids {
-		entry := m.entries[id]
-
-		// Filter by text (search in title and body)
-		if m.filterText != "" {
-			searchText := strings.ToLower(m.filterText)
-			title := strings.ToLower(entry.Title)
-			body := strings.ToLower(entry.Body)
-			if !strings.Contains(title, searchText) && !strings.Contains(body, searchText) {
-				continue // Skip this entry
+	return m.orderedIDs
+}
+
+// refreshOrderedIDs asks storage for the entries matching the current filters and sort.
+// The result is cached in orderedIDs so rendering doesn't re-sort every entry each frame.
+func (m *Model) refreshOrderedIDs() {
+	q := service.Query{
+		Text:      m.filterText,
+		SortBy:    m.sortBy,
+		Ascending: !m.sortDescending,
+	}
+	if m.filterTag != "" {
+		q.Tags = []string{m.filterTag}
+	}
+
+	// Text filters use full-text search, keeping the chosen sort for the hits
+	m.snippets = nil
+	if m.filterText != "" && m.searchEntriesFn != nil {
+		results, err := m.searchEntriesFn(m.filterText)
+		if err != nil {
+			logger.Error("entry_search_failed", "error", err.Error())
+		} else {
+			if len(results) == 0 {
+				m.orderedIDs = nil
+				return
+			}
+			q.Text = ""
+			q.IDs = make([]string, 0, len(results))
+			m.snippets = make(map[string]string, len(results))
+			for _, result := range results {
+				q.IDs = append(q.IDs, result.Entry.ID)
+				m.snippets[result.Entry.ID] = result.Snippet
 			}
 		}
+	}
 
-		// Filter by tag
-		if m.filterTag != "" {
-			hasTag := false
-			for _, tag := range entry.Tags {
-				if tag == m.filterTag {
-					hasTag = true
-					break
-				}
-			}
-			if !hasTag {
-				continue // Skip this entry
+	var entries []core.Entry
+	var err error
+	if m.queryEntriesFn != nil {
+		entries, err = m.queryEntriesFn(q)
+		if err != nil {
+			logger.Error("entry_query_failed", "error", err.Error())
+		}
+	}
+	if m.queryEntriesFn == nil || err != nil {
+		// Fall back to the entries already loaded
+		result, _ := service.QueryEntries(m.entries, q)
+		entries = result.Entries
+	}
+
+	m.orderedIDs = make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// Storage is the source of truth, e.g. for entries pulled by sync
+		m.entries[entry.ID] = entry
+		m.orderedIDs = append(m.orderedIDs, entry.ID)
+	}
+}
+
+// applyChange brings the list up to date with a change made in storage.
+// The entry open in the edit view is left alone; saving it reports any conflict.
+func (m *Model) applyChange(event service.ChangeEvent) {
+	loaded, isLoaded := m.entries[event.ID]
+	switch event.Op {
+	case service.ChangeInsert, service.ChangeUpdate:
+		if isLoaded && loaded.Version >= event.Version {
+			return // Our own save, or already refreshed
+		}
+	case service.ChangeDelete:
+		if !isLoaded {
+			return
+		}
+		if event.ID != m.editingID {
+			delete(m.entries, event.ID)
+		}
+	}
+	logger.Info("entry_changed_in_storage", "op", event.Op, "entry_id", event.ID)
+
+	var selectedID string
+	if m.selectedIndex < len(m.orderedIDs) {
+		selectedID = m.orderedIDs[m.selectedIndex]
+	}
+
+	m.availableTags = m.collectAllTags()
+	m.refreshOrderedIDs()
+	m.selectID(selectedID)
+
+	// The entry on show was deleted elsewhere
+	if m.view == "detail" && event.Op == service.ChangeDelete && event.ID == selectedID {
+		m.view = "list"
+	}
+}
+
+// resolveConflict handles the prompt shown when an edit was saved over a newer version
+func (m Model) resolveConflict(key string) (tea.Model, tea.Cmd) {
+	id := m.conflictEntry.ID
+
+	switch key {
+	case "r": // Reload: discard the edit and show what storage holds now
+		if m.queryEntriesFn != nil {
+			entries, err := m.queryEntriesFn(service.Query{IDs: []string{id}})
+			if err != nil {
+				logger.Error("entry_reload_failed", "entry_id", id, "error", err.Error())
+			} else if len(entries) == 0 {
+				// Deleted elsewhere
+				delete(m.entries, id)
+			} else {
+				m.entries[id] = entries[0]
 			}
 		}
+	case "o": // Overwrite: save the edit unconditionally
+		entry := m.conflictEntry
+		entry.Version = 0
+		if err := m.saveEntryFn(entry); err != nil {
+			logger.Error("entry_save_failed", "error", err.Error())
+		}
+	default:
+		return m, nil
+	}
+
+	m.conflictEntry = core.Entry{}
+	m.availableTags = m.collectAllTags()
+	m.refreshOrderedIDs()
+	m.selectID(id)
+	m.view = "list"
+	return m, nil
+}
 
-		filteredIDs = append(filteredIDs, id)
+// handleTagsKey processes keyboard input in the tag manager
+func (m Model) handleTagsKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		if m.tagIndex > 0 {
+			m.tagIndex--
+		}
+	case "down", "j":
+		if m.tagIndex < len(m.tags)-1 {
+			m.tagIndex++
+		}
+	case "r": // Rename the selected tag
+		if len(m.tags) > 0 && m.renameTagFn != nil && !m.readOnly() {
+			m.renameTagInput.SetValue(m.tags[m.tagIndex].Name)
+			m.renameTagInput.CursorEnd()
+			m.renameTagInput.Focus()
+			m.renamingTag = true
+		}
+	case "esc", "T":
+		m.view = "list"
 	}
+	return m, nil
+}
 
-	// Sort filtered IDs with stable secondary sort by ID
-	sort.Slice(filteredIDs, func(i, j int) bool {
-		entryI := m.entries[filteredIDs[i]]
-		entryJ := m.entries[filteredIDs[j]]
-		idI := filteredIDs[i]
-		idJ := filteredIDs[j]
+// renameSelectedTag renames the tag under the cursor along with the tags beneath it;
+// renaming onto an existing tag merges the two
+func (m *Model) renameSelectedTag(to string) {
+	from := m.tags[m.tagIndex].Name
+	if to == "" || to == from {
+		return
+	}
 
-		var timeI, timeJ time.Time
-		switch m.sortBy {
-		case "started_at":
-			timeI = entryI.StartedAtTimestamp
-			timeJ = entryJ.StartedAtTimestamp
-		case "ended_at":
-			timeI = entryI.EndedAtTimestamp
-			timeJ = entryJ.EndedAtTimestamp
-		case "last_modified":
-			timeI = entryI.LastModifiedTimestamp
-			timeJ = entryJ.LastModifiedTimestamp
-		default:
-			timeI = entryI.StartedAtTimestamp
-			timeJ = entryJ.StartedAtTimestamp
+	if err := m.renameTagFn(from, to); err != nil {
+		logger.Error("tag_rename_failed", "from", from, "to", to, "error", err.Error())
+		m.tagStatus = "Rename failed: " + err.Error()
+		if errors.Is(err, service.ErrConflict) {
+			m.tagStatus = "An entry changed while renaming; nothing was renamed. Try again."
 		}
+		return
+	}
+	m.tagStatus = fmt.Sprintf("Renamed %s to %s", from, to)
 
-		// Handle zero timestamps - put entries without timestamps at the end
-		if timeI.IsZero() && !timeJ.IsZero() {
-			return false
+	if m.filterTag == from {
+		m.filterTag = to
+	}
+	m.availableTags = m.collectAllTags()
+	m.refreshOrderedIDs()
+	m.loadTags()
+	for i, tag := range m.tags {
+		if tag.Name == to {
+			m.tagIndex = i
 		}
-		if !timeI.IsZero() && timeJ.IsZero() {
-			return true
+	}
+}
+
+// loadTags refreshes the tag manager's list, counting loaded entries if storage can't list tags
+func (m *Model) loadTags() {
+	var tags []service.Tag
+	var err error
+	if m.listTagsFn != nil {
+		tags, err = m.listTagsFn()
+		if err != nil {
+			logger.Error("tag_list_failed", "error", err.Error())
 		}
+	}
+	if m.listTagsFn == nil || err != nil {
+		tags = service.CountTags(m.entries)
+	}
 
-		// If both zero or both non-zero, compare timestamps
-		if !timeI.Equal(timeJ) {
-			if m.sortDescending {
-				return timeI.After(timeJ)
-			}
-			return timeI.Before(timeJ)
+	m.tags = service.TagTree(tags)
+	m.tagBias = make(map[string]service.TagBias)
+	for _, bias := range service.BiasByTag(m.entries) {
+		m.tagBias[bias.Tag] = bias
+	}
+	if m.tagIndex >= len(m.tags) {
+		m.tagIndex = max(len(m.tags)-1, 0)
+	}
+}
+
+// handleWorkspacesKey processes keyboard input in the workspace switcher
+func (m Model) handleWorkspacesKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		if m.workspaceIndex > 0 {
+			m.workspaceIndex--
+		}
+	case "down", "j":
+		if m.workspaceIndex < len(m.workspaces) {
+			m.workspaceIndex++
 		}
+	case "enter":
+		return m.switchWorkspace()
+	case "esc", "W":
+		m.view = "list"
+	}
+	return m, nil
+}
+
+// loadWorkspaces refreshes the switcher's list and puts the cursor on the open workspace
+func (m *Model) loadWorkspaces() {
+	workspaces, err := m.listWorkspacesFn()
+	if err != nil {
+		logger.Error("workspace_list_failed", "error", err.Error())
+		m.workspaceStatus = "Couldn't list workspaces: " + err.Error()
+	}
+	m.workspaces = workspaces
 
-		// Timestamps are equal - use ID as stable secondary sort
-		// IDs are timestamps, so numeric comparison
-		if m.sortDescending {
-			return idI > idJ
+	m.workspaceIndex = 0
+	for i, membership := range m.workspaces {
+		if membership.Workspace.ID == m.workspace.Workspace.ID {
+			m.workspaceIndex = i + 1
 		}
-		return idI < idJ
-	})
+	}
+}
+
+// switchWorkspace opens the workspace under the cursor, replacing the loaded entries with its own
+func (m Model) switchWorkspace() (tea.Model, tea.Cmd) {
+	var next service.Membership
+	if m.workspaceIndex > 0 {
+		next = m.workspaces[m.workspaceIndex-1]
+	}
+
+	entries, changes, err := m.switchWorkspaceFn(next.Workspace.ID)
+	if err != nil {
+		logger.Error("workspace_switch_failed", "workspace_id", next.Workspace.ID, "error", err.Error())
+		m.workspaceStatus = "Couldn't open workspace: " + err.Error()
+		return m, nil
+	}
+	logger.Info("workspace_switched", "workspace_id", next.Workspace.ID, "role", next.Role)
+
+	m.workspace = next
+	m.entries = entries
+	m.changes = changes
+	m.selectedIndex = 0
+	m.snippets = nil
+	m.filterText = ""
+	m.filterTag = ""
+	m.availableTags = m.collectAllTags()
+	m.refreshOrderedIDs()
+	m.view = "list"
+
+	if changes != nil {
+		return m, waitForChange(changes)
+	}
+	return m, nil
+}
+
+// readOnly reports whether the open workspace only lets the user read its entries
+func (m Model) readOnly() bool {
+	return m.workspace.Role != "" && !m.workspace.Role.Allows(service.RoleEditor)
+}
 
-	return filteredIDs
+// workspaceLabel names the open workspace, or the user's own entries
+func (m Model) workspaceLabel() string {
+	if m.workspace.Workspace.ID == "" {
+		return "Personal"
+	}
+	return fmt.Sprintf("%s (%s)", m.workspace.Workspace.Name, m.workspace.Role)
+}
+
+// selectID moves the selection to the given entry if it is displayed
+func (m *Model) selectID(id string) {
+	for i, displayed := range m.orderedIDs {
+		if displayed == id {
+			m.selectedIndex = i
+			return
+		}
+	}
+	if m.selectedIndex >= len(m.orderedIDs) && m.selectedIndex > 0 {
+		m.selectedIndex = len(m.orderedIDs) - 1
+	}
 }
 
 // renderFilterSortSection renders the filter and sort controls
@@ -768,8 +1101,11 @@ func (m Model) layoutListView(listItems []string, helpText string) string {
 	borderStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#32a852"))
 
-	// Build top border
+	// Build top border, naming the open workspace if there is one
 	label := "<< ZENZEN >>"
+	if m.workspace.Workspace.ID != "" {
+		label = "<< ZENZEN · " + m.workspaceLabel() + " >>"
+	}
 	slashesNeeded := m.width - len(label)
 	if slashesNeeded < 0 {
 		slashesNeeded = 0
@@ -914,18 +1250,63 @@ func (m Model) renderListView() string {
 				line = fmt.Sprintf("  %s", log.Title)
 			}
 			listItems = append(listItems, line)
+
+			// Show where the search matched
+			if snippet := m.snippets[id]; snippet != "" {
+				listItems = append(listItems, "    "+renderSnippet(snippet, m.width-6))
+			}
 		}
 	}
 
 	// Build help text
 	help := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("8")).
-		Render("↑/↓ (j/k) navigate | enter edit | d delete | n new | q quit")
+		Render("↑/↓ (j/k) navigate | enter edit | d delete | n new | T tags | W workspaces | q quit")
+	if m.readOnly() {
+		help = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("8")).
+			Render("↑/↓ (j/k) navigate | enter view | T tags | W workspaces | q quit (read only)")
+	}
 
 	// Layout everything
 	return m.layoutListView(listItems, help)
 }
 
+// renderSnippet styles the highlighted terms of a search snippet, unescaped from HTML and
+// cut to width characters
+func renderSnippet(snippet string, width int) string {
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("8"))
+
+	matchStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("11")).
+		Bold(true)
+
+	var b strings.Builder
+	remaining := width
+	write := func(text string, style lipgloss.Style) {
+		runes := []rune(text)
+		if len(runes) > remaining {
+			runes = runes[:max(remaining, 0)]
+		}
+		remaining -= len(runes)
+		if len(runes) > 0 {
+			b.WriteString(style.Render(string(runes)))
+		}
+	}
+
+	// Text before the first highlight, then alternating match / plain text
+	parts := strings.Split(snippet, service.HighlightStart)
+	write(html.UnescapeString(parts[0]), dimStyle)
+	for _, part := range parts[1:] {
+		match, rest, _ := strings.Cut(part, service.HighlightEnd)
+		write(html.UnescapeString(match), matchStyle)
+		write(html.UnescapeString(rest), dimStyle)
+	}
+
+	return b.String()
+}
+
 // renderDetailView renders the detail view of selected log
 func (m Model) renderDetailView() string {
 	if len(m.orderedIDs) == 0 || m.selectedIndex >= len(m.orderedIDs) {
@@ -969,16 +1350,154 @@ func (m Model) renderDetailView() string {
 	return m.applyBorder(content)
 }
 
+// renderConflictView asks whether to keep the stored version or the rejected edit
+func (m Model) renderConflictView() string {
+	warning := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FF1493")).
+		Bold(true).
+		Render("This entry was changed elsewhere while you were editing it.")
+
+	footer := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("8")).
+		Render("r reload and discard your edit | o overwrite with your edit")
+
+	content := []string{
+		warning,
+		"",
+		"Your edit: " + m.conflictEntry.Title,
+		"",
+		footer,
+	}
+
+	return m.applyBorder(content)
+}
+
+// renderTagsView renders the tag manager: the tag tree with usage counts, rolled-up
+// estimation bias and metadata
+func (m Model) renderTagsView() string {
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("6")).
+		Bold(true)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("8"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("11")).
+		Bold(true).
+		Background(lipgloss.Color("4"))
+
+	content := []string{
+		headerStyle.Render("Tags"),
+		dimStyle.Render(fmt.Sprintf("  %-24s %4s  %s", "", "uses", "actual/estimate")),
+	}
+
+	if len(m.tags) == 0 {
+		content = append(content, dimStyle.Italic(true).Render("No tags yet. Add some while editing an entry."))
+	}
+
+	// Leave room for the header, status, prompt and footer
+	visible := m.tags
+	offset := 0
+	if rows := m.height - 12; rows > 0 && len(visible) > rows {
+		offset = min(max(m.tagIndex-rows+1, 0), len(visible)-rows)
+		visible = visible[offset : offset+rows]
+	}
+	for i, tag := range visible {
+		// Show the last segment, indented beneath its namespace
+		label := tag.Name[strings.LastIndex(tag.Name, service.TagSeparator)+1:]
+		label = strings.Repeat("  ", service.TagDepth(tag.Name)) + label
+		name := label
+		if tag.Color != "" {
+			name = lipgloss.NewStyle().Foreground(lipgloss.Color(tag.Color)).Render(label)
+		}
+
+		ratio := ""
+		if bias := m.tagBias[tag.Name]; bias.Entries > 0 {
+			ratio = fmt.Sprintf("×%.2f", bias.Ratio())
+		}
+		line := fmt.Sprintf("%-24s %4d  %s", label, tag.Count, ratio)
+		if offset+i == m.tagIndex {
+			line = selectedStyle.Render("▶ " + line)
+		} else {
+			line = "  " + strings.Replace(line, label, name, 1)
+		}
+		if tag.Description != "" {
+			line += "  " + dimStyle.Render(tag.Description)
+		}
+		content = append(content, line)
+	}
+
+	content = append(content, "")
+	if m.renamingTag {
+		promptStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("11")).
+			Bold(true)
+		content = append(content, promptStyle.Render("Rename to: ")+m.renameTagInput.View())
+		content = append(content, dimStyle.Render("enter: rename (an existing name merges) | esc: cancel"))
+	} else {
+		if m.tagStatus != "" {
+			content = append(content, m.tagStatus, "")
+		}
+		content = append(content, dimStyle.Render("↑/↓ (j/k) navigate | r rename/merge | esc back | q quit"))
+	}
+
+	return m.applyBorder(content)
+}
+
+// renderWorkspacesView renders the workspace switcher: the user's own entries, then each workspace
+func (m Model) renderWorkspacesView() string {
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("6")).
+		Bold(true)
+
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("8"))
+
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("11")).
+		Bold(true).
+		Background(lipgloss.Color("4"))
+
+	content := []string{headerStyle.Render("Workspaces"), ""}
+
+	rows := []string{fmt.Sprintf("%-32s %s", "Personal", "")}
+	for _, membership := range m.workspaces {
+		rows = append(rows, fmt.Sprintf("%-32s %s", membership.Workspace.Name, membership.Role))
+	}
+	for i, row := range rows {
+		current := i == 0 && m.workspace.Workspace.ID == "" ||
+			i > 0 && m.workspaces[i-1].Workspace.ID == m.workspace.Workspace.ID
+		if current {
+			row += dimStyle.Render("  (open)")
+		}
+		if i == m.workspaceIndex {
+			row = selectedStyle.Render("▶ " + row)
+		} else {
+			row = "  " + row
+		}
+		content = append(content, row)
+	}
+	if len(m.workspaces) == 0 {
+		content = append(content, "", dimStyle.Italic(true).Render("No shared workspaces yet. Create one with the API and ask its admin to add you."))
+	}
+
+	content = append(content, "")
+	if m.workspaceStatus != "" {
+		content = append(content, m.workspaceStatus, "")
+	}
+	content = append(content, dimStyle.Render("↑/↓ (j/k) navigate | enter open | esc back | q quit"))
+
+	return m.applyBorder(content)
+}
+
 // renderMetadataSection renders the left metadata section (timestamps, title, tags, estimated)
 func (m Model) renderMetadataSection() []string {
-	displayIDs := m.getFilteredAndSortedIDs()
-	if len(displayIDs) == 0 || m.selectedIndex >= len(displayIDs) {
+	log, ok := m.entries[m.editingID]
+	if !ok {
 		return []string{"Error: No entry selected"}
 	}
 
-	selectedID := displayIDs[m.selectedIndex]
-	log := m.entries[selectedID]
-
 	labelStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("8"))
 
@@ -1180,7 +1699,7 @@ func (m Model) renderEditView() string {
 }
 
 // StartTUI starts the interactive TUI
-func StartTUI(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEntryFn DeleteEntryFunc) error {
+func StartTUI(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEntryFn DeleteEntryFunc, queryEntriesFn QueryEntriesFunc, searchEntriesFn SearchEntriesFunc, listTagsFn ListTagsFunc, renameTagFn RenameTagFunc, listWorkspacesFn ListWorkspacesFunc, switchWorkspaceFn SwitchWorkspaceFunc, changes <-chan service.ChangeEvent) error {
 	// Get initial terminal size
 	width, height, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
@@ -1189,53 +1708,29 @@ func StartTUI(entries map[string]core.Entry, saveEntryFn SaveEntryFunc, deleteEn
 		height = 24
 	}
 
-	model := NewModel(entries, saveEntryFn, deleteEntryFn, width, height)
+	model := NewModel(entries, saveEntryFn, deleteEntryFn, queryEntriesFn, searchEntriesFn, listTagsFn, renameTagFn, listWorkspacesFn, switchWorkspaceFn, changes, width, height)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
 	_, err = p.Run()
 	return err
 }
 
-// formatDuration converts time.Duration to a human-readable string like "5d", "1h30m"
-func formatDuration(d time.Duration) string {
-	if d == 0 {
-		return ""
-	}
-
-	var result string
-
-	// Weeks
-	weeks := d / core.WEEK
-	if weeks > 0 {
-		result += fmt.Sprintf("%dw", weeks)
-		d -= weeks * core.WEEK
-	}
-
-	// Days
-	days := d / core.DAY
-	if days > 0 {
-		result += fmt.Sprintf("%dd", days)
-		d -= days * core.DAY
-	}
-
-	// Hours
-	hours := d / time.Hour
-	if hours > 0 {
-		result += fmt.Sprintf("%dh", hours)
-		d -= hours * time.Hour
-	}
-
-	// Minutes
-	minutes := d / time.Minute
-	if minutes > 0 {
-		result += fmt.Sprintf("%dm", minutes)
+// collectAllTags gathers all unique tags, from storage when it can list them
+func (m *Model) collectAllTags() []string {
+	if m.listTagsFn != nil {
+		tags, err := m.listTagsFn()
+		if err == nil {
+			names := make([]string, 0, len(tags))
+			for _, tag := range tags {
+				if tag.Count > 0 {
+					names = append(names, tag.Name)
+				}
+			}
+			return names
+		}
+		logger.Error("tag_list_failed", "error", err.Error())
 	}
 
-	return result
-}
-
-// collectAllTags gathers all unique tags from all entries
-func (m *Model) collectAllTags() []string {
 	tagSet := make(map[string]bool)
 	for _, entry := range m.entries {
 		for _, tag := range entry.Tags {
@@ -1256,21 +1751,8 @@ func (m *Model) collectAllTags() []string {
 func (m *Model) updateFilterTagSuggestions() {
 	input := strings.TrimSpace(m.filterTagInput.Value())
 
-	// Filter available tags based on current input
-	suggestions := []string{}
-
-	if input == "" {
-		// Show all available tags when input is empty
-		suggestions = append(suggestions, m.availableTags...)
-	} else {
-		// Filter tags based on what's being typed
-		inputLower := strings.ToLower(input)
-		for _, tag := range m.availableTags {
-			if strings.HasPrefix(strings.ToLower(tag), inputLower) {
-				suggestions = append(suggestions, tag)
-			}
-		}
-	}
+	// Complete one segment at a time; filtering on a namespace matches its whole subtree
+	suggestions := service.CompleteTag(m.availableTags, input)
 
 	m.tagSuggestions = suggestions
 	m.showTagSuggestions = len(suggestions) > 0
@@ -1286,26 +1768,12 @@ func (m *Model) updateTagSuggestions() {
 	startPos, endPos := m.findTagBoundaries(input, cursorPos)
 	currentTag := strings.TrimSpace(input[startPos:endPos])
 
-	// Filter available tags based on current input
+	// Complete the tag one segment at a time: "in" offers infra, "infra/" offers infra/k8s
 	suggestions := []string{}
-
-	if currentTag == "" {
-		// Show all available tags when cursor is in an empty spot
-		for _, tag := range m.availableTags {
-			if !m.tagAlreadyInInput(tag, input) {
-				suggestions = append(suggestions, tag)
-			}
-		}
-	} else {
-		// Filter tags based on what's being typed
-		currentTagLower := strings.ToLower(currentTag)
-		for _, tag := range m.availableTags {
-			if strings.HasPrefix(strings.ToLower(tag), currentTagLower) {
-				// Don't suggest tags that are already in the input
-				if !m.tagAlreadyInInput(tag, input) {
-					suggestions = append(suggestions, tag)
-				}
-			}
+	for _, tag := range service.CompleteTag(m.availableTags, currentTag) {
+		// Don't suggest tags that are already in the input
+		if !m.tagAlreadyInInput(tag, input) {
+			suggestions = append(suggestions, tag)
 		}
 	}
 